@@ -0,0 +1,140 @@
+// Command thorne-express-stub is a local stand-in for the Thorne Patient Express
+// drop-ship endpoint. Point thorne_express_url (or THORNE_EXPRESS_URL) at it to
+// exercise order submission and tracking without a real account.
+//
+// Orders are kept in memory. Each order reports a tracking number once it has
+// been "in transit" for SHIP_AFTER (default 30s).
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type stubOrder struct {
+	ID             string     `json:"id"`
+	Reference      string     `json:"reference"`
+	SKU            string     `json:"sku"`
+	Quantity       int        `json:"quantity"`
+	PatientName    string     `json:"patient_name"`
+	PatientEmail   string     `json:"patient_email"`
+	Status         string     `json:"status"`
+	Message        string     `json:"message,omitempty"`
+	Carrier        string     `json:"carrier,omitempty"`
+	TrackingNumber string     `json:"tracking_number,omitempty"`
+	ShippedDate    *time.Time `json:"shipped_date,omitempty"`
+	createdAt      time.Time
+}
+
+type stubServer struct {
+	mu        sync.Mutex
+	orders    map[string]*stubOrder
+	apiKey    string
+	shipAfter time.Duration
+}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8090"
+	}
+
+	shipAfter := 30 * time.Second
+	if value := os.Getenv("SHIP_AFTER"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			shipAfter = d
+		}
+	}
+
+	server := &stubServer{
+		orders:    make(map[string]*stubOrder),
+		apiKey:    os.Getenv("THORNE_EXPRESS_API_KEY"),
+		shipAfter: shipAfter,
+	}
+
+	http.HandleFunc("/orders", server.createOrder)
+	http.HandleFunc("/orders/", server.getOrder)
+
+	log.Printf("Thorne Express stub listening on :%s (ship after %s)", port, shipAfter)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
+
+func (s *stubServer) authorized(r *http.Request) bool {
+	if s.apiKey == "" {
+		return true
+	}
+	return r.Header.Get("Authorization") == "Bearer "+s.apiKey
+}
+
+func (s *stubServer) createOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var order stubOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if order.SKU == "" || order.Quantity <= 0 {
+		http.Error(w, "sku and quantity are required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	s.mu.Lock()
+	order.ID = fmt.Sprintf("TPE-%06d", len(s.orders)+1)
+	order.Status = "accepted"
+	order.Message = "Order accepted for drop-ship"
+	order.createdAt = time.Now()
+	s.orders[order.ID] = &order
+	s.mu.Unlock()
+
+	log.Printf("accepted %s for reference %s (%s x%d)", order.ID, order.Reference, order.SKU, order.Quantity)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(order)
+}
+
+func (s *stubServer) getOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/orders/")
+
+	s.mu.Lock()
+	order, exists := s.orders[id]
+	if exists && order.TrackingNumber == "" && time.Since(order.createdAt) >= s.shipAfter {
+		shipped := time.Now()
+		order.Status = "shipped"
+		order.Carrier = "UPS"
+		order.TrackingNumber = "1Z" + strings.ReplaceAll(order.ID, "-", "")
+		order.ShippedDate = &shipped
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"agoat.io/agoat-publisher/services"
+)
+
+// GetSalesSummary handles GET /api/thorne/analytics/summary
+func (h *ThorneHandlers) GetSalesSummary(w http.ResponseWriter, r *http.Request) {
+	filter, err := analyticsFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summary, err := h.thorneService.GetSalesSummary(filter)
+	if err != nil {
+		http.Error(w, "Failed to load sales summary", http.StatusInternalServerError)
+		return
+	}
+
+	if wantsCSV(r) {
+		writeCSV(w, "sales-summary.csv",
+			[]string{"orders", "units", "revenue", "cost", "margin", "margin_percent", "average_order_value", "customers", "repeat_customers", "repeat_purchase_rate"},
+			[][]string{{
+				strconv.Itoa(summary.Orders), strconv.Itoa(summary.Units), money(summary.Revenue),
+				money(summary.Cost), money(summary.Margin), money(summary.MarginPercent),
+				money(summary.AverageOrderValue), strconv.Itoa(summary.Customers),
+				strconv.Itoa(summary.RepeatCustomers), money(summary.RepeatPurchaseRate),
+			}})
+		return
+	}
+
+	writeAnalyticsJSON(w, summary, filter)
+}
+
+// GetRevenueSeries handles GET /api/thorne/analytics/revenue?interval=day|week|month
+func (h *ThorneHandlers) GetRevenueSeries(w http.ResponseWriter, r *http.Request) {
+	filter, err := analyticsFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	switch interval {
+	case "":
+		interval = services.IntervalDay
+	case services.IntervalDay, services.IntervalWeek, services.IntervalMonth:
+	default:
+		http.Error(w, "interval must be one of: day, week, month", http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.thorneService.GetRevenueSeries(filter, interval)
+	if err != nil {
+		http.Error(w, "Failed to load revenue", http.StatusInternalServerError)
+		return
+	}
+
+	if wantsCSV(r) {
+		var rows [][]string
+		for _, point := range series {
+			rows = append(rows, []string{
+				point.Period, strconv.Itoa(point.Orders), strconv.Itoa(point.Units),
+				money(point.Revenue), money(point.Cost), money(point.Margin),
+			})
+		}
+		writeCSV(w, "revenue-by-"+interval+".csv", []string{"period", "orders", "units", "revenue", "cost", "margin"}, rows)
+		return
+	}
+
+	writeAnalyticsJSON(w, series, filter)
+}
+
+// GetTopProducts handles GET /api/thorne/analytics/top-products?limit=10
+func (h *ThorneHandlers) GetTopProducts(w http.ResponseWriter, r *http.Request) {
+	filter, err := analyticsFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 10
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	products, err := h.thorneService.GetTopProducts(filter, limit)
+	if err != nil {
+		http.Error(w, "Failed to load top products", http.StatusInternalServerError)
+		return
+	}
+
+	if wantsCSV(r) {
+		var rows [][]string
+		for _, product := range products {
+			rows = append(rows, []string{
+				product.ProductID, product.ProductName, strconv.Itoa(product.Orders),
+				strconv.Itoa(product.Units), money(product.Revenue), money(product.Margin),
+			})
+		}
+		writeCSV(w, "top-products.csv", []string{"product_id", "product_name", "orders", "units", "revenue", "margin"}, rows)
+		return
+	}
+
+	writeAnalyticsJSON(w, products, filter)
+}
+
+// GetPatientLifetimeValues handles GET /api/thorne/analytics/patients
+func (h *ThorneHandlers) GetPatientLifetimeValues(w http.ResponseWriter, r *http.Request) {
+	filter, err := analyticsFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values, err := h.thorneService.GetPatientLifetimeValues(filter)
+	if err != nil {
+		http.Error(w, "Failed to load patient values", http.StatusInternalServerError)
+		return
+	}
+
+	if wantsCSV(r) {
+		var rows [][]string
+		for _, value := range values {
+			rows = append(rows, []string{
+				value.PatientID, value.PatientName, strconv.Itoa(value.Orders), money(value.Revenue),
+				money(value.Margin), money(value.AverageOrderValue), value.FirstOrder, value.LastOrder,
+			})
+		}
+		writeCSV(w, "patient-lifetime-value.csv",
+			[]string{"patient_id", "patient_name", "orders", "revenue", "margin", "average_order_value", "first_order", "last_order"}, rows)
+		return
+	}
+
+	writeAnalyticsJSON(w, values, filter)
+}
+
+// RebuildOrderRollups handles POST /api/thorne/analytics/rollups/rebuild
+func (h *ThorneHandlers) RebuildOrderRollups(w http.ResponseWriter, r *http.Request) {
+	rollups, err := h.thorneService.RebuildOrderRollups()
+	if err != nil {
+		http.Error(w, "Failed to rebuild rollups", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"built_at":          rollups.BuiltAt,
+			"product_days":      len(rollups.Products),
+			"patient_days":      len(rollups.Patients),
+			"source_updated_at": rollups.SourceUpdatedAt,
+		},
+	})
+}
+
+// analyticsFilter parses from, to (YYYY-MM-DD, inclusive) and site_id
+func analyticsFilter(r *http.Request) (services.AnalyticsFilter, error) {
+	var filter services.AnalyticsFilter
+	query := r.URL.Query()
+	filter.SiteID = query.Get("site_id")
+
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a date in YYYY-MM-DD format", name)
+			}
+			*target = parsed
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return filter, fmt.Errorf("to must not be before from")
+	}
+
+	return filter, nil
+}
+
+func wantsCSV(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "csv"
+}
+
+func writeCSV(w http.ResponseWriter, filename string, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	writer := csv.NewWriter(w)
+	writer.Write(header)
+	writer.WriteAll(rows)
+}
+
+func writeAnalyticsJSON(w http.ResponseWriter, data interface{}, filter services.AnalyticsFilter) {
+	filters := map[string]string{}
+	if !filter.From.IsZero() {
+		filters["from"] = filter.From.Format("2006-01-02")
+	}
+	if !filter.To.IsZero() {
+		filters["to"] = filter.To.Format("2006-01-02")
+	}
+	if filter.SiteID != "" {
+		filters["site_id"] = filter.SiteID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    data,
+		"filters": filters,
+	})
+}
+
+func money(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"agoat.io/agoat-publisher/services"
+)
+
+// maxCatalogUploadBytes caps the size of an import body
+const maxCatalogUploadBytes = 10 << 20
+
+// ImportCatalog handles POST /api/thorne/catalog/import?format=csv|json&type=products|categories&dry_run=true
+// The body is either the raw file or a multipart form with a "file" field.
+func (h *ThorneHandlers) ImportCatalog(w http.ResponseWriter, r *http.Request) {
+	format, recordType := catalogParams(r)
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxCatalogUploadBytes)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxCatalogUploadBytes); err != nil {
+			http.Error(w, "Invalid multipart upload", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "File is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+		if r.URL.Query().Get("format") == "" && strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
+			format = services.CatalogFormatCSV
+		}
+	}
+
+	data, err := services.ParseCatalog(body, format, recordType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.thorneService.ImportCatalog(data, dryRun)
+	if err != nil {
+		http.Error(w, "Failed to import catalog", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Valid {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": report.Valid,
+		"data":    report,
+	})
+}
+
+// ExportCatalog handles GET /api/thorne/catalog/export?format=csv|json&type=products|categories
+func (h *ThorneHandlers) ExportCatalog(w http.ResponseWriter, r *http.Request) {
+	format, recordType := catalogParams(r)
+	if format == services.CatalogFormatJSON && r.URL.Query().Get("type") == "" {
+		recordType = ""
+	}
+
+	data, err := h.thorneService.ExportCatalog(recordType)
+	if err != nil {
+		http.Error(w, "Failed to load catalog", http.StatusInternalServerError)
+		return
+	}
+
+	name := "thorne-catalog"
+	if recordType != "" {
+		name = "thorne-" + recordType
+	}
+	if format == services.CatalogFormatCSV {
+		w.Header().Set("Content-Type", "text/csv")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+
+	if err := services.WriteCatalog(w, data, format, recordType); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// catalogParams reads the format and record type, defaulting to JSON products
+func catalogParams(r *http.Request) (string, string) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		if strings.Contains(r.Header.Get("Content-Type"), "csv") {
+			format = services.CatalogFormatCSV
+		} else {
+			format = services.CatalogFormatJSON
+		}
+	}
+
+	recordType := strings.ToLower(r.URL.Query().Get("type"))
+	if recordType == "" {
+		recordType = services.CatalogTypeProducts
+	}
+
+	return format, recordType
+}
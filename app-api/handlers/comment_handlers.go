@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// CommentHandlers handles HTTP requests for reader comments and their
+// moderation
+type CommentHandlers struct {
+	commentService *services.CommentService
+}
+
+// NewCommentHandlers creates a new comment handlers instance
+func NewCommentHandlers(commentService *services.CommentService) *CommentHandlers {
+	return &CommentHandlers{
+		commentService: commentService,
+	}
+}
+
+// GetCommentSettings handles GET /api/sites/{siteSlug}/comments/settings
+func (h *CommentHandlers) GetCommentSettings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	settings, err := h.commentService.Settings(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to load comment settings")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, settings)
+}
+
+// ListComments handles GET /api/sites/{siteId}/posts/{id}/comments,
+// returning the post's approved comments as a thread
+func (h *CommentHandlers) ListComments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	thread, err := h.commentService.ListComments(vars["siteId"], vars["id"], r.Header.Get("X-User-ID"))
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list comments")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, thread)
+}
+
+// CreateComment handles POST /api/sites/{siteId}/posts/{id}/comments with
+// {"body", "parent_id", "author_name", "author_email", "author_url"}.
+// Without X-User-ID the comment is anonymous, if the site allows it.
+func (h *CommentHandlers) CreateComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.CommentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	comment, err := h.commentService.CreateComment(vars["siteId"], vars["id"], r.Header.Get("X-User-ID"),
+		r.RemoteAddr, r.UserAgent(), input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to create comment")
+		return
+	}
+
+	// Submitters learn whether the comment awaits moderation, not how it
+	// scored against the spam check
+	writeSiteContentJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":         comment.ID,
+		"post_id":    comment.PostID,
+		"parent_id":  comment.ParentID,
+		"status":     comment.Status,
+		"created_at": comment.CreatedAt,
+	})
+}
+
+// AdminListComments handles GET /api/admin/sites/{siteSlug}/comments
+// ?status=&post_id=&page=&per_page=, the moderation queue
+func (h *CommentHandlers) AdminListComments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage < 1 {
+		perPage = 50
+	}
+
+	queue, err := h.commentService.ModerationQueue(vars["siteSlug"], query.Get("status"), query.Get("post_id"),
+		perPage, (page-1)*perPage)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list comments")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, queue)
+}
+
+// AdminModerateComment handles PUT /api/admin/sites/{siteSlug}/comments/{id}
+// with {"status"}
+func (h *CommentHandlers) AdminModerateComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	comment, err := h.commentService.ModerateComment(vars["siteSlug"], vars["id"], input.Status,
+		r.Header.Get("X-User-ID"), r.RemoteAddr, r.UserAgent())
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to moderate comment")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, comment)
+}
+
+// AdminDeleteComment handles DELETE /api/admin/sites/{siteSlug}/comments/{id},
+// moving the comment to the deleted status
+func (h *CommentHandlers) AdminDeleteComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	_, err := h.commentService.ModerateComment(vars["siteSlug"], vars["id"], services.CommentDeleted,
+		r.Header.Get("X-User-ID"), r.RemoteAddr, r.UserAgent())
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to delete comment")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
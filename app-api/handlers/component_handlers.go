@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// AdminListComponents handles GET /api/admin/sites/{siteSlug}/components,
+// optionally filtered with ?type=hero
+func (h *ContentHandlers) AdminListComponents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	components, err := h.siteContentService.ListComponents(vars["siteSlug"], r.URL.Query().Get("type"))
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list components")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, components)
+}
+
+// AdminGetComponent handles GET /api/admin/sites/{siteSlug}/components/{id}
+func (h *ContentHandlers) AdminGetComponent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	component, err := h.siteContentService.GetComponent(vars["siteSlug"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve component")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, component)
+}
+
+// AdminCreateComponent handles POST /api/admin/sites/{siteSlug}/components
+func (h *ContentHandlers) AdminCreateComponent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.SiteComponentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	component, err := h.siteContentService.CreateComponent(vars["siteSlug"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to create component")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusCreated, component)
+}
+
+// AdminUpdateComponent handles PUT /api/admin/sites/{siteSlug}/components/{id}
+func (h *ContentHandlers) AdminUpdateComponent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.SiteComponentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	component, err := h.siteContentService.UpdateComponent(vars["siteSlug"], vars["id"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to update component")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, component)
+}
+
+// AdminDeleteComponent handles DELETE /api/admin/sites/{siteSlug}/components/{id}
+func (h *ContentHandlers) AdminDeleteComponent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.siteContentService.DeleteComponent(vars["siteSlug"], vars["id"]); err != nil {
+		writeSiteContentError(w, err, "Failed to delete component")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]string{"message": "Component deleted successfully"})
+}
+
+// AdminRenderBlocks handles POST /api/admin/sites/{siteSlug}/blocks/render
+// with {"blocks": [...]}, validating and rendering blocks without saving them
+func (h *ContentHandlers) AdminRenderBlocks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input struct {
+		Blocks []services.Block `json:"blocks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	blocks, html, err := h.siteContentService.PrepareBlocks(vars["siteSlug"], input.Blocks)
+	if err == nil {
+		blocks, html, err = h.siteContentService.RenderBlocks(vars["siteSlug"], blocks)
+	}
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to render blocks")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]interface{}{
+		"blocks": blocks,
+		"html":   html,
+	})
+}
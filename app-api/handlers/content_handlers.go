@@ -12,13 +12,15 @@ import (
 
 // ContentHandlers handles HTTP requests for content management
 type ContentHandlers struct {
-	contentService *services.ContentService
+	contentService     *services.ContentService
+	siteContentService *services.SiteContentService
 }
 
 // NewContentHandlers creates a new content handlers instance
-func NewContentHandlers(contentService *services.ContentService) *ContentHandlers {
+func NewContentHandlers(contentService *services.ContentService, siteContentService *services.SiteContentService) *ContentHandlers {
 	return &ContentHandlers{
-		contentService: contentService,
+		contentService:     contentService,
+		siteContentService: siteContentService,
 	}
 }
 
@@ -48,17 +50,22 @@ type APIResponse struct {
 	Errors  []interface{} `json:"errors,omitempty"`
 }
 
-// GetContent handles GET /api/content/{id}
+// GetContent handles GET /api/content/{id}?site_id=
 func (h *ContentHandlers) GetContent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	contentID := vars["id"]
+	siteID := r.URL.Query().Get("site_id")
 
 	if contentID == "" {
 		http.Error(w, "Content ID is required", http.StatusBadRequest)
 		return
 	}
+	if siteID == "" {
+		http.Error(w, "Site ID is required", http.StatusBadRequest)
+		return
+	}
 
-	content, err := h.contentService.GetContent(contentID)
+	content, err := h.contentService.GetContent(contentID, siteID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Content not found", http.StatusNotFound)
@@ -165,6 +172,9 @@ func (h *ContentHandlers) CreateContent(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Site ID is required", http.StatusBadRequest)
 		return
 	}
+	if req.UserID == "" {
+		req.UserID = r.Header.Get("X-User-ID")
+	}
 	if req.UserID == "" {
 		http.Error(w, "User ID is required", http.StatusBadRequest)
 		return
@@ -177,7 +187,7 @@ func (h *ContentHandlers) CreateContent(w http.ResponseWriter, r *http.Request)
 
 	// Generate slug if not provided
 	if req.Slug == "" {
-		req.Slug = slugify(req.Title)
+		req.Slug = services.Slugify(req.Title)
 	}
 
 	content, err := h.contentService.CreateContent(
@@ -189,6 +199,10 @@ func (h *ContentHandlers) CreateContent(w http.ResponseWriter, r *http.Request)
 		req.UserID,
 	)
 	if err != nil {
+		if strings.Contains(err.Error(), "editorial workflow") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to create content", http.StatusInternalServerError)
 		return
 	}
@@ -201,15 +215,20 @@ func (h *ContentHandlers) CreateContent(w http.ResponseWriter, r *http.Request)
 	})
 }
 
-// UpdateContent handles PUT /api/content/{id}
+// UpdateContent handles PUT /api/content/{id}?site_id=
 func (h *ContentHandlers) UpdateContent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	contentID := vars["id"]
+	siteID := r.URL.Query().Get("site_id")
 
 	if contentID == "" {
 		http.Error(w, "Content ID is required", http.StatusBadRequest)
 		return
 	}
+	if siteID == "" {
+		http.Error(w, "Site ID is required", http.StatusBadRequest)
+		return
+	}
 
 	var req UpdateContentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -225,21 +244,27 @@ func (h *ContentHandlers) UpdateContent(w http.ResponseWriter, r *http.Request)
 
 	// Generate slug if not provided
 	if req.Slug == "" {
-		req.Slug = slugify(req.Title)
+		req.Slug = services.Slugify(req.Title)
 	}
 
 	content, err := h.contentService.UpdateContent(
 		contentID,
+		siteID,
 		req.Title,
 		req.Content,
 		req.Slug,
 		req.Status,
+		r.Header.Get("X-User-ID"),
 	)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Content not found", http.StatusNotFound)
 			return
 		}
+		if strings.Contains(err.Error(), "editorial workflow") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to update content", http.StatusInternalServerError)
 		return
 	}
@@ -251,17 +276,22 @@ func (h *ContentHandlers) UpdateContent(w http.ResponseWriter, r *http.Request)
 	})
 }
 
-// DeleteContent handles DELETE /api/content/{id}
+// DeleteContent handles DELETE /api/content/{id}?site_id=
 func (h *ContentHandlers) DeleteContent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	contentID := vars["id"]
+	siteID := r.URL.Query().Get("site_id")
 
 	if contentID == "" {
 		http.Error(w, "Content ID is required", http.StatusBadRequest)
 		return
 	}
+	if siteID == "" {
+		http.Error(w, "Site ID is required", http.StatusBadRequest)
+		return
+	}
 
-	err := h.contentService.DeleteContent(contentID)
+	err := h.contentService.DeleteContent(contentID, siteID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Content not found", http.StatusNotFound)
@@ -278,120 +308,240 @@ func (h *ContentHandlers) DeleteContent(w http.ResponseWriter, r *http.Request)
 	})
 }
 
-// GetSitePage handles GET /api/sites/{siteSlug}/pages/{pageSlug}
+// ListTrash handles GET /api/sites/{siteId}/trash
+func (h *ContentHandlers) ListTrash(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	siteID := vars["siteId"]
+
+	limit := 10
+	offset := 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	contents, err := h.contentService.ListTrash(siteID, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to retrieve trash", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    contents,
+	})
+}
+
+// RestoreContent handles POST /api/sites/{siteId}/trash/{id}/restore
+func (h *ContentHandlers) RestoreContent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	content, err := h.contentService.RestoreContent(vars["id"], vars["siteId"])
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Content not found in trash", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "already used") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to restore content", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    content,
+	})
+}
+
+// PurgeContent handles DELETE /api/sites/{siteId}/trash/{id}
+func (h *ContentHandlers) PurgeContent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.contentService.PurgeContent(vars["id"], vars["siteId"]); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Content not found in trash", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to purge content", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    map[string]string{"message": "Content permanently deleted"},
+	})
+}
+
+// GetSitePage handles GET /api/sites/{siteSlug}/pages/{pagePath}, where
+// pagePath is the page's full path such as about/team
 func (h *ContentHandlers) GetSitePage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	page, err := h.siteContentService.GetPageByPath(vars["siteSlug"], vars["pagePath"], true)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve page")
+		return
+	}
+	h.siteContentService.RenderPageBlocks(page)
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(APIResponse{
-		Success: false,
-		Error: map[string]string{
-			"code":    "NOT_IMPLEMENTED",
-			"message": "GetSitePage endpoint is not yet implemented",
-		},
+		Success: true,
+		Data:    page,
 	})
 }
 
-// GetSiteComponents handles GET /api/sites/{siteSlug}/components/{type}
+// GetSiteComponents handles GET /api/sites/{siteSlug}/components/{type},
+// returning the site's components of that block type with rendered HTML
 func (h *ContentHandlers) GetSiteComponents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	components, err := h.siteContentService.ListComponents(vars["siteSlug"], vars["type"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list components")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(APIResponse{
-		Success: false,
-		Error: map[string]string{
-			"code":    "NOT_IMPLEMENTED",
-			"message": "GetSiteComponents endpoint is not yet implemented",
-		},
+		Success: true,
+		Data:    components,
 	})
 }
 
 // GetSiteDataItems handles GET /api/sites/{siteSlug}/data/{collection}
+// with filter[field][op]=value, sort=-field, page and per_page parameters
 func (h *ContentHandlers) GetSiteDataItems(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	query, err := parseItemQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.siteContentService.ListItems(vars["siteSlug"], vars["collection"], query)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list items")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(APIResponse{
-		Success: false,
-		Error: map[string]string{
-			"code":    "NOT_IMPLEMENTED",
-			"message": "GetSiteDataItems endpoint is not yet implemented",
-		},
+		Success: true,
+		Data:    page,
 	})
 }
 
 // GetSiteDataItem handles GET /api/sites/{siteSlug}/data/{collection}/{id}
 func (h *ContentHandlers) GetSiteDataItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	item, err := h.siteContentService.GetItem(vars["siteSlug"], vars["collection"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve item")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(APIResponse{
-		Success: false,
-		Error: map[string]string{
-			"code":    "NOT_IMPLEMENTED",
-			"message": "GetSiteDataItem endpoint is not yet implemented",
-		},
+		Success: true,
+		Data:    item,
 	})
 }
 
-// SearchSiteDataItems handles GET /api/sites/{siteSlug}/data/{collection}/search
+// SearchSiteDataItems handles GET /api/sites/{siteSlug}/data/{collection}/search?q=
+// and accepts the same filters, sort and paging as GetSiteDataItems
 func (h *ContentHandlers) SearchSiteDataItems(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	query, err := parseItemQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(query.Search) == "" {
+		http.Error(w, "Search query 'q' is required", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.siteContentService.ListItems(vars["siteSlug"], vars["collection"], query)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to search items")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(APIResponse{
-		Success: false,
-		Error: map[string]string{
-			"code":    "NOT_IMPLEMENTED",
-			"message": "SearchSiteDataItems endpoint is not yet implemented",
-		},
+		Success: true,
+		Data:    page,
 	})
 }
 
-// CreateSiteDataItem handles POST /api/sites/{siteSlug}/data/{collection}
+// CreateSiteDataItem handles POST /api/sites/{siteSlug}/data/{collection};
+// the body is the item's field values
 func (h *ContentHandlers) CreateSiteDataItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var data map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.siteContentService.CreateItem(vars["siteSlug"], vars["collection"], data)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to create item")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
+	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(APIResponse{
-		Success: false,
-		Error: map[string]string{
-			"code":    "NOT_IMPLEMENTED",
-			"message": "CreateSiteDataItem endpoint is not yet implemented",
-		},
+		Success: true,
+		Data:    item,
 	})
 }
 
 // GetSiteSettings handles GET /api/sites/{siteSlug}/settings
 func (h *ContentHandlers) GetSiteSettings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	settings, err := h.siteContentService.SettingsMap(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve settings")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(APIResponse{
-		Success: false,
-		Error: map[string]string{
-			"code":    "NOT_IMPLEMENTED",
-			"message": "GetSiteSettings endpoint is not yet implemented",
-		},
+		Success: true,
+		Data:    settings,
 	})
 }
 
-// GetSiteNavigation handles GET /api/sites/{siteSlug}/navigation/{type}
+// GetSiteNavigation handles GET /api/sites/{siteSlug}/navigation/{type}, where
+// type is the menu location (e.g. main, footer)
 func (h *ContentHandlers) GetSiteNavigation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	menu, err := h.siteContentService.GetMenuByLocation(vars["siteSlug"], vars["type"], true)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve navigation")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
 	json.NewEncoder(w).Encode(APIResponse{
-		Success: false,
-		Error: map[string]string{
-			"code":    "NOT_IMPLEMENTED",
-			"message": "GetSiteNavigation endpoint is not yet implemented",
-		},
+		Success: true,
+		Data:    menu,
 	})
 }
-
-// slugify converts a string to a URL-friendly slug
-func slugify(s string) string {
-	s = strings.ToLower(s)
-	s = strings.ReplaceAll(s, " ", "-")
-	s = strings.ReplaceAll(s, "_", "-")
-	var result strings.Builder
-	for _, r := range s {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
-			result.WriteRune(r)
-		}
-	}
-	return result.String()
-}
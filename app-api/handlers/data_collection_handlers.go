@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// AdminListCollections handles GET /api/admin/sites/{siteSlug}/collections
+func (h *ContentHandlers) AdminListCollections(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	collections, err := h.siteContentService.ListCollections(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list collections")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, collections)
+}
+
+// AdminGetCollection handles GET /api/admin/sites/{siteSlug}/collections/{collection}
+func (h *ContentHandlers) AdminGetCollection(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	collection, err := h.siteContentService.GetCollection(vars["siteSlug"], vars["collection"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve collection")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, collection)
+}
+
+// AdminCreateCollection handles POST /api/admin/sites/{siteSlug}/collections
+func (h *ContentHandlers) AdminCreateCollection(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.DataCollectionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.siteContentService.CreateCollection(vars["siteSlug"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to create collection")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusCreated, collection)
+}
+
+// AdminUpdateCollection handles PUT /api/admin/sites/{siteSlug}/collections/{collection};
+// the fields array replaces the whole schema
+func (h *ContentHandlers) AdminUpdateCollection(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.DataCollectionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.siteContentService.UpdateCollection(vars["siteSlug"], vars["collection"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to update collection")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, collection)
+}
+
+// AdminDeleteCollection handles DELETE /api/admin/sites/{siteSlug}/collections/{collection}
+func (h *ContentHandlers) AdminDeleteCollection(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.siteContentService.DeleteCollection(vars["siteSlug"], vars["collection"]); err != nil {
+		writeSiteContentError(w, err, "Failed to delete collection")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]string{"message": "Collection deleted successfully"})
+}
+
+// UpdateSiteDataItem handles PUT /api/sites/{siteSlug}/data/{collection}/{id};
+// the body replaces the item's field values
+func (h *ContentHandlers) UpdateSiteDataItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var data map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.siteContentService.UpdateItem(vars["siteSlug"], vars["collection"], vars["id"], data)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to update item")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, item)
+}
+
+// DeleteSiteDataItem handles DELETE /api/sites/{siteSlug}/data/{collection}/{id}
+func (h *ContentHandlers) DeleteSiteDataItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.siteContentService.DeleteItem(vars["siteSlug"], vars["collection"], vars["id"]); err != nil {
+		writeSiteContentError(w, err, "Failed to delete item")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]string{"message": "Item deleted successfully"})
+}
+
+// parseItemQuery reads filter[field]=value and filter[field][op]=value
+// parameters along with q, sort, page and per_page
+func parseItemQuery(r *http.Request) (services.ItemQuery, error) {
+	params := r.URL.Query()
+	query := services.ItemQuery{
+		Search: params.Get("q"),
+		Sort:   params.Get("sort"),
+	}
+
+	if page := params.Get("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return query, fmt.Errorf("page must be a positive integer")
+		}
+		query.Page = n
+	}
+	if perPage := params.Get("per_page"); perPage != "" {
+		n, err := strconv.Atoi(perPage)
+		if err != nil || n < 1 {
+			return query, fmt.Errorf("per_page must be a positive integer")
+		}
+		query.PerPage = n
+	}
+
+	for key, values := range params {
+		if !strings.HasPrefix(key, "filter[") || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]"), "][")
+		if len(parts) > 2 || parts[0] == "" {
+			return query, fmt.Errorf("malformed filter %q", key)
+		}
+		filter := services.ItemFilter{Field: parts[0], Op: services.FilterEq}
+		if len(parts) == 2 {
+			filter.Op = parts[1]
+		}
+		for _, value := range values {
+			filter.Value = value
+			query.Filters = append(query.Filters, filter)
+		}
+	}
+
+	return query, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// ExportHandlers handles HTTP requests for static site exports
+type ExportHandlers struct {
+	exportService *services.StaticExportService
+}
+
+// NewExportHandlers creates a new export handlers instance
+func NewExportHandlers(exportService *services.StaticExportService) *ExportHandlers {
+	return &ExportHandlers{
+		exportService: exportService,
+	}
+}
+
+// AdminStartExport handles POST /api/admin/sites/{siteSlug}/exports with
+// optional {"base_url", "full"}, starting an export in the background. The
+// returned job is polled until it finishes; its archive is then available
+// for download.
+func (h *ExportHandlers) AdminStartExport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input struct {
+		BaseURL string `json:"base_url"`
+		Full    bool   `json:"full"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.exportService.StartExport(vars["siteSlug"], r.Header.Get("X-User-ID"), input.BaseURL, input.Full,
+		r.Host, r.RemoteAddr, r.UserAgent())
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to start export")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusAccepted, job)
+}
+
+// AdminListExports handles GET /api/admin/sites/{siteSlug}/exports
+func (h *ExportHandlers) AdminListExports(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.exportService.ListJobs(mux.Vars(r)["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list exports")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, jobs)
+}
+
+// AdminGetExport handles GET /api/admin/sites/{siteSlug}/exports/{id}
+func (h *ExportHandlers) AdminGetExport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	job, err := h.exportService.GetJob(vars["siteSlug"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to get export")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, job)
+}
+
+// AdminDownloadExport handles GET /api/admin/sites/{siteSlug}/exports/{id}/archive,
+// serving a finished export as a .tar.gz
+func (h *ExportHandlers) AdminDownloadExport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	archive, err := h.exportService.JobArchive(vars["siteSlug"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to download export")
+		return
+	}
+
+	filename := fmt.Sprintf("%s-export-%s.tar.gz", vars["siteSlug"], time.Now().Format("20060102"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	http.ServeFile(w, r, archive)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// GetOrder returns a single order by ID
+func (h *ThorneHandlers) GetOrder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderID := vars["id"]
+
+	order, err := h.thorneService.GetOrderByID(orderID)
+	if err != nil {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    order,
+	})
+}
+
+// GetPickList returns open direct-ship orders grouped by product
+func (h *ThorneHandlers) GetPickList(w http.ResponseWriter, r *http.Request) {
+	pickList, err := h.thorneService.GetPickList()
+	if err != nil {
+		http.Error(w, "Failed to build pick list", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    pickList,
+	})
+}
+
+// GetPackingSlip returns the packing slip for a direct-ship order
+func (h *ThorneHandlers) GetPackingSlip(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderID := vars["id"]
+
+	slip, err := h.thorneService.GetPackingSlip(orderID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    slip,
+	})
+}
+
+// UpdateOrderTracking handles manual tracking entry for an order
+func (h *ThorneHandlers) UpdateOrderTracking(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderID := vars["id"]
+
+	var update services.TrackingUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if update.TrackingNumber == "" {
+		http.Error(w, "Tracking number is required", http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.thorneService.UpdateOrderTracking(orderID, update)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update tracking", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    order,
+		"message": "Tracking updated",
+	})
+}
+
+// SyncOrderTracking polls the order's fulfillment provider for tracking
+func (h *ThorneHandlers) SyncOrderTracking(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderID := vars["id"]
+
+	order, err := h.thorneService.SyncOrderTracking(orderID)
+	if err != nil {
+		if strings.Contains(err.Error(), "order not found") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    order,
+	})
+}
+
+// FulfillmentWebhook accepts tracking callbacks from the drop-ship provider
+func (h *ThorneHandlers) FulfillmentWebhook(w http.ResponseWriter, r *http.Request) {
+	expected := h.thorneService.FulfillmentAPIKey()
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(provided)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var update services.TrackingUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.thorneService.ApplyProviderTracking(update)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    order,
+	})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// ImportHandlers handles HTTP requests for importing content from other
+// platforms
+type ImportHandlers struct {
+	importService *services.ImportService
+}
+
+// NewImportHandlers creates a new import handlers instance
+func NewImportHandlers(importService *services.ImportService) *ImportHandlers {
+	return &ImportHandlers{
+		importService: importService,
+	}
+}
+
+// AdminImport handles POST /api/admin/sites/{siteSlug}/import?format=wxr|markdown
+// with a WordPress WXR file, or a zip or tar.gz of a WordPress export or a
+// Markdown folder, as the request body. dry_run=true reports what would be
+// imported without changing anything; force=true overwrites posts edited
+// since an earlier import; download_media=true fetches WordPress
+// attachments the upload doesn't include from the old site.
+func (h *ImportHandlers) AdminImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = services.ImportFormatWXR
+	}
+
+	body := http.MaxBytesReader(w, r.Body, h.importService.MaxUploadBytes())
+	report, err := h.importService.ImportUpload(mux.Vars(r)["siteSlug"], format, body, services.ImportOptions{
+		UserID:        r.Header.Get("X-User-ID"),
+		DryRun:        query.Get("dry_run") == "true",
+		Force:         query.Get("force") == "true",
+		DownloadMedia: query.Get("download_media") == "true",
+		IPAddress:     r.RemoteAddr,
+		UserAgent:     r.UserAgent(),
+	})
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "import exceeds the maximum upload size", http.StatusRequestEntityTooLarge)
+			return
+		}
+		writeSiteContentError(w, err, "Failed to import content")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, report)
+}
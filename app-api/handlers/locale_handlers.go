@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// GetSiteLocales handles GET /api/sites/{siteSlug}/locales, returning the
+// site's default and supported locales
+func (h *ContentHandlers) GetSiteLocales(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	locales, err := h.siteContentService.SiteLocales(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to load locales")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, locales)
+}
+
+// ListPostTranslations handles GET /api/sites/{siteId}/posts/{id}/translations,
+// listing every language variant in the post's translation group
+func (h *ContentHandlers) ListPostTranslations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	translations, err := h.siteContentService.ListTranslations(vars["siteId"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list translations")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, translations)
+}
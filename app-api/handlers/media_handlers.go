@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// multipartOverhead allows for form fields and part headers on top of the
+// file itself
+const multipartOverhead = 1 << 20
+
+// MediaHandlers handles HTTP requests for the media library
+type MediaHandlers struct {
+	mediaService *services.MediaService
+}
+
+// NewMediaHandlers creates a new media handlers instance
+func NewMediaHandlers(mediaService *services.MediaService) *MediaHandlers {
+	return &MediaHandlers{
+		mediaService: mediaService,
+	}
+}
+
+// ListMedia handles GET /api/sites/{siteId}/media?type=image/&q=&page=&per_page=
+func (h *MediaHandlers) ListMedia(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	mediaQuery := services.MediaQuery{
+		Type:   query.Get("type"),
+		Search: query.Get("q"),
+	}
+	mediaQuery.Page, _ = strconv.Atoi(query.Get("page"))
+	mediaQuery.PerPage, _ = strconv.Atoi(query.Get("per_page"))
+
+	page, err := h.mediaService.ListAssets(vars["siteId"], mediaQuery)
+	if err != nil {
+		writeMediaError(w, err, "Failed to list media")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, page)
+}
+
+// UploadMedia handles POST /api/sites/{siteId}/media as multipart/form-data
+// with a "file" part and optional alt_text and title fields
+func (h *MediaHandlers) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.mediaService.MaxUploadBytes()+multipartOverhead)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "file exceeds the maximum upload size", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid multipart body", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	input := services.MediaAssetInput{
+		Filename: header.Filename,
+		AltText:  r.FormValue("alt_text"),
+		Title:    r.FormValue("title"),
+	}
+	asset, err := h.mediaService.Upload(r.Context(), vars["siteId"], r.Header.Get("X-User-ID"), input, file, header.Size)
+	if err != nil {
+		writeMediaError(w, err, "Failed to upload media")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusCreated, asset)
+}
+
+// GetMedia handles GET /api/sites/{siteId}/media/{id}
+func (h *MediaHandlers) GetMedia(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	asset, err := h.mediaService.GetAsset(vars["siteId"], vars["id"])
+	if err != nil {
+		writeMediaError(w, err, "Failed to retrieve media")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, asset)
+}
+
+// UpdateMedia handles PUT /api/sites/{siteId}/media/{id}
+func (h *MediaHandlers) UpdateMedia(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var update services.MediaAssetUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	asset, err := h.mediaService.UpdateAsset(vars["siteId"], vars["id"], update)
+	if err != nil {
+		writeMediaError(w, err, "Failed to update media")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, asset)
+}
+
+// DeleteMedia handles DELETE /api/sites/{siteId}/media/{id}
+func (h *MediaHandlers) DeleteMedia(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.mediaService.DeleteAsset(r.Context(), vars["siteId"], vars["id"]); err != nil {
+		writeMediaError(w, err, "Failed to delete media")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMediaUsage handles GET /api/sites/{siteId}/media/usage
+func (h *MediaHandlers) GetMediaUsage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	usage, err := h.mediaService.Usage(vars["siteId"])
+	if err != nil {
+		writeMediaError(w, err, "Failed to calculate storage usage")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, usage)
+}
+
+// CreateUpload handles POST /api/sites/{siteId}/media/uploads, opening a
+// resumable upload from {filename, size, alt_text, title}
+func (h *MediaHandlers) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.MediaUploadInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	upload, err := h.mediaService.CreateUpload(vars["siteId"], r.Header.Get("X-User-ID"), input)
+	if err != nil {
+		writeMediaError(w, err, "Failed to create upload")
+		return
+	}
+
+	writeUploadHeaders(w, upload)
+	writeSiteContentJSON(w, http.StatusCreated, upload)
+}
+
+// GetUpload handles GET and HEAD /api/sites/{siteId}/media/uploads/{id};
+// Upload-Offset tells the client where to resume
+func (h *MediaHandlers) GetUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	upload, err := h.mediaService.GetUpload(vars["siteId"], vars["id"])
+	if err != nil {
+		writeMediaError(w, err, "Failed to retrieve upload")
+		return
+	}
+
+	writeUploadHeaders(w, upload)
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	writeSiteContentJSON(w, http.StatusOK, upload)
+}
+
+// AppendUpload handles PATCH /api/sites/{siteId}/media/uploads/{id}. The
+// body is the next chunk and the Upload-Offset header must equal the bytes
+// already received.
+func (h *MediaHandlers) AppendUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Upload-Offset header is required", http.StatusBadRequest)
+		return
+	}
+	if r.ContentLength <= 0 {
+		http.Error(w, "Content-Length is required", http.StatusLengthRequired)
+		return
+	}
+
+	upload, err := h.mediaService.AppendUpload(r.Context(), vars["siteId"], vars["id"], offset, r.Body, r.ContentLength)
+	if err != nil {
+		if current, getErr := h.mediaService.GetUpload(vars["siteId"], vars["id"]); getErr == nil {
+			writeUploadHeaders(w, current)
+		}
+		writeMediaError(w, err, "Failed to store upload chunk")
+		return
+	}
+
+	writeUploadHeaders(w, upload)
+	writeSiteContentJSON(w, http.StatusOK, upload)
+}
+
+// CompleteUpload handles POST /api/sites/{siteId}/media/uploads/{id}/complete
+func (h *MediaHandlers) CompleteUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	asset, err := h.mediaService.CompleteUpload(r.Context(), vars["siteId"], vars["id"], r.Header.Get("X-User-ID"))
+	if err != nil {
+		writeMediaError(w, err, "Failed to complete upload")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusCreated, asset)
+}
+
+// AbortUpload handles DELETE /api/sites/{siteId}/media/uploads/{id}
+func (h *MediaHandlers) AbortUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.mediaService.AbortUpload(vars["siteId"], vars["id"]); err != nil {
+		writeMediaError(w, err, "Failed to abort upload")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ServeMedia handles GET /media/{key} for stores without their own public
+// endpoint. Object keys are immutable, so responses are cached for a year.
+func (h *MediaHandlers) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	if !strings.HasPrefix(key, "sites/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	blob, err := h.mediaService.Store().Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, services.ErrBlobNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Failed to read media", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", services.MediaTypeForKey(key))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	if seeker, ok := blob.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, path.Base(key), time.Time{}, seeker)
+		return
+	}
+	io.Copy(w, blob)
+}
+
+func writeUploadHeaders(w http.ResponseWriter, upload *services.MediaUpload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.ReceivedBytes, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.TotalBytes, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// writeMediaError maps media service errors to status codes: size and quota
+// violations are 413, rejected content types are 415, out-of-order or
+// unfinished uploads are 409 and the rest follow writeSiteContentError
+func writeMediaError(w http.ResponseWriter, err error, message string) {
+	text := err.Error()
+	switch {
+	case strings.HasPrefix(text, "file exceeds") || strings.HasPrefix(text, "chunk exceeds") ||
+		strings.HasPrefix(text, "storage quota exceeded"):
+		http.Error(w, text, http.StatusRequestEntityTooLarge)
+	case strings.HasPrefix(text, "unsupported media type") || strings.HasPrefix(text, "invalid image"):
+		http.Error(w, text, http.StatusUnsupportedMediaType)
+	case strings.HasPrefix(text, "upload offset mismatch") || strings.HasPrefix(text, "upload is not complete"):
+		http.Error(w, text, http.StatusConflict)
+	default:
+		writeSiteContentError(w, err, message)
+	}
+}
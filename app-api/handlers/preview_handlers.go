@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// PreviewHandlers handles HTTP requests for managing preview tokens
+type PreviewHandlers struct {
+	previewService *services.PreviewService
+}
+
+// NewPreviewHandlers creates a new preview handlers instance
+func NewPreviewHandlers(previewService *services.PreviewService) *PreviewHandlers {
+	return &PreviewHandlers{
+		previewService: previewService,
+	}
+}
+
+// ListTokens handles GET /api/sites/{siteId}/preview-tokens?post_id=
+func (h *PreviewHandlers) ListTokens(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tokens, err := h.previewService.ListTokens(vars["siteId"], r.URL.Query().Get("post_id"))
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list preview tokens")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, tokens)
+}
+
+// CreateToken handles POST /api/sites/{siteId}/preview-tokens with
+// {"post_id", "label", "expires_in"}. The token string is only returned here.
+func (h *PreviewHandlers) CreateToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.PreviewTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.previewService.CreateToken(vars["siteId"], r.Header.Get("X-User-ID"), input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to create preview token")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusCreated, token)
+}
+
+// RevokeToken handles DELETE /api/sites/{siteId}/preview-tokens/{id}
+func (h *PreviewHandlers) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.previewService.RevokeToken(vars["siteId"], vars["id"], r.Header.Get("X-User-ID")); err != nil {
+		writeSiteContentError(w, err, "Failed to revoke preview token")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// GetPatientIntake returns a patient's health-goal intake form
+func (h *ThorneHandlers) GetPatientIntake(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	patientID := vars["id"]
+
+	intake, err := h.thorneService.GetPatientIntake(patientID)
+	if err != nil {
+		if errors.Is(err, services.ErrIntakeNotFound) {
+			http.Error(w, "Intake not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load intake", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    intake,
+	})
+}
+
+// SavePatientIntake creates or replaces a patient's intake form
+func (h *ThorneHandlers) SavePatientIntake(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	patientID := vars["id"]
+
+	var intake services.PatientIntake
+	if err := json.NewDecoder(r.Body).Decode(&intake); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	intake.PatientID = patientID
+
+	saved, err := h.thorneService.SavePatientIntake(intake)
+	if err != nil {
+		if strings.Contains(err.Error(), "patient not found") {
+			http.Error(w, "Patient not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    saved,
+		"message": "Intake saved",
+	})
+}
+
+// GetPatientRecommendations lists recommendations created for a patient
+func (h *ThorneHandlers) GetPatientRecommendations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	patientID := vars["id"]
+
+	recommendations, err := h.thorneService.GetRecommendationsByPatient(patientID)
+	if err != nil {
+		http.Error(w, "Failed to load recommendations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    recommendations,
+	})
+}
+
+// CreatePatientRecommendation generates a draft recommendation for practitioner review
+func (h *ThorneHandlers) CreatePatientRecommendation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	patientID := vars["id"]
+
+	recommendation, err := h.thorneService.CreateRecommendation(patientID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Patient not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to create recommendation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    recommendation,
+	})
+}
+
+// ApproveRecommendation records the practitioner's chosen products
+func (h *ThorneHandlers) ApproveRecommendation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	recommendationID := vars["id"]
+
+	var approval struct {
+		ProductIDs []string `json:"product_ids"`
+		Note       string   `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&approval); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	recommendation, err := h.thorneService.ApproveRecommendation(recommendationID, approval.ProductIDs, approval.Note)
+	if err != nil {
+		if strings.Contains(err.Error(), "recommendation not found") {
+			http.Error(w, "Recommendation not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    recommendation,
+	})
+}
+
+// SendRecommendation marks an approved recommendation as sent to the patient
+func (h *ThorneHandlers) SendRecommendation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	recommendationID := vars["id"]
+
+	recommendation, err := h.thorneService.SendRecommendation(recommendationID)
+	if err != nil {
+		if strings.Contains(err.Error(), "recommendation not found") {
+			http.Error(w, "Recommendation not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    recommendation,
+		"message": "Recommendation sent to patient",
+	})
+}
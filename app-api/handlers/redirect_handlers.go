@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// ResolveRedirect handles GET /api/sites/{siteSlug}/redirects/resolve?path=/old,
+// returning the final target of any redirect rules or post slug changes
+// that apply to the path
+func (h *ContentHandlers) ResolveRedirect(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+
+	match, err := h.siteContentService.ResolveRedirect(vars["siteSlug"], path)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to resolve redirect")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, match)
+}
+
+// GetPostSlugHistory handles GET /api/sites/{siteId}/posts/{id}/slugs
+func (h *ContentHandlers) GetPostSlugHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	entries, err := h.siteContentService.ListSlugHistory(vars["siteId"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list slug history")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, entries)
+}
+
+// AdminListRedirects handles GET /api/admin/sites/{siteSlug}/redirects
+func (h *ContentHandlers) AdminListRedirects(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	redirects, err := h.siteContentService.ListRedirects(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list redirects")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, redirects)
+}
+
+// AdminGetRedirect handles GET /api/admin/sites/{siteSlug}/redirects/{id}
+func (h *ContentHandlers) AdminGetRedirect(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	redirect, err := h.siteContentService.GetRedirect(vars["siteSlug"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve redirect")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, redirect)
+}
+
+// AdminCreateRedirect handles POST /api/admin/sites/{siteSlug}/redirects
+func (h *ContentHandlers) AdminCreateRedirect(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.RedirectInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := h.siteContentService.CreateRedirect(vars["siteSlug"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to create redirect")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusCreated, redirect)
+}
+
+// AdminUpdateRedirect handles PUT /api/admin/sites/{siteSlug}/redirects/{id}
+func (h *ContentHandlers) AdminUpdateRedirect(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.RedirectInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := h.siteContentService.UpdateRedirect(vars["siteSlug"], vars["id"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to update redirect")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, redirect)
+}
+
+// AdminDeleteRedirect handles DELETE /api/admin/sites/{siteSlug}/redirects/{id}
+func (h *ContentHandlers) AdminDeleteRedirect(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.siteContentService.DeleteRedirect(vars["siteSlug"], vars["id"]); err != nil {
+		writeSiteContentError(w, err, "Failed to delete redirect")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// RevisionHandlers handles HTTP requests for post revision history
+type RevisionHandlers struct {
+	revisionService *services.RevisionService
+}
+
+// NewRevisionHandlers creates a new revision handlers instance
+func NewRevisionHandlers(revisionService *services.RevisionService) *RevisionHandlers {
+	return &RevisionHandlers{
+		revisionService: revisionService,
+	}
+}
+
+// ListRevisions handles GET /api/sites/{siteId}/posts/{id}/revisions
+func (h *RevisionHandlers) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	revisions, err := h.revisionService.ListRevisions(vars["siteId"], vars["id"])
+	if err != nil {
+		writeRevisionError(w, err, "Failed to list revisions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    revisions,
+	})
+}
+
+// GetRevision handles GET /api/sites/{siteId}/posts/{id}/revisions/{revisionId}
+// where revisionId is either the revision UUID or its revision number
+func (h *RevisionHandlers) GetRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	revision, err := h.revisionService.GetRevision(vars["siteId"], vars["id"], vars["revisionId"])
+	if err != nil {
+		writeRevisionError(w, err, "Failed to retrieve revision")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    revision,
+	})
+}
+
+// DiffRevisions handles GET /api/sites/{siteId}/posts/{id}/revisions/diff?from=&to=&mode=line|word
+func (h *RevisionHandlers) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" || to == "" {
+		http.Error(w, "from and to revisions are required", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := h.revisionService.DiffRevisions(vars["siteId"], vars["id"], from, to, query.Get("mode"))
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "mode must be") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeRevisionError(w, err, "Failed to diff revisions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    diff,
+	})
+}
+
+// RestoreRevision handles POST /api/sites/{siteId}/posts/{id}/revisions/{revisionId}/restore
+func (h *RevisionHandlers) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		http.Error(w, "X-User-ID header is required", http.StatusUnauthorized)
+		return
+	}
+
+	revision, err := h.revisionService.RestoreRevision(vars["siteId"], vars["id"], vars["revisionId"], userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "already used") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeRevisionError(w, err, "Failed to restore revision")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    revision,
+	})
+}
+
+// GetAutosave handles GET /api/sites/{siteId}/posts/{id}/autosave
+func (h *RevisionHandlers) GetAutosave(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	autosave, err := h.revisionService.GetAutosave(vars["siteId"], vars["id"])
+	if err != nil {
+		writeRevisionError(w, err, "Failed to retrieve autosave")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    autosave,
+	})
+}
+
+// SaveAutosave handles PUT /api/sites/{siteId}/posts/{id}/autosave, keeping
+// the editor's unsaved title, content and slug without saving the post
+func (h *RevisionHandlers) SaveAutosave(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.AutosaveInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	autosave, err := h.revisionService.SaveAutosave(vars["siteId"], vars["id"], r.Header.Get("X-User-ID"), input)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "title") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeRevisionError(w, err, "Failed to save autosave")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    autosave,
+	})
+}
+
+// DiscardAutosave handles DELETE /api/sites/{siteId}/posts/{id}/autosave
+func (h *RevisionHandlers) DiscardAutosave(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.revisionService.DiscardAutosave(vars["siteId"], vars["id"]); err != nil {
+		writeRevisionError(w, err, "Failed to discard autosave")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    "Autosave discarded",
+	})
+}
+
+func writeRevisionError(w http.ResponseWriter, err error, message string) {
+	switch err.Error() {
+	case "post not found":
+		http.Error(w, "Post not found", http.StatusNotFound)
+	case "revision not found":
+		http.Error(w, "Revision not found", http.StatusNotFound)
+	case "autosave not found":
+		http.Error(w, "Autosave not found", http.StatusNotFound)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// SearchHandlers handles HTTP requests for post search
+type SearchHandlers struct {
+	searchService *services.SearchService
+}
+
+// NewSearchHandlers creates a new search handlers instance
+func NewSearchHandlers(searchService *services.SearchService) *SearchHandlers {
+	return &SearchHandlers{
+		searchService: searchService,
+	}
+}
+
+// SearchPosts handles GET /api/sites/{siteId}/posts/search?q=&status=&author=
+// &locale=&from=&to=&page=&per_page=. Callers without X-User-ID only see
+// published posts.
+func (h *SearchHandlers) SearchPosts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage < 1 {
+		perPage = 20
+	}
+
+	search := services.PostSearchQuery{
+		Query:         query.Get("q"),
+		Status:        query.Get("status"),
+		Author:        query.Get("author"),
+		PublishedOnly: r.Header.Get("X-User-ID") == "",
+		Limit:         perPage,
+		Offset:        (page - 1) * perPage,
+	}
+	if value := query.Get("locale"); value != "" {
+		locale, ok := services.NormalizeLocale(value)
+		if !ok {
+			http.Error(w, "locale must be a BCP 47 language tag", http.StatusBadRequest)
+			return
+		}
+		search.Locale = locale
+	}
+	bounds := []struct {
+		name  string
+		value **time.Time
+	}{{"from", &search.From}, {"to", &search.To}}
+	for _, bound := range bounds {
+		name := bound.name
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := parseSearchDate(value, name == "to")
+		if err != nil {
+			http.Error(w, name+" must be an RFC 3339 timestamp or a YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		*bound.value = &t
+	}
+
+	results, err := h.searchService.Search(vars["siteId"], search)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to search posts")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, results)
+}
+
+// AdminReindex handles POST /api/admin/sites/{siteSlug}/search/reindex,
+// rebuilding the site's search index from its posts
+func (h *SearchHandlers) AdminReindex(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	indexed, err := h.searchService.Reindex(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to rebuild search index")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]int{"indexed": indexed})
+}
+
+// parseSearchDate accepts a timestamp or a bare date; a bare upper bound
+// covers the whole day
+func parseSearchDate(value string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if upper {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
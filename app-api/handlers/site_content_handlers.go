@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// ListSitePages handles GET /api/sites/{siteSlug}/pages (published tree)
+func (h *ContentHandlers) ListSitePages(w http.ResponseWriter, r *http.Request) {
+	h.listPages(w, r, true)
+}
+
+// AdminListSitePages handles GET /api/admin/sites/{siteSlug}/pages (all pages)
+func (h *ContentHandlers) AdminListSitePages(w http.ResponseWriter, r *http.Request) {
+	h.listPages(w, r, false)
+}
+
+func (h *ContentHandlers) listPages(w http.ResponseWriter, r *http.Request, publishedOnly bool) {
+	vars := mux.Vars(r)
+
+	pages, err := h.siteContentService.ListPages(vars["siteSlug"], publishedOnly)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list pages")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, pages)
+}
+
+// AdminGetSitePage handles GET /api/admin/sites/{siteSlug}/pages/{id}
+func (h *ContentHandlers) AdminGetSitePage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	page, err := h.siteContentService.GetPage(vars["siteSlug"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve page")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, page)
+}
+
+// AdminCreateSitePage handles POST /api/admin/sites/{siteSlug}/pages
+func (h *ContentHandlers) AdminCreateSitePage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.SitePageInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.siteContentService.CreatePage(vars["siteSlug"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to create page")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusCreated, page)
+}
+
+// AdminUpdateSitePage handles PUT /api/admin/sites/{siteSlug}/pages/{id}
+func (h *ContentHandlers) AdminUpdateSitePage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.SitePageInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.siteContentService.UpdatePage(vars["siteSlug"], vars["id"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to update page")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, page)
+}
+
+// AdminDeleteSitePage handles DELETE /api/admin/sites/{siteSlug}/pages/{id}
+func (h *ContentHandlers) AdminDeleteSitePage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.siteContentService.DeletePage(vars["siteSlug"], vars["id"]); err != nil {
+		writeSiteContentError(w, err, "Failed to delete page")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]string{"message": "Page deleted successfully"})
+}
+
+// AdminListMenus handles GET /api/admin/sites/{siteSlug}/menus
+func (h *ContentHandlers) AdminListMenus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	menus, err := h.siteContentService.ListMenus(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list menus")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, menus)
+}
+
+// AdminGetMenu handles GET /api/admin/sites/{siteSlug}/menus/{id}
+func (h *ContentHandlers) AdminGetMenu(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	menu, err := h.siteContentService.GetMenu(vars["siteSlug"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve menu")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, menu)
+}
+
+// AdminCreateMenu handles POST /api/admin/sites/{siteSlug}/menus
+func (h *ContentHandlers) AdminCreateMenu(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.NavigationMenuInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	menu, err := h.siteContentService.CreateMenu(vars["siteSlug"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to create menu")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusCreated, menu)
+}
+
+// AdminUpdateMenu handles PUT /api/admin/sites/{siteSlug}/menus/{id}; an
+// items array replaces the menu's whole item tree
+func (h *ContentHandlers) AdminUpdateMenu(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.NavigationMenuInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	menu, err := h.siteContentService.UpdateMenu(vars["siteSlug"], vars["id"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to update menu")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, menu)
+}
+
+// AdminDeleteMenu handles DELETE /api/admin/sites/{siteSlug}/menus/{id}
+func (h *ContentHandlers) AdminDeleteMenu(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.siteContentService.DeleteMenu(vars["siteSlug"], vars["id"]); err != nil {
+		writeSiteContentError(w, err, "Failed to delete menu")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]string{"message": "Menu deleted successfully"})
+}
+
+// AdminListSettings handles GET /api/admin/sites/{siteSlug}/settings, returning
+// each setting with its type
+func (h *ContentHandlers) AdminListSettings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	settings, err := h.siteContentService.ListSettings(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list settings")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, settings)
+}
+
+// AdminGetSetting handles GET /api/admin/sites/{siteSlug}/settings/{key}
+func (h *ContentHandlers) AdminGetSetting(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	setting, err := h.siteContentService.GetSetting(vars["siteSlug"], vars["key"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve setting")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, setting)
+}
+
+// AdminSetSetting handles PUT /api/admin/sites/{siteSlug}/settings/{key}
+// with a body of {"value": ..., "type": "boolean"}
+func (h *ContentHandlers) AdminSetSetting(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.SiteSettingInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.siteContentService.SetSetting(vars["siteSlug"], vars["key"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to save setting")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, setting)
+}
+
+// AdminDeleteSetting handles DELETE /api/admin/sites/{siteSlug}/settings/{key}
+func (h *ContentHandlers) AdminDeleteSetting(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.siteContentService.DeleteSetting(vars["siteSlug"], vars["key"]); err != nil {
+		writeSiteContentError(w, err, "Failed to delete setting")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]string{"message": "Setting deleted successfully"})
+}
+
+func writeSiteContentJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// writeSiteContentError maps service errors to status codes: lookups that
+// miss are 404, refused permissions are 403, collisions, workflow
+// conflicts and jobs already running are 409, storage failures are 500 and
+// anything else is a validation error
+func writeSiteContentError(w http.ResponseWriter, err error, message string) {
+	text := err.Error()
+	switch {
+	case strings.HasSuffix(text, "not found"):
+		http.Error(w, text, http.StatusNotFound)
+	case strings.HasPrefix(text, "permission denied"):
+		http.Error(w, text, http.StatusForbidden)
+	case strings.Contains(text, "already exists") || strings.Contains(text, "collide") ||
+		strings.Contains(text, "child pages") || strings.Contains(text, "referenced by") ||
+		strings.HasPrefix(text, "redirect loop") || strings.Contains(text, "cannot move to") ||
+		strings.Contains(text, "editorial workflow") || strings.Contains(text, "already running"):
+		http.Error(w, text, http.StatusConflict)
+	case strings.HasPrefix(text, "rate limit"):
+		http.Error(w, text, http.StatusTooManyRequests)
+	case strings.HasPrefix(text, "failed to"):
+		http.Error(w, message, http.StatusInternalServerError)
+	default:
+		http.Error(w, text, http.StatusBadRequest)
+	}
+}
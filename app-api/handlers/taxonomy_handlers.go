@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// taxonomyFromPath maps the plural route segment to its taxonomy
+func taxonomyFromPath(r *http.Request) string {
+	switch mux.Vars(r)["taxonomy"] {
+	case "tags":
+		return services.TaxonomyTag
+	case "categories":
+		return services.TaxonomyCategory
+	}
+	return ""
+}
+
+// ListTerms handles GET /api/sites/{siteSlug}/{tags|categories}; categories
+// are nested unless ?flat=true
+func (h *ContentHandlers) ListTerms(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	terms, err := h.siteContentService.ListTerms(vars["siteSlug"], taxonomyFromPath(r), r.URL.Query().Get("flat") == "true")
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list terms")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, terms)
+}
+
+// GetTerm handles GET /api/sites/{siteSlug}/{tags|categories}/{term}
+func (h *ContentHandlers) GetTerm(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	term, err := h.siteContentService.GetTerm(vars["siteSlug"], taxonomyFromPath(r), vars["term"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve term")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, term)
+}
+
+// AdminCreateTerm handles POST /api/admin/sites/{siteSlug}/{tags|categories}
+func (h *ContentHandlers) AdminCreateTerm(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.TermInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	term, err := h.siteContentService.CreateTerm(vars["siteSlug"], taxonomyFromPath(r), input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to create term")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusCreated, term)
+}
+
+// AdminUpdateTerm handles PUT /api/admin/sites/{siteSlug}/{tags|categories}/{term}
+func (h *ContentHandlers) AdminUpdateTerm(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.TermInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	term, err := h.siteContentService.UpdateTerm(vars["siteSlug"], taxonomyFromPath(r), vars["term"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to update term")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, term)
+}
+
+// AdminDeleteTerm handles DELETE /api/admin/sites/{siteSlug}/{tags|categories}/{term}
+func (h *ContentHandlers) AdminDeleteTerm(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.siteContentService.DeleteTerm(vars["siteSlug"], taxonomyFromPath(r), vars["term"]); err != nil {
+		writeSiteContentError(w, err, "Failed to delete term")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminMergeTerm handles POST /api/admin/sites/{siteSlug}/{tags|categories}/{term}/merge
+// with {"into": "<id or slug>"}
+func (h *ContentHandlers) AdminMergeTerm(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var body struct {
+		Into string `json:"into"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Into == "" {
+		http.Error(w, "into is required", http.StatusBadRequest)
+		return
+	}
+
+	term, err := h.siteContentService.MergeTerms(vars["siteSlug"], taxonomyFromPath(r), vars["term"], body.Into)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to merge terms")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, term)
+}
+
+// GetPostTerms handles GET /api/sites/{siteId}/posts/{id}/terms
+func (h *ContentHandlers) GetPostTerms(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	terms, err := h.siteContentService.GetPostTerms(vars["siteId"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to retrieve post terms")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, terms)
+}
+
+// SetPostTerms handles PUT /api/sites/{siteId}/posts/{id}/terms with
+// {"tags": [...], "categories": [...]}
+func (h *ContentHandlers) SetPostTerms(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.PostTermsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	terms, err := h.siteContentService.SetPostTerms(vars["siteId"], vars["id"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to assign post terms")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, terms)
+}
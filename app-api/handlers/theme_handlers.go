@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// ThemeHandlers handles HTTP requests for the themes behind the rendered
+// public site
+type ThemeHandlers struct {
+	themeEngine        *services.ThemeEngine
+	pageCache          *services.PageCache
+	siteContentService *services.SiteContentService
+}
+
+// NewThemeHandlers creates a new theme handlers instance
+func NewThemeHandlers(themeEngine *services.ThemeEngine, pageCache *services.PageCache, siteContentService *services.SiteContentService) *ThemeHandlers {
+	return &ThemeHandlers{
+		themeEngine:        themeEngine,
+		pageCache:          pageCache,
+		siteContentService: siteContentService,
+	}
+}
+
+// ListThemes handles GET /api/themes
+func (h *ThemeHandlers) ListThemes(w http.ResponseWriter, r *http.Request) {
+	themes, err := h.themeEngine.Themes()
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list themes")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, themes)
+}
+
+// AdminReloadThemes handles POST /api/admin/themes/reload?theme=, re-reading
+// theme files from disk and emptying the page cache
+func (h *ThemeHandlers) AdminReloadThemes(w http.ResponseWriter, r *http.Request) {
+	h.themeEngine.Reload(r.URL.Query().Get("theme"))
+	purged := h.pageCache.PurgeSite("")
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]int{"purged_pages": purged})
+}
+
+// AdminSetSiteTheme handles PUT /api/admin/sites/{siteSlug}/theme with
+// {"theme"}. The switch applies to the next request.
+func (h *ThemeHandlers) AdminSetSiteTheme(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input struct {
+		Theme string `json:"theme"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	input.Theme = strings.ToLower(strings.TrimSpace(input.Theme))
+	if !h.themeEngine.HasTheme(input.Theme) {
+		writeSiteContentError(w, fmt.Errorf("theme %q is not installed", input.Theme), "Failed to set theme")
+		return
+	}
+
+	siteID, err := h.siteContentService.ResolveSiteID(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to set theme")
+		return
+	}
+	if err := h.siteContentService.SetSiteTemplate(siteID, input.Theme); err != nil {
+		writeSiteContentError(w, err, "Failed to set theme")
+		return
+	}
+	h.pageCache.PurgeSite(siteID)
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]string{"site_id": siteID, "theme": input.Theme})
+}
+
+// AdminPurgeSiteCache handles POST /api/admin/sites/{siteSlug}/cache/purge,
+// dropping the site's cached pages
+func (h *ThemeHandlers) AdminPurgeSiteCache(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	siteID, err := h.siteContentService.ResolveSiteID(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to purge cache")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, map[string]int{"purged_pages": h.pageCache.PurgeSite(siteID)})
+}
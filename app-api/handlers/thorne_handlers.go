@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"agoat.io/agoat-publisher/services"
 	"github.com/gorilla/mux"
@@ -209,6 +210,7 @@ func (h *ThorneHandlers) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		PatientID string `json:"patient_id"`
 		ProductID string `json:"product_id"`
 		Quantity  int    `json:"quantity"`
+		SiteID    string `json:"site_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&orderRequest); err != nil {
@@ -222,11 +224,23 @@ func (h *ThorneHandlers) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := h.thorneService.CreateOrder(orderRequest.PatientID, orderRequest.ProductID, orderRequest.Quantity)
-	if err != nil {
+	order, err := h.thorneService.CreateOrder(orderRequest.PatientID, orderRequest.ProductID, orderRequest.Quantity, orderRequest.SiteID)
+	if err != nil && order == nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err != nil {
+		// The order was saved but the fulfillment provider rejected it
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"data":    order,
+			"error":   err.Error(),
+			"message": "Order saved but could not be submitted for fulfillment",
+		})
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -251,15 +265,54 @@ func (h *ThorneHandlers) GetSettings(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// SearchProducts handles product search
+// SearchProducts handles ranked, faceted product search
 func (h *ThorneHandlers) SearchProducts(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		http.Error(w, "Search query is required", http.StatusBadRequest)
+	params := r.URL.Query()
+	search := services.ProductSearchQuery{
+		Query:    params.Get("q"),
+		Category: params.Get("category"),
+		Sort:     params.Get("sort"),
+		Page:     1,
+		PerPage:  20,
+	}
+
+	if search.Query == "" && search.Category == "" {
+		http.Error(w, "Search query or category is required", http.StatusBadRequest)
+		return
+	}
+
+	if value := params.Get("in_stock"); value != "" {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "in_stock must be true or false", http.StatusBadRequest)
+			return
+		}
+		search.InStock = &inStock
+	}
+	for name, target := range map[string]**float64{"min_price": &search.MinPrice, "max_price": &search.MaxPrice} {
+		if value := params.Get(name); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil || price < 0 {
+				http.Error(w, name+" must be a non-negative number", http.StatusBadRequest)
+				return
+			}
+			*target = &price
+		}
+	}
+	switch search.Sort {
+	case "", "relevance", "price_asc", "price_desc", "name":
+	default:
+		http.Error(w, "sort must be one of: relevance, price_asc, price_desc, name", http.StatusBadRequest)
 		return
 	}
+	if p, err := strconv.Atoi(params.Get("page")); err == nil && p > 0 {
+		search.Page = p
+	}
+	if pp, err := strconv.Atoi(params.Get("per_page")); err == nil && pp > 0 && pp <= 100 {
+		search.PerPage = pp
+	}
 
-	products, err := h.thorneService.SearchProducts(query)
+	result, err := h.thorneService.SearchCatalog(search)
 	if err != nil {
 		http.Error(w, "Failed to search products", http.StatusInternalServerError)
 		return
@@ -268,8 +321,15 @@ func (h *ThorneHandlers) SearchProducts(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"data":    products,
-		"query":   query,
+		"data":    result.Hits,
+		"query":   search.Query,
+		"facets":  result.Facets,
+		"meta": map[string]int{
+			"page":        result.Page,
+			"per_page":    result.PerPage,
+			"total":       result.Total,
+			"total_pages": result.TotalPages,
+		},
 	})
 }
 
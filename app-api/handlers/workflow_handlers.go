@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"agoat.io/agoat-publisher/services"
+	"github.com/gorilla/mux"
+)
+
+// WorkflowHandlers handles HTTP requests for the editorial workflow and the
+// notifications it raises
+type WorkflowHandlers struct {
+	workflowService     *services.WorkflowService
+	notificationService *services.NotificationService
+}
+
+// NewWorkflowHandlers creates a new workflow handlers instance
+func NewWorkflowHandlers(workflowService *services.WorkflowService, notificationService *services.NotificationService) *WorkflowHandlers {
+	return &WorkflowHandlers{
+		workflowService:     workflowService,
+		notificationService: notificationService,
+	}
+}
+
+// AdminGetWorkflow handles GET /api/admin/sites/{siteSlug}/workflow
+func (h *WorkflowHandlers) AdminGetWorkflow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	workflow, err := h.workflowService.GetWorkflow(vars["siteSlug"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to load workflow")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, workflow)
+}
+
+// AdminUpdateWorkflow handles PUT /api/admin/sites/{siteSlug}/workflow with
+// {"enabled", "transitions": [{"from", "to", "roles", "require_comment"}]}
+func (h *WorkflowHandlers) AdminUpdateWorkflow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.Workflow
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	workflow, err := h.workflowService.UpdateWorkflow(vars["siteSlug"], input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to update workflow")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, workflow)
+}
+
+// GetPostWorkflow handles GET /api/sites/{siteId}/posts/{id}/workflow. The
+// available transitions are those open to the X-User-ID user.
+func (h *WorkflowHandlers) GetPostWorkflow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	state, err := h.workflowService.State(vars["siteId"], vars["id"], r.Header.Get("X-User-ID"))
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to load post workflow")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, state)
+}
+
+// TransitionPost handles POST /api/sites/{siteId}/posts/{id}/transitions
+// with {"to", "comment"}
+func (h *WorkflowHandlers) TransitionPost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input services.TransitionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.workflowService.Transition(vars["siteId"], vars["id"], r.Header.Get("X-User-ID"), input)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to change post status")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, event)
+}
+
+// ListReviewers handles GET /api/sites/{siteId}/posts/{id}/reviewers
+func (h *WorkflowHandlers) ListReviewers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	reviewers, err := h.workflowService.ListReviewers(vars["siteId"], vars["id"])
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list reviewers")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, reviewers)
+}
+
+// AssignReviewer handles POST /api/sites/{siteId}/posts/{id}/reviewers with
+// {"user_id"}
+func (h *WorkflowHandlers) AssignReviewer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var input struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	reviewers, err := h.workflowService.AssignReviewer(vars["siteId"], vars["id"], input.UserID, r.Header.Get("X-User-ID"))
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to assign reviewer")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, reviewers)
+}
+
+// UnassignReviewer handles DELETE /api/sites/{siteId}/posts/{id}/reviewers/{userId}
+func (h *WorkflowHandlers) UnassignReviewer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	err := h.workflowService.UnassignReviewer(vars["siteId"], vars["id"], vars["userId"], r.Header.Get("X-User-ID"))
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to unassign reviewer")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListNotifications handles GET /api/notifications?unread=true&limit= for
+// the X-User-ID user
+func (h *WorkflowHandlers) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	unreadOnly := query.Get("unread") == "true"
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	notifications, err := h.notificationService.ListForUser(r.Header.Get("X-User-ID"), unreadOnly, limit)
+	if err != nil {
+		writeSiteContentError(w, err, "Failed to list notifications")
+		return
+	}
+
+	writeSiteContentJSON(w, http.StatusOK, notifications)
+}
+
+// MarkNotificationRead handles POST /api/notifications/{id}/read
+func (h *WorkflowHandlers) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.notificationService.MarkRead(r.Header.Get("X-User-ID"), vars["id"]); err != nil {
+		writeSiteContentError(w, err, "Failed to mark notification read")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
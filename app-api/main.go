@@ -1,14 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"database/sql"
 	"encoding/gob"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -19,7 +28,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // Log levels
@@ -124,18 +133,29 @@ type User struct {
 }
 
 type Post struct {
-	ID          string     `json:"id"`
-	UserID      string     `json:"user_id"`
-	SiteID      string     `json:"site_id"`
-	Title       string     `json:"title"`
-	Content     string     `json:"content"`
-	Slug        string     `json:"slug"`
-	Status      string     `json:"status"`
-	Published   bool       `json:"published"`
-	PublishedAt *time.Time `json:"published_at,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	Author      string     `json:"author"`
+	ID                 string                    `json:"id"`
+	UserID             string                    `json:"user_id"`
+	SiteID             string                    `json:"site_id"`
+	Title              string                    `json:"title"`
+	Content            string                    `json:"content"`
+	ContentFormat      string                    `json:"content_format"`
+	Blocks             services.BlockList        `json:"blocks,omitempty"`
+	Rendered           *services.RenderedContent `json:"rendered,omitempty"`
+	Tags               []*services.Term          `json:"tags,omitempty"`
+	Categories         []*services.Term          `json:"categories,omitempty"`
+	Slug               string                    `json:"slug"`
+	Locale             string                    `json:"locale"`
+	TranslationGroupID string                    `json:"translation_group_id,omitempty"`
+	Alternates         []services.PostAlternate  `json:"alternates,omitempty"`
+	Status             string                    `json:"status"`
+	Published          bool                      `json:"published"`
+	PublishedAt        *time.Time                `json:"published_at,omitempty"`
+	PublishAt          *time.Time                `json:"publish_at,omitempty"`
+	UnpublishAt        *time.Time                `json:"unpublish_at,omitempty"`
+	CreatedAt          time.Time                 `json:"created_at"`
+	UpdatedAt          time.Time                 `json:"updated_at"`
+	Author             string                    `json:"author"`
+	CommentCount       int                       `json:"comment_count"`
 }
 
 type Site struct {
@@ -227,14 +247,14 @@ type APIMeta struct {
 // Updated repository interfaces
 type PostRepository interface {
 	Create(post *Post) error
-	Update(post *Post) error
-	Delete(id string) error
+	Update(post *Post, editorID string) error
 	GetByID(id string) (*Post, error)
-	GetBySlug(slug string, siteID string) (*Post, error)
-	GetAll(limit, offset int, siteID string) ([]Post, error)
-	GetPublished(limit, offset int, siteID string) ([]Post, error)
-	Count(siteID string) (int, error)
-	CountPublished(siteID string) (int, error)
+	GetBySlug(slug string, siteID string, locales services.LocaleChain) (*Post, error)
+	GetAll(limit, offset int, siteID string, filter services.PostTermFilter) ([]Post, error)
+	GetPublished(limit, offset int, siteID string, filter services.PostTermFilter, locales services.LocaleChain) ([]Post, error)
+	Count(siteID string, filter services.PostTermFilter) (int, error)
+	CountPublished(siteID string, filter services.PostTermFilter, locales services.LocaleChain) (int, error)
+	GetScheduled(siteID string) ([]Post, error)
 }
 
 type SiteRepository interface {
@@ -255,72 +275,174 @@ type CustomerRepository interface {
 
 // Updated repositories
 type SQLPostRepository struct {
-	db *sql.DB
+	db        *sql.DB
+	pageCache *services.PageCache
 }
 
+// Create inserts the post and records it as revision 1
 func (r *SQLPostRepository) Create(post *Post) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO posts (user_id, site_id, title, content, slug, status, published, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id`
+		INSERT INTO posts (user_id, site_id, title, content, slug, status, published, published_at,
+		publish_at, unpublish_at, created_at, updated_at, blocks, content_format, locale, translation_group_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $7 THEN $10::TIMESTAMPTZ END, $8, $9, $10, $10, $11, $12, $13,
+		COALESCE(NULLIF($14, '')::UUID, gen_random_uuid()))
+		RETURNING id, published_at, translation_group_id`
 	now := time.Now()
-	err := r.db.QueryRow(query, post.UserID, post.SiteID, post.Title, post.Content,
-		post.Slug, post.Status, post.Published, now, now).Scan(&post.ID)
-	return err
+	err = tx.QueryRow(query, post.UserID, post.SiteID, post.Title, post.Content,
+		post.Slug, post.Status, post.Published, post.PublishAt, post.UnpublishAt, now, post.Blocks, post.ContentFormat,
+		post.Locale, post.TranslationGroupID).Scan(&post.ID, &post.PublishedAt, &post.TranslationGroupID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := services.RecordRevision(tx, revisionSnapshot(post, post.UserID)); err != nil {
+		return err
+	}
+	if err := services.IndexPost(tx, post.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	r.pageCache.PurgeSite(post.SiteID)
+	return nil
 }
 
-func (r *SQLPostRepository) Update(post *Post) error {
+// Update overwrites the post and appends an immutable revision in the same
+// transaction, so the post and its history never disagree. A changed slug
+// is kept in the slug history so links to the old one can be redirected,
+// and editing an approved post sends it back to draft. A post that doesn't
+// belong to post.SiteID is reported as sql.ErrNoRows.
+func (r *SQLPostRepository) Update(post *Post, editorID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var siteID, oldSlug string
+	err = tx.QueryRow(`SELECT site_id, slug FROM posts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`,
+		post.ID).Scan(&siteID, &oldSlug)
+	if err != nil {
+		return err
+	}
+	if siteID != post.SiteID {
+		return sql.ErrNoRows
+	}
+
+	post.Status, err = services.WithdrawApprovalOnEdit(tx, post.ID, editorID, post.Status, post.Title, post.Content)
+	if err != nil {
+		return err
+	}
+
+	if err := services.RecordBaselineRevision(tx, post.ID); err != nil {
+		return err
+	}
+
+	// published_at keeps the first publication time across later edits
 	query := `
 		UPDATE posts SET title = $1, content = $2, slug = $3, 
-		status = $4, published = $5, updated_at = $6 WHERE id = $7`
-	_, err := r.db.Exec(query, post.Title, post.Content, post.Slug,
-		post.Status, post.Published, time.Now(), post.ID)
-	return err
+		status = $4, published = $5, updated_at = $6,
+		published_at = CASE WHEN $5 THEN COALESCE(published_at, $6) ELSE published_at END,
+		publish_at = $7, unpublish_at = $8, blocks = $10, content_format = $11, locale = COALESCE(NULLIF($12, ''), locale)
+		WHERE id = $9 AND deleted_at IS NULL
+		RETURNING published_at, locale, translation_group_id`
+	if err := tx.QueryRow(query, post.Title, post.Content, post.Slug,
+		post.Status, post.Published, time.Now(), post.PublishAt, post.UnpublishAt, post.ID, post.Blocks, post.ContentFormat,
+		post.Locale).Scan(&post.PublishedAt, &post.Locale, &post.TranslationGroupID); err != nil {
+		return err
+	}
+
+	if err := services.RecordSlugChange(tx, siteID, post.ID, oldSlug, post.Slug); err != nil {
+		return err
+	}
+
+	if _, err := services.RecordRevision(tx, revisionSnapshot(post, editorID)); err != nil {
+		return err
+	}
+	if err := services.IndexPost(tx, post.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	r.pageCache.PurgeSite(siteID)
+	return nil
 }
 
-func (r *SQLPostRepository) Delete(id string) error {
-	_, err := r.db.Exec("DELETE FROM posts WHERE id = $1", id)
-	return err
+func revisionSnapshot(post *Post, authorID string) services.RevisionSnapshot {
+	return services.RevisionSnapshot{
+		PostID:   post.ID,
+		SiteID:   post.SiteID,
+		Title:    post.Title,
+		Content:  post.Content,
+		Slug:     post.Slug,
+		Status:   post.Status,
+		AuthorID: authorID,
+	}
 }
 
 func (r *SQLPostRepository) GetByID(id string) (*Post, error) {
 	post := &Post{}
 	query := `
-		SELECT p.id, p.user_id, p.site_id, p.title, p.content, p.slug, p.status, p.published, 
-		p.published_at, p.created_at, p.updated_at, COALESCE(u.username, 'Anonymous')
+		SELECT p.id, p.user_id, p.site_id, p.title, p.content, p.content_format, p.blocks, p.slug, p.status, p.published, 
+		p.published_at, p.publish_at, p.unpublish_at, p.created_at, p.updated_at, COALESCE(u.username, 'Anonymous'),
+		p.locale, p.translation_group_id, p.comment_count
 		FROM posts p
 		LEFT JOIN users u ON p.user_id = u.id
-		WHERE p.id = $1`
+		WHERE p.id = $1 AND p.deleted_at IS NULL`
 	err := r.db.QueryRow(query, id).Scan(&post.ID, &post.UserID, &post.SiteID, &post.Title,
-		&post.Content, &post.Slug, &post.Status, &post.Published, &post.PublishedAt,
-		&post.CreatedAt, &post.UpdatedAt, &post.Author)
+		&post.Content, &post.ContentFormat, &post.Blocks, &post.Slug, &post.Status, &post.Published, &post.PublishedAt, &post.PublishAt, &post.UnpublishAt,
+		&post.CreatedAt, &post.UpdatedAt, &post.Author, &post.Locale, &post.TranslationGroupID, &post.CommentCount)
 	return post, err
 }
 
-func (r *SQLPostRepository) GetBySlug(slug string, siteID string) (*Post, error) {
+// GetBySlug finds the post with the slug and returns the variant of its
+// translation group that comes first in locales. Other variants only stand
+// in for the requested post once published; with no locale in the chain
+// the slug's own post is returned.
+func (r *SQLPostRepository) GetBySlug(slug string, siteID string, locales services.LocaleChain) (*Post, error) {
 	post := &Post{}
 	query := `
-		SELECT p.id, p.user_id, p.site_id, p.title, p.content, p.slug, p.status, p.published, 
-		p.published_at, p.created_at, p.updated_at, COALESCE(u.username, 'Anonymous')
+		SELECT p.id, p.user_id, p.site_id, p.title, p.content, p.content_format, p.blocks, p.slug, p.status, p.published, 
+		p.published_at, p.publish_at, p.unpublish_at, p.created_at, p.updated_at, COALESCE(u.username, 'Anonymous'),
+		p.locale, p.translation_group_id, p.comment_count
 		FROM posts p
 		LEFT JOIN users u ON p.user_id = u.id
-		WHERE p.slug = $1 AND p.site_id = $2`
-	err := r.db.QueryRow(query, slug, siteID).Scan(&post.ID, &post.UserID, &post.SiteID, &post.Title,
-		&post.Content, &post.Slug, &post.Status, &post.Published, &post.PublishedAt,
-		&post.CreatedAt, &post.UpdatedAt, &post.Author)
+		WHERE p.site_id = $2 AND p.deleted_at IS NULL AND (p.slug = $1 OR p.published = true)
+		AND p.translation_group_id = (
+			SELECT translation_group_id FROM posts
+			WHERE slug = $1 AND site_id = $2 AND deleted_at IS NULL
+			ORDER BY array_position($3::TEXT[], locale) NULLS LAST
+			LIMIT 1)
+		ORDER BY array_position($3::TEXT[], p.locale) NULLS LAST, p.slug <> $1
+		LIMIT 1`
+	err := r.db.QueryRow(query, slug, siteID, pq.Array([]string(locales))).Scan(&post.ID, &post.UserID, &post.SiteID, &post.Title,
+		&post.Content, &post.ContentFormat, &post.Blocks, &post.Slug, &post.Status, &post.Published, &post.PublishedAt, &post.PublishAt, &post.UnpublishAt,
+		&post.CreatedAt, &post.UpdatedAt, &post.Author, &post.Locale, &post.TranslationGroupID, &post.CommentCount)
 	return post, err
 }
 
-func (r *SQLPostRepository) GetAll(limit, offset int, siteID string) ([]Post, error) {
+func (r *SQLPostRepository) GetAll(limit, offset int, siteID string, filter services.PostTermFilter) ([]Post, error) {
+	args := []interface{}{siteID}
+	conditions := filter.SQL("p", &args)
+	args = append(args, limit, offset)
 	query := `
-		SELECT p.id, p.user_id, p.site_id, p.title, p.content, p.slug, p.status, p.published, 
-		p.published_at, p.created_at, p.updated_at, COALESCE(u.username, 'Anonymous')
+		SELECT p.id, p.user_id, p.site_id, p.title, p.content, p.content_format, p.blocks, p.slug, p.status, p.published, 
+		p.published_at, p.publish_at, p.unpublish_at, p.created_at, p.updated_at, COALESCE(u.username, 'Anonymous'),
+		p.locale, p.translation_group_id, p.comment_count
 		FROM posts p
 		LEFT JOIN users u ON p.user_id = u.id
-		WHERE p.site_id = $1
+		WHERE p.site_id = $1 AND p.deleted_at IS NULL` + conditions + `
 		ORDER BY p.created_at DESC
-		LIMIT $2 OFFSET $3`
-	rows, err := r.db.Query(query, siteID, limit, offset)
+		LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -329,9 +451,9 @@ func (r *SQLPostRepository) GetAll(limit, offset int, siteID string) ([]Post, er
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		err := rows.Scan(&post.ID, &post.UserID, &post.SiteID, &post.Title, &post.Content,
-			&post.Slug, &post.Status, &post.Published, &post.PublishedAt,
-			&post.CreatedAt, &post.UpdatedAt, &post.Author)
+		err := rows.Scan(&post.ID, &post.UserID, &post.SiteID, &post.Title, &post.Content, &post.ContentFormat, &post.Blocks,
+			&post.Slug, &post.Status, &post.Published, &post.PublishedAt, &post.PublishAt, &post.UnpublishAt,
+			&post.CreatedAt, &post.UpdatedAt, &post.Author, &post.Locale, &post.TranslationGroupID, &post.CommentCount)
 		if err != nil {
 			return nil, err
 		}
@@ -340,16 +462,23 @@ func (r *SQLPostRepository) GetAll(limit, offset int, siteID string) ([]Post, er
 	return posts, nil
 }
 
-func (r *SQLPostRepository) GetPublished(limit, offset int, siteID string) ([]Post, error) {
+// GetPublished returns a page of published posts, optionally restricted to
+// posts carrying the filter's tags and categories. A locale chain lists
+// each translation group once, in its most preferred locale.
+func (r *SQLPostRepository) GetPublished(limit, offset int, siteID string, filter services.PostTermFilter, locales services.LocaleChain) ([]Post, error) {
+	args := []interface{}{siteID}
+	conditions := filter.SQL("p", &args) + locales.SQL("p", &args)
+	args = append(args, limit, offset)
 	query := `
-		SELECT p.id, p.user_id, p.site_id, p.title, p.content, p.slug, p.status, p.published, 
-		p.published_at, p.created_at, p.updated_at, COALESCE(u.username, 'Anonymous')
+		SELECT p.id, p.user_id, p.site_id, p.title, p.content, p.content_format, p.blocks, p.slug, p.status, p.published, 
+		p.published_at, p.publish_at, p.unpublish_at, p.created_at, p.updated_at, COALESCE(u.username, 'Anonymous'),
+		p.locale, p.translation_group_id, p.comment_count
 		FROM posts p
 		LEFT JOIN users u ON p.user_id = u.id
-		WHERE p.site_id = $1 AND p.published = true
+		WHERE p.site_id = $1 AND p.published = true AND p.deleted_at IS NULL` + conditions + `
 		ORDER BY p.created_at DESC
-		LIMIT $2 OFFSET $3`
-	rows, err := r.db.Query(query, siteID, limit, offset)
+		LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -358,9 +487,9 @@ func (r *SQLPostRepository) GetPublished(limit, offset int, siteID string) ([]Po
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		err := rows.Scan(&post.ID, &post.UserID, &post.SiteID, &post.Title, &post.Content,
-			&post.Slug, &post.Status, &post.Published, &post.PublishedAt,
-			&post.CreatedAt, &post.UpdatedAt, &post.Author)
+		err := rows.Scan(&post.ID, &post.UserID, &post.SiteID, &post.Title, &post.Content, &post.ContentFormat, &post.Blocks,
+			&post.Slug, &post.Status, &post.Published, &post.PublishedAt, &post.PublishAt, &post.UnpublishAt,
+			&post.CreatedAt, &post.UpdatedAt, &post.Author, &post.Locale, &post.TranslationGroupID, &post.CommentCount)
 		if err != nil {
 			return nil, err
 		}
@@ -369,18 +498,53 @@ func (r *SQLPostRepository) GetPublished(limit, offset int, siteID string) ([]Po
 	return posts, nil
 }
 
-func (r *SQLPostRepository) Count(siteID string) (int, error) {
+func (r *SQLPostRepository) Count(siteID string, filter services.PostTermFilter) (int, error) {
 	var count int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM posts WHERE site_id = $1", siteID).Scan(&count)
+	args := []interface{}{siteID}
+	conditions := filter.SQL("p", &args)
+	err := r.db.QueryRow("SELECT COUNT(*) FROM posts p WHERE p.site_id = $1 AND p.deleted_at IS NULL"+conditions, args...).Scan(&count)
 	return count, err
 }
 
-func (r *SQLPostRepository) CountPublished(siteID string) (int, error) {
+func (r *SQLPostRepository) CountPublished(siteID string, filter services.PostTermFilter, locales services.LocaleChain) (int, error) {
 	var count int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM posts WHERE site_id = $1 AND published = true", siteID).Scan(&count)
+	args := []interface{}{siteID}
+	conditions := filter.SQL("p", &args) + locales.SQL("p", &args)
+	err := r.db.QueryRow("SELECT COUNT(*) FROM posts p WHERE p.site_id = $1 AND p.published = true AND p.deleted_at IS NULL"+conditions, args...).Scan(&count)
 	return count, err
 }
 
+// GetScheduled returns posts waiting on a publish or unpublish time, soonest first
+func (r *SQLPostRepository) GetScheduled(siteID string) ([]Post, error) {
+	query := `
+		SELECT p.id, p.user_id, p.site_id, p.title, p.content, p.content_format, p.blocks, p.slug, p.status, p.published, 
+		p.published_at, p.publish_at, p.unpublish_at, p.created_at, p.updated_at, COALESCE(u.username, 'Anonymous'),
+		p.locale, p.translation_group_id, p.comment_count
+		FROM posts p
+		LEFT JOIN users u ON p.user_id = u.id
+		WHERE p.site_id = $1 AND p.deleted_at IS NULL
+		AND (p.publish_at IS NOT NULL OR p.unpublish_at IS NOT NULL)
+		ORDER BY LEAST(COALESCE(p.publish_at, p.unpublish_at), COALESCE(p.unpublish_at, p.publish_at))`
+	rows, err := r.db.Query(query, siteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(&post.ID, &post.UserID, &post.SiteID, &post.Title, &post.Content, &post.ContentFormat, &post.Blocks,
+			&post.Slug, &post.Status, &post.Published, &post.PublishedAt, &post.PublishAt, &post.UnpublishAt,
+			&post.CreatedAt, &post.UpdatedAt, &post.Author, &post.Locale, &post.TranslationGroupID, &post.CommentCount)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
 // Site repository implementation
 type SQLSiteRepository struct {
 	db *sql.DB
@@ -452,6 +616,27 @@ type App struct {
 	cognitoAuthHandlers *handlers.CognitoAuthHandlers
 	oidcAuthHandlers    *handlers.OIDCAuthHandlersConfig
 	adminHandlers       *handlers.AdminHandler
+	revisionHandlers    *handlers.RevisionHandlers
+	revisionService     *services.RevisionService
+	previewService      *services.PreviewService
+	previewHandlers     *handlers.PreviewHandlers
+	workflowService     *services.WorkflowService
+	workflowHandlers    *handlers.WorkflowHandlers
+	searchHandlers      *handlers.SearchHandlers
+	commentHandlers     *handlers.CommentHandlers
+	themeEngine         *services.ThemeEngine
+	pageCache           *services.PageCache
+	themeHandlers       *handlers.ThemeHandlers
+	contentService      *services.ContentService
+	siteContentService  *services.SiteContentService
+	contentHandlers     *handlers.ContentHandlers
+	mediaService        *services.MediaService
+	mediaHandlers       *handlers.MediaHandlers
+	mediaLocalServing   bool
+	staticExportService *services.StaticExportService
+	exportHandlers      *handlers.ExportHandlers
+	importService       *services.ImportService
+	importHandlers      *handlers.ImportHandlers
 }
 
 func NewApp(config *Config) (*App, error) {
@@ -499,10 +684,99 @@ func NewApp(config *Config) (*App, error) {
 	// Initialize admin handlers
 	adminHandlers := handlers.NewAdminHandler(db, &Logger{level: logLevel})
 
+	// Initialize content service; deleted posts stay in the trash for the
+	// retention window before they are purged
+	contentService := services.NewContentService(db, &Logger{level: logLevel})
+	if days, err := strconv.Atoi(os.Getenv("CONTENT_TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+		contentService.SetTrashRetention(time.Duration(days) * 24 * time.Hour)
+	}
+	siteContentService := services.NewSiteContentService(db, &Logger{level: logLevel})
+	siteContentService.SetBlockCatalog(thorneService)
+	contentHandlers := handlers.NewContentHandlers(contentService, siteContentService)
+
+	// Initialize post revision history
+	revisionService := services.NewRevisionService(db, &Logger{level: logLevel})
+	revisionHandlers := handlers.NewRevisionHandlers(revisionService)
+
+	// Initialize draft preview links; tokens are signed with
+	// PREVIEW_TOKEN_SECRET, falling back to the session secret
+	previewSecret := os.Getenv("PREVIEW_TOKEN_SECRET")
+	if previewSecret == "" {
+		previewSecret = config.Session.Secret
+	}
+	previewService := services.NewPreviewService(db, &Logger{level: logLevel}, []byte(previewSecret))
+	previewHandlers := handlers.NewPreviewHandlers(previewService)
+
+	// Initialize the editorial workflow and its notifications
+	workflowService := services.NewWorkflowService(db, &Logger{level: logLevel})
+	notificationService := services.NewNotificationService(db, &Logger{level: logLevel})
+	workflowHandlers := handlers.NewWorkflowHandlers(workflowService, notificationService)
+
+	// Initialize post search
+	searchHandlers := handlers.NewSearchHandlers(services.NewSearchService(db, &Logger{level: logLevel}))
+
+	// Initialize comments; COMMENT_RATE_LIMIT_IP and COMMENT_RATE_LIMIT_USER
+	// cap comments per address and per user in COMMENT_RATE_WINDOW
+	commentService := services.NewCommentService(db, &Logger{level: logLevel})
+	commentWindow, _ := time.ParseDuration(os.Getenv("COMMENT_RATE_WINDOW"))
+	perIP, _ := strconv.Atoi(os.Getenv("COMMENT_RATE_LIMIT_IP"))
+	perUser, _ := strconv.Atoi(os.Getenv("COMMENT_RATE_LIMIT_USER"))
+	commentService.SetRateLimits(perIP, perUser, commentWindow)
+	commentHandlers := handlers.NewCommentHandlers(commentService)
+
+	// Initialize the rendered public site; themes live under THEMES_DIR and
+	// rendered pages are cached for PUBLIC_PAGE_CACHE_TTL
+	themesDir := os.Getenv("THEMES_DIR")
+	if themesDir == "" {
+		themesDir = "./templates/themes"
+	}
+	themeEngine := services.NewThemeEngine(themesDir, &Logger{level: logLevel})
+	pageCacheTTL, err := time.ParseDuration(os.Getenv("PUBLIC_PAGE_CACHE_TTL"))
+	if err != nil {
+		pageCacheTTL = time.Minute
+	}
+	pageCache := services.NewPageCache(pageCacheTTL, 1000)
+	contentService.SetPageCache(pageCache)
+	siteContentService.SetPageCache(pageCache)
+	revisionService.SetPageCache(pageCache)
+	workflowService.SetPageCache(pageCache)
+	themeHandlers := handlers.NewThemeHandlers(themeEngine, pageCache, siteContentService)
+
+	// Initialize the media library
+	blobStore, mediaLocalServing, err := newMediaBlobStore()
+	if err != nil {
+		return nil, err
+	}
+	mediaService := services.NewMediaService(db, &Logger{level: logLevel}, blobStore)
+	if mb, err := strconv.Atoi(os.Getenv("MEDIA_MAX_UPLOAD_MB")); err == nil && mb > 0 {
+		mediaService.SetMaxUploadBytes(int64(mb) << 20)
+	}
+	if encoder := services.NewCWebPEncoder(os.Getenv("MEDIA_CWEBP_PATH")); encoder != nil {
+		mediaService.SetWebPEncoder(encoder)
+	} else {
+		log.Printf("Warning: cwebp not found; WebP media variants are disabled")
+	}
+	mediaHandlers := handlers.NewMediaHandlers(mediaService)
+
+	// Initialize static site exports; jobs started from the admin API keep
+	// their output under STATIC_EXPORT_DIR
+	staticExportService := services.NewStaticExportService(db, &Logger{level: logLevel}, siteContentService, themeEngine, mediaService, pageCache)
+	if dir := os.Getenv("STATIC_EXPORT_DIR"); dir != "" {
+		staticExportService.SetJobDir(dir)
+	}
+	exportHandlers := handlers.NewExportHandlers(staticExportService)
+
+	// Initialize WordPress and Markdown imports
+	importService := services.NewImportService(db, &Logger{level: logLevel}, siteContentService, mediaService)
+	if mb, err := strconv.Atoi(os.Getenv("IMPORT_MAX_UPLOAD_MB")); err == nil && mb > 0 {
+		importService.SetMaxUploadBytes(int64(mb) << 20)
+	}
+	importHandlers := handlers.NewImportHandlers(importService)
+
 	app := &App{
 		config:              config,
 		db:                  db,
-		posts:               &SQLPostRepository{db: db},
+		posts:               &SQLPostRepository{db: db, pageCache: pageCache},
 		sites:               &SQLSiteRepository{db: db},
 		sessions:            sessions.NewCookieStore([]byte(config.Session.Secret)),
 		logger:              &Logger{level: logLevel},
@@ -512,11 +786,71 @@ func NewApp(config *Config) (*App, error) {
 		cognitoAuthHandlers: cognitoAuthHandlers,
 		oidcAuthHandlers:    oidcAuthHandlers,
 		adminHandlers:       adminHandlers,
+		revisionHandlers:    revisionHandlers,
+		revisionService:     revisionService,
+		previewService:      previewService,
+		previewHandlers:     previewHandlers,
+		workflowService:     workflowService,
+		workflowHandlers:    workflowHandlers,
+		searchHandlers:      searchHandlers,
+		commentHandlers:     commentHandlers,
+		themeEngine:         themeEngine,
+		pageCache:           pageCache,
+		themeHandlers:       themeHandlers,
+		contentService:      contentService,
+		siteContentService:  siteContentService,
+		contentHandlers:     contentHandlers,
+		mediaService:        mediaService,
+		mediaHandlers:       mediaHandlers,
+		mediaLocalServing:   mediaLocalServing,
+		staticExportService: staticExportService,
+		exportHandlers:      exportHandlers,
+		importService:       importService,
+		importHandlers:      importHandlers,
 	}
+	staticExportService.SetFetcher(app.exportFetch)
 
 	return app, nil
 }
 
+// newMediaBlobStore builds the media store from MEDIA_STORAGE ("local", the
+// default, or "s3"). The boolean reports whether this server must serve the
+// stored files itself under /media.
+func newMediaBlobStore() (services.BlobStore, bool, error) {
+	switch os.Getenv("MEDIA_STORAGE") {
+	case "", "local":
+		dir := os.Getenv("MEDIA_LOCAL_DIR")
+		if dir == "" {
+			dir = "./data/media"
+		}
+		publicURL := os.Getenv("MEDIA_PUBLIC_URL")
+		if publicURL == "" {
+			publicURL = "/media"
+		}
+		store, err := services.NewLocalBlobStore(dir, publicURL)
+		if err != nil {
+			return nil, false, err
+		}
+		return store, true, nil
+	case "s3":
+		store, err := services.NewS3BlobStore(services.S3Config{
+			Endpoint:  os.Getenv("MEDIA_S3_ENDPOINT"),
+			Region:    os.Getenv("MEDIA_S3_REGION"),
+			Bucket:    os.Getenv("MEDIA_S3_BUCKET"),
+			AccessKey: os.Getenv("MEDIA_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("MEDIA_S3_SECRET_KEY"),
+			PublicURL: os.Getenv("MEDIA_S3_PUBLIC_URL"),
+			PathStyle: os.Getenv("MEDIA_S3_PATH_STYLE") != "false",
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		return store, false, nil
+	default:
+		return nil, false, fmt.Errorf("unknown MEDIA_STORAGE %q", os.Getenv("MEDIA_STORAGE"))
+	}
+}
+
 // Helper functions
 func generateErrorResponse(errorType interface{}, errorID string) APIResponse {
 	return APIResponse{
@@ -529,24 +863,1098 @@ func generateErrorResponse(errorType interface{}, errorID string) APIResponse {
 // validate post status against DB CHECK constraint
 func isValidPostStatus(status string) bool {
 	switch status {
-	case "draft", "published", "archived", "deleted":
+	case "draft", "in_review", "approved", "published", "archived", "deleted":
 		return true
 	default:
 		return false
 	}
 }
 
-func slugify(s string) string {
-	s = strings.ToLower(s)
-	s = strings.ReplaceAll(s, " ", "-")
-	s = strings.ReplaceAll(s, "_", "-")
-	var result strings.Builder
-	for _, r := range s {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
-			result.WriteRune(r)
+// applySchedule validates publish_at/unpublish_at and keeps status and the
+// published flag consistent: a post scheduled for the future stays
+// unpublished until the scheduler flips it.
+func applySchedule(post *Post) error {
+	if post.PublishAt != nil && post.UnpublishAt != nil && !post.UnpublishAt.After(*post.PublishAt) {
+		return fmt.Errorf("unpublish_at must be after publish_at")
+	}
+
+	if post.Status == "published" {
+		post.Published = true
+	}
+	if post.PublishAt != nil && post.PublishAt.After(time.Now()) {
+		post.Published = false
+		if post.Status == "published" {
+			post.Status = "draft"
+		}
+	}
+	return nil
+}
+
+// applyContentFormat validates a post's format and blocks. Sending blocks
+// implies the blocks format, whose content is the blocks' rendered HTML so
+// existing readers keep working; other formats drop any blocks.
+func (app *App) applyContentFormat(post *Post) error {
+	if post.ContentFormat == "" {
+		post.ContentFormat = services.ContentFormatHTML
+		if len(post.Blocks) > 0 {
+			post.ContentFormat = services.ContentFormatBlocks
+		}
+	}
+	if !services.IsContentFormat(post.ContentFormat) {
+		return fmt.Errorf("content_format must be one of: markdown, html, blocks")
+	}
+
+	if post.ContentFormat != services.ContentFormatBlocks {
+		post.Blocks = nil
+		return nil
+	}
+	if len(post.Blocks) == 0 {
+		return fmt.Errorf("the blocks format needs at least one block")
+	}
+	blocks, html, err := app.siteContentService.PrepareBlocks(post.SiteID, post.Blocks)
+	if err != nil {
+		return err
+	}
+	post.Blocks, post.Content = blocks, html
+	return nil
+}
+
+// renderPost attaches the render pipeline's output, leaving Content as the
+// stored source. Blocks are re-rendered first so edits to shared components
+// show up; the HTML stored at save time is used if that fails.
+func (app *App) renderPost(post *Post) {
+	if len(post.Blocks) > 0 {
+		if blocks, html, err := app.siteContentService.RenderBlocks(post.SiteID, post.Blocks); err == nil {
+			post.Blocks, post.Content = blocks, html
+		} else {
+			app.logger.Warning("posts", "render", "Failed to render post blocks", map[string]interface{}{
+				"context": map[string]interface{}{
+					"post_id": post.ID,
+					"site_id": post.SiteID,
+					"error":   err.Error(),
+				},
+			})
+		}
+	}
+
+	rendered, err := services.RenderContent(post.Content, post.ContentFormat)
+	if err != nil {
+		app.logger.Warning("posts", "render", "Failed to render post content", map[string]interface{}{
+			"context": map[string]interface{}{
+				"post_id": post.ID,
+				"error":   err.Error(),
+			},
+		})
+		return
+	}
+	post.Rendered = rendered
+}
+
+// attachTerms fills in the tags and categories of posts; a lookup failure
+// is logged and leaves them empty
+func (app *App) attachTerms(posts ...*Post) {
+	if len(posts) == 0 {
+		return
+	}
+	ids := make([]string, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	terms, err := app.siteContentService.TermsForPosts(ids)
+	if err != nil {
+		app.logger.Warning("posts", "terms", "Failed to load post terms", map[string]interface{}{
+			"context": map[string]interface{}{
+				"error": err.Error(),
+			},
+		})
+		return
+	}
+	for _, post := range posts {
+		if postTerms := terms[post.ID]; postTerms != nil {
+			post.Tags, post.Categories = postTerms.Tags, postTerms.Categories
+		}
+	}
+}
+
+// localeChain builds the locale fallback chain for a public request from
+// ?locale=, else the Accept-Language header. If the site's locales can't be
+// loaded there is no chain and every variant is listed.
+func (app *App) localeChain(w http.ResponseWriter, r *http.Request, siteID string) services.LocaleChain {
+	locales, err := app.siteContentService.SiteLocales(siteID)
+	if err != nil {
+		app.logger.Warning("posts", "locales", "Failed to load site locales", map[string]interface{}{
+			"context": map[string]interface{}{
+				"site_id": siteID,
+				"error":   err.Error(),
+			},
+		})
+		return nil
+	}
+	if locale := r.URL.Query().Get("locale"); locale != "" {
+		return locales.Chain(locale)
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	return locales.Chain(services.ParseAcceptLanguage(r.Header.Get("Accept-Language"))...)
+}
+
+// attachAlternates fills in the published language variants of posts for
+// hreflang links; a lookup failure is logged and leaves them empty
+func (app *App) attachAlternates(posts ...*Post) {
+	if len(posts) == 0 {
+		return
+	}
+	groups := make([]string, 0, len(posts))
+	for _, post := range posts {
+		groups = append(groups, post.TranslationGroupID)
+	}
+	alternates, err := app.siteContentService.PostAlternates(posts[0].SiteID, groups)
+	if err != nil {
+		app.logger.Warning("posts", "alternates", "Failed to load post alternates", map[string]interface{}{
+			"context": map[string]interface{}{
+				"error": err.Error(),
+			},
+		})
+		return
+	}
+	for _, post := range posts {
+		post.Alternates = alternates[post.TranslationGroupID]
+	}
+}
+
+// applyLocale checks a post's locale against its site's supported locales.
+// New posts default to the site's default locale and may join an existing
+// translation group; an edit without a locale keeps the current one.
+func (app *App) applyLocale(post *Post, creating bool) error {
+	if !creating && post.Locale == "" {
+		return nil
+	}
+	locale, err := services.ResolvePostLocale(app.db, post.SiteID, post.Locale)
+	if err != nil {
+		return err
+	}
+	post.Locale = locale
+	if creating && post.TranslationGroupID != "" {
+		return services.CheckTranslationGroup(app.db, post.SiteID, post.TranslationGroupID, post.Locale)
+	}
+	return nil
+}
+
+// feedItemLimit is how many of the newest published posts a feed carries
+const feedItemLimit = 20
+
+// feedHandler serves a site's newest published posts as RSS 2.0, Atom or
+// JSON Feed. ?tag= and ?category= narrow the feed like the posts list.
+func (app *App) feedHandler(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		site, ok := app.feedSite(w, r)
+		if !ok {
+			return
+		}
+
+		filter := services.PostTermFilter{
+			Tags:       r.URL.Query()["tag"],
+			Categories: r.URL.Query()["category"],
+		}
+		locales := app.localeChain(w, r, site.ID)
+		if len(locales) > 0 {
+			site.Language = locales[0]
+		}
+		posts, err := app.posts.GetPublished(feedItemLimit, 0, site.ID, filter, locales)
+		if err != nil {
+			app.feedError(w, "feed", err, site.ID)
+			return
+		}
+
+		listed := make([]*Post, len(posts))
+		for i := range posts {
+			listed[i] = &posts[i]
+		}
+		app.attachTerms(listed...)
+
+		items := make([]services.FeedItem, 0, len(posts))
+		var lastModified time.Time
+		for _, post := range listed {
+			app.renderPost(post)
+			item := services.FeedItem{
+				ID:        post.ID,
+				Title:     post.Title,
+				URL:       site.BaseURL + services.LocalizedPostPath(post.Slug, post.Locale, site.DefaultLocale),
+				Author:    post.Author,
+				Published: post.CreatedAt,
+				Updated:   post.UpdatedAt,
+			}
+			if post.PublishedAt != nil {
+				item.Published = *post.PublishedAt
+			}
+			if post.Rendered != nil {
+				item.ContentHTML, item.Summary = post.Rendered.HTML, post.Rendered.Excerpt
+			}
+			for _, term := range append(post.Categories, post.Tags...) {
+				item.Categories = append(item.Categories, term.Name)
+			}
+			if item.Updated.After(lastModified) {
+				lastModified = item.Updated
+			}
+			items = append(items, item)
+		}
+
+		var body []byte
+		var contentType string
+		switch format {
+		case "atom":
+			body, err = services.BuildAtom(site, requestURL(r), items)
+			contentType = "application/atom+xml; charset=utf-8"
+		case "json":
+			body, err = services.BuildJSONFeed(site, requestURL(r), items)
+			contentType = "application/feed+json; charset=utf-8"
+		default:
+			body, err = services.BuildRSS(site, requestURL(r), items)
+			contentType = "application/rss+xml; charset=utf-8"
+		}
+		if err != nil {
+			app.feedError(w, "feed", err, site.ID)
+			return
+		}
+
+		serveSyndication(w, r, contentType, body, lastModified)
+	}
+}
+
+// sitemapHandler serves /sites/{siteSlug}/sitemap.xml, which becomes a
+// sitemap index over sitemap-{n}.xml once the site has more URLs than one
+// sitemap may hold
+func (app *App) sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	site, ok := app.feedSite(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := app.siteContentService.CountSitemapURLs(site.ID)
+	if err != nil {
+		app.feedError(w, "sitemap", err, site.ID)
+		return
+	}
+	lastModified, err := app.siteContentService.LastSitemapUpdate(site.ID)
+	if err != nil {
+		app.feedError(w, "sitemap", err, site.ID)
+		return
+	}
+
+	var body []byte
+	if count > services.MaxSitemapURLs {
+		base := strings.TrimSuffix(strings.SplitN(requestURL(r), "?", 2)[0], "sitemap.xml")
+		sitemaps := []services.SitemapURL{}
+		for n := 1; (n-1)*services.MaxSitemapURLs < count; n++ {
+			sitemaps = append(sitemaps, services.SitemapURL{
+				Loc:     base + "sitemap-" + strconv.Itoa(n) + ".xml",
+				LastMod: lastModified,
+			})
+		}
+		body, err = services.BuildSitemapIndex(sitemaps)
+	} else {
+		var urls []services.SitemapURL
+		urls, err = app.siteContentService.SitemapURLs(site, 0, services.MaxSitemapURLs)
+		if err == nil {
+			body, err = services.BuildSitemap(urls)
+		}
+	}
+	if err != nil {
+		app.feedError(w, "sitemap", err, site.ID)
+		return
+	}
+
+	serveSyndication(w, r, "application/xml; charset=utf-8", body, lastModified)
+}
+
+// sitemapPageHandler serves /sites/{siteSlug}/sitemap-{n}.xml, the nth
+// block of MaxSitemapURLs entries listed by the sitemap index
+func (app *App) sitemapPageHandler(w http.ResponseWriter, r *http.Request) {
+	site, ok := app.feedSite(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(mux.Vars(r)["n"])
+	if err != nil || n < 1 {
+		http.NotFound(w, r)
+		return
+	}
+	urls, err := app.siteContentService.SitemapURLs(site, (n-1)*services.MaxSitemapURLs, services.MaxSitemapURLs)
+	if err != nil {
+		app.feedError(w, "sitemap", err, site.ID)
+		return
+	}
+	if len(urls) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var lastModified time.Time
+	for _, entry := range urls {
+		if entry.LastMod.After(lastModified) {
+			lastModified = entry.LastMod
+		}
+	}
+	body, err := services.BuildSitemap(urls)
+	if err != nil {
+		app.feedError(w, "sitemap", err, site.ID)
+		return
+	}
+
+	serveSyndication(w, r, "application/xml; charset=utf-8", body, lastModified)
+}
+
+// feedSite resolves {siteSlug} for the syndication handlers, writing a 404
+// or 500 itself when it can't
+func (app *App) feedSite(w http.ResponseWriter, r *http.Request) (*services.SiteInfo, bool) {
+	site, err := app.siteContentService.GetSiteInfo(mux.Vars(r)["siteSlug"], r.Host)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return nil, false
+		}
+		app.feedError(w, "feed", err, mux.Vars(r)["siteSlug"])
+		return nil, false
+	}
+	return site, true
+}
+
+func (app *App) feedError(w http.ResponseWriter, action string, err error, siteRef string) {
+	errorID := uuid.New().String()
+	app.logger.Error("syndication", action, "Failed to build "+action, map[string]interface{}{
+		"context": map[string]interface{}{
+			"site":     siteRef,
+			"error":    err.Error(),
+			"error_id": errorID,
+		},
+	})
+	http.Error(w, "Failed to build "+action+" (error "+errorID+")", http.StatusInternalServerError)
+}
+
+// serveSyndication writes a feed or sitemap with a content-hash ETag and
+// Last-Modified, answering If-None-Match and If-Modified-Since with 304
+func serveSyndication(w http.ResponseWriter, r *http.Request, contentType string, body []byte, lastModified time.Time) {
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(body))
+}
+
+// requestURL reconstructs the absolute URL a client asked for, honouring
+// X-Forwarded-Proto from a TLS-terminating proxy
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// publicPostsPerPage is how many posts the rendered index and tag pages list
+const publicPostsPerPage = 10
+
+// publicPage is the data a theme page renders. Links within the site start
+// with BasePath, which is empty when the site is served on its own domain
+// and /s/{siteSlug} otherwise.
+type publicPage struct {
+	Site        *services.SiteInfo
+	Theme       string
+	Kind        string
+	Title       string
+	Description string
+	URL         string
+	BasePath    string
+	Locale      string
+	Posts       []*Post
+	Post        *Post
+	Tag         *services.Term
+	SitePage    *services.SitePage
+	Page        int
+	TotalPages  int
+	PrevURL     string
+	NextURL     string
+	Now         time.Time
+}
+
+// PostURL links to a post in its own language
+func (p *publicPage) PostURL(post *Post) string {
+	return p.BasePath + services.LocalizedPostPath(post.Slug, post.Locale, p.Site.DefaultLocale)
+}
+
+// TagURL links to a tag page
+func (p *publicPage) TagURL(tag *services.Term) string {
+	return p.BasePath + "/tags/" + url.PathEscape(tag.Slug)
+}
+
+// PageURL links to one of the site's pages
+func (p *publicPage) PageURL(sitePage *services.SitePage) string {
+	return p.BasePath + services.PagePath(sitePage.Path)
+}
+
+// AssetURL links to a file in the theme's assets directory. The theme name
+// is part of the URL, so switching themes never serves stale assets.
+func (p *publicPage) AssetURL(asset string) string {
+	return p.BasePath + "/assets/" + p.Theme + "/" + strings.TrimPrefix(asset, "/")
+}
+
+// LiquidVars exposes the page to Liquid themes as site, page, posts, post,
+// tag and site_page, with links resolved since Liquid cannot call PostURL,
+// TagURL or PageURL
+func (p *publicPage) LiquidVars() map[string]interface{} {
+	vars := map[string]interface{}{
+		"site": map[string]interface{}{
+			"name":        p.Site.Name,
+			"slug":        p.Site.Slug,
+			"url":         p.Site.BaseURL,
+			"description": p.Site.Description,
+			"locale":      p.Site.DefaultLocale,
+			"home_url":    p.BasePath + "/",
+			"feed_url":    "/api/sites/" + p.Site.Slug + "/feed.xml",
+		},
+		"page": map[string]interface{}{
+			"kind":        p.Kind,
+			"title":       p.Title,
+			"description": p.Description,
+			"url":         p.URL,
+			"locale":      p.Locale,
+			"number":      p.Page,
+			"total_pages": p.TotalPages,
+			"prev_url":    p.PrevURL,
+			"next_url":    p.NextURL,
+		},
+		"theme":      p.Theme,
+		"assets_url": p.BasePath + "/assets/" + p.Theme,
+		"now":        p.Now,
+	}
+
+	posts := make([]interface{}, len(p.Posts))
+	for i, post := range p.Posts {
+		posts[i] = p.liquidPost(post)
+	}
+	vars["posts"] = posts
+	if p.Post != nil {
+		vars["post"] = p.liquidPost(p.Post)
+	}
+	if p.Tag != nil {
+		vars["tag"] = p.liquidTerm(p.Tag)
+	}
+	if p.SitePage != nil {
+		children := make([]interface{}, len(p.SitePage.Children))
+		for i, child := range p.SitePage.Children {
+			children[i] = map[string]interface{}{
+				"title": child.Title,
+				"path":  child.Path,
+				"url":   p.PageURL(child),
+			}
+		}
+		vars["site_page"] = map[string]interface{}{
+			"title":      p.SitePage.Title,
+			"path":       p.SitePage.Path,
+			"url":        p.PageURL(p.SitePage),
+			"content":    p.SitePage.Content,
+			"updated_at": p.SitePage.UpdatedAt,
+			"children":   children,
+		}
+	}
+	return vars
+}
+
+func (p *publicPage) liquidPost(post *Post) map[string]interface{} {
+	vars := map[string]interface{}{
+		"id":            post.ID,
+		"title":         post.Title,
+		"slug":          post.Slug,
+		"url":           p.PostURL(post),
+		"author":        post.Author,
+		"locale":        post.Locale,
+		"created_at":    post.CreatedAt,
+		"updated_at":    post.UpdatedAt,
+		"published_at":  post.PublishedAt,
+		"comment_count": post.CommentCount,
+	}
+	if post.Rendered != nil {
+		vars["content"] = post.Rendered.HTML
+		vars["excerpt"] = post.Rendered.Excerpt
+		vars["reading_time_minutes"] = post.Rendered.ReadingTimeMinutes
+	}
+
+	tags := make([]interface{}, len(post.Tags))
+	for i, tag := range post.Tags {
+		tags[i] = p.liquidTerm(tag)
+	}
+	vars["tags"] = tags
+
+	alternates := make([]interface{}, 0, len(post.Alternates))
+	for _, alternate := range post.Alternates {
+		alternates = append(alternates, map[string]interface{}{
+			"hreflang": alternate.Hreflang,
+			"post_id":  alternate.PostID,
+			"url":      p.Site.BaseURL + alternate.Href,
+			"path":     p.BasePath + alternate.Href,
+		})
+	}
+	vars["alternates"] = alternates
+	return vars
+}
+
+func (p *publicPage) liquidTerm(term *services.Term) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        term.Name,
+		"slug":        term.Slug,
+		"description": term.Description,
+		"url":         p.TagURL(term),
+	}
+}
+
+// publicSiteHandler renders a site's public pages with its theme: the post
+// index at /, posts at /posts/{slug} and /{locale}/posts/{slug}, tag pages
+// at /tags/{slug}, theme files under /assets/{theme}/ and, when the theme
+// has a page template, the site's published pages at their paths. The site
+// is the one mapped to the request's host, or {siteSlug} under /s/{siteSlug}.
+// Rendered pages are cached per site, theme and URL.
+func (app *App) publicSiteHandler(w http.ResponseWriter, r *http.Request) {
+	siteRef, basePath, path := mux.Vars(r)["siteSlug"], "", r.URL.Path
+	if siteRef != "" {
+		basePath = "/s/" + siteRef
+		path = strings.TrimPrefix(path, basePath)
+	} else {
+		siteID, err := app.siteContentService.ResolveSiteByHost(r.Host)
+		if err != nil {
+			app.publicSiteError(w, err, r.Host)
+			return
+		}
+		siteRef = siteID
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	site, err := app.siteContentService.GetSiteInfo(siteRef, r.Host)
+	if err != nil {
+		app.publicSiteError(w, err, siteRef)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if segments[0] == "assets" && len(segments) >= 3 {
+		file, err := app.themeEngine.AssetPath(segments[1], strings.Join(segments[2:], "/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		http.ServeFile(w, r, file)
+		return
+	}
+
+	theme := app.themeEngine.Resolve(site.Template)
+
+	// Pages are addressed by URL alone, so the locale comes from the path or
+	// ?locale= rather than Accept-Language
+	siteLocales, err := app.siteContentService.SiteLocales(site.ID)
+	if err != nil {
+		app.publicSiteError(w, err, site.ID)
+		return
+	}
+	locales := siteLocales.Chain(r.URL.Query().Get("locale"))
+	pageNumber := 1
+	if n, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && n > 1 {
+		pageNumber = n
+	}
+
+	// Only the query parameters a page reads are part of the key, so made-up
+	// query strings can't push real pages out of the cache
+	cacheKey := services.PageCacheKey(site.ID, theme, r.Host, basePath, path,
+		strings.Join(locales, ","), strconv.Itoa(pageNumber))
+	if cached, ok := app.pageCache.Get(cacheKey); ok {
+		w.Header().Set("X-Cache", "HIT")
+		servePublicPage(w, r, cached)
+		return
+	}
+
+	page := &publicPage{
+		Site:        site,
+		Theme:       theme,
+		Title:       site.Name,
+		Description: site.Description,
+		URL:         site.BaseURL + path,
+		BasePath:    basePath,
+		Locale:      site.DefaultLocale,
+		Page:        1,
+		Now:         time.Now(),
+	}
+
+	var slug string
+	switch {
+	case path == "/":
+		page.Kind = services.ThemePageIndex
+	case len(segments) == 2 && segments[0] == "posts":
+		page.Kind, slug = services.ThemePagePost, segments[1]
+	case len(segments) == 3 && segments[1] == "posts" && containsLocale(siteLocales.Supported, segments[0]):
+		page.Kind, slug = services.ThemePagePost, segments[2]
+		locales = siteLocales.Chain(segments[0])
+	case len(segments) == 2 && segments[0] == "tags":
+		page.Kind, slug = services.ThemePageTag, segments[1]
+	case app.themeEngine.HasPage(theme, services.ThemePagePage):
+		page.Kind = services.ThemePagePage
+	default:
+		app.publicNotFound(w, r, page, path)
+		return
+	}
+
+	var lastModified time.Time
+	if page.Kind == services.ThemePagePage {
+		sitePage, err := app.siteContentService.GetPageByPath(site.ID, path, true)
+		if err != nil {
+			if strings.HasSuffix(err.Error(), "not found") {
+				app.publicNotFound(w, r, page, path)
+			} else {
+				app.publicSiteError(w, err, site.ID)
+			}
+			return
+		}
+		if len(sitePage.Blocks) > 0 {
+			app.siteContentService.RenderPageBlocks(sitePage)
+		} else {
+			sitePage.Content = services.SanitizeHTML(sitePage.Content)
+		}
+		page.SitePage, page.Title = sitePage, sitePage.Title+" – "+site.Name
+		page.URL = site.BaseURL + services.PagePath(sitePage.Path)
+		lastModified = sitePage.UpdatedAt
+	} else if page.Kind == services.ThemePagePost {
+		post, err := app.posts.GetBySlug(slug, site.ID, locales)
+		if err == sql.ErrNoRows || (err == nil && !post.Published) {
+			app.publicNotFound(w, r, page, path)
+			return
+		}
+		if err != nil {
+			app.publicSiteError(w, err, site.ID)
+			return
+		}
+		app.renderPost(post)
+		app.attachTerms(post)
+		app.attachAlternates(post)
+		page.Post, page.Title, page.Locale = post, post.Title, post.Locale
+		page.URL = site.BaseURL + services.LocalizedPostPath(post.Slug, post.Locale, site.DefaultLocale)
+		if post.Rendered != nil && post.Rendered.Excerpt != "" {
+			page.Description = post.Rendered.Excerpt
+		}
+		lastModified = post.UpdatedAt
+	} else {
+		var filter services.PostTermFilter
+		if page.Kind == services.ThemePageTag {
+			tag, err := app.siteContentService.GetTerm(site.ID, services.TaxonomyTag, slug)
+			if err != nil {
+				app.publicNotFound(w, r, page, path)
+				return
+			}
+			filter.Tags = []string{tag.Slug}
+			page.Tag, page.Title = tag, tag.Name+" – "+site.Name
+			if tag.Description != "" {
+				page.Description = tag.Description
+			}
+		}
+		page.Page = pageNumber
+
+		posts, err := app.posts.GetPublished(publicPostsPerPage, (page.Page-1)*publicPostsPerPage, site.ID, filter, locales)
+		if err != nil {
+			app.publicSiteError(w, err, site.ID)
+			return
+		}
+		total, err := app.posts.CountPublished(site.ID, filter, locales)
+		if err != nil {
+			app.publicSiteError(w, err, site.ID)
+			return
+		}
+		if page.Page > 1 && len(posts) == 0 {
+			app.publicNotFound(w, r, page, path)
+			return
+		}
+
+		page.Posts = make([]*Post, len(posts))
+		for i := range posts {
+			page.Posts[i] = &posts[i]
+			app.renderPost(page.Posts[i])
+			if posts[i].UpdatedAt.After(lastModified) {
+				lastModified = posts[i].UpdatedAt
+			}
+		}
+		app.attachTerms(page.Posts...)
+
+		page.TotalPages = (total + publicPostsPerPage - 1) / publicPostsPerPage
+		if page.Page > 1 {
+			page.PrevURL = basePath + path
+			if page.Page > 2 {
+				page.PrevURL += "?page=" + strconv.Itoa(page.Page-1)
+			}
+		}
+		if page.Page < page.TotalPages {
+			page.NextURL = basePath + path + "?page=" + strconv.Itoa(page.Page+1)
+		}
+	}
+
+	body, err := app.themeEngine.Render(theme, page.Kind, page)
+	if err != nil {
+		app.publicSiteError(w, err, site.ID)
+		return
+	}
+	rendered := &services.CachedPage{
+		Body:         body,
+		ContentType:  "text/html; charset=utf-8",
+		ContentLang:  page.Locale,
+		LastModified: lastModified,
+	}
+	app.pageCache.Set(cacheKey, rendered)
+	w.Header().Set("X-Cache", "MISS")
+	servePublicPage(w, r, rendered)
+}
+
+// publicNotFound follows the site's redirect rules and old post slugs for
+// an unknown path, and otherwise renders the theme's not_found page
+func (app *App) publicNotFound(w http.ResponseWriter, r *http.Request, page *publicPage, path string) {
+	if match, err := app.siteContentService.ResolveRedirect(page.Site.ID, path); err == nil {
+		target := match.Target
+		if strings.HasPrefix(target, "/") {
+			target = page.BasePath + target
+		}
+		http.Redirect(w, r, target, match.StatusCode)
+		return
+	}
+
+	if !app.themeEngine.HasPage(page.Theme, services.ThemePageNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	page.Kind, page.Title = services.ThemePageNotFound, "Page not found – "+page.Site.Name
+	body, err := app.themeEngine.Render(page.Theme, page.Kind, page)
+	if err != nil {
+		app.publicSiteError(w, err, page.Site.ID)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(body)
+}
+
+// exportFetch renders a site path for the static exporter through the
+// handlers that serve the site live, as a request to the site's own domain
+func (app *App) exportFetch(site *services.SiteInfo, path string) (*services.StaticResponse, error) {
+	target, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(site.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]string{"siteSlug": site.Slug}
+	var handler http.HandlerFunc
+	switch name := strings.TrimPrefix(target.Path, "/"); {
+	case name == "feed.xml":
+		handler = app.feedHandler("rss")
+	case name == "atom.xml":
+		handler = app.feedHandler("atom")
+	case name == "feed.json":
+		handler = app.feedHandler("json")
+	case name == "sitemap.xml":
+		handler = app.sitemapHandler
+	case strings.HasPrefix(name, "sitemap-") && strings.HasSuffix(name, ".xml"):
+		vars["n"] = strings.TrimSuffix(strings.TrimPrefix(name, "sitemap-"), ".xml")
+		handler = app.sitemapPageHandler
+	default:
+		target.Path = "/s/" + site.Slug + target.Path
+		handler = app.publicSiteHandler
+	}
+
+	r, err := http.NewRequest("GET", target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Host = base.Host
+	r.Header.Set("X-Forwarded-Proto", base.Scheme)
+	recorder := httptest.NewRecorder()
+	handler(recorder, mux.SetURLVars(r, vars))
+
+	return &services.StaticResponse{
+		Status:      recorder.Code,
+		ContentType: recorder.Header().Get("Content-Type"),
+		Body:        recorder.Body.Bytes(),
+		Location:    recorder.Header().Get("Location"),
+	}, nil
+}
+
+// runExportCommand writes a site out as static files instead of starting
+// the server:
+//
+//	agoat-publisher export -site <slug> -out <dir> [-archive site.tar.gz] [-base-url URL] [-full]
+//
+// Exporting into the directory of an earlier export only renders what
+// changed since.
+func (app *App) runExportCommand(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	siteRef := flags.String("site", "", "slug or ID of the site to export")
+	outputDir := flags.String("out", "", "directory to write the site to; an earlier export there is updated")
+	archive := flags.String("archive", "", "also pack the export into this .tar.gz")
+	baseURL := flags.String("base-url", "", "CDN URL or path prefix the export is served from (default: relative links)")
+	host := flags.String("host", "localhost", "host for the site's URLs when it has no domain")
+	full := flags.Bool("full", false, "render every page instead of reusing unchanged ones")
+	jsonReport := flags.Bool("json", false, "print the export report as JSON")
+	flags.Parse(args)
+
+	if *siteRef == "" || *outputDir == "" {
+		fmt.Fprintln(os.Stderr, "export requires -site and -out")
+		flags.Usage()
+		return 2
+	}
+
+	report, err := app.staticExportService.Export(*siteRef, services.StaticExportOptions{
+		OutputDir:   *outputDir,
+		ArchivePath: *archive,
+		SiteHost:    *host,
+		BaseURL:     *baseURL,
+		Full:        *full,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *jsonReport {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+		return 0
+	}
+	fmt.Printf("Exported %d files to %s: %d rendered, %d reused, %d written, %d unchanged, %d removed, %d media files copied\n",
+		report.Files, *outputDir, report.Rendered, report.Reused, report.Written, report.Unchanged, report.Removed, report.MediaCopied)
+	for _, missing := range report.Missing {
+		fmt.Printf("  not found: %s\n", missing)
+	}
+	return 0
+}
+
+// runImportCommand imports a WordPress export or a folder of Markdown posts
+// into a site instead of starting the server:
+//
+//	agoat-publisher import -site <slug> -format wxr|markdown -user <id> [-dry-run] [-force] <path>
+//
+// The path is a WXR file, a Markdown folder, or a zip or tar.gz of either.
+// Running an import again only updates what changed at the source.
+func (app *App) runImportCommand(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	siteRef := flags.String("site", "", "slug or ID of the site to import into")
+	format := flags.String("format", services.ImportFormatWXR, "source format: wxr or markdown")
+	userID := flags.String("user", "", "ID of the user the import runs as; authors posts with no known author")
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without changing anything")
+	force := flags.Bool("force", false, "overwrite posts edited since an earlier import")
+	mediaDir := flags.String("media-dir", "", "copy of wp-content/uploads to read WordPress attachments from")
+	download := flags.Bool("download-media", false, "download WordPress attachments missing from -media-dir from the old site")
+	jsonReport := flags.Bool("json", false, "print the import report as JSON")
+	flags.Parse(args)
+
+	if *siteRef == "" || *userID == "" || flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "import requires -site, -user and the path to import")
+		flags.Usage()
+		return 2
+	}
+
+	workDir, err := os.MkdirTemp("", "agoat-import-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(workDir)
+
+	source, err := services.LoadImportSource(*format, flags.Arg(0), *mediaDir, workDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	report, err := app.importService.Import(*siteRef, source, services.ImportOptions{
+		UserID:        *userID,
+		DryRun:        *dryRun,
+		Force:         *force,
+		DownloadMedia: *download,
+		UserAgent:     "agoat-publisher import",
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *jsonReport {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		if report.DryRun {
+			fmt.Println("Dry run; nothing was changed")
+		}
+		for _, note := range report.Notes {
+			fmt.Println("Note: " + note)
+		}
+		for _, itemType := range []string{services.ImportTypeMedia, services.ImportTypeAuthor, services.ImportTypeCategory,
+			services.ImportTypeTag, services.ImportTypePost, services.ImportTypeRedirect} {
+			counts := report.Summary[itemType]
+			if len(counts) == 0 {
+				continue
+			}
+			fmt.Printf("%-9s %d created, %d updated, %d unchanged, %d conflicts, %d skipped, %d errors\n", itemType+":",
+				counts[services.ImportCreate], counts[services.ImportUpdate], counts[services.ImportUnchanged],
+				counts[services.ImportConflict], counts[services.ImportSkip], counts[services.ImportError])
+		}
+		for _, item := range report.Items {
+			if item.Action == services.ImportError {
+				fmt.Printf("  error: %s %s: %s\n", item.Type, item.Source, item.Error)
+			} else if len(item.Notes) > 0 && item.Action != services.ImportUnchanged {
+				fmt.Printf("  %s: %s %s: %s\n", item.Action, item.Type, item.Source, strings.Join(item.Notes, "; "))
+			}
+		}
+	}
+	if report.ErrorCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runCatalogCommand imports or exports the Thorne product catalog instead of
+// starting the server:
+//
+//	agoat-publisher catalog import [-format csv|json] [-type products|categories] [-dry-run] [-json] <file|->
+//	agoat-publisher catalog export [-format csv|json] [-type products|categories]
+//
+// Imports are validated row by row; nothing is written unless every row is
+// valid, and the exit status is 1 when validation fails.
+func (app *App) runCatalogCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "import" && args[0] != "export") {
+		fmt.Fprintln(os.Stderr, "usage: catalog import [-format csv|json] [-type products|categories] [-dry-run] [-json] <file|->")
+		fmt.Fprintln(os.Stderr, "       catalog export [-format csv|json] [-type products|categories]")
+		return 2
+	}
+
+	command := args[0]
+	flags := flag.NewFlagSet("catalog "+command, flag.ExitOnError)
+	format := flags.String("format", "", "csv or json (defaults to the file extension, then json)")
+	recordType := flags.String("type", services.CatalogTypeProducts, "products or categories (CSV only)")
+	dryRun := flags.Bool("dry-run", false, "validate and report without writing")
+	jsonReport := flags.Bool("json", false, "print the full import report as JSON")
+	flags.Parse(args[1:])
+
+	if command == "export" {
+		if *format == "" {
+			*format = services.CatalogFormatJSON
+		}
+		// JSON exports carry both record types unless -type was given
+		exportType := *recordType
+		if *format == services.CatalogFormatJSON {
+			exportType = ""
+			flags.Visit(func(f *flag.Flag) {
+				if f.Name == "type" {
+					exportType = *recordType
+				}
+			})
+		}
+		data, err := app.thorneService.ExportCatalog(exportType)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := services.WriteCatalog(os.Stdout, data, *format, *recordType); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "catalog import requires a file argument (use - for stdin)")
+		flags.Usage()
+		return 2
+	}
+	path := flags.Arg(0)
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+	if *format == "" {
+		*format = services.CatalogFormatJSON
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			*format = services.CatalogFormatCSV
+		}
+	}
+
+	data, err := services.ParseCatalog(input, *format, *recordType)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	report, err := app.thorneService.ImportCatalog(data, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *jsonReport {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		fmt.Print(services.CatalogReportSummary(report))
+	}
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
+
+func (app *App) publicSiteError(w http.ResponseWriter, err error, siteRef string) {
+	if strings.HasSuffix(err.Error(), "not found") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	errorID := uuid.New().String()
+	app.logger.Error("public_site", "render", "Failed to render page", map[string]interface{}{
+		"context": map[string]interface{}{
+			"site":     siteRef,
+			"error":    err.Error(),
+			"error_id": errorID,
+		},
+	})
+	http.Error(w, "Failed to render page (error "+errorID+")", http.StatusInternalServerError)
+}
+
+// servePublicPage writes a rendered page, answering conditional requests
+// from its ETag and Last-Modified
+func servePublicPage(w http.ResponseWriter, r *http.Request, page *services.CachedPage) {
+	w.Header().Set("Content-Type", page.ContentType)
+	if page.ContentLang != "" {
+		w.Header().Set("Content-Language", page.ContentLang)
+	}
+	w.Header().Set("ETag", page.ETag)
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	http.ServeContent(w, r, "", page.LastModified, bytes.NewReader(page.Body))
+}
+
+// containsLocale reports whether a URL path segment names one of locales
+func containsLocale(locales []string, segment string) bool {
+	for _, locale := range locales {
+		if strings.EqualFold(locale, segment) {
+			return true
 		}
 	}
-	return result.String()
+	return false
 }
 
 // Database initialization
@@ -671,7 +2079,7 @@ func (app *App) sitesHandler(w http.ResponseWriter, r *http.Request) {
 			site.Settings = "{}"
 		}
 		if site.Slug == "" {
-			site.Slug = slugify(site.Name)
+			site.Slug = services.Slugify(site.Name)
 		}
 
 		// For now, use a default customer ID
@@ -729,7 +2137,7 @@ func (app *App) siteHandler(w http.ResponseWriter, r *http.Request) {
 
 		site.ID = id
 		if site.Slug == "" {
-			site.Slug = slugify(site.Name)
+			site.Slug = services.Slugify(site.Name)
 		}
 
 		if err := app.sites.Update(&site); err != nil {
@@ -787,6 +2195,12 @@ func (app *App) postsHandler(w http.ResponseWriter, r *http.Request) {
 		if published := r.URL.Query().Get("published"); published == "true" {
 			publishedOnly = true
 		}
+		// ?tag=a&tag=b and ?category=c narrow the list; a category also
+		// matches posts filed under its subcategories
+		filter := services.PostTermFilter{
+			Tags:       r.URL.Query()["tag"],
+			Categories: r.URL.Query()["category"],
+		}
 
 		app.logger.Info("posts", "list", "Fetching posts", map[string]interface{}{
 			"context": map[string]interface{}{
@@ -794,6 +2208,8 @@ func (app *App) postsHandler(w http.ResponseWriter, r *http.Request) {
 				"page":           page,
 				"per_page":       perPage,
 				"published_only": publishedOnly,
+				"tags":           filter.Tags,
+				"categories":     filter.Categories,
 			},
 		})
 
@@ -803,11 +2219,14 @@ func (app *App) postsHandler(w http.ResponseWriter, r *http.Request) {
 		var total int
 
 		if publishedOnly {
-			posts, err = app.posts.GetPublished(perPage, offset, siteID)
-			total, _ = app.posts.CountPublished(siteID)
+			// Published lists show one variant per translation group in
+			// the reader's language
+			locales := app.localeChain(w, r, siteID)
+			posts, err = app.posts.GetPublished(perPage, offset, siteID, filter, locales)
+			total, _ = app.posts.CountPublished(siteID, filter, locales)
 		} else {
-			posts, err = app.posts.GetAll(perPage, offset, siteID)
-			total, _ = app.posts.Count(siteID)
+			posts, err = app.posts.GetAll(perPage, offset, siteID, filter)
+			total, _ = app.posts.Count(siteID, filter)
 		}
 
 		if err != nil {
@@ -841,6 +2260,15 @@ func (app *App) postsHandler(w http.ResponseWriter, r *http.Request) {
 			},
 		})
 
+		listed := make([]*Post, len(posts))
+		for i := range posts {
+			listed[i] = &posts[i]
+		}
+		app.attachTerms(listed...)
+		if publishedOnly {
+			app.attachAlternates(listed...)
+		}
+
 		totalPages := (total + perPage - 1) / perPage
 		json.NewEncoder(w).Encode(APIResponse{
 			Success: true,
@@ -912,8 +2340,117 @@ func (app *App) postsHandler(w http.ResponseWriter, r *http.Request) {
 		if post.Status == "" {
 			post.Status = "draft"
 		}
+
+		// Posts on editorial workflow sites start as drafts
+		workflowEnabled, err := services.CheckNewPostStatus(app.db, siteID, post.Status)
+		if err != nil {
+			errorID := uuid.New().String()
+			app.logger.Warning("posts", "create", "Status refused by editorial workflow", map[string]interface{}{
+				"context": map[string]interface{}{
+					"site_id":  siteID,
+					"status":   post.Status,
+					"error":    err.Error(),
+					"error_id": errorID,
+				},
+			})
+
+			if strings.HasPrefix(err.Error(), "failed to") {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(generateErrorResponse(TechnicalError{
+					Code:    "DATABASE_ERROR",
+					Message: "Failed to create post",
+					Details: "A technical error occurred while checking the site workflow",
+					ErrorID: errorID,
+				}, errorID))
+				return
+			}
+
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(generateErrorResponse(BusinessError{
+				Code:    "WORKFLOW_REQUIRED",
+				Message: "New posts must start as drafts",
+				Details: err.Error(),
+				ErrorID: errorID,
+			}, errorID))
+			return
+		}
+		if workflowEnabled {
+			post.Published = false
+		}
 		if post.Slug == "" {
-			post.Slug = slugify(post.Title)
+			post.Slug = services.Slugify(post.Title)
+		}
+
+		if err := applySchedule(&post); err != nil {
+			errorID := uuid.New().String()
+			app.logger.Warning("posts", "create", "Invalid schedule provided", map[string]interface{}{
+				"context": map[string]interface{}{
+					"site_id":  siteID,
+					"error":    err.Error(),
+					"error_id": errorID,
+				},
+			})
+
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(generateErrorResponse(BusinessError{
+				Code:    "INVALID_SCHEDULE",
+				Message: "Invalid publishing schedule",
+				Details: err.Error(),
+				ErrorID: errorID,
+			}, errorID))
+			return
+		}
+
+		if err := app.applyContentFormat(&post); err != nil {
+			errorID := uuid.New().String()
+			app.logger.Warning("posts", "create", "Invalid content format or blocks provided", map[string]interface{}{
+				"context": map[string]interface{}{
+					"site_id":  siteID,
+					"error":    err.Error(),
+					"error_id": errorID,
+				},
+			})
+
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(generateErrorResponse(BusinessError{
+				Code:    "INVALID_CONTENT",
+				Message: "Invalid content format or blocks",
+				Details: err.Error(),
+				ErrorID: errorID,
+			}, errorID))
+			return
+		}
+
+		if err := app.applyLocale(&post, true); err != nil {
+			errorID := uuid.New().String()
+			app.logger.Warning("posts", "create", "Invalid locale or translation group provided", map[string]interface{}{
+				"context": map[string]interface{}{
+					"site_id":  siteID,
+					"locale":   post.Locale,
+					"error":    err.Error(),
+					"error_id": errorID,
+				},
+			})
+
+			if strings.HasPrefix(err.Error(), "failed to") {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(generateErrorResponse(TechnicalError{
+					Code:    "DATABASE_ERROR",
+					Message: "Failed to create post",
+					Details: "A technical error occurred while checking the post locale",
+					ErrorID: errorID,
+				}, errorID))
+				return
+			}
+
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(generateErrorResponse(BusinessError{
+				Code:    "INVALID_LOCALE",
+				Message: "Invalid locale or translation group",
+				Details: err.Error(),
+				ErrorID: errorID,
+			}, errorID))
+			return
 		}
 
 		if err := app.posts.Create(&post); err != nil {
@@ -948,6 +2485,8 @@ func (app *App) postsHandler(w http.ResponseWriter, r *http.Request) {
 			},
 		})
 
+		app.renderPost(&post)
+
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(APIResponse{
 			Success: true,
@@ -984,6 +2523,9 @@ func (app *App) postHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		app.renderPost(post)
+		app.attachTerms(post)
+
 		json.NewEncoder(w).Encode(APIResponse{
 			Success: true,
 			Data:    post,
@@ -1014,7 +2556,47 @@ func (app *App) postHandler(w http.ResponseWriter, r *http.Request) {
 		post.ID = id
 		post.SiteID = siteID
 		if post.Slug == "" {
-			post.Slug = slugify(post.Title)
+			post.Slug = services.Slugify(post.Title)
+		}
+
+		// On editorial workflow sites status only changes through transitions;
+		// an omitted status keeps the current one
+		status, workflowEnabled, err := services.CheckStatusChange(app.db, id, post.Status)
+		if err != nil {
+			errorID := uuid.New().String()
+			app.logger.Warning("posts", "update", "Status change refused by editorial workflow", map[string]interface{}{
+				"context": map[string]interface{}{
+					"post_id":  id,
+					"site_id":  siteID,
+					"status":   post.Status,
+					"error":    err.Error(),
+					"error_id": errorID,
+				},
+			})
+
+			if strings.HasPrefix(err.Error(), "failed to") {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(generateErrorResponse(TechnicalError{
+					Code:    "DATABASE_ERROR",
+					Message: "Failed to update post",
+					Details: "A technical error occurred while checking the post status",
+					ErrorID: errorID,
+				}, errorID))
+				return
+			}
+
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(generateErrorResponse(BusinessError{
+				Code:    "WORKFLOW_REQUIRED",
+				Message: "Status change requires a workflow transition",
+				Details: err.Error(),
+				ErrorID: errorID,
+			}, errorID))
+			return
+		}
+		post.Status = status
+		if workflowEnabled {
+			post.Published = post.Status == "published"
 		}
 
 		// Default and validate status to satisfy DB CHECK constraint
@@ -1036,13 +2618,97 @@ func (app *App) postHandler(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(generateErrorResponse(BusinessError{
 				Code:    "INVALID_STATUS",
 				Message: "Invalid post status",
-				Details: "Status must be one of: draft, published, archived, deleted",
+				Details: "Status must be one of: draft, in_review, approved, published, archived, deleted",
+				ErrorID: errorID,
+			}, errorID))
+			return
+		}
+
+		if err := applySchedule(&post); err != nil {
+			errorID := uuid.New().String()
+			app.logger.Warning("posts", "update", "Invalid schedule provided", map[string]interface{}{
+				"context": map[string]interface{}{
+					"post_id":  id,
+					"site_id":  siteID,
+					"error":    err.Error(),
+					"error_id": errorID,
+				},
+			})
+
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(generateErrorResponse(BusinessError{
+				Code:    "INVALID_SCHEDULE",
+				Message: "Invalid publishing schedule",
+				Details: err.Error(),
+				ErrorID: errorID,
+			}, errorID))
+			return
+		}
+
+		if err := app.applyContentFormat(&post); err != nil {
+			errorID := uuid.New().String()
+			app.logger.Warning("posts", "update", "Invalid content format or blocks provided", map[string]interface{}{
+				"context": map[string]interface{}{
+					"post_id":  id,
+					"site_id":  siteID,
+					"error":    err.Error(),
+					"error_id": errorID,
+				},
+			})
+
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(generateErrorResponse(BusinessError{
+				Code:    "INVALID_CONTENT",
+				Message: "Invalid content format or blocks",
+				Details: err.Error(),
+				ErrorID: errorID,
+			}, errorID))
+			return
+		}
+
+		if err := app.applyLocale(&post, false); err != nil {
+			errorID := uuid.New().String()
+			app.logger.Warning("posts", "update", "Invalid locale provided", map[string]interface{}{
+				"context": map[string]interface{}{
+					"post_id":  id,
+					"site_id":  siteID,
+					"locale":   post.Locale,
+					"error":    err.Error(),
+					"error_id": errorID,
+				},
+			})
+
+			if strings.HasPrefix(err.Error(), "failed to") {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(generateErrorResponse(TechnicalError{
+					Code:    "DATABASE_ERROR",
+					Message: "Failed to update post",
+					Details: "A technical error occurred while checking the post locale",
+					ErrorID: errorID,
+				}, errorID))
+				return
+			}
+
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(generateErrorResponse(BusinessError{
+				Code:    "INVALID_LOCALE",
+				Message: "Invalid locale",
+				Details: err.Error(),
 				ErrorID: errorID,
 			}, errorID))
 			return
 		}
 
-		if err := app.posts.Update(&post); err != nil {
+		if err := app.posts.Update(&post, r.Header.Get("X-User-ID")); err != nil {
+			if err == sql.ErrNoRows {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(APIResponse{
+					Success: false,
+					Error:   "Post not found",
+				})
+				return
+			}
+
 			errorID := uuid.New().String()
 			app.logger.Error("posts", "update", "Failed to update post in database", map[string]interface{}{
 				"context": map[string]interface{}{
@@ -1064,13 +2730,27 @@ func (app *App) postHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		app.renderPost(&post)
+		app.attachTerms(&post)
+
 		json.NewEncoder(w).Encode(APIResponse{
 			Success: true,
 			Data:    post,
 		})
 
 	case "DELETE":
-		if err := app.posts.Delete(id); err != nil {
+		// Deleting moves the post to the trash; it can be restored until purged
+		post, err := app.posts.GetByID(id)
+		if err != nil || post.SiteID != siteID {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Error:   "Post not found",
+			})
+			return
+		}
+
+		if err := app.contentService.DeleteContent(id, siteID); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(APIResponse{
 				Success: false,
@@ -1081,9 +2761,42 @@ func (app *App) postHandler(w http.ResponseWriter, r *http.Request) {
 
 		json.NewEncoder(w).Encode(APIResponse{
 			Success: true,
-			Data:    "Post deleted successfully",
+			Data:    "Post moved to trash",
+		})
+	}
+}
+
+// scheduledPostsHandler lists the site's publish/unpublish queue
+func (app *App) scheduledPostsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+	siteID := vars["siteId"]
+
+	posts, err := app.posts.GetScheduled(siteID)
+	if err != nil {
+		errorID := uuid.New().String()
+		app.logger.Error("posts", "scheduled", "Failed to fetch scheduled posts", map[string]interface{}{
+			"context": map[string]interface{}{
+				"site_id":  siteID,
+				"error":    err.Error(),
+				"error_id": errorID,
+			},
 		})
+
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(generateErrorResponse(TechnicalError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to fetch scheduled posts",
+			Details: "A technical error occurred while retrieving the publishing queue",
+			ErrorID: errorID,
+		}, errorID))
+		return
 	}
+
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    posts,
+	})
 }
 
 func (app *App) postBySlugHandler(w http.ResponseWriter, r *http.Request) {
@@ -1092,7 +2805,31 @@ func (app *App) postBySlugHandler(w http.ResponseWriter, r *http.Request) {
 	slug := vars["slug"]
 	siteID := vars["siteId"]
 
-	post, err := app.posts.GetBySlug(slug, siteID)
+	post, err := app.posts.GetBySlug(slug, siteID, app.localeChain(w, r, siteID))
+	if err == sql.ErrNoRows {
+		// An old slug answers with a permanent redirect to the post's
+		// current one
+		if current, lookupErr := app.siteContentService.ResolvePostSlug(siteID, slug); lookupErr == nil {
+			app.logger.Info("posts", "get_by_slug", "Redirecting old slug", map[string]interface{}{
+				"context": map[string]interface{}{
+					"slug":     slug,
+					"site_id":  siteID,
+					"new_slug": current,
+				},
+			})
+
+			w.Header().Set("Location", "/api/sites/"+url.PathEscape(siteID)+"/posts/slug/"+url.PathEscape(current))
+			w.WriteHeader(http.StatusMovedPermanently)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: true,
+				Data: map[string]string{
+					"slug":     current,
+					"old_slug": slug,
+				},
+			})
+			return
+		}
+	}
 	if err != nil {
 		errorID := uuid.New().String()
 		app.logger.Warning("posts", "get_by_slug", "Post not found", map[string]interface{}{
@@ -1118,15 +2855,120 @@ func (app *App) postBySlugHandler(w http.ResponseWriter, r *http.Request) {
 			"slug":    slug,
 			"site_id": siteID,
 			"post_id": post.ID,
+			"locale":  post.Locale,
 		},
 	})
 
+	app.renderPost(post)
+	app.attachTerms(post)
+	app.attachAlternates(post)
+	w.Header().Set("Content-Language", post.Locale)
+
 	json.NewEncoder(w).Encode(APIResponse{
 		Success: true,
 		Data:    post,
 	})
 }
 
+// postPreview is what a preview link shows: the post as saved, whatever its
+// status, and the editor's autosaved changes that haven't been saved yet
+type postPreview struct {
+	Post              *Post                  `json:"post"`
+	Autosave          *services.PostAutosave `json:"autosave,omitempty"`
+	HasUnsavedChanges bool                   `json:"has_unsaved_changes"`
+	ExpiresAt         time.Time              `json:"expires_at"`
+}
+
+// previewPostHandler serves GET /api/sites/{siteId}/posts/{id}/preview and
+// /api/sites/{siteId}/posts/slug/{slug}/preview to holders of a preview
+// token, passed as ?token= or the X-Preview-Token header
+func (app *App) previewPostHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	vars := mux.Vars(r)
+	siteID := vars["siteId"]
+
+	raw := r.URL.Query().Get("token")
+	if raw == "" {
+		raw = r.Header.Get("X-Preview-Token")
+	}
+
+	var post *Post
+	var err error
+	if slug, ok := vars["slug"]; ok {
+		post, err = app.posts.GetBySlug(slug, siteID, nil)
+	} else {
+		post, err = app.posts.GetByID(vars["id"])
+	}
+	if err != nil || post.SiteID != siteID {
+		// Without a valid token a missing post looks the same as a refused
+		// one, so links can't be used to probe for drafts
+		if _, tokenErr := app.previewService.Authorize(raw, siteID, ""); tokenErr != nil {
+			err = tokenErr
+		} else {
+			err = fmt.Errorf("post not found")
+		}
+	}
+
+	var token *services.PreviewToken
+	if err == nil {
+		token, err = app.previewService.Authorize(raw, siteID, post.ID)
+	}
+	if err != nil {
+		errorID := uuid.New().String()
+		app.logger.Warning("posts", "preview", "Preview refused", map[string]interface{}{
+			"context": map[string]interface{}{
+				"site_id":  siteID,
+				"reason":   err.Error(),
+				"error_id": errorID,
+			},
+		})
+
+		status, code := http.StatusUnauthorized, "PREVIEW_TOKEN_INVALID"
+		switch {
+		case err.Error() == "post not found":
+			status, code = http.StatusNotFound, "POST_NOT_FOUND"
+		case strings.HasSuffix(err.Error(), "does not cover this post"):
+			status, code = http.StatusForbidden, "PREVIEW_TOKEN_SCOPE"
+		case !strings.HasPrefix(err.Error(), "preview token"):
+			status, code = http.StatusInternalServerError, "DATABASE_ERROR"
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(generateErrorResponse(BusinessError{
+			Code:    code,
+			Message: "Preview unavailable",
+			Details: err.Error(),
+			ErrorID: errorID,
+		}, errorID))
+		return
+	}
+
+	app.previewService.RecordUse(token, post.ID, r.RemoteAddr, r.UserAgent())
+	app.logger.Info("posts", "preview", "Post previewed", map[string]interface{}{
+		"context": map[string]interface{}{
+			"site_id":  siteID,
+			"post_id":  post.ID,
+			"token_id": token.ID,
+		},
+	})
+
+	preview := &postPreview{Post: post, ExpiresAt: token.ExpiresAt}
+	if autosave, err := app.revisionService.GetAutosave(siteID, post.ID); err == nil {
+		preview.Autosave = autosave
+		preview.HasUnsavedChanges = autosave.Title != post.Title || autosave.Content != post.Content ||
+			autosave.Slug != post.Slug
+	}
+
+	app.renderPost(post)
+	app.attachTerms(post)
+
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    preview,
+	})
+}
+
 func (app *App) loginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		app.logger.Warning("auth", "login", "Invalid HTTP method", map[string]interface{}{
@@ -1251,6 +3093,22 @@ func main() {
 	}
 	defer app.db.Close()
 
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		code := app.runExportCommand(os.Args[2:])
+		app.db.Close()
+		os.Exit(code)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		code := app.runImportCommand(os.Args[2:])
+		app.db.Close()
+		os.Exit(code)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "catalog" {
+		code := app.runCatalogCommand(os.Args[2:])
+		app.db.Close()
+		os.Exit(code)
+	}
+
 	// Log startup information
 	app.logger.Info("main", "startup", "Server starting", map[string]interface{}{
 		"context": map[string]interface{}{
@@ -1260,6 +3118,22 @@ func main() {
 		},
 	})
 
+	// Publish and unpublish scheduled posts; PUBLISH_SCHEDULER_INTERVAL=0 disables
+	schedulerInterval := 60 * time.Second
+	if value := os.Getenv("PUBLISH_SCHEDULER_INTERVAL"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			schedulerInterval = time.Duration(seconds) * time.Second
+		}
+	}
+	if schedulerInterval > 0 {
+		scheduler := services.NewPublishScheduler(app.db, app.logger, schedulerInterval)
+		scheduler.SetPageCache(app.pageCache)
+		go scheduler.Run(context.Background())
+	}
+
+	go app.contentService.RunTrashPurge(context.Background(), time.Hour)
+	go app.mediaService.RunUploadPurge(context.Background(), time.Hour)
+
 	router := mux.NewRouter()
 
 	// API routes
@@ -1269,8 +3143,9 @@ func main() {
 	api.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-User-ID, X-User-Role")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-User-ID, X-User-Role, Upload-Offset, X-Preview-Token")
+			w.Header().Set("Access-Control-Expose-Headers", "Upload-Offset, Upload-Length, Upload-Expires")
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -1297,8 +3172,139 @@ func main() {
 	api.HandleFunc("/sites", app.sitesHandler).Methods("GET", "POST")
 	api.HandleFunc("/sites/{id}", app.siteHandler).Methods("GET", "PUT", "DELETE")
 	api.HandleFunc("/sites/{siteId}/posts", app.postsHandler).Methods("GET", "POST")
-	api.HandleFunc("/sites/{siteId}/posts/{id}", app.postHandler).Methods("GET", "PUT", "DELETE")
+	api.HandleFunc("/sites/{siteId}/posts/scheduled", app.scheduledPostsHandler).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/search", app.searchHandlers.SearchPosts).Methods("GET")
+	// Slug routes come before every /posts/{id}/... route, which would
+	// otherwise take a post slugged "comments" or "revisions" as an id
 	api.HandleFunc("/sites/{siteId}/posts/slug/{slug}", app.postBySlugHandler).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/slug/{slug}/preview", app.previewPostHandler).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}", app.postHandler).Methods("GET", "PUT", "DELETE")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/terms", app.contentHandlers.GetPostTerms).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/terms", app.contentHandlers.SetPostTerms).Methods("PUT")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/translations", app.contentHandlers.ListPostTranslations).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/comments", app.commentHandlers.ListComments).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/comments", app.commentHandlers.CreateComment).Methods("POST")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/slugs", app.contentHandlers.GetPostSlugHistory).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/revisions", app.revisionHandlers.ListRevisions).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/revisions/diff", app.revisionHandlers.DiffRevisions).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/revisions/{revisionId}", app.revisionHandlers.GetRevision).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/revisions/{revisionId}/restore", app.revisionHandlers.RestoreRevision).Methods("POST")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/autosave", app.revisionHandlers.GetAutosave).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/autosave", app.revisionHandlers.SaveAutosave).Methods("PUT")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/autosave", app.revisionHandlers.DiscardAutosave).Methods("DELETE")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/preview", app.previewPostHandler).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/preview-tokens", app.previewHandlers.ListTokens).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/preview-tokens", app.previewHandlers.CreateToken).Methods("POST")
+	api.HandleFunc("/sites/{siteId}/preview-tokens/{id}", app.previewHandlers.RevokeToken).Methods("DELETE")
+
+	// Editorial workflow routes
+	api.HandleFunc("/sites/{siteId}/posts/{id}/workflow", app.workflowHandlers.GetPostWorkflow).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/transitions", app.workflowHandlers.TransitionPost).Methods("POST")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/reviewers", app.workflowHandlers.ListReviewers).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/reviewers", app.workflowHandlers.AssignReviewer).Methods("POST")
+	api.HandleFunc("/sites/{siteId}/posts/{id}/reviewers/{userId}", app.workflowHandlers.UnassignReviewer).Methods("DELETE")
+	api.HandleFunc("/admin/sites/{siteSlug}/workflow", app.workflowHandlers.AdminGetWorkflow).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/workflow", app.workflowHandlers.AdminUpdateWorkflow).Methods("PUT")
+	api.HandleFunc("/admin/sites/{siteSlug}/search/reindex", app.searchHandlers.AdminReindex).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/comments", app.commentHandlers.AdminListComments).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/comments/{id}", app.commentHandlers.AdminModerateComment).Methods("PUT")
+	api.HandleFunc("/admin/sites/{siteSlug}/comments/{id}", app.commentHandlers.AdminDeleteComment).Methods("DELETE")
+	api.HandleFunc("/themes", app.themeHandlers.ListThemes).Methods("GET")
+	api.HandleFunc("/admin/themes/reload", app.themeHandlers.AdminReloadThemes).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/theme", app.themeHandlers.AdminSetSiteTheme).Methods("PUT")
+	api.HandleFunc("/admin/sites/{siteSlug}/cache/purge", app.themeHandlers.AdminPurgeSiteCache).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/exports", app.exportHandlers.AdminStartExport).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/exports", app.exportHandlers.AdminListExports).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/exports/{id}", app.exportHandlers.AdminGetExport).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/exports/{id}/archive", app.exportHandlers.AdminDownloadExport).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/import", app.importHandlers.AdminImport).Methods("POST")
+	api.HandleFunc("/notifications", app.workflowHandlers.ListNotifications).Methods("GET")
+	api.HandleFunc("/notifications/{id}/read", app.workflowHandlers.MarkNotificationRead).Methods("POST")
+	api.HandleFunc("/sites/{siteId}/trash", app.contentHandlers.ListTrash).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/trash/{id}/restore", app.contentHandlers.RestoreContent).Methods("POST")
+	api.HandleFunc("/sites/{siteId}/trash/{id}", app.contentHandlers.PurgeContent).Methods("DELETE")
+
+	// Media library
+	api.HandleFunc("/sites/{siteId}/media", app.mediaHandlers.ListMedia).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/media", app.mediaHandlers.UploadMedia).Methods("POST")
+	api.HandleFunc("/sites/{siteId}/media/usage", app.mediaHandlers.GetMediaUsage).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/media/uploads", app.mediaHandlers.CreateUpload).Methods("POST")
+	api.HandleFunc("/sites/{siteId}/media/uploads/{id}", app.mediaHandlers.GetUpload).Methods("GET", "HEAD")
+	api.HandleFunc("/sites/{siteId}/media/uploads/{id}", app.mediaHandlers.AppendUpload).Methods("PATCH")
+	api.HandleFunc("/sites/{siteId}/media/uploads/{id}", app.mediaHandlers.AbortUpload).Methods("DELETE")
+	api.HandleFunc("/sites/{siteId}/media/uploads/{id}/complete", app.mediaHandlers.CompleteUpload).Methods("POST")
+	api.HandleFunc("/sites/{siteId}/media/{id}", app.mediaHandlers.GetMedia).Methods("GET")
+	api.HandleFunc("/sites/{siteId}/media/{id}", app.mediaHandlers.UpdateMedia).Methods("PUT")
+	api.HandleFunc("/sites/{siteId}/media/{id}", app.mediaHandlers.DeleteMedia).Methods("DELETE")
+
+	// Content API
+	api.HandleFunc("/content", app.contentHandlers.ListContent).Methods("GET")
+	api.HandleFunc("/content", app.contentHandlers.CreateContent).Methods("POST")
+	api.HandleFunc("/content/slug/{slug}", app.contentHandlers.GetContentBySlug).Methods("GET")
+	api.HandleFunc("/content/{id}", app.contentHandlers.GetContent).Methods("GET")
+	api.HandleFunc("/content/{id}", app.contentHandlers.UpdateContent).Methods("PUT")
+	api.HandleFunc("/content/{id}", app.contentHandlers.DeleteContent).Methods("DELETE")
+
+	// Site-scoped content addressed by site slug
+	api.HandleFunc("/sites/{siteSlug}/pages", app.contentHandlers.ListSitePages).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/pages/{pagePath:.+}", app.contentHandlers.GetSitePage).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/components/{type}", app.contentHandlers.GetSiteComponents).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/data/{collection}", app.contentHandlers.GetSiteDataItems).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/data/{collection}", app.contentHandlers.CreateSiteDataItem).Methods("POST")
+	api.HandleFunc("/sites/{siteSlug}/data/{collection}/search", app.contentHandlers.SearchSiteDataItems).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/data/{collection}/{id}", app.contentHandlers.GetSiteDataItem).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/data/{collection}/{id}", app.contentHandlers.UpdateSiteDataItem).Methods("PUT")
+	api.HandleFunc("/sites/{siteSlug}/data/{collection}/{id}", app.contentHandlers.DeleteSiteDataItem).Methods("DELETE")
+	api.HandleFunc("/sites/{siteSlug}/settings", app.contentHandlers.GetSiteSettings).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/locales", app.contentHandlers.GetSiteLocales).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/comments/settings", app.commentHandlers.GetCommentSettings).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/navigation/{type}", app.contentHandlers.GetSiteNavigation).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/feed.xml", app.feedHandler("rss")).Methods("GET", "HEAD")
+	api.HandleFunc("/sites/{siteSlug}/atom.xml", app.feedHandler("atom")).Methods("GET", "HEAD")
+	api.HandleFunc("/sites/{siteSlug}/feed.json", app.feedHandler("json")).Methods("GET", "HEAD")
+	api.HandleFunc("/sites/{siteSlug}/sitemap.xml", app.sitemapHandler).Methods("GET", "HEAD")
+	api.HandleFunc("/sites/{siteSlug}/sitemap-{n:[0-9]+}.xml", app.sitemapPageHandler).Methods("GET", "HEAD")
+	api.HandleFunc("/sites/{siteSlug}/redirects/resolve", app.contentHandlers.ResolveRedirect).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/{taxonomy:tags|categories}", app.contentHandlers.ListTerms).Methods("GET")
+	api.HandleFunc("/sites/{siteSlug}/{taxonomy:tags|categories}/{term}", app.contentHandlers.GetTerm).Methods("GET")
+
+	// Site pages, menus and settings administration
+	api.HandleFunc("/admin/sites/{siteSlug}/pages", app.contentHandlers.AdminListSitePages).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/pages", app.contentHandlers.AdminCreateSitePage).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/pages/{id}", app.contentHandlers.AdminGetSitePage).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/pages/{id}", app.contentHandlers.AdminUpdateSitePage).Methods("PUT")
+	api.HandleFunc("/admin/sites/{siteSlug}/pages/{id}", app.contentHandlers.AdminDeleteSitePage).Methods("DELETE")
+	api.HandleFunc("/admin/sites/{siteSlug}/menus", app.contentHandlers.AdminListMenus).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/menus", app.contentHandlers.AdminCreateMenu).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/menus/{id}", app.contentHandlers.AdminGetMenu).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/menus/{id}", app.contentHandlers.AdminUpdateMenu).Methods("PUT")
+	api.HandleFunc("/admin/sites/{siteSlug}/menus/{id}", app.contentHandlers.AdminDeleteMenu).Methods("DELETE")
+	api.HandleFunc("/admin/sites/{siteSlug}/settings", app.contentHandlers.AdminListSettings).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/settings/{key}", app.contentHandlers.AdminGetSetting).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/settings/{key}", app.contentHandlers.AdminSetSetting).Methods("PUT")
+	api.HandleFunc("/admin/sites/{siteSlug}/settings/{key}", app.contentHandlers.AdminDeleteSetting).Methods("DELETE")
+	api.HandleFunc("/admin/sites/{siteSlug}/collections", app.contentHandlers.AdminListCollections).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/collections", app.contentHandlers.AdminCreateCollection).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/collections/{collection}", app.contentHandlers.AdminGetCollection).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/collections/{collection}", app.contentHandlers.AdminUpdateCollection).Methods("PUT")
+	api.HandleFunc("/admin/sites/{siteSlug}/collections/{collection}", app.contentHandlers.AdminDeleteCollection).Methods("DELETE")
+	api.HandleFunc("/admin/sites/{siteSlug}/components", app.contentHandlers.AdminListComponents).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/components", app.contentHandlers.AdminCreateComponent).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/components/{id}", app.contentHandlers.AdminGetComponent).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/components/{id}", app.contentHandlers.AdminUpdateComponent).Methods("PUT")
+	api.HandleFunc("/admin/sites/{siteSlug}/components/{id}", app.contentHandlers.AdminDeleteComponent).Methods("DELETE")
+	api.HandleFunc("/admin/sites/{siteSlug}/blocks/render", app.contentHandlers.AdminRenderBlocks).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/redirects", app.contentHandlers.AdminListRedirects).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/redirects", app.contentHandlers.AdminCreateRedirect).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/redirects/{id}", app.contentHandlers.AdminGetRedirect).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/redirects/{id}", app.contentHandlers.AdminUpdateRedirect).Methods("PUT")
+	api.HandleFunc("/admin/sites/{siteSlug}/redirects/{id}", app.contentHandlers.AdminDeleteRedirect).Methods("DELETE")
+	api.HandleFunc("/admin/sites/{siteSlug}/{taxonomy:tags|categories}", app.contentHandlers.ListTerms).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/{taxonomy:tags|categories}", app.contentHandlers.AdminCreateTerm).Methods("POST")
+	api.HandleFunc("/admin/sites/{siteSlug}/{taxonomy:tags|categories}/{term}", app.contentHandlers.GetTerm).Methods("GET")
+	api.HandleFunc("/admin/sites/{siteSlug}/{taxonomy:tags|categories}/{term}", app.contentHandlers.AdminUpdateTerm).Methods("PUT")
+	api.HandleFunc("/admin/sites/{siteSlug}/{taxonomy:tags|categories}/{term}", app.contentHandlers.AdminDeleteTerm).Methods("DELETE")
+	api.HandleFunc("/admin/sites/{siteSlug}/{taxonomy:tags|categories}/{term}/merge", app.contentHandlers.AdminMergeTerm).Methods("POST")
 
 	// Thorne API endpoints
 	api.HandleFunc("/thorne/products", app.thorneHandlers.GetProducts).Methods("GET")
@@ -1308,13 +3314,32 @@ func main() {
 	api.HandleFunc("/thorne/products/category/{id}", app.thorneHandlers.GetProductsByCategory).Methods("GET")
 	api.HandleFunc("/thorne/patients", app.thorneHandlers.GetPatients).Methods("GET")
 	api.HandleFunc("/thorne/patients/{id}", app.thorneHandlers.GetPatient).Methods("GET")
+	api.HandleFunc("/thorne/patients/{id}/intake", app.thorneHandlers.GetPatientIntake).Methods("GET")
+	api.HandleFunc("/thorne/patients/{id}/intake", app.thorneHandlers.SavePatientIntake).Methods("PUT")
+	api.HandleFunc("/thorne/patients/{id}/recommendations", app.thorneHandlers.GetPatientRecommendations).Methods("GET")
+	api.HandleFunc("/thorne/patients/{id}/recommendations", app.thorneHandlers.CreatePatientRecommendation).Methods("POST")
+	api.HandleFunc("/thorne/recommendations/{id}/approve", app.thorneHandlers.ApproveRecommendation).Methods("POST")
+	api.HandleFunc("/thorne/recommendations/{id}/send", app.thorneHandlers.SendRecommendation).Methods("POST")
 	api.HandleFunc("/thorne/register", app.thorneHandlers.RegisterPatient).Methods("POST")
 	api.HandleFunc("/thorne/orders", app.thorneHandlers.GetOrders).Methods("GET")
 	api.HandleFunc("/thorne/orders/patient/{id}", app.thorneHandlers.GetOrdersByPatient).Methods("GET")
 	api.HandleFunc("/thorne/orders", app.thorneHandlers.CreateOrder).Methods("POST")
+	api.HandleFunc("/thorne/orders/{id}", app.thorneHandlers.GetOrder).Methods("GET")
+	api.HandleFunc("/thorne/orders/{id}/packing-slip", app.thorneHandlers.GetPackingSlip).Methods("GET")
+	api.HandleFunc("/thorne/orders/{id}/tracking", app.thorneHandlers.UpdateOrderTracking).Methods("POST")
+	api.HandleFunc("/thorne/orders/{id}/tracking/sync", app.thorneHandlers.SyncOrderTracking).Methods("POST")
+	api.HandleFunc("/thorne/fulfillment/pick-list", app.thorneHandlers.GetPickList).Methods("GET")
+	api.HandleFunc("/thorne/fulfillment/webhook", app.thorneHandlers.FulfillmentWebhook).Methods("POST")
 	api.HandleFunc("/thorne/settings", app.thorneHandlers.GetSettings).Methods("GET")
 	api.HandleFunc("/thorne/search", app.thorneHandlers.SearchProducts).Methods("GET")
 	api.HandleFunc("/thorne/stats", app.thorneHandlers.GetProductStats).Methods("GET")
+	api.HandleFunc("/thorne/analytics/summary", app.thorneHandlers.GetSalesSummary).Methods("GET")
+	api.HandleFunc("/thorne/analytics/revenue", app.thorneHandlers.GetRevenueSeries).Methods("GET")
+	api.HandleFunc("/thorne/analytics/top-products", app.thorneHandlers.GetTopProducts).Methods("GET")
+	api.HandleFunc("/thorne/analytics/patients", app.thorneHandlers.GetPatientLifetimeValues).Methods("GET")
+	api.HandleFunc("/thorne/analytics/rollups/rebuild", app.thorneHandlers.RebuildOrderRollups).Methods("POST")
+	api.HandleFunc("/thorne/catalog/import", app.thorneHandlers.ImportCatalog).Methods("POST")
+	api.HandleFunc("/thorne/catalog/export", app.thorneHandlers.ExportCatalog).Methods("GET")
 
 	// Azure Authentication API endpoints
 	api.HandleFunc("/auth/azure-user", app.azureAuthHandlers.CreateOrUpdateUser).Methods("POST")
@@ -1348,6 +3373,18 @@ func main() {
 
 	// Frontend authentication routes (non-API)
 	router.HandleFunc("/auth/signout", app.handleSignout).Methods("GET")
+
+	// Media files stored on local disk are served by this process
+	if app.mediaLocalServing {
+		router.HandleFunc("/media/{key:.+}", app.mediaHandlers.ServeMedia).Methods("GET", "HEAD")
+	}
+
+	// Rendered public sites, by path for any site and by host for sites with
+	// a domain; registered last so every other route takes precedence
+	router.PathPrefix("/s/{siteSlug}").HandlerFunc(app.publicSiteHandler).Methods("GET", "HEAD")
+	router.PathPrefix("/").MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+		return !strings.HasPrefix(r.URL.Path, "/api/")
+	}).HandlerFunc(app.publicSiteHandler).Methods("GET", "HEAD")
 	
 
 	app.logger.Info("main", "startup", "Server ready to accept connections", map[string]interface{}{
@@ -33,19 +33,22 @@ echo ""
 
 # Apply DDL scripts
 echo "📝 Applying DDL scripts..."
-if [ -f "$SCRIPT_DIR/ddl/001_current_database_schema.sql" ]; then
-    echo "  ✅ Applying schema..."
-    if psql "$DSN" -f "$SCRIPT_DIR/ddl/001_current_database_schema.sql"; then
-        echo "  ✅ Schema applied successfully!"
-    else
-        echo "  ❌ Error: Failed to apply schema"
-        exit 1
-    fi
-else
+ddl_files=("$SCRIPT_DIR"/ddl/*.sql)
+if [ ! -f "${ddl_files[0]}" ]; then
     echo "  ❌ Error: DDL script not found"
     exit 1
 fi
 
+for ddl_file in "${ddl_files[@]}"; do
+    echo "  ✅ Applying $(basename "$ddl_file")..."
+    if psql "$DSN" -f "$ddl_file"; then
+        echo "  ✅ $(basename "$ddl_file") applied successfully!"
+    else
+        echo "  ❌ Error: Failed to apply $(basename "$ddl_file")"
+        exit 1
+    fi
+done
+
 echo ""
 
 # Apply data scripts in order
@@ -0,0 +1,29 @@
+-- AGoat Publisher - Post Revisions
+-- Description: Immutable history of every post edit
+
+-- =============================================================================
+-- POST REVISIONS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    post_id BIGINT NOT NULL,
+    site_id UUID NOT NULL,
+    revision_number INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    status VARCHAR(50),
+    author_id BIGINT,
+    restored_from UUID,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    FOREIGN KEY (author_id) REFERENCES users(id),
+    UNIQUE (post_id, revision_number)
+);
+
+COMMENT ON TABLE post_revisions IS 'Append-only snapshots of post content written on every update';
+
+CREATE INDEX IF NOT EXISTS idx_post_revisions_post_id ON post_revisions (post_id, revision_number DESC);
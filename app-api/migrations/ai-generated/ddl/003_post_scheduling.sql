@@ -0,0 +1,15 @@
+-- AGoat Publisher - Scheduled Publishing
+-- Description: Publish and unpublish posts at a scheduled time
+
+-- =============================================================================
+-- POSTS SCHEDULING COLUMNS
+-- =============================================================================
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS unpublish_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN posts.publish_at IS 'When the scheduler should publish the post; cleared once it fires';
+COMMENT ON COLUMN posts.unpublish_at IS 'When the scheduler should unpublish the post; cleared once it fires';
+
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts (publish_at) WHERE publish_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_unpublish_at ON posts (unpublish_at) WHERE unpublish_at IS NOT NULL;
@@ -0,0 +1,75 @@
+-- AGoat Publisher - Site Pages and Navigation
+-- Description: Hierarchical pages and nested navigation menus per site
+
+-- =============================================================================
+-- SITE PAGES TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS site_pages (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    parent_id UUID,
+    title VARCHAR(255) NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    path VARCHAR(1024) NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    status VARCHAR(50) NOT NULL DEFAULT 'draft'
+        CONSTRAINT check_site_page_status CHECK (status IN ('draft', 'published', 'archived')),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    FOREIGN KEY (parent_id) REFERENCES site_pages(id),
+    UNIQUE (site_id, path)
+);
+
+COMMENT ON TABLE site_pages IS 'Hierarchical site pages; path is the slash-joined slugs from the root';
+
+CREATE INDEX IF NOT EXISTS idx_site_pages_parent ON site_pages (site_id, parent_id, sort_order);
+
+-- =============================================================================
+-- NAVIGATION MENUS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS navigation_menus (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    location VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    UNIQUE (site_id, location)
+);
+
+COMMENT ON TABLE navigation_menus IS 'Named menus per site, addressed by location (e.g. main, footer)';
+
+-- =============================================================================
+-- NAVIGATION ITEMS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS navigation_items (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    menu_id UUID NOT NULL,
+    parent_id UUID,
+    label VARCHAR(255) NOT NULL,
+    item_type VARCHAR(20) NOT NULL
+        CONSTRAINT check_navigation_item_type CHECK (item_type IN ('page', 'post', 'url')),
+    page_id UUID,
+    post_id BIGINT,
+    url VARCHAR(2048),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    open_in_new_tab BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (menu_id) REFERENCES navigation_menus(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES navigation_items(id) ON DELETE CASCADE,
+    FOREIGN KEY (page_id) REFERENCES site_pages(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+COMMENT ON TABLE navigation_items IS 'Ordered, nested menu entries pointing at a page, a post or a URL';
+
+CREATE INDEX IF NOT EXISTS idx_navigation_items_menu ON navigation_items (menu_id, parent_id, sort_order);
@@ -0,0 +1,61 @@
+-- AGoat Publisher - Custom Data Collections
+-- Description: User-defined collection schemas with JSONB items (headless CMS)
+
+-- =============================================================================
+-- DATA COLLECTIONS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS data_collections (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    description TEXT,
+    fields JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    UNIQUE (site_id, slug)
+);
+
+COMMENT ON TABLE data_collections IS 'Collection schemas; fields is an array of {name, type, required, unique, options, collection}';
+
+-- =============================================================================
+-- DATA ITEMS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS data_items (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    collection_id UUID NOT NULL,
+    site_id UUID NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    search_text TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (collection_id) REFERENCES data_collections(id) ON DELETE CASCADE,
+    FOREIGN KEY (site_id) REFERENCES sites(id)
+);
+
+COMMENT ON TABLE data_items IS 'Collection items validated against the collection schema';
+
+CREATE INDEX IF NOT EXISTS idx_data_items_collection ON data_items (collection_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_items_data ON data_items USING GIN (data);
+
+-- =============================================================================
+-- DATA ITEM UNIQUE VALUES TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS data_item_unique_values (
+    collection_id UUID NOT NULL,
+    field_name VARCHAR(255) NOT NULL,
+    value_key TEXT NOT NULL,
+    item_id UUID NOT NULL,
+
+    PRIMARY KEY (collection_id, field_name, value_key),
+    FOREIGN KEY (collection_id) REFERENCES data_collections(id) ON DELETE CASCADE,
+    FOREIGN KEY (item_id) REFERENCES data_items(id) ON DELETE CASCADE
+);
+
+COMMENT ON TABLE data_item_unique_values IS 'Enforces unique collection fields across concurrent writers';
@@ -0,0 +1,35 @@
+-- AGoat Publisher - Block Content
+-- Description: Typed content blocks on posts and pages, plus reusable per-site components
+
+-- =============================================================================
+-- SITE COMPONENTS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS site_components (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    block_type VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    props JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    UNIQUE (site_id, block_type, name),
+    CHECK (block_type IN ('hero', 'rich_text', 'image', 'product_grid', 'call_to_action', 'compliance_disclaimer'))
+);
+
+COMMENT ON TABLE site_components IS 'Named per-site block variants that posts and pages reference by id';
+
+-- =============================================================================
+-- BLOCK COLUMNS
+-- =============================================================================
+
+-- blocks holds an array of {type, component, props}; content keeps the
+-- rendered HTML so existing readers keep working
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS blocks JSONB;
+ALTER TABLE site_pages ADD COLUMN IF NOT EXISTS blocks JSONB;
+ALTER TABLE post_revisions ADD COLUMN IF NOT EXISTS blocks JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_posts_blocks ON posts USING GIN (blocks);
+CREATE INDEX IF NOT EXISTS idx_site_pages_blocks ON site_pages USING GIN (blocks);
@@ -0,0 +1,11 @@
+-- AGoat Publisher - Content Format
+-- Description: Records whether post content is Markdown, HTML or rendered blocks
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_format VARCHAR(20) NOT NULL DEFAULT 'html';
+ALTER TABLE posts ADD CONSTRAINT posts_content_format_check
+    CHECK (content_format IN ('markdown', 'html', 'blocks'));
+
+-- Posts saved with blocks before the column existed
+UPDATE posts SET content_format = 'blocks' WHERE blocks IS NOT NULL AND content_format = 'html';
+
+ALTER TABLE post_revisions ADD COLUMN IF NOT EXISTS content_format VARCHAR(20);
@@ -0,0 +1,87 @@
+-- AGoat Publisher - Media Library
+-- Description: Per-site media assets, generated image variants and resumable upload sessions
+
+-- =============================================================================
+-- MEDIA ASSETS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS media_assets (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    checksum_sha256 CHAR(64) NOT NULL,
+    storage_key VARCHAR(1024) NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    alt_text TEXT,
+    title VARCHAR(255),
+    uploaded_by BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    UNIQUE (storage_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_assets_site_created ON media_assets (site_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_media_assets_site_checksum ON media_assets (site_id, checksum_sha256);
+
+COMMENT ON TABLE media_assets IS 'Uploaded media; the MIME type is sniffed from the content, never taken from the client';
+
+-- =============================================================================
+-- MEDIA VARIANTS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS media_variants (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    asset_id UUID NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    storage_key VARCHAR(1024) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (asset_id) REFERENCES media_assets(id) ON DELETE CASCADE,
+    UNIQUE (asset_id, name)
+);
+
+COMMENT ON TABLE media_variants IS 'Resized and WebP renditions of image assets (thumbnail, small, medium, large, *_webp)';
+
+-- =============================================================================
+-- RESUMABLE UPLOADS
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS media_uploads (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100),
+    total_bytes BIGINT NOT NULL,
+    received_bytes BIGINT NOT NULL DEFAULT 0,
+    alt_text TEXT,
+    title VARCHAR(255),
+    created_by BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    FOREIGN KEY (site_id) REFERENCES sites(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_uploads_expires ON media_uploads (expires_at);
+
+COMMENT ON TABLE media_uploads IS 'In-progress resumable uploads; total_bytes counts against the customer quota until completed or expired';
+
+CREATE TABLE IF NOT EXISTS media_upload_parts (
+    upload_id UUID NOT NULL,
+    byte_offset BIGINT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    storage_key VARCHAR(1024) NOT NULL,
+
+    PRIMARY KEY (upload_id, byte_offset),
+    FOREIGN KEY (upload_id) REFERENCES media_uploads(id) ON DELETE CASCADE
+);
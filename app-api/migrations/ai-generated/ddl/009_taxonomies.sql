@@ -0,0 +1,45 @@
+-- AGoat Publisher - Taxonomies
+-- Description: Per-site tags (flat) and categories (hierarchical) assigned to posts
+
+-- =============================================================================
+-- TAXONOMY TERMS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS taxonomy_terms (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    taxonomy VARCHAR(20) NOT NULL
+        CONSTRAINT taxonomy_terms_taxonomy_check CHECK (taxonomy IN ('tag', 'category')),
+    parent_id UUID,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    description TEXT,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    FOREIGN KEY (parent_id) REFERENCES taxonomy_terms(id),
+    UNIQUE (site_id, taxonomy, slug),
+    CONSTRAINT taxonomy_terms_tag_parent_check CHECK (taxonomy = 'category' OR parent_id IS NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_taxonomy_terms_parent ON taxonomy_terms (parent_id);
+
+COMMENT ON TABLE taxonomy_terms IS 'Tags and categories; only categories may have a parent';
+
+-- =============================================================================
+-- POST TERM ASSIGNMENTS
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS post_terms (
+    post_id BIGINT NOT NULL,
+    term_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (post_id, term_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (term_id) REFERENCES taxonomy_terms(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_terms_term ON post_terms (term_id);
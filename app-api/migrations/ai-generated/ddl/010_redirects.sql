@@ -0,0 +1,44 @@
+-- AGoat Publisher - Slug History and Redirects
+-- Description: Old post slugs per site and site-level path redirect rules
+
+-- =============================================================================
+-- POST SLUG HISTORY TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS post_slug_history (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    post_id BIGINT NOT NULL,
+    old_slug VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    UNIQUE (site_id, old_slug)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_slug_history_post ON post_slug_history (post_id);
+
+COMMENT ON TABLE post_slug_history IS 'Slugs a post used to have; each old slug points at the post that last held it';
+
+-- =============================================================================
+-- SITE REDIRECTS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS site_redirects (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    source_path VARCHAR(1024) NOT NULL,
+    target_path VARCHAR(2048) NOT NULL,
+    match_type VARCHAR(20) NOT NULL DEFAULT 'exact'
+        CONSTRAINT site_redirects_match_type_check CHECK (match_type IN ('exact', 'prefix')),
+    status_code INTEGER NOT NULL DEFAULT 301
+        CONSTRAINT site_redirects_status_code_check CHECK (status_code IN (301, 302, 307, 308)),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    UNIQUE (site_id, match_type, source_path)
+);
+
+COMMENT ON TABLE site_redirects IS 'Per-site path redirects; prefix rules carry the rest of the path over to the target';
@@ -0,0 +1,31 @@
+-- AGoat Publisher - Preview Tokens
+-- Description: Expiring, revocable signed links that let reviewers without an account view unpublished posts
+
+-- =============================================================================
+-- PREVIEW TOKENS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS preview_tokens (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    post_id BIGINT,
+    scope VARCHAR(20) NOT NULL
+        CONSTRAINT preview_tokens_scope_check CHECK (scope IN ('post', 'site')),
+    label VARCHAR(255),
+    created_by BIGINT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    use_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    CONSTRAINT preview_tokens_post_scope_check CHECK ((scope = 'post') = (post_id IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_preview_tokens_site ON preview_tokens (site_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_preview_tokens_post ON preview_tokens (post_id);
+
+COMMENT ON TABLE preview_tokens IS 'Preview link state; the token itself is HMAC-signed and never stored';
@@ -0,0 +1,83 @@
+-- AGoat Publisher - Editorial Workflow
+-- Description: Per-site review workflow, reviewer assignments, transition history and notification events
+
+-- =============================================================================
+-- SITE WORKFLOWS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS site_workflows (
+    site_id UUID NOT NULL PRIMARY KEY,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    transitions JSONB NOT NULL DEFAULT '[]',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id)
+);
+
+COMMENT ON TABLE site_workflows IS 'Allowed post status transitions per site and the roles that may make them';
+
+-- =============================================================================
+-- POST REVIEWERS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS post_reviewers (
+    post_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    assigned_by BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (assigned_by) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_reviewers_user ON post_reviewers (user_id);
+
+-- =============================================================================
+-- POST WORKFLOW EVENTS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS post_workflow_events (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    post_id BIGINT NOT NULL,
+    from_status VARCHAR(50) NOT NULL,
+    to_status VARCHAR(50) NOT NULL,
+    actor_id BIGINT,
+    comment TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_workflow_events_post ON post_workflow_events (post_id, created_at);
+
+COMMENT ON TABLE post_workflow_events IS 'Status transitions with approval and rejection comments';
+
+-- =============================================================================
+-- NOTIFICATION EVENTS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS notification_events (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID,
+    event_type VARCHAR(100) NOT NULL,
+    recipient_id BIGINT,
+    actor_id BIGINT,
+    resource_type VARCHAR(100),
+    resource_id VARCHAR(255),
+    payload JSONB NOT NULL DEFAULT '{}',
+    read_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    FOREIGN KEY (recipient_id) REFERENCES users(id),
+    FOREIGN KEY (actor_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_events_recipient ON notification_events (recipient_id, created_at);
+
+COMMENT ON TABLE notification_events IS 'Outbox of user notifications; rows without a recipient are site-wide';
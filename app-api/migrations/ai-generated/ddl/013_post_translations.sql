@@ -0,0 +1,29 @@
+-- AGoat Publisher - Post Translations
+-- Description: Language variants of posts grouped into translation groups, with slugs unique per site and locale
+
+-- =============================================================================
+-- POSTS TRANSLATION COLUMNS
+-- =============================================================================
+
+-- Every existing post starts as the only variant of its own group
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS translation_group_id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS locale VARCHAR(35) NOT NULL DEFAULT 'en';
+
+-- Existing posts take their site's default locale
+UPDATE posts SET locale = s.setting_value
+FROM site_settings s
+WHERE s.site_id = posts.site_id AND s.setting_key = 'default_locale'
+AND s.setting_value IS NOT NULL AND s.setting_value <> '';
+
+COMMENT ON COLUMN posts.translation_group_id IS 'Posts sharing a group are translations of each other';
+COMMENT ON COLUMN posts.locale IS 'Language tag of this variant, one of the site supported_locales setting';
+
+-- =============================================================================
+-- SLUG UNIQUENESS PER LOCALE
+-- =============================================================================
+
+-- Slugs were unique per site; translations may reuse a slug in another locale
+DROP INDEX IF EXISTS posts@posts_slug_site_id_key CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_site_locale_slug ON posts (site_id, locale, slug);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_translation_locale ON posts (translation_group_id, locale) WHERE deleted_at IS NULL;
@@ -0,0 +1,25 @@
+-- AGoat Publisher - Post Search
+-- Description: Full-text search documents for posts, kept in step with posts by the API
+
+-- =============================================================================
+-- POST SEARCH DOCUMENTS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS post_search_documents (
+    post_id BIGINT NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    search_config VARCHAR(50) NOT NULL DEFAULT 'simple',
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    tags TEXT NOT NULL DEFAULT '',
+    document TSVECTOR NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (site_id) REFERENCES sites(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_search_documents_site ON post_search_documents (site_id);
+CREATE INDEX IF NOT EXISTS idx_post_search_documents_document ON post_search_documents USING GIN (document);
+
+COMMENT ON TABLE post_search_documents IS 'Title, rendered plain text and tag names of live posts as a weighted tsvector; rebuild existing posts with POST /api/admin/sites/{siteSlug}/search/reindex';
@@ -0,0 +1,48 @@
+-- AGoat Publisher - Comments
+-- Description: Threaded reader comments on published posts with a moderation queue
+
+-- =============================================================================
+-- COMMENTS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS comments (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    post_id BIGINT NOT NULL,
+    parent_id UUID,
+    user_id BIGINT,
+    author_name VARCHAR(100),
+    author_email VARCHAR(255),
+    author_url VARCHAR(500),
+    body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    spam_score REAL NOT NULL DEFAULT 0,
+    spam_reasons JSONB NOT NULL DEFAULT '[]',
+    ip_address INET,
+    user_agent TEXT,
+    moderated_by BIGINT,
+    moderated_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (moderated_by) REFERENCES users(id),
+    CONSTRAINT comments_status_check CHECK (status IN ('pending', 'approved', 'spam', 'deleted'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_post ON comments (post_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_site_status ON comments (site_id, status, created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_ip ON comments (ip_address, created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_user ON comments (user_id, created_at);
+
+COMMENT ON TABLE comments IS 'Reader comments; comments_enabled, comments_allow_anonymous and comments_auto_approve site settings control submission';
+
+-- =============================================================================
+-- POST COMMENT COUNTS
+-- =============================================================================
+
+-- Approved comments per post, kept in step by the comment service
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS comment_count INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+-- AGoat Publisher - Site Themes
+-- Description: Theme selection for the server-rendered public site
+
+-- =============================================================================
+-- SITES TABLE
+-- =============================================================================
+
+-- Name of the theme directory under THEMES_DIR; sites naming a theme that is
+-- not installed are rendered with the default theme
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS template VARCHAR(100) DEFAULT 'default';
@@ -0,0 +1,29 @@
+-- AGoat Publisher - Content Imports
+-- Description: Links between imported WordPress and Markdown items and the posts and media created from them
+
+-- =============================================================================
+-- IMPORT ITEMS TABLE
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS import_items (
+    id UUID DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    source VARCHAR(20) NOT NULL
+        CONSTRAINT import_items_source_check CHECK (source IN ('wxr', 'markdown')),
+    kind VARCHAR(20) NOT NULL
+        CONSTRAINT import_items_kind_check CHECK (kind IN ('post', 'media')),
+    source_key VARCHAR(1024) NOT NULL,
+    target_id VARCHAR(255) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    UNIQUE (site_id, source, kind, source_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_items_target ON import_items (kind, target_id);
+
+COMMENT ON TABLE import_items IS 'Source items already imported into a site, so re-running an import updates instead of duplicating';
+COMMENT ON COLUMN import_items.source_key IS 'WXR post ID, as post:12 or attachment:34, or a Markdown or media file path relative to the imported folder';
+COMMENT ON COLUMN import_items.checksum IS 'SHA-256 of the source item as last imported; unchanged items are skipped';
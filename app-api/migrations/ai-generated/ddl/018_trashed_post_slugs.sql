@@ -0,0 +1,13 @@
+-- AGoat Publisher - Trashed Post Slugs
+-- Description: Posts in the trash no longer hold their slug
+
+-- =============================================================================
+-- SLUG UNIQUENESS FOR LIVE POSTS
+-- =============================================================================
+
+-- Trashed posts are kept for CONTENT_TRASH_RETENTION_DAYS; only live posts
+-- claim a slug, so a new post can reuse it meanwhile. Restoring a trashed
+-- post whose slug was taken is refused by the application.
+DROP INDEX IF EXISTS posts@idx_posts_site_locale_slug CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_site_locale_slug_live ON posts (site_id, locale, slug) WHERE deleted_at IS NULL;
@@ -0,0 +1,25 @@
+-- AGoat Publisher - Post Autosaves
+-- Description: Unsaved editor state kept apart from the post and its history
+
+-- =============================================================================
+-- POST AUTOSAVES TABLE
+-- =============================================================================
+
+-- One row per post holding the editor's latest unsaved title, content and
+-- slug. Saving the post records a revision and clears the autosave; preview
+-- links show it alongside the saved post.
+CREATE TABLE IF NOT EXISTS post_autosaves (
+    post_id BIGINT NOT NULL PRIMARY KEY,
+    site_id UUID NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    author_id BIGINT,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (site_id) REFERENCES sites(id),
+    FOREIGN KEY (author_id) REFERENCES users(id)
+);
+
+COMMENT ON TABLE post_autosaves IS 'Latest unsaved editor state of a post; cleared when the post is saved';
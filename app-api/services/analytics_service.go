@@ -0,0 +1,461 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Analytics intervals
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+const rollupDateLayout = "2006-01-02"
+
+// ProductDailyRollup aggregates one product's sales for one site and day
+type ProductDailyRollup struct {
+	Date      string  `json:"date"`
+	SiteID    string  `json:"site_id,omitempty"`
+	ProductID string  `json:"product_id"`
+	Orders    int     `json:"orders"`
+	Units     int     `json:"units"`
+	Revenue   float64 `json:"revenue"`
+	Cost      float64 `json:"cost"`
+}
+
+// PatientDailyRollup aggregates one patient's purchases for one site and day
+type PatientDailyRollup struct {
+	Date      string  `json:"date"`
+	SiteID    string  `json:"site_id,omitempty"`
+	PatientID string  `json:"patient_id"`
+	Orders    int     `json:"orders"`
+	Revenue   float64 `json:"revenue"`
+	Cost      float64 `json:"cost"`
+}
+
+// OrderRollups is the persisted set of daily aggregates
+type OrderRollups struct {
+	SourceUpdatedAt time.Time            `json:"source_updated_at"`
+	BuiltAt         time.Time            `json:"built_at"`
+	Products        []ProductDailyRollup `json:"products"`
+	Patients        []PatientDailyRollup `json:"patients"`
+}
+
+// AnalyticsFilter limits analytics to a date range and site. From and To are
+// inclusive calendar days; zero values leave that side open.
+type AnalyticsFilter struct {
+	From   time.Time
+	To     time.Time
+	SiteID string
+}
+
+// SalesSummary holds headline metrics for a period
+type SalesSummary struct {
+	Orders             int     `json:"orders"`
+	Units              int     `json:"units"`
+	Revenue            float64 `json:"revenue"`
+	Cost               float64 `json:"cost"`
+	Margin             float64 `json:"margin"`
+	MarginPercent      float64 `json:"margin_percent"`
+	AverageOrderValue  float64 `json:"average_order_value"`
+	Customers          int     `json:"customers"`
+	RepeatCustomers    int     `json:"repeat_customers"`
+	RepeatPurchaseRate float64 `json:"repeat_purchase_rate"`
+}
+
+// RevenuePoint is revenue and margin for one period
+type RevenuePoint struct {
+	Period  string  `json:"period"`
+	Orders  int     `json:"orders"`
+	Units   int     `json:"units"`
+	Revenue float64 `json:"revenue"`
+	Cost    float64 `json:"cost"`
+	Margin  float64 `json:"margin"`
+}
+
+// TopProduct ranks a product by revenue
+type TopProduct struct {
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Orders      int     `json:"orders"`
+	Units       int     `json:"units"`
+	Revenue     float64 `json:"revenue"`
+	Margin      float64 `json:"margin"`
+}
+
+// PatientValue is a patient's lifetime value within the filter
+type PatientValue struct {
+	PatientID         string  `json:"patient_id"`
+	PatientName       string  `json:"patient_name"`
+	Orders            int     `json:"orders"`
+	Revenue           float64 `json:"revenue"`
+	Margin            float64 `json:"margin"`
+	AverageOrderValue float64 `json:"average_order_value"`
+	FirstOrder        string  `json:"first_order"`
+	LastOrder         string  `json:"last_order"`
+}
+
+// countsTowardSales reports whether an order status represents a real sale
+func countsTowardSales(status string) bool {
+	switch status {
+	case OrderStatusFailed, "cancelled", "refunded":
+		return false
+	}
+	return true
+}
+
+// BuildOrderRollups aggregates orders into daily product and patient rollups
+func BuildOrderRollups(orders []Order, products []ThorneProduct) *OrderRollups {
+	wholesale := make(map[string]float64)
+	for _, product := range products {
+		wholesale[product.ID] = product.WholesalePrice
+	}
+
+	productRollups := make(map[string]*ProductDailyRollup)
+	patientRollups := make(map[string]*PatientDailyRollup)
+
+	for _, order := range orders {
+		if !countsTowardSales(order.Status) {
+			continue
+		}
+		date := order.OrderDate.UTC().Format(rollupDateLayout)
+
+		// Orders created before unit_cost was captured fall back to today's wholesale price
+		unitCost := order.UnitCost
+		if unitCost == 0 {
+			unitCost = wholesale[order.ProductID]
+		}
+		cost := unitCost * float64(order.Quantity)
+
+		productKey := date + "|" + order.SiteID + "|" + order.ProductID
+		pr, ok := productRollups[productKey]
+		if !ok {
+			pr = &ProductDailyRollup{Date: date, SiteID: order.SiteID, ProductID: order.ProductID}
+			productRollups[productKey] = pr
+		}
+		pr.Orders++
+		pr.Units += order.Quantity
+		pr.Revenue += order.TotalPrice
+		pr.Cost += cost
+
+		patientKey := date + "|" + order.SiteID + "|" + order.PatientID
+		pa, ok := patientRollups[patientKey]
+		if !ok {
+			pa = &PatientDailyRollup{Date: date, SiteID: order.SiteID, PatientID: order.PatientID}
+			patientRollups[patientKey] = pa
+		}
+		pa.Orders++
+		pa.Revenue += order.TotalPrice
+		pa.Cost += cost
+	}
+
+	rollups := &OrderRollups{
+		BuiltAt:  time.Now(),
+		Products: []ProductDailyRollup{},
+		Patients: []PatientDailyRollup{},
+	}
+	for _, pr := range productRollups {
+		rollups.Products = append(rollups.Products, *pr)
+	}
+	for _, pa := range patientRollups {
+		rollups.Patients = append(rollups.Patients, *pa)
+	}
+	sort.Slice(rollups.Products, func(i, j int) bool {
+		a, b := rollups.Products[i], rollups.Products[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		return a.ProductID < b.ProductID
+	})
+	sort.Slice(rollups.Patients, func(i, j int) bool {
+		a, b := rollups.Patients[i], rollups.Patients[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		return a.PatientID < b.PatientID
+	})
+
+	return rollups
+}
+
+// GetOrderRollups returns the daily rollups, rebuilding them when orders have changed
+func (s *ThorneService) GetOrderRollups() (*OrderRollups, error) {
+	info, err := os.Stat(filepath.Join(s.configPath, "thorne-orders.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat config file thorne-orders.json: %v", err)
+	}
+
+	var rollups OrderRollups
+	if err := s.loadOptionalJSON("thorne-order-rollups.json", &rollups); err != nil {
+		return nil, err
+	}
+	if !rollups.BuiltAt.IsZero() && rollups.SourceUpdatedAt.Equal(info.ModTime().UTC()) {
+		return &rollups, nil
+	}
+
+	return s.RebuildOrderRollups()
+}
+
+// RebuildOrderRollups recomputes and saves the daily rollups from the orders file
+func (s *ThorneService) RebuildOrderRollups() (*OrderRollups, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(filepath.Join(s.configPath, "thorne-orders.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat config file thorne-orders.json: %v", err)
+	}
+	orders, err := s.GetOrders()
+	if err != nil {
+		return nil, err
+	}
+	products, err := s.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	rollups := BuildOrderRollups(orders, products)
+	rollups.SourceUpdatedAt = info.ModTime().UTC()
+	if err := s.saveJSON("thorne-order-rollups.json", rollups); err != nil {
+		return nil, err
+	}
+	return rollups, nil
+}
+
+// matches reports whether a rollup row falls inside the filter
+func (f AnalyticsFilter) matches(date, siteID string) bool {
+	if f.SiteID != "" && siteID != f.SiteID {
+		return false
+	}
+	if !f.From.IsZero() && date < f.From.Format(rollupDateLayout) {
+		return false
+	}
+	if !f.To.IsZero() && date > f.To.Format(rollupDateLayout) {
+		return false
+	}
+	return true
+}
+
+// GetSalesSummary returns revenue, margin, average order value and repeat-purchase rate
+func (s *ThorneService) GetSalesSummary(filter AnalyticsFilter) (*SalesSummary, error) {
+	rollups, err := s.GetOrderRollups()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &SalesSummary{}
+	for _, pr := range rollups.Products {
+		if filter.matches(pr.Date, pr.SiteID) {
+			summary.Units += pr.Units
+		}
+	}
+
+	patientOrders := make(map[string]int)
+	for _, pa := range rollups.Patients {
+		if !filter.matches(pa.Date, pa.SiteID) {
+			continue
+		}
+		summary.Orders += pa.Orders
+		summary.Revenue += pa.Revenue
+		summary.Cost += pa.Cost
+		patientOrders[pa.PatientID] += pa.Orders
+	}
+
+	summary.Customers = len(patientOrders)
+	for _, count := range patientOrders {
+		if count > 1 {
+			summary.RepeatCustomers++
+		}
+	}
+
+	summary.Margin = summary.Revenue - summary.Cost
+	if summary.Revenue > 0 {
+		summary.MarginPercent = roundCents(summary.Margin / summary.Revenue * 100)
+	}
+	if summary.Orders > 0 {
+		summary.AverageOrderValue = roundCents(summary.Revenue / float64(summary.Orders))
+	}
+	if summary.Customers > 0 {
+		summary.RepeatPurchaseRate = roundCents(float64(summary.RepeatCustomers) / float64(summary.Customers) * 100)
+	}
+	summary.Revenue = roundCents(summary.Revenue)
+	summary.Cost = roundCents(summary.Cost)
+	summary.Margin = roundCents(summary.Margin)
+
+	return summary, nil
+}
+
+// GetRevenueSeries returns revenue and margin grouped by day, week or month
+func (s *ThorneService) GetRevenueSeries(filter AnalyticsFilter, interval string) ([]RevenuePoint, error) {
+	rollups, err := s.GetOrderRollups()
+	if err != nil {
+		return nil, err
+	}
+
+	points := make(map[string]*RevenuePoint)
+	for _, pr := range rollups.Products {
+		if !filter.matches(pr.Date, pr.SiteID) {
+			continue
+		}
+		period, err := periodFor(pr.Date, interval)
+		if err != nil {
+			return nil, err
+		}
+		point, ok := points[period]
+		if !ok {
+			point = &RevenuePoint{Period: period}
+			points[period] = point
+		}
+		point.Orders += pr.Orders
+		point.Units += pr.Units
+		point.Revenue += pr.Revenue
+		point.Cost += pr.Cost
+	}
+
+	series := []RevenuePoint{}
+	for _, point := range points {
+		point.Margin = roundCents(point.Revenue - point.Cost)
+		point.Revenue = roundCents(point.Revenue)
+		point.Cost = roundCents(point.Cost)
+		series = append(series, *point)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Period < series[j].Period })
+
+	return series, nil
+}
+
+// GetTopProducts returns the best-selling products by revenue
+func (s *ThorneService) GetTopProducts(filter AnalyticsFilter, limit int) ([]TopProduct, error) {
+	rollups, err := s.GetOrderRollups()
+	if err != nil {
+		return nil, err
+	}
+	products, err := s.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, product := range products {
+		names[product.ID] = product.Name
+	}
+
+	totals := make(map[string]*TopProduct)
+	costs := make(map[string]float64)
+	for _, pr := range rollups.Products {
+		if !filter.matches(pr.Date, pr.SiteID) {
+			continue
+		}
+		top, ok := totals[pr.ProductID]
+		if !ok {
+			top = &TopProduct{ProductID: pr.ProductID, ProductName: names[pr.ProductID]}
+			totals[pr.ProductID] = top
+		}
+		top.Orders += pr.Orders
+		top.Units += pr.Units
+		top.Revenue += pr.Revenue
+		costs[pr.ProductID] += pr.Cost
+	}
+
+	ranked := []TopProduct{}
+	for id, top := range totals {
+		top.Margin = roundCents(top.Revenue - costs[id])
+		top.Revenue = roundCents(top.Revenue)
+		ranked = append(ranked, *top)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Revenue != ranked[j].Revenue {
+			return ranked[i].Revenue > ranked[j].Revenue
+		}
+		return ranked[i].Units > ranked[j].Units
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked, nil
+}
+
+// GetPatientLifetimeValues returns per-patient revenue, highest first
+func (s *ThorneService) GetPatientLifetimeValues(filter AnalyticsFilter) ([]PatientValue, error) {
+	rollups, err := s.GetOrderRollups()
+	if err != nil {
+		return nil, err
+	}
+	patients, err := s.GetPatients()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, patient := range patients {
+		names[patient.ID] = patient.Name
+	}
+
+	values := make(map[string]*PatientValue)
+	costs := make(map[string]float64)
+	for _, pa := range rollups.Patients {
+		if !filter.matches(pa.Date, pa.SiteID) {
+			continue
+		}
+		value, ok := values[pa.PatientID]
+		if !ok {
+			value = &PatientValue{PatientID: pa.PatientID, PatientName: names[pa.PatientID], FirstOrder: pa.Date}
+			values[pa.PatientID] = value
+		}
+		value.Orders += pa.Orders
+		value.Revenue += pa.Revenue
+		costs[pa.PatientID] += pa.Cost
+		if pa.Date < value.FirstOrder {
+			value.FirstOrder = pa.Date
+		}
+		if pa.Date > value.LastOrder {
+			value.LastOrder = pa.Date
+		}
+	}
+
+	ranked := []PatientValue{}
+	for id, value := range values {
+		value.Margin = roundCents(value.Revenue - costs[id])
+		if value.Orders > 0 {
+			value.AverageOrderValue = roundCents(value.Revenue / float64(value.Orders))
+		}
+		value.Revenue = roundCents(value.Revenue)
+		ranked = append(ranked, *value)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Revenue != ranked[j].Revenue {
+			return ranked[i].Revenue > ranked[j].Revenue
+		}
+		return ranked[i].PatientID < ranked[j].PatientID
+	})
+
+	return ranked, nil
+}
+
+// periodFor maps a rollup date to its day, ISO week (starting Monday) or month
+func periodFor(date, interval string) (string, error) {
+	switch interval {
+	case "", IntervalDay:
+		return date, nil
+	case IntervalWeek:
+		t, err := time.Parse(rollupDateLayout, date)
+		if err != nil {
+			return "", err
+		}
+		offset := (int(t.Weekday()) + 6) % 7
+		return t.AddDate(0, 0, -offset).Format(rollupDateLayout), nil
+	case IntervalMonth:
+		return date[:7], nil
+	default:
+		return "", fmt.Errorf("unsupported interval: %s", interval)
+	}
+}
+
+func roundCents(value float64) float64 {
+	return math.Round(value*100) / 100
+}
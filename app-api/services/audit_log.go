@@ -0,0 +1,66 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// AuditEntry is one row for audit_logs. UserID and IPAddress may be empty;
+// an IP address with a port (as in http.Request.RemoteAddr) is accepted.
+type AuditEntry struct {
+	UserID       string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Details      map[string]interface{}
+	IPAddress    string
+	UserAgent    string
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// RecordAudit appends an entry to audit_logs
+func RecordAudit(db execer, entry AuditEntry) error {
+	details := entry.Details
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO audit_logs (user_id, action, resource_type, resource_id, details, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6::INET, $7)`,
+		userIDParam(entry.UserID), entry.Action, nullableString(entry.ResourceType), nullableString(entry.ResourceID),
+		string(encoded), auditIP(entry.IPAddress), nullableString(entry.UserAgent))
+	if err != nil {
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+	return nil
+}
+
+// auditIP strips any port and returns nil for anything that is not an IP
+func auditIP(address string) interface{} {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	if net.ParseIP(address) == nil {
+		return nil
+	}
+	return address
+}
+
+// userIDParam converts a numeric user id to a query parameter, or nil
+func userIDParam(userID string) interface{} {
+	if id, err := strconv.ParseInt(userID, 10, 64); err == nil {
+		return id
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrBlobNotFound is returned by BlobStore.Get for a missing key
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores media bytes under slash-separated keys
+type BlobStore interface {
+	// Put writes size bytes from r under key, replacing any existing blob
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob at key; callers must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL clients use to fetch key
+	URL(key string) string
+}
+
+// LocalBlobStore keeps blobs on the local filesystem under root
+type LocalBlobStore struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalBlobStore creates a store rooted at root whose blobs are served
+// from baseURL (e.g. "/media")
+func NewLocalBlobStore(root, baseURL string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create media directory: %w", err)
+	}
+	return &LocalBlobStore{root: root, baseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+// path maps a key to a file under root, refusing keys that escape it
+func (s *LocalBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+// Put implements BlobStore; the blob is written to a temporary file and
+// renamed so readers never see a partial file
+func (s *LocalBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("failed to write blob: wrote %d of %d bytes", written, size)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store blob: %w", err)
+	}
+	return nil
+}
+
+// Get implements BlobStore
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	}
+	return file, err
+}
+
+// Delete implements BlobStore
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
+
+// URL implements BlobStore
+func (s *LocalBlobStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+// Block types
+const (
+	BlockHero                 = "hero"
+	BlockRichText             = "rich_text"
+	BlockImage                = "image"
+	BlockProductGrid          = "product_grid"
+	BlockCallToAction         = "call_to_action"
+	BlockComplianceDisclaimer = "compliance_disclaimer"
+)
+
+const maxBlocksPerDocument = 100
+
+// defaultMedicalDisclaimer is used when a disclaimer block has no text and
+// the catalog settings do not define one
+const defaultMedicalDisclaimer = "These statements have not been evaluated by the Food and Drug Administration. " +
+	"This product is not intended to diagnose, treat, cure, or prevent any disease."
+
+// Block is one typed unit of post or page content. A block either carries
+// its own props or references a site component, in which case its props
+// override the component's. Data and HTML are filled in when rendering.
+type Block struct {
+	Type      string                 `json:"type"`
+	Component string                 `json:"component,omitempty"`
+	Props     map[string]interface{} `json:"props,omitempty"`
+	Data      interface{}            `json:"data,omitempty"`
+	HTML      string                 `json:"html,omitempty"`
+}
+
+// BlockList stores blocks in a JSONB column; rendered output is never
+// persisted
+type BlockList []Block
+
+// Value implements driver.Valuer
+func (b BlockList) Value() (driver.Value, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	stored := make([]Block, len(b))
+	for i, block := range b {
+		stored[i] = Block{Type: block.Type, Component: block.Component, Props: block.Props}
+	}
+	return json.Marshal(stored)
+}
+
+// Scan implements sql.Scanner
+func (b *BlockList) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		*b = nil
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into BlockList", src)
+	}
+	return json.Unmarshal(raw, (*[]Block)(b))
+}
+
+// Prop kinds used by block specs
+const (
+	propString = "string"
+	propText   = "text"
+	propHTML   = "html"
+	propURL    = "url"
+	propBool   = "bool"
+	propInt    = "int"
+	propEnum   = "enum"
+	propIDs    = "ids"
+)
+
+type blockProp struct {
+	kind     string
+	required bool
+	options  []string
+	min, max int
+	def      interface{}
+}
+
+var blockSpecs = map[string]map[string]blockProp{
+	BlockHero: {
+		"heading":    {kind: propString, required: true},
+		"subheading": {kind: propText},
+		"image_url":  {kind: propURL},
+		"cta_label":  {kind: propString},
+		"cta_url":    {kind: propURL},
+		"align":      {kind: propEnum, options: []string{"left", "center", "right"}, def: "center"},
+	},
+	BlockRichText: {
+		"html": {kind: propHTML, required: true},
+	},
+	BlockImage: {
+		"src":     {kind: propURL, required: true},
+		"alt":     {kind: propString, required: true},
+		"caption": {kind: propText},
+		"link":    {kind: propURL},
+		"width":   {kind: propInt, min: 1, max: 10000},
+		"height":  {kind: propInt, min: 1, max: 10000},
+	},
+	BlockProductGrid: {
+		"heading":     {kind: propString},
+		"product_ids": {kind: propIDs},
+		"category":    {kind: propString},
+		"limit":       {kind: propInt, min: 1, max: 48, def: 8},
+		"columns":     {kind: propInt, min: 1, max: 6, def: 3},
+		"show_price":  {kind: propBool, def: true},
+	},
+	BlockCallToAction: {
+		"heading":      {kind: propString, required: true},
+		"text":         {kind: propText},
+		"button_label": {kind: propString, required: true},
+		"button_url":   {kind: propURL, required: true},
+		"style":        {kind: propEnum, options: []string{"primary", "secondary"}, def: "primary"},
+	},
+	BlockComplianceDisclaimer: {
+		"kind": {kind: propEnum, options: []string{"medical_disclaimer", "authorized_seller_notice",
+			"satisfaction_guarantee", "return_policy"}, def: "medical_disclaimer"},
+		"text": {kind: propText},
+	},
+}
+
+// BlockTypes returns the supported block types in a stable order
+func BlockTypes() []string {
+	types := make([]string, 0, len(blockSpecs))
+	for blockType := range blockSpecs {
+		types = append(types, blockType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func isBlockType(blockType string) bool {
+	_, ok := blockSpecs[blockType]
+	return ok
+}
+
+// normalizeBlockProps validates props against the block type's spec,
+// sanitizes rich text and fills defaults
+func normalizeBlockProps(blockType string, props map[string]interface{}) (map[string]interface{}, error) {
+	spec, ok := blockSpecs[blockType]
+	if !ok {
+		return nil, fmt.Errorf("unknown block type %q; use one of: %s", blockType, strings.Join(BlockTypes(), ", "))
+	}
+
+	var problems []string
+	for name := range props {
+		if _, ok := spec[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown prop", name))
+		}
+	}
+
+	clean := make(map[string]interface{}, len(spec))
+	for name, prop := range spec {
+		value, present := props[name]
+		if !present || value == nil || value == "" {
+			if prop.required {
+				problems = append(problems, fmt.Sprintf("%s: is required", name))
+			} else if prop.def != nil {
+				clean[name] = prop.def
+			}
+			continue
+		}
+
+		normalized, err := normalizeBlockProp(prop, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		clean[name] = normalized
+	}
+
+	switch blockType {
+	case BlockHero:
+		if (clean["cta_label"] == nil) != (clean["cta_url"] == nil) {
+			problems = append(problems, "cta_label and cta_url must be set together")
+		}
+	case BlockProductGrid:
+		if clean["product_ids"] == nil && clean["category"] == nil {
+			problems = append(problems, "product_ids or category is required")
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid %s block: %s", blockType, strings.Join(problems, "; "))
+	}
+	return clean, nil
+}
+
+func normalizeBlockProp(prop blockProp, value interface{}) (interface{}, error) {
+	text, isText := value.(string)
+
+	switch prop.kind {
+	case propString:
+		if !isText || strings.ContainsAny(text, "\r\n") {
+			return nil, fmt.Errorf("must be a single-line string")
+		}
+		if len(text) > 1024 {
+			return nil, fmt.Errorf("must be at most 1024 characters")
+		}
+		return strings.TrimSpace(text), nil
+	case propText:
+		if !isText {
+			return nil, fmt.Errorf("must be a string")
+		}
+		return text, nil
+	case propHTML:
+		if !isText {
+			return nil, fmt.Errorf("must be an HTML string")
+		}
+		return SanitizeHTML(text), nil
+	case propURL:
+		if !isText || !isNavigableURL(strings.TrimSpace(text)) {
+			return nil, fmt.Errorf("must be a site path or an absolute http(s) URL")
+		}
+		return strings.TrimSpace(text), nil
+	case propBool:
+		boolean, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return boolean, nil
+	case propInt:
+		number, ok := value.(float64)
+		if !ok {
+			if n, isInt := value.(int); isInt {
+				number, ok = float64(n), true
+			}
+		}
+		if !ok || number != float64(int(number)) {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		if int(number) < prop.min || int(number) > prop.max {
+			return nil, fmt.Errorf("must be between %d and %d", prop.min, prop.max)
+		}
+		return int(number), nil
+	case propEnum:
+		if isText && containsString(prop.options, text) {
+			return text, nil
+		}
+		return nil, fmt.Errorf("must be one of: %s", strings.Join(prop.options, ", "))
+	case propIDs:
+		list, ok := value.([]interface{})
+		if !ok {
+			if ids, isStrings := value.([]string); isStrings {
+				for _, id := range ids {
+					list = append(list, id)
+				}
+				ok = true
+			}
+		}
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("must be a non-empty list of ids")
+		}
+		ids := make([]string, 0, len(list))
+		for _, item := range list {
+			id, isString := item.(string)
+			if !isString || id == "" {
+				return nil, fmt.Errorf("must be a list of ids")
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+	return nil, fmt.Errorf("has unknown kind %q", prop.kind)
+}
+
+// ProductCard is the product data a product grid renders and returns
+type ProductCard struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	ImageURL string  `json:"image_url,omitempty"`
+	Price    float64 `json:"price"`
+	InStock  bool    `json:"in_stock"`
+}
+
+var blockTemplates = template.Must(template.New("blocks").Funcs(template.FuncMap{
+	"price": func(amount float64) string { return fmt.Sprintf("$%.2f", amount) },
+}).Parse(`
+{{define "hero"}}<section class="block block-hero block-hero--{{.Props.align}}">
+{{- with .Props.image_url}}<img class="block-hero__image" src="{{.}}" alt="">{{end -}}
+<h1 class="block-hero__heading">{{.Props.heading}}</h1>
+{{- with .Props.subheading}}<p class="block-hero__subheading">{{.}}</p>{{end -}}
+{{- with .Props.cta_url}}<a class="block-hero__cta" href="{{.}}">{{$.Props.cta_label}}</a>{{end -}}
+</section>{{end}}
+{{define "rich_text"}}<section class="block block-rich-text">{{.RichText}}</section>{{end}}
+{{define "image"}}<figure class="block block-image">
+{{- if .Props.link}}<a href="{{.Props.link}}">{{end -}}
+<img src="{{.Props.src}}" alt="{{.Props.alt}}"{{with .Props.width}} width="{{.}}"{{end}}{{with .Props.height}} height="{{.}}"{{end}} loading="lazy">
+{{- if .Props.link}}</a>{{end -}}
+{{- with .Props.caption}}<figcaption>{{.}}</figcaption>{{end -}}
+</figure>{{end}}
+{{define "product_grid"}}<section class="block block-product-grid block-product-grid--cols-{{.Props.columns}}">
+{{- with .Props.heading}}<h2 class="block-product-grid__heading">{{.}}</h2>{{end -}}
+<ul class="block-product-grid__items">
+{{- range .Products}}<li class="product-card{{if not .InStock}} product-card--out-of-stock{{end}}" data-product-id="{{.ID}}">
+{{- with .ImageURL}}<img src="{{.}}" alt="" loading="lazy">{{end -}}
+<span class="product-card__name">{{.Name}}</span>
+{{- if $.Props.show_price}}<span class="product-card__price">{{price .Price}}</span>{{end -}}
+</li>{{end -}}
+</ul></section>{{end}}
+{{define "call_to_action"}}<section class="block block-cta block-cta--{{.Props.style}}">
+<h2 class="block-cta__heading">{{.Props.heading}}</h2>
+{{- with .Props.text}}<p class="block-cta__text">{{.}}</p>{{end -}}
+<a class="block-cta__button" href="{{.Props.button_url}}">{{.Props.button_label}}</a>
+</section>{{end}}
+{{define "compliance_disclaimer"}}<aside class="block block-disclaimer block-disclaimer--{{.Props.kind}}" role="note"><p>{{.Text}}</p></aside>{{end}}
+`))
+
+type blockView struct {
+	Props    map[string]interface{}
+	RichText template.HTML
+	Products []ProductCard
+	Text     string
+}
+
+// renderBlock renders a block whose props are already normalized and whose
+// product data (for grids) is already resolved
+func renderBlock(block *Block, products []ProductCard, disclaimer string) error {
+	view := blockView{Props: block.Props, Products: products, Text: disclaimer}
+	if html, ok := block.Props["html"].(string); ok {
+		// Sanitized on save and again here in case the allow-list tightened
+		view.RichText = template.HTML(SanitizeHTML(html))
+	}
+
+	var out bytes.Buffer
+	if err := blockTemplates.ExecuteTemplate(&out, block.Type, view); err != nil {
+		return fmt.Errorf("failed to render %s block: %w", block.Type, err)
+	}
+	block.HTML = out.String()
+	return nil
+}
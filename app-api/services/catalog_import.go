@@ -0,0 +1,534 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Catalog import/export formats and record types
+const (
+	CatalogFormatCSV  = "csv"
+	CatalogFormatJSON = "json"
+
+	CatalogTypeProducts   = "products"
+	CatalogTypeCategories = "categories"
+)
+
+// csvListSeparator joins list fields such as benefits in a single CSV cell
+const csvListSeparator = "|"
+
+var productCSVHeader = []string{
+	"id", "name", "description", "category", "image_url", "wholesale_price",
+	"retail_price", "sku", "in_stock", "benefits", "ingredients", "fulfillment",
+}
+
+var categoryCSVHeader = []string{"id", "name", "description", "icon", "benefits", "color"}
+
+// CatalogData is the JSON import/export document, matching the config files
+type CatalogData struct {
+	Products   []ThorneProduct  `json:"products,omitempty"`
+	Categories []ThorneCategory `json:"categories,omitempty"`
+
+	// productErrors holds CSV cells that didn't parse, by product index, so
+	// validation reports them on the product's row
+	productErrors map[int][]string
+}
+
+// CatalogRowResult reports the validation outcome for one imported record
+type CatalogRowResult struct {
+	Row    int      `json:"row"`
+	Type   string   `json:"type"`
+	ID     string   `json:"id"`
+	SKU    string   `json:"sku,omitempty"`
+	Action string   `json:"action"` // create, update, unchanged, invalid
+	Errors []string `json:"errors,omitempty"`
+}
+
+// CatalogImportReport summarizes an import or dry run
+type CatalogImportReport struct {
+	DryRun     bool               `json:"dry_run"`
+	Applied    bool               `json:"applied"`
+	Valid      bool               `json:"valid"`
+	Created    int                `json:"created"`
+	Updated    int                `json:"updated"`
+	Unchanged  int                `json:"unchanged"`
+	ErrorCount int                `json:"error_count"`
+	Rows       []CatalogRowResult `json:"rows"`
+}
+
+// ParseCatalog reads products or categories from CSV or JSON. For CSV the
+// record type selects the column layout; JSON may carry both types at once.
+func ParseCatalog(r io.Reader, format, recordType string) (*CatalogData, error) {
+	switch format {
+	case CatalogFormatJSON:
+		var data CatalogData
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&data); err != nil {
+			return nil, fmt.Errorf("failed to parse catalog JSON: %v", err)
+		}
+		return &data, nil
+	case CatalogFormatCSV:
+		return parseCatalogCSV(r, recordType)
+	default:
+		return nil, fmt.Errorf("unsupported catalog format: %s", format)
+	}
+}
+
+// parseCatalogCSV reads a CSV file whose header names the columns
+func parseCatalogCSV(r io.Reader, recordType string) (*CatalogData, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	expected := productCSVHeader
+	if recordType == CatalogTypeCategories {
+		expected = categoryCSVHeader
+	} else if recordType != CatalogTypeProducts {
+		return nil, fmt.Errorf("unsupported catalog type: %s", recordType)
+	}
+	for _, required := range []string{"id", "name"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing required column %q (expected %s)", required, strings.Join(expected, ","))
+		}
+	}
+
+	data := &CatalogData{}
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %v", line, err)
+		}
+
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		if recordType == CatalogTypeCategories {
+			data.Categories = append(data.Categories, ThorneCategory{
+				ID:          cell("id"),
+				Name:        cell("name"),
+				Description: cell("description"),
+				Icon:        cell("icon"),
+				Benefits:    splitList(cell("benefits")),
+				Color:       cell("color"),
+			})
+			continue
+		}
+
+		// Cells that don't parse are kept as errors so validation can report the row
+		var cellErrors []string
+		wholesalePrice, err := parsePrice(cell("wholesale_price"))
+		if err != nil {
+			cellErrors = append(cellErrors, "wholesale_price "+err.Error())
+		}
+		retailPrice, err := parsePrice(cell("retail_price"))
+		if err != nil {
+			cellErrors = append(cellErrors, "retail_price "+err.Error())
+		}
+		inStock, err := parseBoolDefault(cell("in_stock"), true)
+		if err != nil {
+			cellErrors = append(cellErrors, "in_stock "+err.Error())
+		}
+		product := ThorneProduct{
+			ID:             cell("id"),
+			Name:           cell("name"),
+			Description:    cell("description"),
+			Category:       cell("category"),
+			ImageURL:       cell("image_url"),
+			WholesalePrice: wholesalePrice,
+			RetailPrice:    retailPrice,
+			SKU:            cell("sku"),
+			InStock:        inStock,
+			Benefits:       splitList(cell("benefits")),
+			Ingredients:    splitList(cell("ingredients")),
+			Fulfillment:    cell("fulfillment"),
+		}
+		if len(cellErrors) > 0 {
+			if data.productErrors == nil {
+				data.productErrors = make(map[int][]string)
+			}
+			data.productErrors[len(data.Products)] = cellErrors
+		}
+		data.Products = append(data.Products, product)
+	}
+
+	return data, nil
+}
+
+// WriteCatalog writes products or categories in the requested format
+func WriteCatalog(w io.Writer, data *CatalogData, format, recordType string) error {
+	switch format {
+	case CatalogFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	case CatalogFormatCSV:
+		writer := csv.NewWriter(w)
+		switch recordType {
+		case CatalogTypeProducts:
+			writer.Write(productCSVHeader)
+			for _, p := range data.Products {
+				writer.Write([]string{
+					p.ID, p.Name, p.Description, p.Category, p.ImageURL,
+					strconv.FormatFloat(p.WholesalePrice, 'f', 2, 64),
+					strconv.FormatFloat(p.RetailPrice, 'f', 2, 64),
+					p.SKU, strconv.FormatBool(p.InStock),
+					strings.Join(p.Benefits, csvListSeparator),
+					strings.Join(p.Ingredients, csvListSeparator),
+					p.Fulfillment,
+				})
+			}
+		case CatalogTypeCategories:
+			writer.Write(categoryCSVHeader)
+			for _, c := range data.Categories {
+				writer.Write([]string{
+					c.ID, c.Name, c.Description, c.Icon,
+					strings.Join(c.Benefits, csvListSeparator), c.Color,
+				})
+			}
+		default:
+			return fmt.Errorf("unsupported catalog type: %s", recordType)
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("unsupported catalog format: %s", format)
+	}
+}
+
+// ExportCatalog returns the current catalog, limited to one record type when given
+func (s *ThorneService) ExportCatalog(recordType string) (*CatalogData, error) {
+	data := &CatalogData{}
+	var err error
+
+	if recordType == "" || recordType == CatalogTypeProducts {
+		if data.Products, err = s.GetProducts(); err != nil {
+			return nil, err
+		}
+	}
+	if recordType == "" || recordType == CatalogTypeCategories {
+		if data.Categories, err = s.GetCategories(); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// ImportCatalog validates every record and, unless dryRun is set or a record
+// is invalid, upserts the whole batch by ID in a single all-or-nothing write
+func (s *ThorneService) ImportCatalog(data *CatalogData, dryRun bool) (*CatalogImportReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	products, err := s.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CatalogImportReport{DryRun: dryRun, Rows: []CatalogRowResult{}}
+
+	// Categories first, so products in the same batch may reference new ones
+	categoryIndex := make(map[string]int)
+	for i, c := range categories {
+		categoryIndex[c.ID] = i
+	}
+	seenCategories := make(map[string]int)
+	for i, c := range data.Categories {
+		row := CatalogRowResult{Row: i + 1, Type: "category", ID: c.ID}
+		if c.ID == "" {
+			row.Errors = append(row.Errors, "id is required")
+		} else if !isValidCatalogID(c.ID) {
+			row.Errors = append(row.Errors, "id may only contain lowercase letters, digits and dashes")
+		}
+		if c.Name == "" {
+			row.Errors = append(row.Errors, "name is required")
+		}
+		if first, dup := seenCategories[c.ID]; dup && c.ID != "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate id (first seen on row %d)", first))
+		}
+		seenCategories[c.ID] = row.Row
+
+		if len(row.Errors) == 0 {
+			if existing, ok := categoryIndex[c.ID]; ok {
+				if categoriesEqual(categories[existing], c) {
+					row.Action = "unchanged"
+				} else {
+					row.Action = "update"
+					categories[existing] = c
+				}
+			} else {
+				row.Action = "create"
+				categoryIndex[c.ID] = len(categories)
+				categories = append(categories, c)
+			}
+		}
+		report.addRow(row)
+	}
+
+	productIndex := make(map[string]int)
+	for i, p := range products {
+		productIndex[p.ID] = i
+	}
+	batchIDs := make(map[string]bool)
+	for _, p := range data.Products {
+		batchIDs[p.ID] = true
+	}
+	seenProducts := make(map[string]int)
+	seenSKUs := make(map[string]int)
+	for i, p := range data.Products {
+		row := CatalogRowResult{Row: i + 1, Type: "product", ID: p.ID, SKU: p.SKU}
+		row.Errors = append(row.Errors, data.productErrors[i]...)
+		if p.ID == "" {
+			row.Errors = append(row.Errors, "id is required")
+		}
+		if p.Name == "" {
+			row.Errors = append(row.Errors, "name is required")
+		}
+		if p.SKU == "" {
+			row.Errors = append(row.Errors, "sku is required")
+		}
+		if _, ok := categoryIndex[p.Category]; !ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("unknown category id %q", p.Category))
+		}
+		if !(p.WholesalePrice >= 0) {
+			row.Errors = append(row.Errors, "wholesale_price must be a non-negative number")
+		}
+		if !(p.RetailPrice >= 0) {
+			row.Errors = append(row.Errors, "retail_price must be a non-negative number")
+		}
+		if p.Fulfillment != "" && p.Fulfillment != FulfillmentDirect && p.Fulfillment != FulfillmentThorne {
+			row.Errors = append(row.Errors, fmt.Sprintf("fulfillment must be %s or %s", FulfillmentDirect, FulfillmentThorne))
+		}
+		if first, dup := seenProducts[p.ID]; dup && p.ID != "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate id (first seen on row %d)", first))
+		}
+		seenProducts[p.ID] = row.Row
+		sku := strings.ToUpper(p.SKU)
+		if first, dup := seenSKUs[sku]; dup && sku != "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate sku (first seen on row %d)", first))
+		}
+		seenSKUs[sku] = row.Row
+		for _, existing := range products {
+			if existing.ID != p.ID && strings.EqualFold(existing.SKU, p.SKU) && !batchIDs[existing.ID] {
+				row.Errors = append(row.Errors, fmt.Sprintf("sku already used by product %s", existing.ID))
+				break
+			}
+		}
+
+		if len(row.Errors) == 0 {
+			if existing, ok := productIndex[p.ID]; ok {
+				if productsEqual(products[existing], p) {
+					row.Action = "unchanged"
+				} else {
+					row.Action = "update"
+					products[existing] = p
+				}
+			} else {
+				row.Action = "create"
+				productIndex[p.ID] = len(products)
+				products = append(products, p)
+			}
+		}
+		report.addRow(row)
+	}
+
+	report.Valid = report.ErrorCount == 0
+	if dryRun || !report.Valid || report.Created+report.Updated == 0 {
+		return report, nil
+	}
+
+	if err := s.writeCatalogFiles(products, categories); err != nil {
+		return nil, err
+	}
+	report.Applied = true
+	return report, nil
+}
+
+// addRow records a row result and updates the report counters
+func (r *CatalogImportReport) addRow(row CatalogRowResult) {
+	if len(row.Errors) > 0 {
+		row.Action = "invalid"
+		r.ErrorCount += len(row.Errors)
+	}
+	switch row.Action {
+	case "create":
+		r.Created++
+	case "update":
+		r.Updated++
+	case "unchanged":
+		r.Unchanged++
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+// writeCatalogFiles replaces the products and categories files together. Both
+// files are staged first; if the second rename fails the first is rolled back.
+func (s *ThorneService) writeCatalogFiles(products []ThorneProduct, categories []ThorneCategory) error {
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"thorne-categories.json", struct {
+			Categories []ThorneCategory `json:"categories"`
+		}{categories}},
+		{"thorne-products.json", struct {
+			Products []ThorneProduct `json:"products"`
+		}{products}},
+	}
+
+	var staged, backups []string
+	cleanup := func() {
+		for _, path := range staged {
+			os.Remove(path)
+		}
+	}
+
+	for _, file := range files {
+		jsonData, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("failed to marshal data for %s: %v", file.name, err)
+		}
+		target := filepath.Join(s.configPath, file.name)
+		current, err := os.ReadFile(target)
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("failed to read config file %s: %v", file.name, err)
+		}
+		backups = append(backups, string(current))
+
+		tmp := target + ".import"
+		if err := os.WriteFile(tmp, jsonData, 0644); err != nil {
+			cleanup()
+			return fmt.Errorf("failed to stage config file %s: %v", file.name, err)
+		}
+		staged = append(staged, tmp)
+	}
+
+	for i, file := range files {
+		target := filepath.Join(s.configPath, file.name)
+		if err := os.Rename(staged[i], target); err != nil {
+			for j := 0; j < i; j++ {
+				os.WriteFile(filepath.Join(s.configPath, files[j].name), []byte(backups[j]), 0644)
+			}
+			cleanup()
+			return fmt.Errorf("failed to write config file %s: %v", file.name, err)
+		}
+	}
+
+	return nil
+}
+
+// CatalogReportSummary formats a short human-readable summary for CLI output
+func CatalogReportSummary(report *CatalogImportReport) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "created=%d updated=%d unchanged=%d errors=%d dry_run=%t applied=%t\n",
+		report.Created, report.Updated, report.Unchanged, report.ErrorCount, report.DryRun, report.Applied)
+
+	rows := append([]CatalogRowResult(nil), report.Rows...)
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Type > rows[j].Type })
+	for _, row := range rows {
+		for _, e := range row.Errors {
+			fmt.Fprintf(&b, "%s row %d (%s): %s\n", row.Type, row.Row, row.ID, e)
+		}
+	}
+	return b.String()
+}
+
+// isValidCatalogID allows the slug-style IDs used by the category files
+func isValidCatalogID(id string) bool {
+	for _, r := range id {
+		if !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	var items []string
+	for _, item := range strings.Split(value, csvListSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePrice reads a required price cell; the error completes a message
+// starting with the column name
+func parsePrice(value string) (float64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("is required")
+	}
+	price, err := strconv.ParseFloat(strings.TrimPrefix(value, "$"), 64)
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) {
+		return 0, fmt.Errorf("must be a number, got %q", value)
+	}
+	return price, nil
+}
+
+// parseBoolDefault reads an optional yes/no cell, returning fallback when it
+// is blank
+func parseBoolDefault(value string, fallback bool) (bool, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	switch strings.ToLower(value) {
+	case "yes", "y":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback, fmt.Errorf("must be true/false or yes/no, got %q", value)
+	}
+	return parsed, nil
+}
+
+func productsEqual(a, b ThorneProduct) bool {
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return bytes.Equal(left, right)
+}
+
+func categoriesEqual(a, b ThorneCategory) bool {
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return bytes.Equal(left, right)
+}
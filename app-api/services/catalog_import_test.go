@@ -0,0 +1,64 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportCatalogReportsInvalidCells(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"thorne-products.json":   `{"products": []}`,
+		"thorne-categories.json": `{"categories": [{"id": "vitamins", "name": "Vitamins"}]}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	csv := strings.Join([]string{
+		"id,name,category,wholesale_price,retail_price,sku,in_stock",
+		"ok,Good,vitamins,10,$20,SKU-1,yes",
+		"blank,Blank price,vitamins,,20,SKU-2,",
+		"bad,Bad stock,vitamins,10,20,SKU-3,maybe",
+		"nan,Bad price,vitamins,ten,20,SKU-4,true",
+	}, "\n")
+	data, err := ParseCatalog(strings.NewReader(csv), CatalogFormatCSV, CatalogTypeProducts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := NewThorneService(dir).ImportCatalog(data, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"ok":    "",
+		"blank": "wholesale_price is required",
+		"bad":   `in_stock must be true/false or yes/no, got "maybe"`,
+		"nan":   `wholesale_price must be a number, got "ten"`,
+	}
+	checked := 0
+	for _, row := range report.Rows {
+		expected, ok := want[row.ID]
+		if !ok {
+			continue
+		}
+		checked++
+		if expected == "" {
+			if len(row.Errors) > 0 {
+				t.Errorf("row %s: unexpected errors %v", row.ID, row.Errors)
+			}
+			continue
+		}
+		if row.Action != "invalid" || len(row.Errors) == 0 || row.Errors[0] != expected {
+			t.Errorf("row %s: action %q errors %v, want invalid with %q", row.ID, row.Action, row.Errors, expected)
+		}
+	}
+	if checked != len(want) {
+		t.Errorf("report has %d of the %d expected rows", checked, len(want))
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// SpamChecker scores a comment before it is stored. Implementations may
+// call out to an external service; an error leaves the comment pending for
+// a moderator rather than rejecting it.
+type SpamChecker interface {
+	Check(comment *Comment) (*SpamVerdict, error)
+}
+
+// SpamVerdict is a checker's opinion of a comment. Comments judged spam go
+// straight to the spam queue.
+type SpamVerdict struct {
+	Spam    bool     `json:"spam"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+var spamLinkPattern = regexp.MustCompile(`(?i)https?://|www\.|\[url`)
+
+// defaultSpamTerms are phrases that rarely appear in a genuine comment
+var defaultSpamTerms = []string{
+	"viagra", "cialis", "casino", "payday loan", "crypto giveaway", "buy followers",
+	"work from home", "earn money fast", "seo services", "click here",
+}
+
+// HeuristicSpamChecker is the default SpamChecker. It adds up weights for
+// common spam signals (links, known phrases, shouting, repeated
+// characters) and calls a comment spam once the total reaches Threshold.
+type HeuristicSpamChecker struct {
+	Terms     []string
+	MaxLinks  int
+	Threshold float64
+}
+
+// NewHeuristicSpamChecker creates a heuristic checker with the default
+// phrase list, allowing two links per comment
+func NewHeuristicSpamChecker() *HeuristicSpamChecker {
+	return &HeuristicSpamChecker{
+		Terms:     defaultSpamTerms,
+		MaxLinks:  2,
+		Threshold: 1,
+	}
+}
+
+// Check scores a comment's body, author name and URL
+func (c *HeuristicSpamChecker) Check(comment *Comment) (*SpamVerdict, error) {
+	verdict := &SpamVerdict{Reasons: []string{}}
+	add := func(score float64, reason string) {
+		verdict.Score += score
+		verdict.Reasons = append(verdict.Reasons, reason)
+	}
+
+	body := strings.ToLower(comment.Body)
+	links := len(spamLinkPattern.FindAllString(comment.Body, -1))
+	if links > c.MaxLinks {
+		add(1, "too many links")
+	} else if links > 0 && len(strings.Fields(comment.Body)) <= 3*links {
+		add(0.6, "mostly links")
+	}
+
+	for _, term := range c.Terms {
+		if strings.Contains(body, term) || strings.Contains(strings.ToLower(comment.AuthorName), term) {
+			add(0.8, "contains \""+term+"\"")
+		}
+	}
+
+	if spamLinkPattern.MatchString(comment.AuthorName) {
+		add(1, "link in author name")
+	}
+	if longestRun(comment.Body) >= 10 {
+		add(0.4, "repeated characters")
+	}
+
+	var letters, upper int
+	for _, r := range comment.Body {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters >= 20 && upper*10 >= letters*7 {
+		add(0.4, "mostly capitals")
+	}
+
+	verdict.Spam = verdict.Score >= c.Threshold
+	return verdict, nil
+}
+
+// longestRun returns the length of the longest run of one repeated rune
+func longestRun(text string) int {
+	longest, run := 0, 0
+	var previous rune
+	for i, r := range text {
+		if i > 0 && r == previous {
+			run++
+		} else {
+			run = 1
+		}
+		previous = r
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
@@ -0,0 +1,726 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Comment statuses; only approved comments are shown to readers
+const (
+	CommentPending  = "pending"
+	CommentApproved = "approved"
+	CommentSpam     = "spam"
+	CommentDeleted  = "deleted"
+)
+
+// Site settings that control comments; all are booleans and default to
+// false, so comments stay off until a site opts in
+const (
+	SettingCommentsEnabled        = "comments_enabled"
+	SettingCommentsAllowAnonymous = "comments_allow_anonymous"
+	SettingCommentsAutoApprove    = "comments_auto_approve"
+)
+
+const (
+	maxCommentLength     = 10000
+	defaultCommentIPRate = 5
+	defaultCommentUser   = 10
+	defaultCommentWindow = 10 * time.Minute
+)
+
+// Comment is a reader comment. Body is plain text. Moderation fields
+// (email, IP address, spam score) are cleared before comments are shown
+// publicly.
+type Comment struct {
+	ID          string     `json:"id"`
+	SiteID      string     `json:"site_id"`
+	PostID      string     `json:"post_id"`
+	PostTitle   string     `json:"post_title,omitempty"`
+	ParentID    string     `json:"parent_id,omitempty"`
+	UserID      string     `json:"user_id,omitempty"`
+	AuthorName  string     `json:"author_name"`
+	AuthorEmail string     `json:"author_email,omitempty"`
+	AuthorURL   string     `json:"author_url,omitempty"`
+	Body        string     `json:"body"`
+	Status      string     `json:"status"`
+	SpamScore   float64    `json:"spam_score,omitempty"`
+	SpamReasons []string   `json:"spam_reasons,omitempty"`
+	IPAddress   string     `json:"ip_address,omitempty"`
+	ModeratedAt *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Replies     []*Comment `json:"replies,omitempty"`
+}
+
+// CommentInput submits a comment. The author fields are only used for
+// anonymous comments; signed-in users comment under their username.
+type CommentInput struct {
+	ParentID    string `json:"parent_id"`
+	AuthorName  string `json:"author_name"`
+	AuthorEmail string `json:"author_email"`
+	AuthorURL   string `json:"author_url"`
+	Body        string `json:"body"`
+}
+
+// CommentSettings is a site's comment configuration
+type CommentSettings struct {
+	Enabled        bool `json:"enabled"`
+	AllowAnonymous bool `json:"allow_anonymous"`
+	AutoApprove    bool `json:"auto_approve"`
+}
+
+// CommentThread is a post's visible comments as a tree, oldest first
+type CommentThread struct {
+	PostID   string     `json:"post_id"`
+	Count    int        `json:"count"`
+	Comments []*Comment `json:"comments"`
+}
+
+// CommentQueue is one page of the moderation queue, newest first
+type CommentQueue struct {
+	Status   string     `json:"status"`
+	Comments []*Comment `json:"comments"`
+	Total    int        `json:"total"`
+	Limit    int        `json:"limit"`
+	Offset   int        `json:"offset"`
+}
+
+// CommentService stores comments, screens them for spam and rate limits
+// and keeps posts.comment_count equal to each post's approved comments
+type CommentService struct {
+	db           *sql.DB
+	logger       Logger
+	spamChecker  SpamChecker
+	perIPLimit   int
+	perUserLimit int
+	rateWindow   time.Duration
+}
+
+// NewCommentService creates a comment service using the heuristic spam
+// checker and the default rate limits
+func NewCommentService(db *sql.DB, logger Logger) *CommentService {
+	return &CommentService{
+		db:           db,
+		logger:       logger,
+		spamChecker:  NewHeuristicSpamChecker(),
+		perIPLimit:   defaultCommentIPRate,
+		perUserLimit: defaultCommentUser,
+		rateWindow:   defaultCommentWindow,
+	}
+}
+
+// SetSpamChecker replaces the spam checker; nil disables spam checks
+func (s *CommentService) SetSpamChecker(checker SpamChecker) {
+	s.spamChecker = checker
+}
+
+// SetRateLimits changes how many comments one IP address and one user may
+// post per window; a limit of zero or less leaves that limit unchanged
+func (s *CommentService) SetRateLimits(perIP, perUser int, window time.Duration) {
+	if perIP > 0 {
+		s.perIPLimit = perIP
+	}
+	if perUser > 0 {
+		s.perUserLimit = perUser
+	}
+	if window > 0 {
+		s.rateWindow = window
+	}
+}
+
+// IsCommentStatus reports whether status is a known comment status
+func IsCommentStatus(status string) bool {
+	switch status {
+	case CommentPending, CommentApproved, CommentSpam, CommentDeleted:
+		return true
+	}
+	return false
+}
+
+// Settings returns a site's comment settings
+func (s *CommentService) Settings(siteRef string) (*CommentSettings, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return loadCommentSettings(s.db, siteID)
+}
+
+func loadCommentSettings(db *sql.DB, siteID string) (*CommentSettings, error) {
+	rows, err := db.Query(`
+		SELECT setting_key, COALESCE(setting_value, '') FROM site_settings
+		WHERE site_id = $1 AND setting_key IN ($2, $3, $4)`,
+		siteID, SettingCommentsEnabled, SettingCommentsAllowAnonymous, SettingCommentsAutoApprove)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load comment settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := &CommentSettings{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan comment setting: %w", err)
+		}
+		enabled, _ := strconv.ParseBool(value)
+		switch key {
+		case SettingCommentsEnabled:
+			settings.Enabled = enabled
+		case SettingCommentsAllowAnonymous:
+			settings.AllowAnonymous = enabled
+		case SettingCommentsAutoApprove:
+			settings.AutoApprove = enabled
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load comment settings: %w", err)
+	}
+	return settings, nil
+}
+
+// checkCommentSetting rejects comment settings that are not booleans
+func checkCommentSetting(key, stored string) error {
+	switch key {
+	case SettingCommentsEnabled, SettingCommentsAllowAnonymous, SettingCommentsAutoApprove:
+		if _, err := strconv.ParseBool(stored); err != nil {
+			return fmt.Errorf("invalid value for setting %q: must be true or false", key)
+		}
+	}
+	return nil
+}
+
+// ListComments returns the visible comments on a published post as a
+// thread. A removed comment that has visible replies stays in the tree as
+// an empty placeholder; the viewer also sees their own pending comments.
+func (s *CommentService) ListComments(siteRef, postID, viewerID string) (*CommentThread, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := publishedPost(s.db, siteID, postID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.post_id::TEXT = $1 AND c.site_id = $2
+		ORDER BY c.created_at, c.id`, postID, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []*Comment
+	for rows.Next() {
+		comment, err := scanComment(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+
+	thread := &CommentThread{PostID: postID, Comments: commentTree(comments, viewerID)}
+	for _, comment := range comments {
+		if comment.Status == CommentApproved {
+			thread.Count++
+		}
+	}
+	return thread, nil
+}
+
+// commentTree nests comments under their parents and drops those the
+// viewer may not see, keeping placeholders for hidden comments with
+// visible replies
+func commentTree(comments []*Comment, viewerID string) []*Comment {
+	byID := make(map[string]*Comment, len(comments))
+	for _, comment := range comments {
+		comment.Replies = nil
+		byID[comment.ID] = comment
+	}
+	roots := []*Comment{}
+	for _, comment := range comments {
+		if parent, ok := byID[comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		} else {
+			roots = append(roots, comment)
+		}
+	}
+
+	var prune func(list []*Comment) []*Comment
+	prune = func(list []*Comment) []*Comment {
+		kept := []*Comment{}
+		for _, comment := range list {
+			comment.Replies = prune(comment.Replies)
+			visible := comment.Status == CommentApproved ||
+				(comment.Status == CommentPending && viewerID != "" && comment.UserID == viewerID)
+			if !visible {
+				if len(comment.Replies) == 0 {
+					continue
+				}
+				*comment = Comment{ID: comment.ID, SiteID: comment.SiteID, PostID: comment.PostID,
+					ParentID: comment.ParentID, Status: CommentDeleted, CreatedAt: comment.CreatedAt,
+					UpdatedAt: comment.UpdatedAt, Replies: comment.Replies}
+			}
+			comment.publicView()
+			kept = append(kept, comment)
+		}
+		return kept
+	}
+	return prune(roots)
+}
+
+// publicView clears fields only moderators see
+func (c *Comment) publicView() {
+	c.AuthorEmail = ""
+	c.IPAddress = ""
+	c.SpamScore = 0
+	c.SpamReasons = nil
+	c.ModeratedAt = nil
+}
+
+// CreateComment submits a comment on a published post. userID is empty for
+// anonymous readers; ipAddress and userAgent identify the submitter for
+// rate limiting and moderation. The comment is approved, queued or marked
+// spam according to the spam check and the site's settings.
+func (s *CommentService) CreateComment(siteRef, postID, userID, ipAddress, userAgent string, input CommentInput) (*Comment, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := loadCommentSettings(s.db, siteID)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Enabled {
+		return nil, fmt.Errorf("permission denied: comments are turned off for this site")
+	}
+	if userID == "" && !settings.AllowAnonymous {
+		return nil, fmt.Errorf("permission denied: sign in to comment")
+	}
+	postTitle, postAuthorID, err := publishedPost(s.db, siteID, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &Comment{
+		SiteID:    siteID,
+		PostID:    postID,
+		PostTitle: postTitle,
+		ParentID:  strings.TrimSpace(input.ParentID),
+		UserID:    userID,
+		Body:      strings.TrimSpace(input.Body),
+	}
+	if err := s.fillAuthor(comment, input); err != nil {
+		return nil, err
+	}
+	if comment.Body == "" {
+		return nil, fmt.Errorf("body is required")
+	}
+	if utf8.RuneCountInString(comment.Body) > maxCommentLength {
+		return nil, fmt.Errorf("body must be at most %d characters", maxCommentLength)
+	}
+
+	var parentAuthorID string
+	if comment.ParentID != "" {
+		err := s.db.QueryRow(`
+			SELECT COALESCE(user_id::TEXT, '') FROM comments
+			WHERE id::TEXT = $1 AND post_id::TEXT = $2 AND status = $3`,
+			comment.ParentID, postID, CommentApproved).Scan(&parentAuthorID)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("parent comment not found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up parent comment: %w", err)
+		}
+	}
+
+	ip, _ := auditIP(ipAddress).(string)
+	if err := s.checkRate(ip, userID, postID, comment.Body); err != nil {
+		return nil, err
+	}
+
+	comment.Status = CommentPending
+	if settings.AutoApprove {
+		comment.Status = CommentApproved
+	}
+	comment.SpamReasons = []string{}
+	if s.spamChecker != nil {
+		verdict, err := s.spamChecker.Check(comment)
+		if err != nil {
+			// Let a moderator decide when the checker is unavailable
+			s.logger.Error("comment_service", "create_comment", "Spam check failed", map[string]interface{}{
+				"post_id": postID,
+				"error":   err.Error(),
+			})
+			comment.Status = CommentPending
+		} else {
+			comment.SpamScore = verdict.Score
+			comment.SpamReasons = verdict.Reasons
+			if verdict.Spam {
+				comment.Status = CommentSpam
+			}
+		}
+	}
+	reasons, err := json.Marshal(comment.SpamReasons)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode spam reasons: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO comments (site_id, post_id, parent_id, user_id, author_name, author_email, author_url, body,
+		status, spam_score, spam_reasons, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12::INET, $13)
+		RETURNING id, created_at, updated_at`,
+		siteID, postID, nullableString(comment.ParentID), userIDParam(userID), comment.AuthorName,
+		nullableString(comment.AuthorEmail), nullableString(comment.AuthorURL), comment.Body, comment.Status,
+		comment.SpamScore, string(reasons), nullableString(ip), nullableString(userAgent)).Scan(
+		&comment.ID, &comment.CreatedAt, &comment.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+	comment.IPAddress = ip
+
+	if comment.Status == CommentApproved {
+		if err := recountComments(tx, postID); err != nil {
+			return nil, err
+		}
+	}
+	if err := notifyComment(tx, comment, postAuthorID, parentAuthorID, userID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit comment: %w", err)
+	}
+
+	s.logger.Info("comment_service", "create_comment", "Comment submitted", map[string]interface{}{
+		"comment_id": comment.ID,
+		"site_id":    siteID,
+		"post_id":    postID,
+		"status":     comment.Status,
+		"spam_score": comment.SpamScore,
+	})
+	return comment, nil
+}
+
+// fillAuthor sets the comment's author from the signed-in user or, for
+// anonymous comments, from the validated input
+func (s *CommentService) fillAuthor(comment *Comment, input CommentInput) error {
+	if comment.UserID != "" {
+		err := s.db.QueryRow(`SELECT username FROM users WHERE id::TEXT = $1`, comment.UserID).Scan(&comment.AuthorName)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up user: %w", err)
+		}
+		return nil
+	}
+
+	comment.AuthorName = strings.TrimSpace(input.AuthorName)
+	if comment.AuthorName == "" {
+		return fmt.Errorf("author_name is required for anonymous comments")
+	}
+	if utf8.RuneCountInString(comment.AuthorName) > 100 {
+		return fmt.Errorf("author_name must be at most 100 characters")
+	}
+	if email := strings.TrimSpace(input.AuthorEmail); email != "" {
+		address, err := mail.ParseAddress(email)
+		if err != nil || address.Address != email {
+			return fmt.Errorf("author_email must be an email address")
+		}
+		comment.AuthorEmail = email
+	}
+	if link := strings.TrimSpace(input.AuthorURL); link != "" {
+		parsed, err := url.Parse(link)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(link) > 500 {
+			return fmt.Errorf("author_url must be an absolute http or https URL")
+		}
+		comment.AuthorURL = link
+	}
+	return nil
+}
+
+// checkRate enforces the per-IP and per-user limits and rejects the same
+// text posted twice to a post within the window
+func (s *CommentService) checkRate(ip, userID, postID, body string) error {
+	since := time.Now().Add(-s.rateWindow)
+	var byIP, byUser, duplicates int
+	err := s.db.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE $1 <> '' AND ip_address = NULLIF($1, '')::INET),
+			COUNT(*) FILTER (WHERE $2 <> '' AND user_id::TEXT = $2),
+			COUNT(*) FILTER (WHERE post_id::TEXT = $3 AND body = $4)
+		FROM comments
+		WHERE created_at > $5 AND (($1 <> '' AND ip_address = NULLIF($1, '')::INET) OR ($2 <> '' AND user_id::TEXT = $2))`,
+		ip, userID, postID, body, since).Scan(&byIP, &byUser, &duplicates)
+	if err != nil {
+		return fmt.Errorf("failed to check comment rate: %w", err)
+	}
+	if byIP >= s.perIPLimit {
+		return fmt.Errorf("rate limit exceeded: at most %d comments per %s from one address", s.perIPLimit, s.rateWindow)
+	}
+	if byUser >= s.perUserLimit {
+		return fmt.Errorf("rate limit exceeded: at most %d comments per %s per user", s.perUserLimit, s.rateWindow)
+	}
+	if duplicates > 0 {
+		return fmt.Errorf("duplicate comment already exists")
+	}
+	return nil
+}
+
+// ModerationQueue lists a site's comments in one status, pending by
+// default, optionally for one post
+func (s *CommentService) ModerationQueue(siteRef, status, postID string, limit, offset int) (*CommentQueue, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if status == "" {
+		status = CommentPending
+	}
+	if !IsCommentStatus(status) {
+		return nil, fmt.Errorf("status must be one of: pending, approved, spam, deleted")
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+commentColumns+`, p.title, COUNT(*) OVER ()
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.site_id = $1 AND c.status = $2 AND ($3 = '' OR c.post_id::TEXT = $3)
+		ORDER BY c.created_at DESC, c.id
+		LIMIT $4 OFFSET $5`, siteID, status, postID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer rows.Close()
+
+	queue := &CommentQueue{Status: status, Comments: []*Comment{}, Limit: limit, Offset: offset}
+	for rows.Next() {
+		comment, err := scanComment(rows, true, &queue.Total)
+		if err != nil {
+			return nil, err
+		}
+		queue.Comments = append(queue.Comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	return queue, nil
+}
+
+// ModerateComment moves a comment to another status, updates its post's
+// comment count and records the decision in the audit log
+func (s *CommentService) ModerateComment(siteRef, commentID, status, moderatorID, ipAddress, userAgent string) (*Comment, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if !IsCommentStatus(status) {
+		return nil, fmt.Errorf("status must be one of: pending, approved, spam, deleted")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	comment, err := scanComment(tx.QueryRow(`
+		SELECT `+commentColumns+`, p.title
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.id::TEXT = $1 AND c.site_id = $2
+		FOR UPDATE OF c`, commentID, siteID), true)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("comment not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	previous := comment.Status
+	if previous == status {
+		return comment, nil
+	}
+
+	err = tx.QueryRow(`
+		UPDATE comments SET status = $2, moderated_by = $3, moderated_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING moderated_at, updated_at`, comment.ID, status, userIDParam(moderatorID)).Scan(
+		&comment.ModeratedAt, &comment.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to moderate comment: %w", err)
+	}
+	comment.Status = status
+
+	if previous == CommentApproved || status == CommentApproved {
+		if err := recountComments(tx, comment.PostID); err != nil {
+			return nil, err
+		}
+	}
+	if status == CommentApproved {
+		var postAuthorID, parentAuthorID string
+		err := tx.QueryRow(`
+			SELECT COALESCE(p.user_id::TEXT, ''), COALESCE(parent.user_id::TEXT, '')
+			FROM posts p
+			LEFT JOIN comments parent ON parent.id = $2::UUID
+			WHERE p.id = $1`, comment.PostID, nullableString(comment.ParentID)).Scan(&postAuthorID, &parentAuthorID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up comment recipients: %w", err)
+		}
+		if err := notifyComment(tx, comment, postAuthorID, parentAuthorID, comment.UserID); err != nil {
+			return nil, err
+		}
+	}
+	err = RecordAudit(tx, AuditEntry{
+		UserID:       moderatorID,
+		Action:       "comment.moderate",
+		ResourceType: "comment",
+		ResourceID:   comment.ID,
+		Details: map[string]interface{}{
+			"site_id": siteID,
+			"post_id": comment.PostID,
+			"from":    previous,
+			"to":      status,
+		},
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit moderation: %w", err)
+	}
+
+	s.logger.Info("comment_service", "moderate_comment", "Comment moderated", map[string]interface{}{
+		"comment_id":   comment.ID,
+		"site_id":      siteID,
+		"from":         previous,
+		"to":           status,
+		"moderator_id": moderatorID,
+	})
+	return comment, nil
+}
+
+// publishedPost returns the title and author of a live, published post
+func publishedPost(q queryRower, siteID, postID string) (string, string, error) {
+	var title, authorID string
+	err := q.QueryRow(`
+		SELECT title, COALESCE(user_id::TEXT, '') FROM posts
+		WHERE id::TEXT = $1 AND site_id = $2 AND published = true AND deleted_at IS NULL`,
+		postID, siteID).Scan(&title, &authorID)
+	if err == sql.ErrNoRows {
+		return "", "", fmt.Errorf("post not found")
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to look up post: %w", err)
+	}
+	return title, authorID, nil
+}
+
+// recountComments sets a post's comment_count to its approved comments
+func recountComments(db execer, postID string) error {
+	_, err := db.Exec(`
+		UPDATE posts SET comment_count = (
+			SELECT COUNT(*) FROM comments WHERE post_id = posts.id AND status = 'approved')
+		WHERE id::TEXT = $1`, postID)
+	if err != nil {
+		return fmt.Errorf("failed to update comment count: %w", err)
+	}
+	return nil
+}
+
+// notifyComment tells the post's author about a comment awaiting
+// moderation, and once it is approved also the author of the comment it
+// replies to. Nobody is notified about their own comment or about spam.
+func notifyComment(db execer, comment *Comment, postAuthorID, parentAuthorID, actorID string) error {
+	base := Notification{
+		SiteID:       comment.SiteID,
+		ActorID:      actorID,
+		ResourceType: "comment",
+		ResourceID:   comment.ID,
+		Payload: map[string]interface{}{
+			"post_id":     comment.PostID,
+			"post_title":  comment.PostTitle,
+			"author_name": comment.AuthorName,
+		},
+	}
+
+	var notifications []Notification
+	switch comment.Status {
+	case CommentPending:
+		base.Type, base.RecipientID = "comment.pending", postAuthorID
+		notifications = append(notifications, base)
+	case CommentApproved:
+		base.Type, base.RecipientID = "comment.created", postAuthorID
+		notifications = append(notifications, base)
+		if parentAuthorID != "" && parentAuthorID != postAuthorID {
+			base.Type, base.RecipientID = "comment.reply", parentAuthorID
+			notifications = append(notifications, base)
+		}
+	}
+
+	for _, notification := range notifications {
+		if notification.RecipientID == "" || notification.RecipientID == actorID {
+			continue
+		}
+		if err := RaiseNotification(db, notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const commentColumns = `c.id, c.site_id, c.post_id::TEXT, COALESCE(c.parent_id::TEXT, ''), COALESCE(c.user_id::TEXT, ''),
+	COALESCE(u.username, c.author_name, ''), COALESCE(c.author_email, ''), COALESCE(c.author_url, ''), c.body, c.status,
+	c.spam_score, c.spam_reasons, COALESCE(host(c.ip_address), ''), c.moderated_at, c.created_at, c.updated_at`
+
+// scanComment reads commentColumns, followed by the post title when
+// withPost is set and then any extra columns
+func scanComment(row rowScanner, withPost bool, extra ...interface{}) (*Comment, error) {
+	comment := &Comment{}
+	var reasons []byte
+	dest := []interface{}{&comment.ID, &comment.SiteID, &comment.PostID, &comment.ParentID, &comment.UserID,
+		&comment.AuthorName, &comment.AuthorEmail, &comment.AuthorURL, &comment.Body, &comment.Status,
+		&comment.SpamScore, &reasons, &comment.IPAddress, &comment.ModeratedAt, &comment.CreatedAt, &comment.UpdatedAt}
+	if withPost {
+		dest = append(dest, &comment.PostTitle)
+	}
+	dest = append(dest, extra...)
+	if err := row.Scan(dest...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan comment: %w", err)
+	}
+	if err := json.Unmarshal(reasons, &comment.SpamReasons); err != nil {
+		comment.SpamReasons = []string{}
+	}
+	return comment, nil
+}
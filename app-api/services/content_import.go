@@ -0,0 +1,1079 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Import source formats
+const (
+	ImportFormatWXR      = "wxr"
+	ImportFormatMarkdown = "markdown"
+)
+
+// Import item types and the actions an import reports for them
+const (
+	ImportTypePost     = "post"
+	ImportTypeMedia    = "media"
+	ImportTypeAuthor   = "author"
+	ImportTypeTag      = "tag"
+	ImportTypeCategory = "category"
+	ImportTypeRedirect = "redirect"
+
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+	ImportConflict  = "conflict"
+	ImportSkip      = "skip"
+	ImportError     = "error"
+)
+
+const (
+	// importDownloadTimeout bounds fetching one remote media file
+	importDownloadTimeout = 30 * time.Second
+	// defaultMaxImportBytes limits an export file or archive uploaded
+	// through the API
+	defaultMaxImportBytes = 100 << 20
+)
+
+// ImportSource is content read from another platform, ready to import into
+// a site. Posts refer to authors by Key, to categories by slug and to
+// media by Key.
+type ImportSource struct {
+	Format     string
+	Authors    []*ImportAuthor
+	Categories []*ImportCategory
+	Posts      []*ImportPost
+	Media      []*ImportMedia
+	// Skipped lists source items the importer doesn't handle, for the report
+	Skipped []ImportItemResult
+}
+
+// ImportAuthor is a post author; authors are matched to users by email
+type ImportAuthor struct {
+	Key   string
+	Name  string
+	Email string
+}
+
+// ImportCategory is a category, nested under the category whose slug is
+// Parent
+type ImportCategory struct {
+	Slug        string
+	Name        string
+	Parent      string
+	Description string
+}
+
+// ImportPost is one post. Key identifies it in the source across runs;
+// OldPaths are the URL paths it had on the old site, which redirect to
+// its new URL; MediaRefs maps references in Content to media keys.
+type ImportPost struct {
+	Key        string
+	Title      string
+	Slug       string
+	Content    string
+	Format     string
+	Status     string
+	Date       time.Time
+	PublishAt  *time.Time
+	Locale     string
+	AuthorKey  string
+	Tags       []string
+	Categories []string
+	OldPaths   []string
+	MediaRefs  map[string]string
+}
+
+// ImportMedia is a media file, read from Path or, when downloads are
+// allowed, fetched from URL
+type ImportMedia struct {
+	Key      string
+	Filename string
+	Title    string
+	AltText  string
+	Path     string
+	URL      string
+}
+
+// ImportOptions control an import. UserID authors posts whose author is
+// unknown and uploads the media.
+type ImportOptions struct {
+	UserID        string `json:"-"`
+	DryRun        bool   `json:"dry_run"`
+	Force         bool   `json:"force"`
+	DownloadMedia bool   `json:"download_media"`
+	IPAddress     string `json:"-"`
+	UserAgent     string `json:"-"`
+}
+
+// ImportItemResult reports what an import did, or would do, with one item
+type ImportItemResult struct {
+	Type   string   `json:"type"`
+	Source string   `json:"source"`
+	Title  string   `json:"title,omitempty"`
+	Action string   `json:"action"` // create, update, unchanged, conflict, skip, error
+	ID     string   `json:"id,omitempty"`
+	Slug   string   `json:"slug,omitempty"`
+	Notes  []string `json:"notes,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// ImportReport summarises an import or dry run; Summary counts items by
+// type and action
+type ImportReport struct {
+	Format     string                    `json:"format"`
+	SiteID     string                    `json:"site_id"`
+	DryRun     bool                      `json:"dry_run"`
+	Summary    map[string]map[string]int `json:"summary"`
+	ErrorCount int                       `json:"error_count"`
+	Notes      []string                  `json:"notes,omitempty"`
+	Items      []ImportItemResult        `json:"items"`
+}
+
+func (r *ImportReport) add(item ImportItemResult) {
+	if r.Summary[item.Type] == nil {
+		r.Summary[item.Type] = map[string]int{}
+	}
+	r.Summary[item.Type][item.Action]++
+	if item.Action == ImportError {
+		r.ErrorCount++
+	}
+	r.Items = append(r.Items, item)
+}
+
+// ImportService imports posts, authors, tags, categories and media from a
+// WordPress export or a folder of Markdown files. Imports are idempotent:
+// each imported post and media file is remembered by its source key, so a
+// second run skips what is unchanged and updates what changed at the
+// source, leaving posts edited here since the last run alone unless
+// forced.
+type ImportService struct {
+	db          *sql.DB
+	logger      Logger
+	siteContent *SiteContentService
+	media       *MediaService
+	client      *http.Client
+
+	maxUploadBytes int64
+}
+
+// NewImportService creates an import service
+func NewImportService(db *sql.DB, logger Logger, siteContent *SiteContentService, media *MediaService) *ImportService {
+	return &ImportService{
+		db:          db,
+		logger:      logger,
+		siteContent: siteContent,
+		media:       media,
+		client:      newImportHTTPClient(),
+
+		maxUploadBytes: defaultMaxImportBytes,
+	}
+}
+
+// SetMaxUploadBytes overrides the size limit on uploaded imports
+func (s *ImportService) SetMaxUploadBytes(limit int64) {
+	if limit > 0 {
+		s.maxUploadBytes = limit
+	}
+}
+
+// MaxUploadBytes returns the size limit on uploaded imports
+func (s *ImportService) MaxUploadBytes() int64 {
+	return s.maxUploadBytes
+}
+
+// newImportHTTPClient builds the client for media downloads. The URLs come
+// from an uploaded file, so addresses inside the network are refused.
+func newImportHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return fmt.Errorf("refusing to download media from %s", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport, Timeout: importDownloadTimeout}
+}
+
+// importRun is the state of one import
+type importRun struct {
+	s       *ImportService
+	source  *ImportSource
+	opts    ImportOptions
+	report  *ImportReport
+	siteID  string
+	locales *SiteLocales
+	now     time.Time
+	// workflow is set when the site's editorial workflow is enabled, so
+	// posts are imported as drafts awaiting approval
+	workflow bool
+
+	mediaURLs  map[string]string
+	authors    map[string]string
+	tags       map[string]string
+	categories map[string]string
+	// slugs reserves the slugs taken during a dry run, by locale
+	slugs map[string]bool
+}
+
+// Import applies source to a site, or with opts.DryRun only reports what
+// it would do
+func (s *ImportService) Import(siteRef string, source *ImportSource, opts ImportOptions) (*ImportReport, error) {
+	siteID, err := s.siteContent.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if source.Format != ImportFormatWXR && source.Format != ImportFormatMarkdown {
+		return nil, fmt.Errorf("format must be %s or %s", ImportFormatWXR, ImportFormatMarkdown)
+	}
+	if opts.UserID == "" {
+		return nil, fmt.Errorf("an importing user is required")
+	}
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id::TEXT = $1 AND deleted_at IS NULL)`,
+		opts.UserID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("user not found")
+	}
+	locales, err := loadSiteLocales(s.db, siteID)
+	if err != nil {
+		return nil, err
+	}
+	workflowEnabled, err := CheckNewPostStatus(s.db, siteID, PostStatusDraft)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &importRun{
+		s:          s,
+		source:     source,
+		opts:       opts,
+		report:     &ImportReport{Format: source.Format, SiteID: siteID, DryRun: opts.DryRun, Summary: map[string]map[string]int{}, Items: []ImportItemResult{}},
+		siteID:     siteID,
+		locales:    locales,
+		now:        time.Now(),
+		workflow:   workflowEnabled,
+		mediaURLs:  make(map[string]string),
+		authors:    make(map[string]string),
+		tags:       make(map[string]string),
+		categories: make(map[string]string),
+		slugs:      make(map[string]bool),
+	}
+	if workflowEnabled {
+		run.report.Notes = append(run.report.Notes,
+			"the site's editorial workflow is enabled; posts are imported as drafts and published once approved")
+	}
+
+	for _, media := range source.Media {
+		run.report.add(run.importMedia(media))
+	}
+	if err := run.importAuthors(); err != nil {
+		return nil, err
+	}
+	if err := run.importCategories(); err != nil {
+		return nil, err
+	}
+	if err := run.importTags(); err != nil {
+		return nil, err
+	}
+	for _, post := range source.Posts {
+		run.importPost(post)
+	}
+	for _, skipped := range source.Skipped {
+		run.report.add(skipped)
+	}
+
+	if !opts.DryRun {
+		s.siteContent.pageCache.PurgeSite(siteID)
+
+		details := map[string]interface{}{
+			"format":      source.Format,
+			"error_count": run.report.ErrorCount,
+		}
+		for itemType, actions := range run.report.Summary {
+			details[itemType] = actions
+		}
+		if err := RecordAudit(s.db, AuditEntry{
+			UserID:       opts.UserID,
+			Action:       "site.import",
+			ResourceType: "site",
+			ResourceID:   siteID,
+			Details:      details,
+			IPAddress:    opts.IPAddress,
+			UserAgent:    opts.UserAgent,
+		}); err != nil {
+			s.logger.Error("import_service", "audit", "Failed to write audit log", map[string]interface{}{
+				"site_id": siteID,
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	s.logger.Info("import_service", "import", "Imported content", map[string]interface{}{
+		"site_id":     siteID,
+		"format":      source.Format,
+		"dry_run":     opts.DryRun,
+		"posts":       run.report.Summary[ImportTypePost],
+		"error_count": run.report.ErrorCount,
+	})
+	return run.report, nil
+}
+
+// ImportUpload imports an uploaded WXR file or a zip or tar.gz archive of a
+// WordPress export or Markdown folder. The upload is unpacked into a
+// temporary directory that is removed afterwards.
+func (s *ImportService) ImportUpload(siteRef, format string, body io.Reader, opts ImportOptions) (*ImportReport, error) {
+	workDir, err := os.MkdirTemp("", "agoat-import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	uploadPath := filepath.Join(workDir, "upload")
+	file, err := os.Create(uploadPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+	_, err = io.Copy(file, io.LimitReader(body, s.maxUploadBytes+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+	if info, err := os.Stat(uploadPath); err == nil && info.Size() > s.maxUploadBytes {
+		return nil, fmt.Errorf("import exceeds the %d MB upload limit", s.maxUploadBytes>>20)
+	}
+
+	source, err := LoadImportSource(format, uploadPath, "", filepath.Join(workDir, "files"))
+	if err != nil {
+		return nil, err
+	}
+	return s.Import(siteRef, source, opts)
+}
+
+// importItem is a row of import_items
+type importItem struct {
+	targetID  string
+	checksum  string
+	updatedAt time.Time
+}
+
+func (r *importRun) lookupItem(kind, key string) (*importItem, error) {
+	item := &importItem{}
+	err := r.s.db.QueryRow(`
+		SELECT target_id, checksum, updated_at FROM import_items
+		WHERE site_id = $1 AND source = $2 AND kind = $3 AND source_key = $4`,
+		r.siteID, r.source.Format, kind, key).Scan(&item.targetID, &item.checksum, &item.updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up imported item: %w", err)
+	}
+	return item, nil
+}
+
+func (r *importRun) saveItem(q execer, kind, key, targetID, checksum string) error {
+	_, err := q.Exec(`
+		INSERT INTO import_items (site_id, source, kind, source_key, target_id, checksum, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (site_id, source, kind, source_key)
+		DO UPDATE SET target_id = EXCLUDED.target_id, checksum = EXCLUDED.checksum, updated_at = EXCLUDED.updated_at`,
+		r.siteID, r.source.Format, kind, key, targetID, checksum, r.now)
+	if err != nil {
+		return fmt.Errorf("failed to record imported item: %w", err)
+	}
+	return nil
+}
+
+// importMedia uploads a media file unless the same file was imported
+// before. Local files are compared by content; remote files are only
+// downloaded the first time.
+func (r *importRun) importMedia(media *ImportMedia) ImportItemResult {
+	result := ImportItemResult{Type: ImportTypeMedia, Source: media.Key, Title: media.Filename}
+	fail := func(err error) ImportItemResult {
+		result.Action, result.Error = ImportError, err.Error()
+		return result
+	}
+
+	previous, err := r.lookupItem(ImportTypeMedia, media.Key)
+	if err != nil {
+		return fail(err)
+	}
+	var previousAsset *MediaAsset
+	if previous != nil {
+		if asset, err := r.s.media.GetAsset(r.siteID, previous.targetID); err == nil {
+			previousAsset = asset
+		}
+	}
+
+	var data []byte
+	switch {
+	case media.Path != "":
+		data, err = os.ReadFile(media.Path)
+		if err != nil {
+			return fail(fmt.Errorf("failed to read media file: %w", err))
+		}
+	case media.URL != "" && previousAsset != nil:
+		r.mediaURLs[media.Key] = previousAsset.URL
+		result.Action, result.ID = ImportUnchanged, previousAsset.ID
+		return result
+	case media.URL != "" && r.opts.DownloadMedia:
+		if r.opts.DryRun {
+			result.Action = ImportCreate
+			result.Notes = append(result.Notes, "would download "+media.URL)
+			return result
+		}
+		data, err = r.download(media.URL)
+		if err != nil {
+			return fail(err)
+		}
+	default:
+		result.Action = ImportSkip
+		result.Notes = append(result.Notes, "file not available; links to it are left pointing at the old site")
+		return result
+	}
+
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	if previousAsset != nil && previous.checksum == checksum {
+		r.mediaURLs[media.Key] = previousAsset.URL
+		result.Action, result.ID = ImportUnchanged, previousAsset.ID
+		return result
+	}
+	result.Action = ImportCreate
+	if previousAsset != nil {
+		result.Action = ImportUpdate
+		result.Notes = append(result.Notes, "file changed; uploaded as a new asset")
+	}
+	if r.opts.DryRun {
+		return result
+	}
+
+	asset, err := r.s.media.Upload(context.Background(), r.siteID, r.opts.UserID, MediaAssetInput{
+		Filename: media.Filename,
+		AltText:  media.AltText,
+		Title:    media.Title,
+	}, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fail(err)
+	}
+	if err := r.saveItem(r.s.db, ImportTypeMedia, media.Key, asset.ID, checksum); err != nil {
+		return fail(err)
+	}
+	r.mediaURLs[media.Key] = asset.URL
+	result.ID = asset.ID
+	return result
+}
+
+// download fetches a remote media file, bounded by the upload size limit
+func (r *importRun) download(url string) ([]byte, error) {
+	response, err := r.s.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download media: %s returned %s", url, response.Status)
+	}
+	limit := r.s.media.MaxUploadBytes()
+	data, err := io.ReadAll(io.LimitReader(response.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("media file exceeds the %d MB upload limit", limit>>20)
+	}
+	return data, nil
+}
+
+// importAuthors matches the authors of the imported posts to users by
+// email, creating users that can't sign in for the rest. Authors without
+// an email get a placeholder address under .invalid, so a re-run finds
+// them again.
+func (r *importRun) importAuthors() error {
+	used := make(map[string]bool)
+	for _, post := range r.source.Posts {
+		used[post.AuthorKey] = true
+	}
+
+	var customerID sql.NullString
+	var siteSlug string
+	if err := r.s.db.QueryRow(`SELECT customer_id, slug FROM sites WHERE id = $1`, r.siteID).Scan(&customerID, &siteSlug); err != nil {
+		return fmt.Errorf("failed to load site: %w", err)
+	}
+
+	for _, author := range r.source.Authors {
+		if author.Key == "" || !used[author.Key] {
+			continue
+		}
+		name := strings.TrimSpace(author.Name)
+		if name == "" {
+			name = author.Key
+		}
+		email := strings.ToLower(strings.TrimSpace(author.Email))
+		if email == "" {
+			local := Slugify(author.Key)
+			if local == "" {
+				local = "author"
+			}
+			email = local + "@" + siteSlug + ".import.invalid"
+		}
+		result := ImportItemResult{Type: ImportTypeAuthor, Source: author.Key, Title: name}
+
+		var userID string
+		err := r.s.db.QueryRow(`SELECT id FROM users WHERE LOWER(email) = $1 AND deleted_at IS NULL`, email).Scan(&userID)
+		switch {
+		case err == nil:
+			result.Action, result.ID = ImportUnchanged, userID
+			r.authors[author.Key] = userID
+		case err != sql.ErrNoRows:
+			return fmt.Errorf("failed to look up author: %w", err)
+		case r.opts.DryRun:
+			result.Action = ImportCreate
+		default:
+			err := r.s.db.QueryRow(`
+				INSERT INTO users (username, email, password_hash, customer_id, site_id, role, status, auth_method, account_enabled)
+				VALUES ($1, $2, '!', $3, $4, 'author', 'inactive', 'import', false)
+				RETURNING id`, name, email, customerID, r.siteID).Scan(&userID)
+			if err != nil {
+				result.Action, result.Error = ImportError, fmt.Sprintf("failed to create user: %v", err)
+				break
+			}
+			result.Action, result.ID = ImportCreate, userID
+			r.authors[author.Key] = userID
+		}
+		if result.Action == ImportCreate {
+			result.Notes = append(result.Notes, "created as "+email+" without sign-in")
+		}
+		r.report.add(result)
+	}
+	return nil
+}
+
+// importCategories creates the categories the source declares that the
+// site lacks, parents before children. Existing categories are matched by
+// slug and left as they are.
+func (r *importRun) importCategories() error {
+	pending := make([]*ImportCategory, 0, len(r.source.Categories))
+	for _, category := range r.source.Categories {
+		if slug := Slugify(category.Slug); slug != "" {
+			category.Slug = slug
+			pending = append(pending, category)
+		}
+	}
+
+	for len(pending) > 0 {
+		var waiting []*ImportCategory
+		for _, category := range pending {
+			parent := Slugify(category.Parent)
+			_, parentKnown := r.categories[parent]
+			if parent != "" && !parentKnown && containsImportCategory(pending, parent) {
+				waiting = append(waiting, category)
+				continue
+			}
+			if err := r.importCategory(category, parent); err != nil {
+				return err
+			}
+		}
+		if len(waiting) == len(pending) {
+			// A cycle of parents; import the rest at the top level
+			for _, category := range waiting {
+				if err := r.importCategory(category, ""); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		pending = waiting
+	}
+	return nil
+}
+
+func containsImportCategory(categories []*ImportCategory, slug string) bool {
+	for _, category := range categories {
+		if category.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *importRun) importCategory(category *ImportCategory, parent string) error {
+	if _, done := r.categories[category.Slug]; done {
+		return nil
+	}
+	name := strings.TrimSpace(category.Name)
+	if name == "" {
+		name = category.Slug
+	}
+	result := ImportItemResult{Type: ImportTypeCategory, Source: category.Slug, Title: name, Slug: category.Slug}
+
+	var id string
+	err := r.s.db.QueryRow(`SELECT id FROM taxonomy_terms WHERE site_id = $1 AND taxonomy = $2 AND slug = $3`,
+		r.siteID, TaxonomyCategory, category.Slug).Scan(&id)
+	switch {
+	case err == nil:
+		result.Action, result.ID = ImportUnchanged, id
+	case err != sql.ErrNoRows:
+		return fmt.Errorf("failed to look up category: %w", err)
+	case r.opts.DryRun:
+		result.Action = ImportCreate
+	default:
+		parentID := r.categories[parent]
+		err := r.s.db.QueryRow(`
+			INSERT INTO taxonomy_terms (site_id, taxonomy, parent_id, name, slug, description)
+			VALUES ($1, $2, NULLIF($3, '')::UUID, $4, $5, NULLIF($6, ''))
+			RETURNING id`, r.siteID, TaxonomyCategory, parentID, name, category.Slug,
+			strings.TrimSpace(category.Description)).Scan(&id)
+		if err != nil {
+			result.Action, result.Error = ImportError, fmt.Sprintf("failed to create category: %v", err)
+			break
+		}
+		result.Action, result.ID = ImportCreate, id
+	}
+	r.categories[category.Slug] = id
+	r.report.add(result)
+	return nil
+}
+
+// importTags creates the tags of the imported posts that the site lacks,
+// matching existing tags by slug or name as tagging a post does
+func (r *importRun) importTags() error {
+	var names []string
+	seen := make(map[string]bool)
+	for _, post := range r.source.Posts {
+		for _, name := range post.Tags {
+			name = strings.TrimSpace(name)
+			key := strings.ToLower(name)
+			if name != "" && !seen[key] {
+				seen[key] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		slug := Slugify(name)
+		result := ImportItemResult{Type: ImportTypeTag, Source: name, Title: name, Slug: slug}
+		if slug == "" {
+			result.Action, result.Error = ImportError, fmt.Sprintf("tag %q has no usable characters for a slug", name)
+			r.report.add(result)
+			continue
+		}
+
+		var id string
+		err := r.s.db.QueryRow(`
+			SELECT id FROM taxonomy_terms
+			WHERE site_id = $1 AND taxonomy = 'tag' AND (slug = $2 OR LOWER(name) = LOWER($3))
+			ORDER BY (slug = $2) DESC
+			LIMIT 1`, r.siteID, slug, name).Scan(&id)
+		switch {
+		case err == nil:
+			result.Action, result.ID = ImportUnchanged, id
+		case err != sql.ErrNoRows:
+			return fmt.Errorf("failed to look up tag: %w", err)
+		case r.opts.DryRun:
+			result.Action = ImportCreate
+		default:
+			tx, err := r.s.db.Begin()
+			if err != nil {
+				return fmt.Errorf("failed to begin transaction: %w", err)
+			}
+			id, err = r.s.siteContent.resolveOrCreateTag(tx, r.siteID, name)
+			if err == nil {
+				err = tx.Commit()
+			}
+			tx.Rollback()
+			if err != nil {
+				result.Action, result.Error = ImportError, err.Error()
+				break
+			}
+			result.Action, result.ID = ImportCreate, id
+		}
+		r.tags[strings.ToLower(name)] = id
+		r.report.add(result)
+	}
+	return nil
+}
+
+// importChecksum identifies the source version of a post, so a re-run can
+// tell whether it changed
+func importChecksum(post *ImportPost) string {
+	publishAt := ""
+	if post.PublishAt != nil {
+		publishAt = post.PublishAt.UTC().Format(time.RFC3339)
+	}
+	refs := make([]string, 0, len(post.MediaRefs))
+	for ref, key := range post.MediaRefs {
+		refs = append(refs, ref+"="+key)
+	}
+	sort.Strings(refs)
+	return hashStrings(post.Title, post.Slug, post.Content, post.Format, post.Status,
+		post.Date.UTC().Format(time.RFC3339), publishAt, post.Locale, post.AuthorKey,
+		strings.Join(post.Tags, "\x00"), strings.Join(post.Categories, "\x00"), strings.Join(refs, "\x00"))
+}
+
+// importPost creates or updates one post and its redirects
+func (r *importRun) importPost(post *ImportPost) {
+	result := ImportItemResult{Type: ImportTypePost, Source: post.Key, Title: post.Title}
+	fail := func(err error) {
+		result.Action, result.Error = ImportError, err.Error()
+		r.report.add(result)
+	}
+
+	title := strings.TrimSpace(post.Title)
+	if title == "" {
+		title = post.Slug
+	}
+	if title == "" {
+		fail(fmt.Errorf("post has neither a title nor a slug"))
+		return
+	}
+	if len(title) > 255 {
+		title = strings.ToValidUTF8(title[:255], "")
+	}
+	slug := post.Slug
+	if !pageSlugPattern.MatchString(slug) {
+		slug = Slugify(slug)
+		if slug == "" {
+			slug = Slugify(title)
+		}
+		if slug == "" {
+			fail(fmt.Errorf("post %q has no usable characters for a slug", title))
+			return
+		}
+		if post.Slug != "" {
+			result.Notes = append(result.Notes, fmt.Sprintf("slug %q is not valid here; imported as %q", post.Slug, slug))
+		}
+	}
+	locale := r.locales.Default
+	if post.Locale != "" {
+		normalized, ok := NormalizeLocale(post.Locale)
+		if ok && containsString(r.locales.Supported, normalized) {
+			locale = normalized
+		} else {
+			result.Notes = append(result.Notes, fmt.Sprintf("locale %q is not supported by the site; imported as %q", post.Locale, locale))
+		}
+	}
+	format := post.Format
+	if format == "" {
+		format = ContentFormatHTML
+	}
+	status := post.Status
+	if status != PostStatusPublished {
+		status = PostStatusDraft
+	}
+	published := status == PostStatusPublished
+	if post.PublishAt != nil && post.PublishAt.After(r.now) {
+		status, published = PostStatusDraft, false
+	}
+	if r.workflow && published {
+		status, published = PostStatusDraft, false
+		result.Notes = append(result.Notes, "published at the source; imported as draft pending approval")
+	}
+	authorID := r.authors[post.AuthorKey]
+	if authorID == "" {
+		authorID = r.opts.UserID
+	}
+	checksum := importChecksum(post)
+
+	previous, err := r.lookupItem(ImportTypePost, post.Key)
+	if err != nil {
+		fail(err)
+		return
+	}
+	var postID, currentSlug string
+	if previous != nil {
+		var updatedAt time.Time
+		var deleted bool
+		err := r.s.db.QueryRow(`SELECT slug, updated_at, deleted_at IS NOT NULL FROM posts WHERE id::TEXT = $1`,
+			previous.targetID).Scan(&currentSlug, &updatedAt, &deleted)
+		switch {
+		case err == sql.ErrNoRows || (err == nil && deleted):
+			result.Action, result.ID = ImportSkip, previous.targetID
+			result.Notes = append(result.Notes, "deleted since it was imported")
+			r.report.add(result)
+			return
+		case err != nil:
+			fail(fmt.Errorf("failed to load imported post: %w", err))
+			return
+		}
+		postID, result.ID, result.Slug = previous.targetID, previous.targetID, currentSlug
+		if previous.checksum == checksum {
+			result.Action = ImportUnchanged
+			r.report.add(result)
+			r.importRedirects(post, currentSlug, locale)
+			return
+		}
+		if updatedAt.After(previous.updatedAt.Add(time.Second)) && !r.opts.Force {
+			result.Action = ImportConflict
+			result.Notes = append(result.Notes, "edited here since the last import; re-run with force to overwrite")
+			r.report.add(result)
+			return
+		}
+	}
+
+	// An imported slug that another post holds gets a numbered suffix; the
+	// post's old URLs still reach it through their redirects
+	finalSlug, err := r.freeSlug(slug, locale, postID)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if finalSlug != slug {
+		if postID != "" {
+			finalSlug = currentSlug
+		}
+		result.Notes = append(result.Notes, fmt.Sprintf("slug %q is taken; imported as %q", slug, finalSlug))
+	}
+	result.Slug = finalSlug
+
+	content := post.Content
+	for ref, key := range post.MediaRefs {
+		if mediaURL, ok := r.mediaURLs[key]; ok {
+			content = replaceMediaRef(content, ref, mediaURL)
+		}
+	}
+
+	var tagIDs, categoryIDs []string
+	for _, name := range post.Tags {
+		if id := r.tags[strings.ToLower(strings.TrimSpace(name))]; id != "" && !containsString(tagIDs, id) {
+			tagIDs = append(tagIDs, id)
+		}
+	}
+	for _, slug := range post.Categories {
+		if id := r.categories[Slugify(slug)]; id != "" && !containsString(categoryIDs, id) {
+			categoryIDs = append(categoryIDs, id)
+		}
+	}
+
+	result.Action = ImportCreate
+	if postID != "" {
+		result.Action = ImportUpdate
+	}
+	if r.opts.DryRun {
+		r.slugs[locale+"/"+finalSlug] = true
+		r.report.add(result)
+		r.importRedirects(post, finalSlug, locale)
+		return
+	}
+
+	date := post.Date
+	if date.IsZero() {
+		date = r.now
+	}
+	var publishedAt *time.Time
+	if published {
+		publishedAt = &date
+	}
+
+	tx, err := r.s.db.Begin()
+	if err != nil {
+		fail(fmt.Errorf("failed to begin transaction: %w", err))
+		return
+	}
+	defer tx.Rollback()
+
+	if postID == "" {
+		err = tx.QueryRow(`
+			INSERT INTO posts (user_id, site_id, title, content, slug, status, published, published_at,
+			publish_at, created_at, updated_at, content_format, locale)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING id`, authorID, r.siteID, title, content, finalSlug, status, published, publishedAt,
+			post.PublishAt, date, r.now, format, locale).Scan(&postID)
+		if err != nil {
+			fail(fmt.Errorf("failed to create post: %w", err))
+			return
+		}
+	} else {
+		if err := RecordBaselineRevision(tx, postID); err != nil {
+			fail(err)
+			return
+		}
+		_, err = tx.Exec(`
+			UPDATE posts SET user_id = $1, title = $2, content = $3, slug = $4, status = $5, published = $6,
+			published_at = CASE WHEN $6 THEN COALESCE(published_at, $7) ELSE published_at END,
+			publish_at = $8, updated_at = $9, content_format = $10, blocks = NULL
+			WHERE id::TEXT = $11`, authorID, title, content, finalSlug, status, published, publishedAt,
+			post.PublishAt, r.now, format, postID)
+		if err != nil {
+			fail(fmt.Errorf("failed to update post: %w", err))
+			return
+		}
+		if err := RecordSlugChange(tx, r.siteID, postID, currentSlug, finalSlug); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	if _, err := RecordRevision(tx, RevisionSnapshot{
+		PostID:   postID,
+		SiteID:   r.siteID,
+		Title:    title,
+		Content:  content,
+		Slug:     finalSlug,
+		Status:   status,
+		AuthorID: r.opts.UserID,
+	}); err != nil {
+		fail(err)
+		return
+	}
+	if err := replacePostTerms(tx, postID, TaxonomyTag, tagIDs); err != nil {
+		fail(err)
+		return
+	}
+	if err := replacePostTerms(tx, postID, TaxonomyCategory, categoryIDs); err != nil {
+		fail(err)
+		return
+	}
+	if err := IndexPost(tx, postID); err != nil {
+		fail(err)
+		return
+	}
+	if err := r.saveItem(tx, ImportTypePost, post.Key, postID, checksum); err != nil {
+		fail(err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		fail(fmt.Errorf("failed to commit post: %w", err))
+		return
+	}
+
+	result.ID = postID
+	r.report.add(result)
+	r.importRedirects(post, finalSlug, locale)
+}
+
+// freeSlug returns slug, or slug with the lowest free numbered suffix when
+// another post of the site holds it in locale
+func (r *importRun) freeSlug(slug, locale, postID string) (string, error) {
+	for n := 1; n <= 1000; n++ {
+		candidate := slug
+		if n > 1 {
+			candidate = slug + "-" + strconv.Itoa(n)
+		}
+		if r.slugs[locale+"/"+candidate] {
+			continue
+		}
+		var taken bool
+		err := r.s.db.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM posts WHERE site_id = $1 AND locale = $2 AND slug = $3 AND id::TEXT <> $4 AND deleted_at IS NULL)`,
+			r.siteID, locale, candidate, postID).Scan(&taken)
+		if err != nil {
+			return "", fmt.Errorf("failed to check slug: %w", err)
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free slug for %q", slug)
+}
+
+// importRedirects points the post's old URLs at its new one. A path that
+// already redirects elsewhere is reported as a conflict and left alone.
+func (r *importRun) importRedirects(post *ImportPost, slug, locale string) {
+	target := LocalizedPostPath(slug, locale, r.locales.Default)
+	seen := map[string]bool{target: true}
+	for _, oldPath := range post.OldPaths {
+		source := normalizeImportPath(oldPath)
+		if source == "" || seen[source] {
+			continue
+		}
+		seen[source] = true
+		result := ImportItemResult{Type: ImportTypeRedirect, Source: source, Title: target}
+
+		var existing string
+		err := r.s.db.QueryRow(`
+			SELECT target_path FROM site_redirects WHERE site_id = $1 AND source_path = $2 AND match_type = $3`,
+			r.siteID, source, RedirectExact).Scan(&existing)
+		switch {
+		case err == nil && existing == target:
+			result.Action = ImportUnchanged
+		case err == nil:
+			result.Action = ImportConflict
+			result.Notes = append(result.Notes, "already redirects to "+existing)
+		case err != sql.ErrNoRows:
+			result.Action, result.Error = ImportError, fmt.Sprintf("failed to look up redirect: %v", err)
+		case r.opts.DryRun:
+			result.Action = ImportCreate
+		default:
+			redirect, err := r.s.siteContent.CreateRedirect(r.siteID, RedirectInput{Source: source, Target: target})
+			if err != nil {
+				result.Action, result.Error = ImportError, err.Error()
+				break
+			}
+			result.Action, result.ID = ImportCreate, redirect.ID
+		}
+		r.report.add(result)
+	}
+}
+
+// normalizeImportPath turns an old URL or path into a redirect source
+// path, without query, fragment or trailing slash
+func normalizeImportPath(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if i := strings.Index(raw, "://"); i >= 0 {
+		rest := raw[i+3:]
+		slash := strings.Index(rest, "/")
+		if slash < 0 {
+			return ""
+		}
+		raw = rest[slash:]
+	}
+	if i := strings.IndexAny(raw, "?#"); i >= 0 {
+		raw = raw[:i]
+	}
+	if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") {
+		return ""
+	}
+	raw = path.Clean(raw)
+	if raw == "/" {
+		return ""
+	}
+	return raw
+}
+
+// replaceMediaRef points references to a media file in post content at its
+// imported URL. Absolute URLs are replaced wherever they appear; relative
+// references only as Markdown link targets or quoted attribute values.
+func replaceMediaRef(content, ref, mediaURL string) string {
+	if strings.Contains(ref, "://") {
+		return strings.ReplaceAll(content, ref, mediaURL)
+	}
+	for _, wrap := range [][2]string{{"](", ")"}, {"](", " "}, {`"`, `"`}, {"'", "'"}} {
+		content = strings.ReplaceAll(content, wrap[0]+ref+wrap[1], wrap[0]+mediaURL+wrap[1])
+	}
+	return content
+}
@@ -0,0 +1,483 @@
+package services
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Content formats stored in posts.content_format
+const (
+	ContentFormatMarkdown = "markdown"
+	ContentFormatHTML     = "html"
+	ContentFormatBlocks   = "blocks"
+)
+
+const (
+	wordsPerMinute     = 225
+	excerptLength      = 200
+	renderCacheEntries = 512
+)
+
+// TOCEntry is one heading in a rendered document
+type TOCEntry struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+// RenderedContent is the sanitized output of the render pipeline. Hash
+// identifies the source and format it was rendered from.
+type RenderedContent struct {
+	Format             string     `json:"format"`
+	HTML               string     `json:"html"`
+	TOC                []TOCEntry `json:"toc"`
+	Excerpt            string     `json:"excerpt"`
+	WordCount          int        `json:"word_count"`
+	ReadingTimeMinutes int        `json:"reading_time_minutes"`
+	Hash               string     `json:"hash"`
+}
+
+// IsContentFormat reports whether format is a supported content format
+func IsContentFormat(format string) bool {
+	switch format {
+	case ContentFormatMarkdown, ContentFormatHTML, ContentFormatBlocks:
+		return true
+	}
+	return false
+}
+
+// renderCache is a small LRU of rendered documents keyed by content hash
+type renderCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	limit   int
+}
+
+type renderCacheEntry struct {
+	key      string
+	rendered RenderedContent
+}
+
+var contentRenderCache = &renderCache{
+	entries: map[string]*list.Element{},
+	order:   list.New(),
+	limit:   renderCacheEntries,
+}
+
+func (c *renderCache) get(key string) (RenderedContent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return RenderedContent{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*renderCacheEntry).rendered, true
+}
+
+func (c *renderCache) put(key string, rendered RenderedContent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&renderCacheEntry{key: key, rendered: rendered})
+	for c.order.Len() > c.limit {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*renderCacheEntry).key)
+	}
+}
+
+// RenderContent turns stored content into sanitized HTML with a table of
+// contents, excerpt, word count and reading time. Markdown is converted
+// first; blocks content is the HTML rendered from a post's blocks. Results
+// are cached by a hash of format and source.
+func RenderContent(source, format string) (*RenderedContent, error) {
+	if format == "" {
+		format = ContentFormatHTML
+	}
+	if !IsContentFormat(format) {
+		return nil, fmt.Errorf("content_format must be one of: markdown, html, blocks")
+	}
+
+	sum := sha256.Sum256([]byte(format + "\x00" + source))
+	key := hex.EncodeToString(sum[:])
+	if cached, ok := contentRenderCache.get(key); ok {
+		return &cached, nil
+	}
+
+	var body string
+	switch format {
+	case ContentFormatMarkdown:
+		body = contentPolicy.sanitize(MarkdownToHTML(source))
+	case ContentFormatHTML:
+		body = contentPolicy.sanitize(source)
+	case ContentFormatBlocks:
+		body = blockPolicy.sanitize(source)
+	}
+
+	body, toc := addHeadingIDs(body)
+	text := plainText(body)
+	words := len(strings.Fields(text))
+
+	rendered := RenderedContent{
+		Format:             format,
+		HTML:               body,
+		TOC:                toc,
+		Excerpt:            excerpt(text, excerptLength),
+		WordCount:          words,
+		ReadingTimeMinutes: int(math.Ceil(float64(words) / wordsPerMinute)),
+		Hash:               key,
+	}
+	contentRenderCache.put(key, rendered)
+	return &rendered, nil
+}
+
+var (
+	headingOpen = regexp.MustCompile(`<h([1-6])((?:\s[^>]*)?)>`)
+	blockTag    = regexp.MustCompile(`(?i)</?(?:p|h[1-6]|li|ul|ol|blockquote|pre|br|hr|table|tr|td|th|section|figure|figcaption|aside)\b[^>]*>`)
+	anyTag      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// addHeadingIDs gives each h2-h6 a unique id derived from its text and
+// collects them as the table of contents. The page title owns h1, so h1
+// headings (from hero blocks) are left out of the contents.
+func addHeadingIDs(body string) (string, []TOCEntry) {
+	toc := []TOCEntry{}
+	used := map[string]int{}
+
+	var out strings.Builder
+	for {
+		loc := headingOpen.FindStringSubmatchIndex(body)
+		if loc == nil {
+			out.WriteString(body)
+			break
+		}
+		level, _ := strconv.Atoi(body[loc[2]:loc[3]])
+		closing := "</h" + body[loc[2]:loc[3]] + ">"
+		end := strings.Index(body[loc[1]:], closing)
+		if end < 0 || level == 1 {
+			out.WriteString(body[:loc[1]])
+			body = body[loc[1]:]
+			continue
+		}
+
+		inner := body[loc[1] : loc[1]+end]
+		text := plainText(inner)
+		id := Slugify(text)
+		if id == "" {
+			id = "section"
+		}
+		if n := used[id]; n > 0 {
+			used[id] = n + 1
+			id = fmt.Sprintf("%s-%d", id, n+1)
+		} else {
+			used[id] = 1
+		}
+		toc = append(toc, TOCEntry{Level: level, ID: id, Text: text})
+
+		out.WriteString(body[:loc[0]])
+		out.WriteString(`<h` + body[loc[2]:loc[3]] + body[loc[4]:loc[5]] + ` id="` + id + `">`)
+		out.WriteString(inner + closing)
+		body = body[loc[1]+end+len(closing):]
+	}
+	return out.String(), toc
+}
+
+// plainText strips tags and entities and collapses whitespace; block
+// elements separate words, inline elements do not
+func plainText(body string) string {
+	body = anyTag.ReplaceAllString(blockTag.ReplaceAllString(body, " "), "")
+	return strings.Join(strings.Fields(html.UnescapeString(body)), " ")
+}
+
+// excerpt cuts text at a word boundary no longer than limit runes
+func excerpt(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:limit])
+	if space := strings.LastIndex(cut, " "); space > 0 {
+		cut = cut[:space]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
+
+var (
+	mdHeading     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdRule        = regexp.MustCompile(`^ {0,3}(?:(?:\*\s*){3,}|(?:-\s*){3,}|(?:_\s*){3,})$`)
+	mdListItem    = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	mdFence       = regexp.MustCompile("^ {0,3}(```|~~~)")
+	mdEscape      = regexp.MustCompile("\\\\([\\\\`*_{}\\[\\]()#+\\-.!~>|])")
+	mdCodeSpan    = regexp.MustCompile("(`+)(.+?)(`+)")
+	mdImage       = regexp.MustCompile(`!\[([^\]]*)\]\(\s*([^)\s]+)(?:\s+"([^"]*)")?\s*\)`)
+	mdLink        = regexp.MustCompile(`\[([^\]]+)\]\(\s*([^)\s]+)(?:\s+"([^"]*)")?\s*\)`)
+	mdAutolink    = regexp.MustCompile(`<((?:https?://|mailto:)[^>\s]+)>`)
+	mdStrong      = regexp.MustCompile(`\*\*([^*\s](?:.*?[^*\s])?)\*\*|__([^_\s](?:.*?[^_\s])?)__`)
+	mdEmphasis    = regexp.MustCompile(`\*([^*\s](?:[^*]*?[^*\s])?)\*|(?:^|\b)_([^_\s](?:[^_]*?[^_\s])?)_(?:\b|$)`)
+	mdStrike      = regexp.MustCompile(`~~([^~\s](?:.*?[^~\s])?)~~`)
+	mdPlaceholder = regexp.MustCompile("\x00([0-9]+)\x00")
+)
+
+// MarkdownToHTML converts the common Markdown subset: headings, paragraphs,
+// emphasis, code spans and fenced blocks, links, images, block quotes,
+// nested lists and horizontal rules. Raw HTML is passed through, so the
+// output must be sanitized. Headings are shifted down one level because
+// the post title is the page's h1.
+func MarkdownToHTML(source string) string {
+	source = strings.ReplaceAll(strings.ReplaceAll(source, "\r\n", "\n"), "\x00", "")
+	return renderMarkdownBlocks(strings.Split(source, "\n"))
+}
+
+func renderMarkdownBlocks(lines []string) string {
+	var out strings.Builder
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + renderParagraph(paragraph) + "</p>\n")
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+
+		case mdFence.MatchString(line):
+			flush()
+			fence := mdFence.FindStringSubmatch(line)[1]
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			out.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+
+		case mdHeading.MatchString(trimmed):
+			flush()
+			match := mdHeading.FindStringSubmatch(trimmed)
+			level := len(match[1]) + 1
+			if level > 6 {
+				level = 6
+			}
+			tag := "h" + strconv.Itoa(level)
+			out.WriteString("<" + tag + ">" + renderInline(match[2]) + "</" + tag + ">\n")
+
+		case mdRule.MatchString(line):
+			flush()
+			out.WriteString("<hr>\n")
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				text := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(text, " "))
+			}
+			i--
+			out.WriteString("<blockquote>\n" + renderMarkdownBlocks(quoted) + "</blockquote>\n")
+
+		case mdListItem.MatchString(line) && (len(paragraph) == 0 || canInterruptParagraph(line)):
+			flush()
+			consumed, list := renderMarkdownList(lines[i:])
+			out.WriteString(list)
+			i += consumed - 1
+
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+	return out.String()
+}
+
+// renderMarkdownList renders the list starting at lines[0] and returns how
+// many lines it consumed. Item bodies are rendered recursively after
+// removing their indentation, which is how nested lists are handled.
+func renderMarkdownList(lines []string) (int, string) {
+	first := mdListItem.FindStringSubmatch(lines[0])
+	ordered := first[2] != "-" && first[2] != "*" && first[2] != "+"
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+
+	var items [][]string
+	i := 0
+	for i < len(lines) {
+		match := mdListItem.FindStringSubmatch(lines[i])
+		if match == nil || len(match[1]) > len(first[1]) {
+			break
+		}
+		itemOrdered := match[2] != "-" && match[2] != "*" && match[2] != "+"
+		if itemOrdered != ordered {
+			break
+		}
+
+		body := []string{match[3]}
+		indent := len(match[0]) - len(match[3])
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				// A blank line continues the item only if indented content follows
+				if i+1 < len(lines) && leadingSpaces(lines[i+1]) >= 2 {
+					body = append(body, "")
+					continue
+				}
+				break
+			}
+			if leadingSpaces(line) >= 2 {
+				body = append(body, dedent(line, indent))
+				continue
+			}
+			if mdListItem.MatchString(line) {
+				break
+			}
+			// Lazy continuation of the item's first paragraph
+			body = append(body, line)
+		}
+		items = append(items, body)
+
+		if i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+			if i+1 < len(lines) && mdListItem.MatchString(lines[i+1]) {
+				i++
+				continue
+			}
+			break
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("<" + tag + ">\n")
+	for _, body := range items {
+		inner := strings.TrimSpace(renderMarkdownBlocks(body))
+		// Tight items render without a wrapping paragraph
+		if strings.HasPrefix(inner, "<p>") && strings.Count(inner, "<p>") == 1 {
+			end := strings.Index(inner, "</p>")
+			inner = inner[3:end] + inner[end+4:]
+		}
+		out.WriteString("<li>" + strings.TrimSpace(inner) + "</li>\n")
+	}
+	out.WriteString("</" + tag + ">\n")
+	return i, out.String()
+}
+
+// canInterruptParagraph follows CommonMark: a bullet or an ordered list
+// starting at 1 may begin directly after paragraph text
+func canInterruptParagraph(line string) bool {
+	match := mdListItem.FindStringSubmatch(line)
+	return match[2] == "-" || match[2] == "*" || match[2] == "+" || strings.TrimRight(match[2], ".)") == "1"
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func dedent(line string, n int) string {
+	if spaces := leadingSpaces(line); spaces < n {
+		n = spaces
+	}
+	return line[n:]
+}
+
+// renderParagraph joins paragraph lines; two trailing spaces or a trailing
+// backslash make a hard line break
+func renderParagraph(lines []string) string {
+	var out strings.Builder
+	for i, line := range lines {
+		hardBreak := strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\")
+		line = strings.TrimRight(strings.TrimSpace(line), "\\")
+		out.WriteString(renderInline(line))
+		if i < len(lines)-1 {
+			if hardBreak {
+				out.WriteString("<br>")
+			}
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+// renderInline converts inline Markdown. Generated markup and escaped text
+// are swapped for placeholders so later patterns cannot match inside them.
+func renderInline(text string) string {
+	var held []string
+	hold := func(markup string) string {
+		held = append(held, markup)
+		return "\x00" + strconv.Itoa(len(held)-1) + "\x00"
+	}
+
+	text = mdEscape.ReplaceAllStringFunc(text, func(m string) string {
+		return hold(html.EscapeString(m[1:]))
+	})
+	text = mdCodeSpan.ReplaceAllStringFunc(text, func(m string) string {
+		match := mdCodeSpan.FindStringSubmatch(m)
+		if match[1] != match[3] {
+			return m
+		}
+		return hold("<code>" + html.EscapeString(strings.TrimSpace(match[2])) + "</code>")
+	})
+	text = mdImage.ReplaceAllStringFunc(text, func(m string) string {
+		match := mdImage.FindStringSubmatch(m)
+		markup := `<img src="` + html.EscapeString(match[2]) + `" alt="` + html.EscapeString(match[1]) + `"`
+		if match[3] != "" {
+			markup += ` title="` + html.EscapeString(match[3]) + `"`
+		}
+		return hold(markup + ">")
+	})
+	text = mdLink.ReplaceAllStringFunc(text, func(m string) string {
+		match := mdLink.FindStringSubmatch(m)
+		markup := `<a href="` + html.EscapeString(match[2]) + `"`
+		if match[3] != "" {
+			markup += ` title="` + html.EscapeString(match[3]) + `"`
+		}
+		return hold(markup + ">" + renderInline(match[1]) + "</a>")
+	})
+	text = mdAutolink.ReplaceAllStringFunc(text, func(m string) string {
+		target := m[1 : len(m)-1]
+		return hold(`<a href="` + html.EscapeString(target) + `">` + html.EscapeString(strings.TrimPrefix(target, "mailto:")) + "</a>")
+	})
+
+	text = mdStrong.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = mdEmphasis.ReplaceAllStringFunc(text, func(m string) string {
+		match := mdEmphasis.FindStringSubmatch(m)
+		if match[1] != "" {
+			return "<em>" + match[1] + "</em>"
+		}
+		// Keep the boundary characters the pattern consumed around _x_
+		prefix := m[:strings.Index(m, "_")]
+		suffix := m[strings.LastIndex(m, "_")+1:]
+		return prefix + "<em>" + match[2] + "</em>" + suffix
+	})
+	text = mdStrike.ReplaceAllString(text, "<s>$1</s>")
+
+	// Placeholders may nest (link text holds code spans), so expand until stable
+	for strings.Contains(text, "\x00") {
+		expanded := mdPlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+			n, _ := strconv.Atoi(m[1 : len(m)-1])
+			return held[n]
+		})
+		if expanded == text {
+			break
+		}
+		text = expanded
+	}
+	return text
+}
@@ -1,17 +1,22 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
-
-	"github.com/google/uuid"
 )
 
+// DefaultTrashRetention is how long soft-deleted content stays restorable
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
 // ContentService handles content management operations
 type ContentService struct {
-	db     *sql.DB
-	logger Logger
+	db             *sql.DB
+	logger         Logger
+	trashRetention time.Duration
+	pageCache      *PageCache
 }
 
 // Logger interface for logging
@@ -33,32 +38,36 @@ type Content struct {
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 	PublishedAt *time.Time `json:"published_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	PurgeAt     *time.Time `json:"purge_at,omitempty"`
 }
 
 // NewContentService creates a new content service
 func NewContentService(db *sql.DB, logger Logger) *ContentService {
 	return &ContentService{
-		db:     db,
-		logger: logger,
+		db:             db,
+		logger:         logger,
+		trashRetention: DefaultTrashRetention,
 	}
 }
 
-// GetContent retrieves content by ID
-func (s *ContentService) GetContent(id string) (*Content, error) {
+// GetContent retrieves a site's content by ID
+func (s *ContentService) GetContent(id, siteID string) (*Content, error) {
 	s.logger.Debug("content_service", "get_content", "Retrieving content", map[string]interface{}{
 		"content_id": id,
+		"site_id":    siteID,
 	})
 
 	query := `
 		SELECT id, title, content, slug, status, site_id, user_id, created_at, updated_at, published_at
 		FROM posts 
-		WHERE id = $1 AND deleted_at IS NULL
+		WHERE id = $1 AND site_id = $2 AND deleted_at IS NULL
 	`
 
 	var content Content
 	var publishedAt sql.NullTime
 
-	err := s.db.QueryRow(query, id).Scan(
+	err := s.db.QueryRow(query, id, siteID).Scan(
 		&content.ID,
 		&content.Title,
 		&content.Content,
@@ -233,7 +242,7 @@ func (s *ContentService) ListContent(siteID string, limit, offset int) ([]*Conte
 	return contents, nil
 }
 
-// CreateContent creates a new content item
+// CreateContent creates a new content item and records it as revision 1
 func (s *ContentService) CreateContent(title, content, slug, status, siteID, userID string) (*Content, error) {
 	s.logger.Debug("content_service", "create_content", "Creating new content", map[string]interface{}{
 		"title":   title,
@@ -243,19 +252,32 @@ func (s *ContentService) CreateContent(title, content, slug, status, siteID, use
 		"user_id": userID,
 	})
 
-	id := uuid.New().String()
 	now := time.Now()
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := CheckNewPostStatus(tx, siteID, status); err != nil {
+		return nil, err
+	}
+	locale, err := ResolvePostLocale(s.db, siteID, "")
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		INSERT INTO posts (id, title, content, slug, status, site_id, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO posts (title, content, slug, status, published, published_at, site_id, user_id, created_at, updated_at, locale)
+		VALUES ($1, $2, $3, $4, $5, CASE WHEN $5 THEN $8::TIMESTAMPTZ END, $6, $7, $8, $8, $9)
 		RETURNING id, title, content, slug, status, site_id, user_id, created_at, updated_at, published_at
 	`
 
 	var newContent Content
 	var publishedAt sql.NullTime
 
-	err := s.db.QueryRow(query, id, title, content, slug, status, siteID, userID, now, now).Scan(
+	err = tx.QueryRow(query, title, content, slug, status, status == "published", siteID, userID, now, locale).Scan(
 		&newContent.ID,
 		&newContent.Title,
 		&newContent.Content,
@@ -279,9 +301,20 @@ func (s *ContentService) CreateContent(title, content, slug, status, siteID, use
 		return nil, fmt.Errorf("failed to create content: %w", err)
 	}
 
+	if _, err := RecordRevision(tx, contentSnapshot(&newContent, userID)); err != nil {
+		return nil, err
+	}
+	if err := IndexPost(tx, newContent.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit content: %w", err)
+	}
+
 	if publishedAt.Valid {
 		newContent.PublishedAt = &publishedAt.Time
 	}
+	s.pageCache.PurgeSite(newContent.SiteID)
 
 	s.logger.Info("content_service", "create_content", "Content created successfully", map[string]interface{}{
 		"content_id": newContent.ID,
@@ -295,10 +328,12 @@ func (s *ContentService) CreateContent(title, content, slug, status, siteID, use
 	return &newContent, nil
 }
 
-// UpdateContent updates an existing content item
-func (s *ContentService) UpdateContent(id, title, content, slug, status string) (*Content, error) {
+// UpdateContent updates one of a site's content items and appends a
+// revision authored by userID
+func (s *ContentService) UpdateContent(id, siteID, title, content, slug, status, userID string) (*Content, error) {
 	s.logger.Debug("content_service", "update_content", "Updating content", map[string]interface{}{
 		"content_id": id,
+		"site_id":    siteID,
 		"title":      title,
 		"slug":       slug,
 		"status":     status,
@@ -306,17 +341,53 @@ func (s *ContentService) UpdateContent(id, title, content, slug, status string)
 
 	now := time.Now()
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the post and keep its current slug so a rename can be redirected
+	var oldSlug string
+	err = tx.QueryRow(`SELECT slug FROM posts WHERE id = $1 AND site_id = $2 AND deleted_at IS NULL FOR UPDATE`,
+		id, siteID).Scan(&oldSlug)
+	if err == sql.ErrNoRows {
+		s.logger.Info("content_service", "update_content", "Content not found", map[string]interface{}{
+			"content_id": id,
+		})
+		return nil, fmt.Errorf("content not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load content: %w", err)
+	}
+
+	// Sites with the editorial workflow only change status through
+	// transitions, and edits to an approved post withdraw the approval
+	status, _, err = CheckStatusChange(tx, id, status)
+	if err != nil {
+		return nil, err
+	}
+	status, err = WithdrawApprovalOnEdit(tx, id, userID, status, title, content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := RecordBaselineRevision(tx, id); err != nil {
+		return nil, err
+	}
+
 	query := `
 		UPDATE posts 
-		SET title = $1, content = $2, slug = $3, status = $4, updated_at = $5
-		WHERE id = $6 AND deleted_at IS NULL
+		SET title = $1, content = $2, slug = $3, status = $4, published = $5, updated_at = $6,
+		published_at = CASE WHEN $5 THEN COALESCE(published_at, $6) ELSE published_at END
+		WHERE id = $7 AND deleted_at IS NULL
 		RETURNING id, title, content, slug, status, site_id, user_id, created_at, updated_at, published_at
 	`
 
 	var updatedContent Content
 	var publishedAt sql.NullTime
 
-	err := s.db.QueryRow(query, title, content, slug, status, now, id).Scan(
+	err = tx.QueryRow(query, title, content, slug, status, status == "published", now, id).Scan(
 		&updatedContent.ID,
 		&updatedContent.Title,
 		&updatedContent.Content,
@@ -343,9 +414,23 @@ func (s *ContentService) UpdateContent(id, title, content, slug, status string)
 		return nil, fmt.Errorf("failed to update content: %w", err)
 	}
 
+	if err := RecordSlugChange(tx, siteID, id, oldSlug, updatedContent.Slug); err != nil {
+		return nil, err
+	}
+	if _, err := RecordRevision(tx, contentSnapshot(&updatedContent, userID)); err != nil {
+		return nil, err
+	}
+	if err := IndexPost(tx, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit content: %w", err)
+	}
+
 	if publishedAt.Valid {
 		updatedContent.PublishedAt = &publishedAt.Time
 	}
+	s.pageCache.PurgeSite(updatedContent.SiteID)
 
 	s.logger.Info("content_service", "update_content", "Content updated successfully", map[string]interface{}{
 		"content_id": id,
@@ -357,10 +442,23 @@ func (s *ContentService) UpdateContent(id, title, content, slug, status string)
 	return &updatedContent, nil
 }
 
-// DeleteContent soft deletes a content item
-func (s *ContentService) DeleteContent(id string) error {
+func contentSnapshot(content *Content, authorID string) RevisionSnapshot {
+	return RevisionSnapshot{
+		PostID:   content.ID,
+		SiteID:   content.SiteID,
+		Title:    content.Title,
+		Content:  content.Content,
+		Slug:     content.Slug,
+		Status:   content.Status,
+		AuthorID: authorID,
+	}
+}
+
+// DeleteContent moves one of a site's content items to the trash
+func (s *ContentService) DeleteContent(id, siteID string) error {
 	s.logger.Debug("content_service", "delete_content", "Deleting content", map[string]interface{}{
 		"content_id": id,
+		"site_id":    siteID,
 	})
 
 	now := time.Now()
@@ -368,10 +466,10 @@ func (s *ContentService) DeleteContent(id string) error {
 	query := `
 		UPDATE posts 
 		SET deleted_at = $1, updated_at = $2
-		WHERE id = $3 AND deleted_at IS NULL
+		WHERE id = $3 AND site_id = $4 AND deleted_at IS NULL
 	`
 
-	result, err := s.db.Exec(query, now, now, id)
+	result, err := s.db.Exec(query, now, now, id, siteID)
 	if err != nil {
 		s.logger.Error("content_service", "delete_content", "Database error", map[string]interface{}{
 			"content_id": id,
@@ -396,9 +494,221 @@ func (s *ContentService) DeleteContent(id string) error {
 		return fmt.Errorf("content not found")
 	}
 
+	s.reindex("delete_content", id)
+	s.pageCache.PurgeSite(siteID)
+
 	s.logger.Info("content_service", "delete_content", "Content deleted successfully", map[string]interface{}{
 		"content_id": id,
 	})
 
 	return nil
 }
+
+// reindex refreshes a post's search document after a change that has
+// already been committed; a failure leaves the index stale until the next
+// edit or reindex, so it is logged rather than returned
+func (s *ContentService) reindex(action, id string) {
+	if err := IndexPost(s.db, id); err != nil {
+		s.logger.Error("content_service", action, "Search index update failed", map[string]interface{}{
+			"content_id": id,
+			"error":      err.Error(),
+		})
+	}
+}
+
+// SetPageCache sets the public page cache to purge when content changes
+func (s *ContentService) SetPageCache(cache *PageCache) {
+	s.pageCache = cache
+}
+
+// SetTrashRetention changes how long deleted content stays in the trash
+func (s *ContentService) SetTrashRetention(retention time.Duration) {
+	s.trashRetention = retention
+}
+
+// ListTrash retrieves soft-deleted content for a site, most recently deleted first
+func (s *ContentService) ListTrash(siteID string, limit, offset int) ([]*Content, error) {
+	s.logger.Debug("content_service", "list_trash", "Retrieving trash", map[string]interface{}{
+		"site_id": siteID,
+		"limit":   limit,
+		"offset":  offset,
+	})
+
+	query := `
+		SELECT id, title, content, slug, status, site_id, user_id, created_at, updated_at, published_at, deleted_at
+		FROM posts 
+		WHERE site_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := s.db.Query(query, siteID, limit, offset)
+	if err != nil {
+		s.logger.Error("content_service", "list_trash", "Database error", map[string]interface{}{
+			"site_id": siteID,
+			"error":   err.Error(),
+		})
+		return nil, fmt.Errorf("failed to retrieve trash: %w", err)
+	}
+	defer rows.Close()
+
+	contents := []*Content{}
+	for rows.Next() {
+		var content Content
+		var publishedAt sql.NullTime
+		var deletedAt time.Time
+
+		err := rows.Scan(
+			&content.ID,
+			&content.Title,
+			&content.Content,
+			&content.Slug,
+			&content.Status,
+			&content.SiteID,
+			&content.UserID,
+			&content.CreatedAt,
+			&content.UpdatedAt,
+			&publishedAt,
+			&deletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trash row: %w", err)
+		}
+
+		if publishedAt.Valid {
+			content.PublishedAt = &publishedAt.Time
+		}
+		purgeAt := deletedAt.Add(s.trashRetention)
+		content.DeletedAt = &deletedAt
+		content.PurgeAt = &purgeAt
+
+		contents = append(contents, &content)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate trash rows: %w", err)
+	}
+
+	return contents, nil
+}
+
+// RestoreContent moves a soft-deleted content item out of the trash. Trashed
+// posts don't hold their slug, so a post created with it meanwhile blocks the
+// restore.
+func (s *ContentService) RestoreContent(id, siteID string) (*Content, error) {
+	var slug string
+	var taken bool
+	err := s.db.QueryRow(`
+		SELECT p.slug, EXISTS (
+			SELECT 1 FROM posts o
+			WHERE o.site_id = p.site_id AND o.locale = p.locale AND o.slug = p.slug
+			AND o.id <> p.id AND o.deleted_at IS NULL
+		)
+		FROM posts p
+		WHERE p.id = $1 AND p.site_id = $2 AND p.deleted_at IS NOT NULL`, id, siteID).Scan(&slug, &taken)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("content not found in trash")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore content: %w", err)
+	}
+	if taken {
+		return nil, fmt.Errorf("slug %q is already used by another post", slug)
+	}
+
+	query := `
+		UPDATE posts 
+		SET deleted_at = NULL, updated_at = $1
+		WHERE id = $2 AND site_id = $3 AND deleted_at IS NOT NULL
+	`
+
+	result, err := s.db.Exec(query, time.Now(), id, siteID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("slug %q is already used by another post", slug)
+		}
+		s.logger.Error("content_service", "restore_content", "Database error", map[string]interface{}{
+			"content_id": id,
+			"error":      err.Error(),
+		})
+		return nil, fmt.Errorf("failed to restore content: %w", err)
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return nil, fmt.Errorf("content not found in trash")
+	}
+
+	s.reindex("restore_content", id)
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("content_service", "restore_content", "Content restored from trash", map[string]interface{}{
+		"content_id": id,
+		"site_id":    siteID,
+	})
+
+	return s.GetContent(id, siteID)
+}
+
+// PurgeContent permanently deletes a content item that is already in the trash
+func (s *ContentService) PurgeContent(id, siteID string) error {
+	result, err := s.db.Exec(`DELETE FROM posts WHERE id = $1 AND site_id = $2 AND deleted_at IS NOT NULL`, id, siteID)
+	if err != nil {
+		s.logger.Error("content_service", "purge_content", "Database error", map[string]interface{}{
+			"content_id": id,
+			"error":      err.Error(),
+		})
+		return fmt.Errorf("failed to purge content: %w", err)
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return fmt.Errorf("content not found in trash")
+	}
+
+	s.logger.Info("content_service", "purge_content", "Content purged", map[string]interface{}{
+		"content_id": id,
+		"site_id":    siteID,
+	})
+
+	return nil
+}
+
+// PurgeExpiredTrash permanently deletes content trashed longer ago than the
+// retention window
+func (s *ContentService) PurgeExpiredTrash() (int64, error) {
+	cutoff := time.Now().Add(-s.trashRetention)
+
+	result, err := s.db.Exec(`DELETE FROM posts WHERE deleted_at IS NOT NULL AND deleted_at < $1`, cutoff)
+	if err != nil {
+		s.logger.Error("content_service", "purge_expired", "Database error", map[string]interface{}{
+			"cutoff": cutoff,
+			"error":  err.Error(),
+		})
+		return 0, fmt.Errorf("failed to purge expired trash: %w", err)
+	}
+
+	purged, _ := result.RowsAffected()
+	if purged > 0 {
+		s.logger.Info("content_service", "purge_expired", "Expired trash purged", map[string]interface{}{
+			"purged": purged,
+			"cutoff": cutoff,
+		})
+	}
+
+	return purged, nil
+}
+
+// RunTrashPurge purges expired trash every interval until the context is cancelled
+func (s *ContentService) RunTrashPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.PurgeExpiredTrash()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
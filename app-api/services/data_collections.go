@@ -0,0 +1,940 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Collection field types
+const (
+	FieldTypeString    = "string"
+	FieldTypeText      = "text"
+	FieldTypeNumber    = "number"
+	FieldTypeInteger   = "integer"
+	FieldTypeBoolean   = "boolean"
+	FieldTypeDate      = "date"
+	FieldTypeDateTime  = "datetime"
+	FieldTypeEmail     = "email"
+	FieldTypeURL       = "url"
+	FieldTypeEnum      = "enum"
+	FieldTypeReference = "reference"
+	FieldTypeJSON      = "json"
+)
+
+// Filter operators for collection queries
+const (
+	FilterEq       = "eq"
+	FilterNe       = "ne"
+	FilterGt       = "gt"
+	FilterGte      = "gte"
+	FilterLt       = "lt"
+	FilterLte      = "lte"
+	FilterContains = "contains"
+	FilterIn       = "in"
+	FilterExists   = "exists"
+)
+
+const maxCollectionItemsPerPage = 100
+
+var fieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// CollectionField describes one field of a collection schema
+type CollectionField struct {
+	Name       string   `json:"name"`
+	Label      string   `json:"label,omitempty"`
+	Type       string   `json:"type"`
+	Required   bool     `json:"required,omitempty"`
+	Unique     bool     `json:"unique,omitempty"`
+	Options    []string `json:"options,omitempty"`
+	Collection string   `json:"collection,omitempty"`
+}
+
+// DataCollection is a user-defined schema for a site's structured data
+type DataCollection struct {
+	ID          string            `json:"id"`
+	SiteID      string            `json:"site_id"`
+	Name        string            `json:"name"`
+	Slug        string            `json:"slug"`
+	Description string            `json:"description,omitempty"`
+	Fields      []CollectionField `json:"fields"`
+	ItemCount   int               `json:"item_count"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// DataCollectionInput is the writable part of a collection
+type DataCollectionInput struct {
+	Name        string            `json:"name"`
+	Slug        string            `json:"slug"`
+	Description string            `json:"description"`
+	Fields      []CollectionField `json:"fields"`
+}
+
+// DataItem is one validated entry of a collection
+type DataItem struct {
+	ID         string                 `json:"id"`
+	Collection string                 `json:"collection"`
+	Data       map[string]interface{} `json:"data"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+}
+
+// ItemFilter restricts items by one field
+type ItemFilter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// ItemQuery selects, orders and pages collection items. Sort is a field
+// name, created_at or updated_at, prefixed with - for descending.
+type ItemQuery struct {
+	Filters []ItemFilter
+	Search  string
+	Sort    string
+	Page    int
+	PerPage int
+}
+
+// ItemPage is one page of query results
+type ItemPage struct {
+	Items      []*DataItem `json:"items"`
+	Page       int         `json:"page"`
+	PerPage    int         `json:"per_page"`
+	Total      int         `json:"total"`
+	TotalPages int         `json:"total_pages"`
+}
+
+func (c *DataCollection) field(name string) *CollectionField {
+	for i := range c.Fields {
+		if c.Fields[i].Name == name {
+			return &c.Fields[i]
+		}
+	}
+	return nil
+}
+
+// ListCollections returns a site's collection schemas with item counts
+func (s *SiteContentService) ListCollections(siteRef string) ([]*DataCollection, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT c.id, c.site_id, c.name, c.slug, COALESCE(c.description, ''), c.fields, c.created_at, c.updated_at,
+		(SELECT COUNT(*) FROM data_items i WHERE i.collection_id = c.id)
+		FROM data_collections c
+		WHERE c.site_id = $1
+		ORDER BY c.name`, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	defer rows.Close()
+
+	collections := []*DataCollection{}
+	for rows.Next() {
+		collection, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, collection)
+	}
+	return collections, rows.Err()
+}
+
+// GetCollection returns one collection schema by slug
+func (s *SiteContentService) GetCollection(siteRef, slug string) (*DataCollection, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.getCollection(s.db, siteID, slug)
+}
+
+func (s *SiteContentService) getCollection(q queryRower, siteID, slug string) (*DataCollection, error) {
+	return scanCollection(q.QueryRow(`
+		SELECT c.id, c.site_id, c.name, c.slug, COALESCE(c.description, ''), c.fields, c.created_at, c.updated_at,
+		(SELECT COUNT(*) FROM data_items i WHERE i.collection_id = c.id)
+		FROM data_collections c
+		WHERE c.site_id = $1 AND c.slug = $2`, siteID, slug))
+}
+
+func scanCollection(row rowScanner) (*DataCollection, error) {
+	collection := &DataCollection{}
+	var fields []byte
+	err := row.Scan(&collection.ID, &collection.SiteID, &collection.Name, &collection.Slug, &collection.Description,
+		&fields, &collection.CreatedAt, &collection.UpdatedAt, &collection.ItemCount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("collection not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve collection: %w", err)
+	}
+	if err := json.Unmarshal(fields, &collection.Fields); err != nil {
+		return nil, fmt.Errorf("failed to decode collection fields: %w", err)
+	}
+	return collection, nil
+}
+
+// CreateCollection defines a new collection
+func (s *SiteContentService) CreateCollection(siteRef string, input DataCollectionInput) (*DataCollection, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateCollectionInput(siteID, &input); err != nil {
+		return nil, err
+	}
+
+	fields, _ := json.Marshal(input.Fields)
+	_, err = s.db.Exec(`
+		INSERT INTO data_collections (site_id, name, slug, description, fields)
+		VALUES ($1, $2, $3, $4, $5)`, siteID, input.Name, input.Slug, input.Description, fields)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("a collection already exists with slug %q", input.Slug)
+		}
+		return nil, fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	s.logger.Info("site_content_service", "create_collection", "Collection created", map[string]interface{}{
+		"site_id":    siteID,
+		"collection": input.Slug,
+		"fields":     len(input.Fields),
+	})
+
+	return s.getCollection(s.db, siteID, input.Slug)
+}
+
+// UpdateCollection replaces a collection's schema. Existing items are not
+// rewritten, but fields that become unique are checked against them.
+func (s *SiteContentService) UpdateCollection(siteRef, slug string, input DataCollectionInput) (*DataCollection, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if input.Slug == "" {
+		input.Slug = slug
+	}
+	if err := s.validateCollectionInput(siteID, &input); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := s.getCollection(tx, siteID, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, _ := json.Marshal(input.Fields)
+	_, err = tx.Exec(`
+		UPDATE data_collections SET name = $1, slug = $2, description = $3, fields = $4, updated_at = $5
+		WHERE id = $6`, input.Name, input.Slug, input.Description, fields, time.Now(), current.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("a collection already exists with slug %q", input.Slug)
+		}
+		return nil, fmt.Errorf("failed to update collection: %w", err)
+	}
+
+	if input.Slug != slug {
+		// Reference fields in other collections follow the rename
+		if err := s.renameCollectionReferences(tx, siteID, slug, input.Slug); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rebuild the unique index for the new schema
+	if _, err := tx.Exec(`DELETE FROM data_item_unique_values WHERE collection_id = $1`, current.ID); err != nil {
+		return nil, fmt.Errorf("failed to reset unique values: %w", err)
+	}
+	updated := &DataCollection{ID: current.ID, Slug: input.Slug, Fields: input.Fields}
+	rows, err := tx.Query(`SELECT id, data FROM data_items WHERE collection_id = $1`, current.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load items: %w", err)
+	}
+	type storedItem struct {
+		id   string
+		data map[string]interface{}
+	}
+	var items []storedItem
+	for rows.Next() {
+		var item storedItem
+		var raw []byte
+		if err := rows.Scan(&item.id, &raw); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		json.Unmarshal(raw, &item.data)
+		items = append(items, item)
+	}
+	rows.Close()
+	for _, item := range items {
+		if err := writeUniqueValues(tx, updated, item.id, item.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit collection: %w", err)
+	}
+
+	s.logger.Info("site_content_service", "update_collection", "Collection updated", map[string]interface{}{
+		"site_id":    siteID,
+		"collection": input.Slug,
+		"fields":     len(input.Fields),
+	})
+
+	return s.getCollection(s.db, siteID, input.Slug)
+}
+
+func (s *SiteContentService) renameCollectionReferences(tx *sql.Tx, siteID, from, to string) error {
+	rows, err := tx.Query(`SELECT id, fields FROM data_collections WHERE site_id = $1`, siteID)
+	if err != nil {
+		return fmt.Errorf("failed to load collections: %w", err)
+	}
+	updates := map[string][]CollectionField{}
+	for rows.Next() {
+		var id string
+		var raw []byte
+		var fields []CollectionField
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan collection: %w", err)
+		}
+		json.Unmarshal(raw, &fields)
+		changed := false
+		for i := range fields {
+			if fields[i].Type == FieldTypeReference && fields[i].Collection == from {
+				fields[i].Collection = to
+				changed = true
+			}
+		}
+		if changed {
+			updates[id] = fields
+		}
+	}
+	rows.Close()
+
+	for id, fields := range updates {
+		encoded, _ := json.Marshal(fields)
+		if _, err := tx.Exec(`UPDATE data_collections SET fields = $1 WHERE id = $2`, encoded, id); err != nil {
+			return fmt.Errorf("failed to update references: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteCollection removes a collection and its items unless another
+// collection references it
+func (s *SiteContentService) DeleteCollection(siteRef, slug string) error {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return err
+	}
+
+	collections, err := s.ListCollections(siteID)
+	if err != nil {
+		return err
+	}
+	for _, other := range collections {
+		if other.Slug == slug {
+			continue
+		}
+		for _, field := range other.Fields {
+			if field.Type == FieldTypeReference && field.Collection == slug {
+				return fmt.Errorf("collection is referenced by %s.%s", other.Slug, field.Name)
+			}
+		}
+	}
+
+	result, err := s.db.Exec(`DELETE FROM data_collections WHERE site_id = $1 AND slug = $2`, siteID, slug)
+	if err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("collection not found")
+	}
+
+	s.logger.Info("site_content_service", "delete_collection", "Collection deleted", map[string]interface{}{
+		"site_id":    siteID,
+		"collection": slug,
+	})
+	return nil
+}
+
+func (s *SiteContentService) validateCollectionInput(siteID string, input *DataCollectionInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if input.Slug == "" {
+		input.Slug = Slugify(input.Name)
+	}
+	if !pageSlugPattern.MatchString(input.Slug) {
+		return fmt.Errorf("slug must be lowercase letters, digits and single hyphens")
+	}
+	if len(input.Fields) == 0 {
+		return fmt.Errorf("a collection needs at least one field")
+	}
+
+	seen := map[string]bool{}
+	for i := range input.Fields {
+		field := &input.Fields[i]
+		if !fieldNamePattern.MatchString(field.Name) {
+			return fmt.Errorf("field name %q must start with a letter and use lowercase letters, digits or underscores", field.Name)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("field %q is defined twice", field.Name)
+		}
+		seen[field.Name] = true
+
+		switch field.Type {
+		case FieldTypeString, FieldTypeText, FieldTypeNumber, FieldTypeInteger, FieldTypeBoolean,
+			FieldTypeDate, FieldTypeDateTime, FieldTypeEmail, FieldTypeURL:
+		case FieldTypeEnum:
+			if len(field.Options) == 0 {
+				return fmt.Errorf("enum field %q needs options", field.Name)
+			}
+		case FieldTypeReference:
+			if field.Collection == "" {
+				return fmt.Errorf("reference field %q needs a target collection", field.Name)
+			}
+			if field.Collection != input.Slug {
+				var exists bool
+				if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM data_collections WHERE site_id = $1 AND slug = $2)`,
+					siteID, field.Collection).Scan(&exists); err != nil {
+					return fmt.Errorf("failed to check referenced collection: %w", err)
+				}
+				if !exists {
+					return fmt.Errorf("reference field %q points at unknown collection %q", field.Name, field.Collection)
+				}
+			}
+		case FieldTypeJSON:
+			if field.Unique {
+				return fmt.Errorf("json field %q cannot be unique", field.Name)
+			}
+		default:
+			return fmt.Errorf("field %q has unknown type %q", field.Name, field.Type)
+		}
+		if field.Type != FieldTypeEnum {
+			field.Options = nil
+		}
+		if field.Type != FieldTypeReference {
+			field.Collection = ""
+		}
+	}
+	return nil
+}
+
+// ListItems filters, searches, sorts and pages a collection's items
+func (s *SiteContentService) ListItems(siteRef, collectionSlug string, query ItemQuery) (*ItemPage, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := s.getCollection(s.db, siteID, collectionSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PerPage < 1 {
+		query.PerPage = 20
+	}
+	if query.PerPage > maxCollectionItemsPerPage {
+		query.PerPage = maxCollectionItemsPerPage
+	}
+
+	args := []interface{}{collection.ID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+	conditions := []string{"collection_id = $1"}
+
+	for _, filter := range query.Filters {
+		condition, err := filterCondition(collection, filter, arg)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	for _, term := range strings.Fields(strings.ToLower(query.Search)) {
+		conditions = append(conditions, "search_text LIKE "+arg("%"+likePrefix(term)+"%"))
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM data_items WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count items: %w", err)
+	}
+
+	orderBy, err := sortExpression(collection, query.Sort, arg)
+	if err != nil {
+		return nil, err
+	}
+	limit := arg(query.PerPage)
+	offset := arg((query.Page - 1) * query.PerPage)
+
+	rows, err := s.db.Query(`
+		SELECT id, data, created_at, updated_at FROM data_items
+		WHERE `+where+`
+		ORDER BY `+orderBy+`, id
+		LIMIT `+limit+` OFFSET `+offset, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+	defer rows.Close()
+
+	page := &ItemPage{
+		Items:      []*DataItem{},
+		Page:       query.Page,
+		PerPage:    query.PerPage,
+		Total:      total,
+		TotalPages: (total + query.PerPage - 1) / query.PerPage,
+	}
+	for rows.Next() {
+		item, err := scanItem(rows, collection.Slug)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, item)
+	}
+	return page, rows.Err()
+}
+
+// filterCondition builds a SQL condition for one filter. Field names are
+// bound as parameters and only accepted when they exist in the schema.
+func filterCondition(collection *DataCollection, filter ItemFilter, arg func(interface{}) string) (string, error) {
+	field := collection.field(filter.Field)
+	if field == nil {
+		return "", fmt.Errorf("unknown filter field %q", filter.Field)
+	}
+	if filter.Op == "" {
+		filter.Op = FilterEq
+	}
+
+	value := "data->>" + arg(field.Name)
+	numeric := field.Type == FieldTypeNumber || field.Type == FieldTypeInteger
+	if numeric && filter.Op != FilterExists && filter.Op != FilterIn {
+		if _, err := strconv.ParseFloat(filter.Value, 64); err != nil {
+			return "", fmt.Errorf("filter on %q needs a number", field.Name)
+		}
+		value = "(" + value + ")::DECIMAL"
+	}
+	operand := func() string {
+		if numeric {
+			return arg(filter.Value) + "::DECIMAL"
+		}
+		if field.Type == FieldTypeBoolean {
+			return arg(strings.ToLower(filter.Value))
+		}
+		return arg(filter.Value)
+	}
+
+	switch filter.Op {
+	case FilterEq:
+		return value + " = " + operand(), nil
+	case FilterNe:
+		return value + " IS DISTINCT FROM " + operand(), nil
+	case FilterGt:
+		return value + " > " + operand(), nil
+	case FilterGte:
+		return value + " >= " + operand(), nil
+	case FilterLt:
+		return value + " < " + operand(), nil
+	case FilterLte:
+		return value + " <= " + operand(), nil
+	case FilterContains:
+		return value + " ILIKE " + arg("%"+likePrefix(filter.Value)+"%"), nil
+	case FilterIn:
+		return value + " = ANY(" + arg(pq.Array(strings.Split(filter.Value, ","))) + ")", nil
+	case FilterExists:
+		if filter.Value == "false" {
+			return value + " IS NULL", nil
+		}
+		return value + " IS NOT NULL", nil
+	}
+	return "", fmt.Errorf("unknown filter operator %q", filter.Op)
+}
+
+func sortExpression(collection *DataCollection, sortKey string, arg func(interface{}) string) (string, error) {
+	direction := "ASC"
+	if strings.HasPrefix(sortKey, "-") {
+		direction = "DESC"
+		sortKey = sortKey[1:]
+	}
+
+	switch sortKey {
+	case "":
+		return "created_at DESC", nil
+	case "created_at", "updated_at":
+		return sortKey + " " + direction, nil
+	}
+
+	field := collection.field(sortKey)
+	if field == nil {
+		return "", fmt.Errorf("unknown sort field %q", sortKey)
+	}
+	expression := "data->>" + arg(field.Name)
+	if field.Type == FieldTypeNumber || field.Type == FieldTypeInteger {
+		expression = "(" + expression + ")::DECIMAL"
+	}
+	return expression + " " + direction + " NULLS LAST", nil
+}
+
+// GetItem returns one item of a collection
+func (s *SiteContentService) GetItem(siteRef, collectionSlug, itemID string) (*DataItem, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := s.getCollection(s.db, siteID, collectionSlug)
+	if err != nil {
+		return nil, err
+	}
+	return s.getItem(s.db, collection, itemID)
+}
+
+func (s *SiteContentService) getItem(q queryRower, collection *DataCollection, itemID string) (*DataItem, error) {
+	return scanItem(q.QueryRow(`
+		SELECT id, data, created_at, updated_at FROM data_items
+		WHERE collection_id = $1 AND id::TEXT = $2`, collection.ID, itemID), collection.Slug)
+}
+
+func scanItem(row rowScanner, collectionSlug string) (*DataItem, error) {
+	item := &DataItem{Collection: collectionSlug}
+	var raw []byte
+	if err := row.Scan(&item.ID, &raw, &item.CreatedAt, &item.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("item not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve item: %w", err)
+	}
+	if err := json.Unmarshal(raw, &item.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode item: %w", err)
+	}
+	return item, nil
+}
+
+// CreateItem validates data against the collection schema and stores it
+func (s *SiteContentService) CreateItem(siteRef, collectionSlug string, data map[string]interface{}) (*DataItem, error) {
+	return s.saveItem(siteRef, collectionSlug, "", data)
+}
+
+// UpdateItem replaces an item's data after validating it
+func (s *SiteContentService) UpdateItem(siteRef, collectionSlug, itemID string, data map[string]interface{}) (*DataItem, error) {
+	return s.saveItem(siteRef, collectionSlug, itemID, data)
+}
+
+func (s *SiteContentService) saveItem(siteRef, collectionSlug, itemID string, data map[string]interface{}) (*DataItem, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := s.getCollection(s.db, siteID, collectionSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	clean, err := validateItemData(collection, data)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.checkReferences(tx, siteID, collection, clean); err != nil {
+		return nil, err
+	}
+
+	encoded, _ := json.Marshal(clean)
+	searchText := itemSearchText(collection, clean)
+	now := time.Now()
+
+	if itemID == "" {
+		err = tx.QueryRow(`
+			INSERT INTO data_items (collection_id, site_id, data, search_text, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $5)
+			RETURNING id`, collection.ID, siteID, encoded, searchText, now).Scan(&itemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create item: %w", err)
+		}
+	} else {
+		result, err := tx.Exec(`
+			UPDATE data_items SET data = $1, search_text = $2, updated_at = $3
+			WHERE collection_id = $4 AND id::TEXT = $5`, encoded, searchText, now, collection.ID, itemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update item: %w", err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return nil, fmt.Errorf("item not found")
+		}
+		if _, err := tx.Exec(`DELETE FROM data_item_unique_values WHERE item_id = $1`, itemID); err != nil {
+			return nil, fmt.Errorf("failed to reset unique values: %w", err)
+		}
+	}
+
+	if err := writeUniqueValues(tx, collection, itemID, clean); err != nil {
+		return nil, err
+	}
+
+	item, err := s.getItem(tx, collection, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit item: %w", err)
+	}
+
+	return item, nil
+}
+
+// DeleteItem removes an item unless other items reference it
+func (s *SiteContentService) DeleteItem(siteRef, collectionSlug, itemID string) error {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return err
+	}
+	collection, err := s.getCollection(s.db, siteID, collectionSlug)
+	if err != nil {
+		return err
+	}
+
+	collections, err := s.ListCollections(siteID)
+	if err != nil {
+		return err
+	}
+	for _, other := range collections {
+		for _, field := range other.Fields {
+			if field.Type != FieldTypeReference || field.Collection != collection.Slug {
+				continue
+			}
+			var referenced bool
+			err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM data_items WHERE collection_id = $1 AND data->>$2 = $3)`,
+				other.ID, field.Name, itemID).Scan(&referenced)
+			if err != nil {
+				return fmt.Errorf("failed to check references: %w", err)
+			}
+			if referenced {
+				return fmt.Errorf("item is referenced by %s.%s", other.Slug, field.Name)
+			}
+		}
+	}
+
+	result, err := s.db.Exec(`DELETE FROM data_items WHERE collection_id = $1 AND id::TEXT = $2`, collection.ID, itemID)
+	if err != nil {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("item not found")
+	}
+	return nil
+}
+
+// validateItemData checks every field against the schema and returns the
+// normalised data; unknown fields are rejected
+func validateItemData(collection *DataCollection, data map[string]interface{}) (map[string]interface{}, error) {
+	var problems []string
+	for name := range data {
+		if collection.field(name) == nil {
+			problems = append(problems, fmt.Sprintf("%s: unknown field", name))
+		}
+	}
+
+	clean := make(map[string]interface{}, len(collection.Fields))
+	for _, field := range collection.Fields {
+		value, present := data[field.Name]
+		if !present || value == nil || value == "" {
+			if field.Required {
+				problems = append(problems, fmt.Sprintf("%s: is required", field.Name))
+			}
+			continue
+		}
+
+		normalized, err := normalizeFieldValue(field, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", field.Name, err))
+			continue
+		}
+		clean[field.Name] = normalized
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid item: %s", strings.Join(problems, "; "))
+	}
+	return clean, nil
+}
+
+func normalizeFieldValue(field CollectionField, value interface{}) (interface{}, error) {
+	text, isText := value.(string)
+
+	switch field.Type {
+	case FieldTypeString, FieldTypeText:
+		if !isText {
+			return nil, fmt.Errorf("must be a string")
+		}
+		if field.Type == FieldTypeString && len(text) > 1024 {
+			return nil, fmt.Errorf("must be at most 1024 characters")
+		}
+		return text, nil
+	case FieldTypeNumber:
+		number, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return number, nil
+	case FieldTypeInteger:
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return number, nil
+	case FieldTypeBoolean:
+		boolean, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return boolean, nil
+	case FieldTypeDate:
+		if _, err := time.Parse("2006-01-02", text); !isText || err != nil {
+			return nil, fmt.Errorf("must be a date in YYYY-MM-DD format")
+		}
+		return text, nil
+	case FieldTypeDateTime:
+		parsed, err := time.Parse(time.RFC3339, text)
+		if !isText || err != nil {
+			return nil, fmt.Errorf("must be an RFC 3339 timestamp")
+		}
+		// Stored in UTC so text comparison orders correctly
+		return parsed.UTC().Format(time.RFC3339), nil
+	case FieldTypeEmail:
+		address, err := mail.ParseAddress(text)
+		if !isText || err != nil || address.Address != text {
+			return nil, fmt.Errorf("must be an email address")
+		}
+		return text, nil
+	case FieldTypeURL:
+		parsed, err := url.Parse(text)
+		if !isText || err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("must be an absolute http or https URL")
+		}
+		return text, nil
+	case FieldTypeEnum:
+		for _, option := range field.Options {
+			if text == option {
+				return text, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of: %s", strings.Join(field.Options, ", "))
+	case FieldTypeReference:
+		if !isText {
+			return nil, fmt.Errorf("must be the id of a %s item", field.Collection)
+		}
+		return text, nil
+	case FieldTypeJSON:
+		return value, nil
+	}
+	return nil, fmt.Errorf("has unknown type %q", field.Type)
+}
+
+// checkReferences confirms every reference points at an item of the target
+// collection in the same site
+func (s *SiteContentService) checkReferences(tx *sql.Tx, siteID string, collection *DataCollection, data map[string]interface{}) error {
+	for _, field := range collection.Fields {
+		if field.Type != FieldTypeReference {
+			continue
+		}
+		target, ok := data[field.Name].(string)
+		if !ok {
+			continue
+		}
+		var exists bool
+		err := tx.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM data_items i
+				JOIN data_collections c ON i.collection_id = c.id
+				WHERE c.site_id = $1 AND c.slug = $2 AND i.id::TEXT = $3
+			)`, siteID, field.Collection, target).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check reference: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("invalid item: %s: no %s item with id %s", field.Name, field.Collection, target)
+		}
+	}
+	return nil
+}
+
+// writeUniqueValues claims the item's unique field values; the primary key
+// on data_item_unique_values rejects duplicates even under concurrency
+func writeUniqueValues(tx *sql.Tx, collection *DataCollection, itemID string, data map[string]interface{}) error {
+	for _, field := range collection.Fields {
+		if !field.Unique {
+			continue
+		}
+		value, ok := data[field.Name]
+		if !ok {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO data_item_unique_values (collection_id, field_name, value_key, item_id)
+			VALUES ($1, $2, $3, $4)`, collection.ID, field.Name, uniqueValueKey(value), itemID)
+		if err != nil {
+			if strings.Contains(err.Error(), "duplicate key") {
+				return fmt.Errorf("value for %s already exists in %s", field.Name, collection.Slug)
+			}
+			return fmt.Errorf("failed to record unique value: %w", err)
+		}
+	}
+	return nil
+}
+
+// uniqueValueKey canonicalises values so "SKU-1" and "sku-1" collide
+func uniqueValueKey(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.ToLower(strings.TrimSpace(v))
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func itemSearchText(collection *DataCollection, data map[string]interface{}) string {
+	var parts []string
+	for _, field := range collection.Fields {
+		switch field.Type {
+		case FieldTypeString, FieldTypeText, FieldTypeEmail, FieldTypeURL, FieldTypeEnum:
+			if text, ok := data[field.Name].(string); ok {
+				parts = append(parts, strings.ToLower(text))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
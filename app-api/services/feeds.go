@@ -0,0 +1,458 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxSitemapURLs is the sitemap protocol's per-file limit; larger sites get
+// a sitemap index pointing at numbered sitemaps of this size
+const MaxSitemapURLs = 50000
+
+// SiteInfo describes a site for syndication. BaseURL is the absolute
+// origin (scheme and host) from the site's primary domain.
+type SiteInfo struct {
+	ID            string
+	Name          string
+	Slug          string
+	BaseURL       string
+	Description   string
+	Language      string
+	DefaultLocale string
+	Template      string
+}
+
+// FeedItem is one post as it appears in a feed
+type FeedItem struct {
+	ID          string
+	Title       string
+	URL         string
+	Summary     string
+	ContentHTML string
+	Author      string
+	Categories  []string
+	Published   time.Time
+	Updated     time.Time
+}
+
+// SitemapURL is one <url> entry
+type SitemapURL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+// GetSiteInfo loads a site's name and primary domain. The domain marked
+// is_primary wins, then the oldest mapped domain, then sites.domain;
+// fallbackHost is used when the site has no domain at all. Description and
+// language come from the site_description and language settings; the
+// language defaults to the site's default locale.
+func (s *SiteContentService) GetSiteInfo(siteRef, fallbackHost string) (*SiteInfo, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &SiteInfo{ID: siteID}
+	var host string
+	sslEnabled := true
+	err = s.db.QueryRow(`
+		SELECT s.name, s.slug, COALESCE(d.domain_name, s.domain, ''), COALESCE(d.ssl_enabled, true), COALESCE(s.template, '')
+		FROM sites s
+		LEFT JOIN LATERAL (
+			SELECT domain_name, ssl_enabled FROM domains
+			WHERE site_id = s.id
+			ORDER BY is_primary DESC NULLS LAST, created_at
+			LIMIT 1
+		) d ON true
+		WHERE s.id = $1`, siteID).Scan(&info.Name, &info.Slug, &host, &sslEnabled, &info.Template)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("site not found")
+		}
+		return nil, fmt.Errorf("failed to load site: %w", err)
+	}
+
+	if host == "" {
+		host = fallbackHost
+	}
+	scheme := "https"
+	if !sslEnabled {
+		scheme = "http"
+	}
+	info.BaseURL = scheme + "://" + strings.TrimSuffix(host, "/")
+
+	settings, err := listSiteSettings(s.db, siteID)
+	if err != nil {
+		return nil, err
+	}
+	for _, setting := range settings {
+		value, _ := setting.Value.(string)
+		switch setting.Key {
+		case "site_description":
+			info.Description = value
+		case "language":
+			info.Language = value
+		}
+	}
+
+	locales, err := loadSiteLocales(s.db, siteID)
+	if err != nil {
+		return nil, err
+	}
+	info.DefaultLocale = locales.Default
+	if info.Language == "" {
+		info.Language = locales.Default
+	}
+	return info, nil
+}
+
+// CountSitemapURLs returns how many URLs the site's sitemap lists
+func (s *SiteContentService) CountSitemapURLs(siteID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM site_pages WHERE site_id = $1 AND status = 'published')
+			+ (SELECT COUNT(*) FROM posts WHERE site_id = $1 AND published = true AND deleted_at IS NULL)`,
+		siteID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count sitemap urls: %w", err)
+	}
+	return count, nil
+}
+
+// SitemapURLs returns one page of the site's published pages followed by
+// its published posts, in a stable order so numbered sitemaps don't shift
+// between requests
+func (s *SiteContentService) SitemapURLs(site *SiteInfo, offset, limit int) ([]SitemapURL, error) {
+	rows, err := s.db.Query(`
+		SELECT kind, path, locale, updated_at FROM (
+			SELECT 0 AS kind, path, '' AS locale, updated_at, id::TEXT AS sort_key
+			FROM site_pages WHERE site_id = $1 AND status = 'published'
+			UNION ALL
+			SELECT 1 AS kind, slug AS path, locale, updated_at, lpad(id::TEXT, 20, '0') AS sort_key
+			FROM posts WHERE site_id = $1 AND published = true AND deleted_at IS NULL
+		) entries
+		ORDER BY kind, sort_key
+		LIMIT $2 OFFSET $3`, site.ID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sitemap urls: %w", err)
+	}
+	defer rows.Close()
+
+	urls := []SitemapURL{}
+	for rows.Next() {
+		var kind int
+		var path, locale string
+		var updated time.Time
+		if err := rows.Scan(&kind, &path, &locale, &updated); err != nil {
+			return nil, fmt.Errorf("failed to scan sitemap url: %w", err)
+		}
+		loc := site.BaseURL + LocalizedPostPath(path, locale, site.DefaultLocale)
+		if kind == 0 {
+			loc = site.BaseURL + PagePath(path)
+		}
+		urls = append(urls, SitemapURL{Loc: loc, LastMod: updated})
+	}
+	return urls, rows.Err()
+}
+
+// latestUpdate is the newest Updated time among items, or the zero time
+func latestUpdate(items []FeedItem) time.Time {
+	var latest time.Time
+	for _, item := range items {
+		if item.Updated.After(latest) {
+			latest = item.Updated
+		}
+	}
+	return latest
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Content string     `xml:"xmlns:content,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Self          rssLink   `xml:"atom:link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+	Content     cdata    `xml:"content:encoded"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+// BuildRSS renders an RSS 2.0 feed with full content in content:encoded
+func BuildRSS(site *SiteInfo, feedURL string, items []FeedItem) ([]byte, error) {
+	channel := rssChannel{
+		Title:       site.Name,
+		Link:        site.BaseURL + "/",
+		Self:        rssLink{Href: feedURL, Rel: "self", Type: "application/rss+xml"},
+		Description: site.Description,
+		Language:    site.Language,
+		Items:       []rssItem{},
+	}
+	if channel.Description == "" {
+		channel.Description = site.Name
+	}
+	if latest := latestUpdate(items); !latest.IsZero() {
+		channel.LastBuildDate = latest.UTC().Format(time.RFC1123Z)
+	}
+	for _, item := range items {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.URL,
+			GUID:        rssGUID{IsPermaLink: true, Value: item.URL},
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+			Creator:     item.Author,
+			Categories:  item.Categories,
+			Description: item.Summary,
+			Content:     cdata{Value: item.ContentHTML},
+		})
+	}
+
+	return marshalXML(rssDocument{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Content: "http://purl.org/rss/1.0/modules/content/",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	})
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang     string      `xml:"xml:lang,attr,omitempty"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// BuildAtom renders an Atom 1.0 feed
+func BuildAtom(site *SiteInfo, feedURL string, items []FeedItem) ([]byte, error) {
+	updated := latestUpdate(items)
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	feed := atomFeed{
+		Lang:     site.Language,
+		ID:       site.BaseURL + "/",
+		Title:    site.Name,
+		Subtitle: site.Description,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: site.BaseURL + "/", Rel: "alternate", Type: "text/html"},
+			{Href: feedURL, Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: []atomEntry{},
+	}
+	for _, item := range items {
+		entry := atomEntry{
+			ID:        item.URL,
+			Title:     item.Title,
+			Link:      atomLink{Href: item.URL, Rel: "alternate", Type: "text/html"},
+			Published: item.Published.UTC().Format(time.RFC3339),
+			Updated:   item.Updated.UTC().Format(time.RFC3339),
+			Summary:   item.Summary,
+			Content:   atomContent{Type: "html", Value: item.ContentHTML},
+		}
+		if item.Author != "" {
+			entry.Author = &atomAuthor{Name: item.Author}
+		}
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return marshalXML(feed)
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Language    string         `json:"language,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	Summary       string           `json:"summary,omitempty"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// BuildJSONFeed renders a JSON Feed 1.1 document
+func BuildJSONFeed(site *SiteInfo, feedURL string, items []FeedItem) ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       site.Name,
+		HomePageURL: site.BaseURL + "/",
+		FeedURL:     feedURL,
+		Description: site.Description,
+		Language:    site.Language,
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range items {
+		entry := jsonFeedItem{
+			ID:            item.ID,
+			URL:           item.URL,
+			Title:         item.Title,
+			ContentHTML:   item.ContentHTML,
+			Summary:       item.Summary,
+			DatePublished: item.Published.UTC().Format(time.RFC3339),
+			DateModified:  item.Updated.UTC().Format(time.RFC3339),
+			Tags:          item.Categories,
+		}
+		if item.Author != "" {
+			entry.Authors = []jsonFeedAuthor{{Name: item.Author}}
+		}
+		feed.Items = append(feed.Items, entry)
+	}
+
+	return json.MarshalIndent(feed, "", "  ")
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name          `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURLEntry `xml:"url"`
+}
+
+type sitemapURLEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name          `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURLEntry `xml:"sitemap"`
+}
+
+// BuildSitemap renders a <urlset> sitemap
+func BuildSitemap(urls []SitemapURL) ([]byte, error) {
+	set := sitemapURLSet{URLs: []sitemapURLEntry{}}
+	for _, url := range urls {
+		entry := sitemapURLEntry{Loc: url.Loc}
+		if !url.LastMod.IsZero() {
+			entry.LastMod = url.LastMod.UTC().Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, entry)
+	}
+	return marshalXML(set)
+}
+
+// BuildSitemapIndex renders a <sitemapindex> pointing at the given sitemaps
+func BuildSitemapIndex(sitemaps []SitemapURL) ([]byte, error) {
+	index := sitemapIndex{Sitemaps: []sitemapURLEntry{}}
+	for _, sitemap := range sitemaps {
+		entry := sitemapURLEntry{Loc: sitemap.Loc}
+		if !sitemap.LastMod.IsZero() {
+			entry.LastMod = sitemap.LastMod.UTC().Format(time.RFC3339)
+		}
+		index.Sitemaps = append(index.Sitemaps, entry)
+	}
+	return marshalXML(index)
+}
+
+// LastSitemapUpdate returns the newest update among the site's published
+// pages and posts, for sitemap Last-Modified headers
+func (s *SiteContentService) LastSitemapUpdate(siteID string) (time.Time, error) {
+	var latest sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT GREATEST(
+			(SELECT MAX(updated_at) FROM site_pages WHERE site_id = $1 AND status = 'published'),
+			(SELECT MAX(updated_at) FROM posts WHERE site_id = $1 AND published = true AND deleted_at IS NULL))`,
+		siteID).Scan(&latest)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to find last update: %w", err)
+	}
+	return latest.Time, nil
+}
+
+func marshalXML(document interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,572 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fulfillment channel names stored on Order.Fulfillment
+const (
+	FulfillmentDirect = "direct"
+	FulfillmentThorne = "thorne"
+)
+
+// Order statuses used by the fulfillment flow
+const (
+	OrderStatusPending    = "pending"
+	OrderStatusProcessing = "processing"
+	OrderStatusSubmitted  = "submitted"
+	OrderStatusShipped    = "shipped"
+	OrderStatusFulfilled  = "fulfilled"
+	OrderStatusFailed     = "fulfillment_failed"
+)
+
+// FulfillmentProvider submits orders to a fulfillment channel and reports shipping progress
+type FulfillmentProvider interface {
+	// Name returns the channel name stored on Order.Fulfillment
+	Name() string
+	// Submit hands the order to the channel
+	Submit(order *Order, product *ThorneProduct, patient *Patient) (*FulfillmentResult, error)
+	// Track returns the latest shipping state known to the channel, or nil if there is none yet
+	Track(order *Order) (*TrackingUpdate, error)
+}
+
+// FulfillmentResult is the outcome of submitting an order to a provider
+type FulfillmentResult struct {
+	Provider   string `json:"provider"`
+	ExternalID string `json:"external_id,omitempty"`
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+}
+
+// TrackingUpdate carries shipping information back onto an order
+type TrackingUpdate struct {
+	OrderID        string     `json:"order_id,omitempty"`
+	ExternalID     string     `json:"external_id,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	Carrier        string     `json:"carrier,omitempty"`
+	TrackingNumber string     `json:"tracking_number"`
+	ShippedDate    *time.Time `json:"shipped_date,omitempty"`
+}
+
+// FulfillmentSettings configures order routing and the drop-ship endpoint
+type FulfillmentSettings struct {
+	DefaultProvider     string `json:"default_provider"`
+	ThorneExpressURL    string `json:"thorne_express_url"`
+	ThorneExpressAPIKey string `json:"thorne_express_api_key"`
+	TimeoutSeconds      int    `json:"timeout_seconds"`
+}
+
+// PickListItem is one product line on the direct-ship pick list
+type PickListItem struct {
+	ProductID   string   `json:"product_id"`
+	ProductName string   `json:"product_name"`
+	SKU         string   `json:"sku"`
+	Quantity    int      `json:"quantity"`
+	OrderIDs    []string `json:"order_ids"`
+}
+
+// PickList aggregates open direct-ship orders by product
+type PickList struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	OrderCount  int            `json:"order_count"`
+	Items       []PickListItem `json:"items"`
+}
+
+// PackingSlip is the printable summary that ships with a direct order
+type PackingSlip struct {
+	OrderID          string            `json:"order_id"`
+	OrderDate        time.Time         `json:"order_date"`
+	PractitionerName string            `json:"practitioner_name"`
+	ContactEmail     string            `json:"contact_email"`
+	Phone            string            `json:"phone"`
+	ReturnAddress    map[string]string `json:"return_address"`
+	PatientName      string            `json:"patient_name"`
+	PatientEmail     string            `json:"patient_email"`
+	ProductName      string            `json:"product_name"`
+	SKU              string            `json:"sku"`
+	Quantity         int               `json:"quantity"`
+	ReturnPolicy     string            `json:"return_policy"`
+}
+
+// DirectFulfillmentProvider ships orders from the practitioner's own stock.
+// Orders wait on the pick list until tracking is entered manually.
+type DirectFulfillmentProvider struct{}
+
+// NewDirectFulfillmentProvider creates a new DirectFulfillmentProvider instance
+func NewDirectFulfillmentProvider() *DirectFulfillmentProvider {
+	return &DirectFulfillmentProvider{}
+}
+
+// Name returns the direct channel name
+func (p *DirectFulfillmentProvider) Name() string {
+	return FulfillmentDirect
+}
+
+// Submit queues the order for picking and packing
+func (p *DirectFulfillmentProvider) Submit(order *Order, product *ThorneProduct, patient *Patient) (*FulfillmentResult, error) {
+	return &FulfillmentResult{
+		Provider: FulfillmentDirect,
+		Status:   OrderStatusProcessing,
+		Message:  "Order added to the pick list",
+	}, nil
+}
+
+// Track has nothing to report for direct orders; tracking is entered by hand
+func (p *DirectFulfillmentProvider) Track(order *Order) (*TrackingUpdate, error) {
+	return nil, nil
+}
+
+// ThorneExpressProvider drop-ships orders by submitting them to an external HTTP endpoint
+type ThorneExpressProvider struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+// NewThorneExpressProvider creates a new ThorneExpressProvider instance
+func NewThorneExpressProvider(endpoint, apiKey string, timeout time.Duration) *ThorneExpressProvider {
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	return &ThorneExpressProvider{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		apiKey:   apiKey,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the drop-ship channel name
+func (p *ThorneExpressProvider) Name() string {
+	return FulfillmentThorne
+}
+
+// thorneExpressOrder is the payload sent to the drop-ship endpoint
+type thorneExpressOrder struct {
+	Reference    string `json:"reference"`
+	SKU          string `json:"sku"`
+	Quantity     int    `json:"quantity"`
+	PatientName  string `json:"patient_name"`
+	PatientEmail string `json:"patient_email"`
+}
+
+// thorneExpressResponse is the drop-ship endpoint's view of an order
+type thorneExpressResponse struct {
+	ID             string     `json:"id"`
+	Status         string     `json:"status"`
+	Message        string     `json:"message"`
+	Carrier        string     `json:"carrier"`
+	TrackingNumber string     `json:"tracking_number"`
+	ShippedDate    *time.Time `json:"shipped_date"`
+}
+
+// Submit posts the order to the drop-ship endpoint
+func (p *ThorneExpressProvider) Submit(order *Order, product *ThorneProduct, patient *Patient) (*FulfillmentResult, error) {
+	if p.endpoint == "" {
+		return nil, fmt.Errorf("thorne express endpoint is not configured")
+	}
+
+	payload := thorneExpressOrder{
+		Reference: order.ID,
+		SKU:       product.SKU,
+		Quantity:  order.Quantity,
+	}
+	if patient != nil {
+		payload.PatientName = patient.Name
+		payload.PatientEmail = patient.Email
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode drop-ship order: %v", err)
+	}
+
+	var response thorneExpressResponse
+	if err := p.do(http.MethodPost, "/orders", body, &response); err != nil {
+		return nil, err
+	}
+	if response.ID == "" {
+		return nil, fmt.Errorf("drop-ship endpoint returned no order id")
+	}
+
+	return &FulfillmentResult{
+		Provider:   FulfillmentThorne,
+		ExternalID: response.ID,
+		Status:     OrderStatusSubmitted,
+		Message:    response.Message,
+	}, nil
+}
+
+// Track asks the drop-ship endpoint for the order's shipping state
+func (p *ThorneExpressProvider) Track(order *Order) (*TrackingUpdate, error) {
+	if order.FulfillmentRef == "" {
+		return nil, fmt.Errorf("order %s has not been submitted to thorne express", order.ID)
+	}
+
+	var response thorneExpressResponse
+	if err := p.do(http.MethodGet, "/orders/"+order.FulfillmentRef, nil, &response); err != nil {
+		return nil, err
+	}
+	if response.TrackingNumber == "" {
+		return nil, nil
+	}
+
+	return &TrackingUpdate{
+		OrderID:        order.ID,
+		ExternalID:     response.ID,
+		Status:         response.Status,
+		Carrier:        response.Carrier,
+		TrackingNumber: response.TrackingNumber,
+		ShippedDate:    response.ShippedDate,
+	}, nil
+}
+
+// do sends a request to the drop-ship endpoint and decodes the JSON response
+func (p *ThorneExpressProvider) do(method, path string, body []byte, target interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, p.endpoint+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build drop-ship request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("drop-ship request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read drop-ship response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("drop-ship endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to parse drop-ship response: %v", err)
+	}
+	return nil
+}
+
+// FulfillmentRouter chooses a provider for each order
+type FulfillmentRouter struct {
+	providers map[string]FulfillmentProvider
+}
+
+// NewFulfillmentRouter creates a router over the given providers
+func NewFulfillmentRouter(providers ...FulfillmentProvider) *FulfillmentRouter {
+	router := &FulfillmentRouter{providers: make(map[string]FulfillmentProvider)}
+	for _, provider := range providers {
+		router.providers[provider.Name()] = provider
+	}
+	return router
+}
+
+// Provider returns the provider registered under name
+func (r *FulfillmentRouter) Provider(name string) (FulfillmentProvider, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown fulfillment provider: %s", name)
+	}
+	return provider, nil
+}
+
+// Route picks the provider for a product. A product-level override wins, then the
+// configured default, then whichever channel the feature flags leave enabled.
+func (r *FulfillmentRouter) Route(product *ThorneProduct, settings *ThorneSettings) (FulfillmentProvider, error) {
+	enabled := func(name string) bool {
+		if _, ok := r.providers[name]; !ok {
+			return false
+		}
+		switch name {
+		case FulfillmentDirect:
+			return settings.Features["enable_direct_fulfillment"]
+		case FulfillmentThorne:
+			return settings.Features["enable_thorne_express"]
+		}
+		return true
+	}
+
+	candidates := []string{product.Fulfillment, settings.Fulfillment.DefaultProvider, FulfillmentDirect, FulfillmentThorne}
+	for _, name := range candidates {
+		if name != "" && enabled(name) {
+			return r.providers[name], nil
+		}
+	}
+
+	if product.Fulfillment != "" {
+		return nil, fmt.Errorf("fulfillment provider %s is disabled for product %s", product.Fulfillment, product.ID)
+	}
+	return nil, fmt.Errorf("no fulfillment provider is enabled")
+}
+
+// newFulfillmentRouter builds the router from the current settings, letting
+// THORNE_EXPRESS_URL and THORNE_EXPRESS_API_KEY override the JSON config
+func (s *ThorneService) newFulfillmentRouter(settings *ThorneSettings) *FulfillmentRouter {
+	endpoint := settings.Fulfillment.ThorneExpressURL
+	if env := os.Getenv("THORNE_EXPRESS_URL"); env != "" {
+		endpoint = env
+	}
+	apiKey := settings.Fulfillment.ThorneExpressAPIKey
+	if env := os.Getenv("THORNE_EXPRESS_API_KEY"); env != "" {
+		apiKey = env
+	}
+	timeout := time.Duration(settings.Fulfillment.TimeoutSeconds) * time.Second
+
+	return NewFulfillmentRouter(
+		NewDirectFulfillmentProvider(),
+		NewThorneExpressProvider(endpoint, apiKey, timeout),
+	)
+}
+
+// FulfillmentAPIKey returns the shared key drop-ship callbacks must present
+func (s *ThorneService) FulfillmentAPIKey() string {
+	if env := os.Getenv("THORNE_EXPRESS_API_KEY"); env != "" {
+		return env
+	}
+	settings, err := s.GetSettings()
+	if err != nil {
+		return ""
+	}
+	return settings.Fulfillment.ThorneExpressAPIKey
+}
+
+// fulfillOrder routes a new order and submits it to the chosen provider
+func (s *ThorneService) fulfillOrder(order *Order, product *ThorneProduct, patient *Patient) error {
+	settings, err := s.GetSettings()
+	if err != nil {
+		return err
+	}
+
+	provider, err := s.newFulfillmentRouter(settings).Route(product, settings)
+	if err != nil {
+		return err
+	}
+	order.Fulfillment = provider.Name()
+
+	result, err := provider.Submit(order, product, patient)
+	if err != nil {
+		order.Status = OrderStatusFailed
+		return fmt.Errorf("failed to submit order to %s: %v", provider.Name(), err)
+	}
+
+	order.Status = result.Status
+	order.FulfillmentRef = result.ExternalID
+	return nil
+}
+
+// UpdateOrderTracking applies a tracking update to an order and persists it
+func (s *ThorneService) UpdateOrderTracking(orderID string, update TrackingUpdate) (*Order, error) {
+	if update.TrackingNumber == "" {
+		return nil, fmt.Errorf("tracking number is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders, err := s.GetOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		if orders[i].ID != orderID {
+			continue
+		}
+		applyTrackingUpdate(&orders[i], update)
+		if err := s.saveOrders(orders); err != nil {
+			return nil, err
+		}
+		return &orders[i], nil
+	}
+
+	return nil, fmt.Errorf("order not found: %s", orderID)
+}
+
+// ApplyProviderTracking matches a provider callback to an order by external ID or order ID
+func (s *ThorneService) ApplyProviderTracking(update TrackingUpdate) (*Order, error) {
+	if update.OrderID != "" {
+		return s.UpdateOrderTracking(update.OrderID, update)
+	}
+	if update.ExternalID == "" {
+		return nil, fmt.Errorf("order_id or external_id is required")
+	}
+
+	orders, err := s.GetOrders()
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		if order.FulfillmentRef == update.ExternalID {
+			return s.UpdateOrderTracking(order.ID, update)
+		}
+	}
+
+	return nil, fmt.Errorf("order not found for external id: %s", update.ExternalID)
+}
+
+// SyncOrderTracking polls the order's provider and applies any new tracking
+func (s *ThorneService) SyncOrderTracking(orderID string) (*Order, error) {
+	order, err := s.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := s.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.newFulfillmentRouter(settings).Provider(order.Fulfillment)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := provider.Track(order)
+	if err != nil {
+		return nil, err
+	}
+	if update == nil {
+		return order, nil
+	}
+
+	return s.UpdateOrderTracking(orderID, *update)
+}
+
+// GetPickList aggregates open direct-ship orders by product
+func (s *ThorneService) GetPickList() (*PickList, error) {
+	orders, err := s.GetOrders()
+	if err != nil {
+		return nil, err
+	}
+	products, err := s.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	skus := make(map[string]string)
+	for _, product := range products {
+		skus[product.ID] = product.SKU
+	}
+
+	items := make(map[string]*PickListItem)
+	pickList := &PickList{GeneratedAt: time.Now(), Items: []PickListItem{}}
+
+	for _, order := range orders {
+		if order.Fulfillment != FulfillmentDirect {
+			continue
+		}
+		if order.Status != OrderStatusPending && order.Status != OrderStatusProcessing {
+			continue
+		}
+
+		item, exists := items[order.ProductID]
+		if !exists {
+			item = &PickListItem{
+				ProductID:   order.ProductID,
+				ProductName: order.ProductName,
+				SKU:         skus[order.ProductID],
+			}
+			items[order.ProductID] = item
+		}
+		item.Quantity += order.Quantity
+		item.OrderIDs = append(item.OrderIDs, order.ID)
+		pickList.OrderCount++
+	}
+
+	for _, item := range items {
+		pickList.Items = append(pickList.Items, *item)
+	}
+	sort.Slice(pickList.Items, func(i, j int) bool {
+		return pickList.Items[i].SKU < pickList.Items[j].SKU
+	})
+
+	return pickList, nil
+}
+
+// GetPackingSlip builds the packing slip for a direct-ship order
+func (s *ThorneService) GetPackingSlip(orderID string) (*PackingSlip, error) {
+	order, err := s.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Fulfillment != FulfillmentDirect {
+		return nil, fmt.Errorf("order %s is not fulfilled directly", orderID)
+	}
+
+	settings, err := s.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	slip := &PackingSlip{
+		OrderID:          order.ID,
+		OrderDate:        order.OrderDate,
+		PractitionerName: settings.PractitionerName,
+		ContactEmail:     settings.ContactEmail,
+		Phone:            settings.Phone,
+		ReturnAddress:    settings.Address,
+		ProductName:      order.ProductName,
+		Quantity:         order.Quantity,
+		ReturnPolicy:     settings.Compliance["return_policy"],
+	}
+
+	if product, err := s.GetProductByID(order.ProductID); err == nil {
+		slip.SKU = product.SKU
+	}
+	if patient, err := s.GetPatientByID(order.PatientID); err == nil {
+		slip.PatientName = patient.Name
+		slip.PatientEmail = patient.Email
+	}
+
+	return slip, nil
+}
+
+// applyTrackingUpdate copies tracking fields onto an order
+func applyTrackingUpdate(order *Order, update TrackingUpdate) {
+	trackingNumber := update.TrackingNumber
+	order.TrackingNumber = &trackingNumber
+	if update.Carrier != "" {
+		order.Carrier = update.Carrier
+	}
+	if update.ExternalID != "" && order.FulfillmentRef == "" {
+		order.FulfillmentRef = update.ExternalID
+	}
+
+	shipped := update.ShippedDate
+	if shipped == nil && order.ShippedDate == nil {
+		now := time.Now()
+		shipped = &now
+	}
+	if shipped != nil {
+		order.ShippedDate = shipped
+	}
+
+	switch update.Status {
+	case OrderStatusFulfilled, "delivered":
+		order.Status = OrderStatusFulfilled
+	default:
+		if order.Status != OrderStatusFulfilled {
+			order.Status = OrderStatusShipped
+		}
+	}
+}
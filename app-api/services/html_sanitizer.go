@@ -0,0 +1,282 @@
+package services
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// htmlPolicy is an allow-list of elements and, for each, the attributes it
+// may keep; global attributes are allowed on every permitted element.
+// Anything else is stripped, leaving its text content.
+type htmlPolicy struct {
+	tags   map[string][]string
+	global []string
+}
+
+// contentPolicy applies to author-supplied HTML and Markdown output
+var contentPolicy = htmlPolicy{
+	tags: map[string][]string{
+		"p": nil, "br": nil, "hr": nil, "span": nil,
+		"strong": nil, "b": nil, "em": nil, "i": nil, "u": nil, "s": nil,
+		"sub": nil, "sup": nil, "code": nil, "pre": nil, "blockquote": nil,
+		"h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+		"ul": nil, "ol": nil, "li": nil,
+		"table": nil, "thead": nil, "tbody": nil, "tr": nil, "th": nil, "td": nil,
+		"a":   {"href", "title"},
+		"img": {"src", "alt", "title", "width", "height"},
+	},
+}
+
+// blockPolicy also admits the structural markup and classes that block
+// templates emit
+var blockPolicy = htmlPolicy{
+	tags: mergeTags(contentPolicy.tags, map[string][]string{
+		"h1": nil, "section": nil, "figure": nil, "figcaption": nil,
+		"aside": {"role"},
+		"li":    {"data-product-id"},
+		"img":   {"src", "alt", "title", "width", "height", "loading"},
+	}),
+	global: []string{"class"},
+}
+
+func mergeTags(base, extra map[string][]string) map[string][]string {
+	merged := make(map[string][]string, len(base)+len(extra))
+	for tag, attrs := range base {
+		merged[tag] = attrs
+	}
+	for tag, attrs := range extra {
+		merged[tag] = attrs
+	}
+	return merged
+}
+
+var sanitizerVoidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// sanitizerDropTags lose their content as well as their markup
+var sanitizerDropTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"noscript": true, "template": true, "textarea": true, "title": true, "head": true, "svg": true, "math": true,
+}
+
+var (
+	sanitizerTagName   = regexp.MustCompile(`^</?([a-zA-Z][a-zA-Z0-9]*)`)
+	sanitizerAttribute = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	sanitizerDimension = regexp.MustCompile(`^[0-9]{1,5}$`)
+)
+
+// SanitizeHTML reduces untrusted HTML to the allow-listed elements and
+// attributes. Text is re-escaped, URLs must be http(s), mailto, tel,
+// site-relative or fragments, and unclosed elements are closed.
+func SanitizeHTML(input string) string {
+	return contentPolicy.sanitize(input)
+}
+
+func (p htmlPolicy) sanitize(input string) string {
+	var out strings.Builder
+	var open []string
+
+	for i := 0; i < len(input); {
+		lt := strings.IndexByte(input[i:], '<')
+		if lt < 0 {
+			out.WriteString(escapeText(input[i:]))
+			break
+		}
+		out.WriteString(escapeText(input[i : i+lt]))
+		i += lt
+
+		rest := input[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return closeOpenTags(&out, open)
+			}
+			i += 4 + end + 3
+			continue
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return closeOpenTags(&out, open)
+			}
+			i += end + 1
+			continue
+		}
+
+		match := sanitizerTagName.FindStringSubmatch(rest)
+		if match == nil {
+			out.WriteString("&lt;")
+			i++
+			continue
+		}
+		end := tagEnd(rest, len(match[0]))
+		if end < 0 {
+			// Unterminated tag: drop the remainder rather than guess
+			return closeOpenTags(&out, open)
+		}
+		name := strings.ToLower(match[1])
+		closing := rest[1] == '/'
+		attrs := rest[len(match[0]):end]
+		i += end + 1
+
+		if sanitizerDropTags[name] {
+			if !closing {
+				i += skipElementContent(input[i:], name)
+			}
+			continue
+		}
+		allowed, ok := p.tags[name]
+		if !ok {
+			continue
+		}
+
+		if closing {
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == name {
+					for k := len(open) - 1; k >= j; k-- {
+						out.WriteString("</" + open[k] + ">")
+					}
+					open = open[:j]
+					break
+				}
+			}
+			continue
+		}
+
+		out.WriteString("<" + name + sanitizeAttributes(attrs, allowed, p.global) + ">")
+		if !sanitizerVoidTags[name] {
+			open = append(open, name)
+		}
+	}
+
+	return closeOpenTags(&out, open)
+}
+
+func escapeText(text string) string {
+	return html.EscapeString(html.UnescapeString(text))
+}
+
+func closeOpenTags(out *strings.Builder, open []string) string {
+	for j := len(open) - 1; j >= 0; j-- {
+		out.WriteString("</" + open[j] + ">")
+	}
+	return out.String()
+}
+
+// tagEnd finds the closing '>' of a tag, skipping quoted attribute values
+func tagEnd(tag string, from int) int {
+	var quote byte
+	for i := from; i < len(tag); i++ {
+		c := tag[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// skipElementContent returns the length of input up to and including the
+// closing tag for name, or all of it when the element is never closed. The
+// tag name is matched ASCII case-insensitively on the original bytes, as
+// browsers do; lowercasing the input first would shift offsets wherever
+// case mapping changes a character's encoded length.
+func skipElementContent(input, name string) int {
+	closing := "</" + name
+	for at := 0; at < len(input); at++ {
+		idx := strings.Index(input[at:], "</")
+		if idx < 0 {
+			break
+		}
+		at += idx
+		if !hasPrefixFoldASCII(input[at:], closing) {
+			continue
+		}
+		after := at + len(closing)
+		if after == len(input) || strings.IndexByte(">/ \t\n\r\f", input[after]) >= 0 {
+			end := strings.IndexByte(input[after:], '>')
+			if end < 0 {
+				return len(input)
+			}
+			return after + end + 1
+		}
+	}
+	return len(input)
+}
+
+// hasPrefixFoldASCII reports whether s starts with prefix, ignoring the case
+// of ASCII letters only
+func hasPrefixFoldASCII(s, prefix string) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		a, b := s[i], prefix[i]
+		if 'A' <= a && a <= 'Z' {
+			a += 'a' - 'A'
+		}
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		if a != b {
+			return false
+		}
+	}
+	return true
+}
+
+func sanitizeAttributes(raw string, allowed, global []string) string {
+	if len(allowed) == 0 && len(global) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	seen := map[string]bool{}
+	for _, match := range sanitizerAttribute.FindAllStringSubmatch(raw, -1) {
+		name := strings.ToLower(match[1])
+		if seen[name] || !(containsString(allowed, name) || containsString(global, name)) {
+			continue
+		}
+		value := html.UnescapeString(match[2] + match[3] + match[4])
+
+		switch name {
+		case "href", "src":
+			value = strings.TrimSpace(value)
+			if !isSafeContentURL(value) {
+				continue
+			}
+		case "width", "height":
+			if !sanitizerDimension.MatchString(value) {
+				continue
+			}
+		}
+
+		seen[name] = true
+		out.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	return out.String()
+}
+
+// isSafeContentURL accepts the same targets as navigation links plus
+// in-page fragments
+func isSafeContentURL(raw string) bool {
+	if strings.HasPrefix(raw, "#") {
+		return !strings.ContainsAny(raw, " \t\r\n")
+	}
+	return isNavigableURL(raw)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
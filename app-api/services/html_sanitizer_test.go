@@ -0,0 +1,78 @@
+package services
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"allowed markup", `<p>Hello <strong>world</strong></p>`, `<p>Hello <strong>world</strong></p>`},
+		{"text is escaped", `1 < 2 & "x"`, `1 &lt; 2 &amp; &#34;x&#34;`},
+		{"safe link", `<a href="https://example.com/a?b=1&amp;c=2" title="t">x</a>`, `<a href="https://example.com/a?b=1&amp;c=2" title="t">x</a>`},
+		{"relative link", `<a href="/posts/hello">x</a>`, `<a href="/posts/hello">x</a>`},
+		{"javascript url", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript url mixed case", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript url with whitespace", `<a href="  javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript url with tab", "<a href=\"java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"entity-encoded javascript url", `<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"hex entity-encoded javascript url", `<a href="&#x6A;&#x61;vascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"named entity colon", `<a href="javascript&colon;alert(1)">x</a>`, `<a>x</a>`},
+		{"data url image", `<img src="data:image/svg+xml;base64,PHN2Zz4=" alt="a">`, `<img alt="a">`},
+		{"protocol-relative url", `<a href="//evil.example/">x</a>`, `<a>x</a>`},
+		{"backslash protocol-relative url", `<a href="/\evil.example/">x</a>`, `<a>x</a>`},
+		{"unquoted attribute", `<a href=javascript:alert(1)>x</a>`, `<a>x</a>`},
+		{"event handler", `<p onclick="alert(1)">x</p>`, `<p>x</p>`},
+		{"event handler on image", `<img src="/a.png" onerror="alert(1)">`, `<img src="/a.png">`},
+		{"event handler after slash", `<img/onerror=alert(1) src="/a.png">`, `<img src="/a.png">`},
+		{"style attribute", `<p style="background:url(javascript:alert(1))">x</p>`, `<p>x</p>`},
+		{"disallowed element keeps text", `<div><font color="red">x</font></div>`, `x`},
+		{"script dropped", `a<script>alert(1)</script>b`, `ab`},
+		{"script closing tag case", `a<script>alert(1)</SCRIPT >b`, `ab`},
+		{"script dropped with fake closing tag", `a<script>"</scriptx>"; alert(1)</script>b`, `ab`},
+		{"style dropped", `<style>p{color:red}</style><p>x</p>`, `<p>x</p>`},
+		{"iframe dropped", `<iframe src="https://evil.example"></iframe>x`, `x`},
+		{"svg dropped", `<svg><script>alert(1)</script></svg>x`, `x`},
+		{"unclosed script drops the rest", `a<script>alert(1)`, `a`},
+		{"kelvin sign before closing tag", "<script>K</script><b>x</b>", `<b>x</b>`},
+		{"kelvin sign does not close script", "<script>K</Kscript><img src=x onerror=alert(1)></script>y", `y`},
+		{"unterminated tag", `<p>x<img src="/a.png" onerror="alert(1)"`, `<p>x</p>`},
+		{"unterminated quote", `<p>x<a href="/a>y</a></p>`, `<p>x</p>`},
+		{"unclosed elements are closed", `<ul><li><em>x`, `<ul><li><em>x</em></li></ul>`},
+		{"stray closing tag", `x</p>`, `x`},
+		{"comment removed", `a<!-- <script>alert(1)</script> -->b`, `ab`},
+		{"bare less-than", `a <b`, `a `},
+		{"invalid width", `<img src="/a.png" width="100%">`, `<img src="/a.png">`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeHTML(tt.input); got != tt.want {
+				t.Errorf("SanitizeHTML(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipElementContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"closed", `alert(1)</script>rest`, len(`alert(1)</script>`)},
+		{"upper case closing tag", `alert(1)</SCRIPT>rest`, len(`alert(1)</SCRIPT>`)},
+		{"closing tag with space", `x</script >rest`, len(`x</script >`)},
+		{"longer tag name is not a match", `x</scripts></script>rest`, len(`x</scripts></script>`)},
+		{"never closed", `alert(1)`, len(`alert(1)`)},
+		{"closing tag never ends", `x</script`, len(`x</script`)},
+		{"multi-byte case mapping", "KK</script>rest", len("KK</script>")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skipElementContent(tt.input, "script"); got != tt.want {
+				t.Errorf("skipElementContent(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
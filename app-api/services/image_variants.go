@@ -0,0 +1,264 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// maxImagePixels bounds decoded image size so a small, highly compressed
+// upload cannot exhaust memory
+const maxImagePixels = 50_000_000
+
+// ImageVariantSpec is a named rendition bounded to MaxEdge pixels on its
+// longest side
+type ImageVariantSpec struct {
+	Name    string
+	MaxEdge int
+}
+
+// imageVariantSpecs are generated for every raster upload larger than the
+// bound; a "<name>_webp" companion is added when a WebP encoder is available
+var imageVariantSpecs = []ImageVariantSpec{
+	{Name: "thumbnail", MaxEdge: 150},
+	{Name: "small", MaxEdge: 480},
+	{Name: "medium", MaxEdge: 1024},
+	{Name: "large", MaxEdge: 1920},
+}
+
+// encodedImage is one generated rendition ready for storage
+type encodedImage struct {
+	Name     string
+	MimeType string
+	Ext      string
+	Width    int
+	Height   int
+	Data     []byte
+}
+
+// WebPEncoder converts an image to WebP. The standard library has no WebP
+// encoder, so the default implementation shells out to cwebp.
+type WebPEncoder interface {
+	EncodeWebP(ctx context.Context, img image.Image) ([]byte, error)
+}
+
+// CWebPEncoder encodes through the cwebp command-line tool
+type CWebPEncoder struct {
+	path    string
+	quality int
+}
+
+// NewCWebPEncoder locates cwebp (path, or $PATH when empty) and returns nil
+// when it is not installed
+func NewCWebPEncoder(path string) *CWebPEncoder {
+	if path == "" {
+		found, err := exec.LookPath("cwebp")
+		if err != nil {
+			return nil
+		}
+		path = found
+	} else if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	return &CWebPEncoder{path: path, quality: 80}
+}
+
+// EncodeWebP implements WebPEncoder
+func (e *CWebPEncoder) EncodeWebP(ctx context.Context, img image.Image) ([]byte, error) {
+	var input bytes.Buffer
+	if err := png.Encode(&input, img); err != nil {
+		return nil, fmt.Errorf("failed to encode webp input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var output, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.path, "-quiet", "-q", fmt.Sprint(e.quality), "-o", "-", "--", "-")
+	cmd.Stdin = &input
+	cmd.Stdout = &output
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to encode webp: %v: %s", err, stderr.String())
+	}
+	return output.Bytes(), nil
+}
+
+// imageDimensions reads the pixel size of a JPEG, PNG, GIF or WebP image
+// without decoding it
+func imageDimensions(data []byte, mimeType string) (int, int, bool) {
+	if mimeType == "image/webp" {
+		return webpDimensions(data)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
+
+// webpDimensions parses the VP8, VP8L or VP8X chunk header of a WebP file
+func webpDimensions(data []byte) (int, int, bool) {
+	if len(data) < 30 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, false
+	}
+	chunk := data[12:]
+	switch string(chunk[0:4]) {
+	case "VP8 ":
+		// Frame tag (3 bytes), start code (3 bytes), then 14-bit sizes
+		if chunk[11] != 0x9d || chunk[12] != 0x01 || chunk[13] != 0x2a {
+			return 0, 0, false
+		}
+		width := int(binary.LittleEndian.Uint16(chunk[14:16]) & 0x3fff)
+		height := int(binary.LittleEndian.Uint16(chunk[16:18]) & 0x3fff)
+		return width, height, true
+	case "VP8L":
+		if chunk[8] != 0x2f {
+			return 0, 0, false
+		}
+		bits := binary.LittleEndian.Uint32(chunk[9:13])
+		return int(bits&0x3fff) + 1, int((bits>>14)&0x3fff) + 1, true
+	case "VP8X":
+		width := int(chunk[12]) | int(chunk[13])<<8 | int(chunk[14])<<16
+		height := int(chunk[15]) | int(chunk[16])<<8 | int(chunk[17])<<16
+		return width + 1, height + 1, true
+	}
+	return 0, 0, false
+}
+
+// isResizableImage reports whether variants can be generated for mimeType
+func isResizableImage(mimeType string) bool {
+	return mimeType == "image/jpeg" || mimeType == "image/png" || mimeType == "image/gif"
+}
+
+// generateImageVariants decodes a raster image and renders each variant
+// that is smaller than the original, keeping the source format (GIFs become
+// PNG stills) and adding WebP companions when webp is non-nil. A WebP
+// failure is returned as webpErr and only drops the WebP renditions.
+func generateImageVariants(ctx context.Context, r io.Reader, mimeType string, webp WebPEncoder) (variants []encodedImage, webpErr error, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid image: %w", err)
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, nil, fmt.Errorf("image exceeds %d pixels", maxImagePixels)
+	}
+
+	var src image.Image
+	switch mimeType {
+	case "image/jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		src, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		src, err = gif.Decode(bytes.NewReader(data))
+	default:
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid image: %w", err)
+	}
+
+	encodeWebP := func(name string, img image.Image, width, height int) {
+		if webp == nil || webpErr != nil {
+			return
+		}
+		encoded, err := webp.EncodeWebP(ctx, img)
+		if err != nil {
+			webpErr = err
+			return
+		}
+		variants = append(variants, encodedImage{
+			Name: name, MimeType: "image/webp", Ext: ".webp",
+			Width: width, Height: height, Data: encoded,
+		})
+	}
+
+	bounds := src.Bounds()
+	for _, spec := range imageVariantSpecs {
+		width, height := fitWithin(bounds.Dx(), bounds.Dy(), spec.MaxEdge)
+		if width >= bounds.Dx() && height >= bounds.Dy() {
+			continue
+		}
+		resized := resizeImage(src, width, height)
+
+		var buf bytes.Buffer
+		variant := encodedImage{Name: spec.Name, Width: width, Height: height}
+		if mimeType == "image/jpeg" {
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85})
+			variant.MimeType, variant.Ext = "image/jpeg", ".jpg"
+		} else {
+			err = png.Encode(&buf, resized)
+			variant.MimeType, variant.Ext = "image/png", ".png"
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode %s variant: %w", spec.Name, err)
+		}
+		variant.Data = buf.Bytes()
+		variants = append(variants, variant)
+		encodeWebP(spec.Name+"_webp", resized, width, height)
+	}
+	encodeWebP("original_webp", src, bounds.Dx(), bounds.Dy())
+
+	return variants, webpErr, nil
+}
+
+// fitWithin scales width x height so the longest side is at most maxEdge
+func fitWithin(width, height, maxEdge int) (int, int) {
+	if width <= maxEdge && height <= maxEdge {
+		return width, height
+	}
+	if width >= height {
+		return maxEdge, max(1, height*maxEdge/width)
+	}
+	return max(1, width*maxEdge/height), maxEdge
+}
+
+// resizeImage downsamples src with an area-averaging box filter, which is
+// adequate for the reductions variants need and avoids a dependency
+func resizeImage(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	for y := 0; y < height; y++ {
+		y0 := y * srcH / height
+		y1 := max(y0+1, (y+1)*srcH/height)
+		for x := 0; x < width; x++ {
+			x0 := x * srcW / width
+			x1 := max(x0+1, (x+1)*srcW/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				offset := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(rgba.Pix[offset])
+					g += uint64(rgba.Pix[offset+1])
+					b += uint64(rgba.Pix[offset+2])
+					a += uint64(rgba.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
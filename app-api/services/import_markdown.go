@@ -0,0 +1,812 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits on extracting an uploaded import archive
+const (
+	importArchiveMaxFiles = 10000
+	importArchiveMaxBytes = 2 << 30
+)
+
+var (
+	markdownImageRef  = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	markdownLinkRef   = regexp.MustCompile(`\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	markdownHTMLRef   = regexp.MustCompile(`(?i)<(?:img|source|video|a)\s[^>]*?(?:src|href)\s*=\s*["']([^"']+)["']`)
+	jekyllPostName    = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})-(.+)$`)
+	importMediaSuffix = map[string]bool{
+		".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+		".pdf": true, ".mp4": true, ".webm": true,
+	}
+)
+
+// markdownFile is a Markdown post found in an import folder
+type markdownFile struct {
+	rel   string // slash-separated path below the root
+	slug  string // slug implied by the file name
+	date  time.Time
+	draft bool
+	// oldPath is the URL the generator gave the post, when it can be known
+	oldPath string
+}
+
+// LoadImportSource reads an import from srcPath: a WXR file, a folder, or
+// a zip or tar.gz archive of either, which is extracted into workDir. A
+// WXR import reads attachments from mediaDir, or from the wp-content/uploads
+// folder inside an archive.
+func LoadImportSource(format, srcPath, mediaDir, workDir string) (*ImportSource, error) {
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import source: %w", err)
+	}
+	root := srcPath
+	if !info.IsDir() {
+		file, err := os.Open(srcPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read import source: %w", err)
+		}
+		defer file.Close()
+		magic := make([]byte, 4)
+		n, _ := io.ReadFull(file, magic)
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to read import source: %w", err)
+		}
+		if !isImportArchive(magic[:n]) {
+			if format != ImportFormatWXR {
+				return nil, fmt.Errorf("a markdown import must be a folder or a zip or tar.gz archive")
+			}
+			return ParseWXR(file, mediaDir)
+		}
+		if workDir == "" {
+			return nil, fmt.Errorf("failed to extract archive: no working directory")
+		}
+		if err := ExtractImportArchive(file, workDir); err != nil {
+			return nil, err
+		}
+		root = workDir
+	}
+	root = importRoot(root)
+
+	switch format {
+	case ImportFormatWXR:
+		exportFile, err := findImportFile(root, func(rel string, entry fs.DirEntry) bool {
+			return !entry.IsDir() && strings.EqualFold(path.Ext(rel), ".xml")
+		})
+		if err != nil {
+			return nil, err
+		}
+		if exportFile == "" {
+			return nil, fmt.Errorf("no WXR .xml file found in the import")
+		}
+		if mediaDir == "" {
+			uploads, err := findImportFile(root, func(rel string, entry fs.DirEntry) bool {
+				return entry.IsDir() && (rel == "uploads" || strings.HasSuffix(rel, "wp-content/uploads"))
+			})
+			if err != nil {
+				return nil, err
+			}
+			mediaDir = uploads
+		}
+		file, err := os.Open(exportFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read import source: %w", err)
+		}
+		defer file.Close()
+		return ParseWXR(file, mediaDir)
+	case ImportFormatMarkdown:
+		return ParseMarkdownFolder(root)
+	default:
+		return nil, fmt.Errorf("format must be %s or %s", ImportFormatWXR, ImportFormatMarkdown)
+	}
+}
+
+func isImportArchive(magic []byte) bool {
+	return bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte{0x1f, 0x8b})
+}
+
+// importRoot steps into the single folder archives usually wrap their
+// content in
+func importRoot(dir string) string {
+	for {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return dir
+		}
+		var visible []fs.DirEntry
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), ".") && entry.Name() != "__MACOSX" {
+				visible = append(visible, entry)
+			}
+		}
+		if len(visible) != 1 || !visible[0].IsDir() {
+			return dir
+		}
+		dir = filepath.Join(dir, visible[0].Name())
+	}
+}
+
+// findImportFile returns the first path under root, in lexical order, that
+// match accepts
+func findImportFile(root string, match func(rel string, entry fs.DirEntry) bool) (string, error) {
+	found := ""
+	err := filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if rel != "." && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel != "." && match(rel, entry) {
+			found = p
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read import folder: %w", err)
+	}
+	return found, nil
+}
+
+// ExtractImportArchive unpacks a zip or tar.gz archive into dir. Entries
+// that would land outside dir, links and device files are ignored.
+func ExtractImportArchive(r io.Reader, dir string) error {
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(4)
+	var written int64
+	var files int
+	create := func(name string, mode fs.FileMode, content io.Reader) error {
+		target, ok := importArchivePath(dir, name)
+		if !ok {
+			return nil
+		}
+		if files++; files > importArchiveMaxFiles {
+			return fmt.Errorf("archive has more than %d files", importArchiveMaxFiles)
+		}
+		if mode.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		if !mode.IsRegular() {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(out, io.LimitReader(content, importArchiveMaxBytes-written+1))
+		written += n
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		if written > importArchiveMaxBytes {
+			return fmt.Errorf("archive expands to more than %d MB", importArchiveMaxBytes>>20)
+		}
+		return nil
+	}
+
+	var err error
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		err = extractImportZip(buffered, create)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		err = extractImportTar(buffered, create)
+	default:
+		return fmt.Errorf("import archive must be a zip or tar.gz file")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+	return nil
+}
+
+func extractImportZip(r io.Reader, create func(string, fs.FileMode, io.Reader) error) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, entry := range archive.File {
+		content, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = create(entry.Name, entry.Mode(), content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractImportTar(r io.Reader, create func(string, fs.FileMode, io.Reader) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := create(header.Name, header.FileInfo().Mode(), archive); err != nil {
+			return err
+		}
+	}
+}
+
+// importArchivePath is where an archive entry is extracted, refusing names
+// that climb out of dir
+func importArchivePath(dir, name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	cleaned := path.Clean("/" + name)
+	if cleaned == "/" || cleaned != "/"+strings.TrimSuffix(strings.TrimPrefix(name, "./"), "/") {
+		return "", false
+	}
+	return filepath.Join(dir, filepath.FromSlash(cleaned)), true
+}
+
+// ParseMarkdownFolder reads a folder of Markdown posts with YAML, TOML or
+// JSON front matter. Jekyll sites (_posts and _drafts) and Hugo sites
+// (content) are recognised, so posts keep their slugs and dates from file
+// names and redirects can be made from their old URLs; any other folder is
+// read as a flat collection of .md files. Images and files the posts link
+// to inside the folder are imported as media.
+func ParseMarkdownFolder(root string) (*ImportSource, error) {
+	info, err := os.Stat(root)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("markdown import folder %s not found", root)
+	}
+	files, err := findMarkdownFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Markdown files found in the import")
+	}
+
+	source := &ImportSource{Format: ImportFormatMarkdown}
+	authors := make(map[string]bool)
+	media := make(map[string]bool)
+	for _, file := range files {
+		post, refs, err := parseMarkdownPost(root, file)
+		if err != nil {
+			source.Skipped = append(source.Skipped, ImportItemResult{
+				Type: ImportTypePost, Source: file.rel, Action: ImportError, Error: err.Error(),
+			})
+			continue
+		}
+		if post.AuthorKey != "" && !authors[post.AuthorKey] {
+			authors[post.AuthorKey] = true
+			source.Authors = append(source.Authors, &ImportAuthor{Key: post.AuthorKey, Name: post.AuthorKey})
+		}
+		for _, category := range post.Categories {
+			if !containsImportCategory(source.Categories, category) {
+				source.Categories = append(source.Categories, &ImportCategory{Slug: category})
+			}
+		}
+		for _, ref := range refs {
+			if !media[ref] {
+				media[ref] = true
+				source.Media = append(source.Media, &ImportMedia{
+					Key:      ref,
+					Filename: path.Base(ref),
+					Path:     filepath.Join(root, filepath.FromSlash(ref)),
+				})
+			}
+		}
+		source.Posts = append(source.Posts, post)
+	}
+	return source, nil
+}
+
+// findMarkdownFiles lists the posts of a Jekyll, Hugo or plain folder
+func findMarkdownFiles(root string) ([]markdownFile, error) {
+	isDir := func(name string) bool {
+		info, err := os.Stat(filepath.Join(root, name))
+		return err == nil && info.IsDir()
+	}
+	var dirs []string
+	layout := "plain"
+	switch {
+	case isDir("_posts") || isDir("_drafts"):
+		layout, dirs = "jekyll", []string{"_posts", "_drafts"}
+	case isDir("content"):
+		layout, dirs = "hugo", []string{"content"}
+	default:
+		dirs = []string{"."}
+	}
+
+	var files []markdownFile
+	for _, dir := range dirs {
+		base := filepath.Join(root, dir)
+		if !isDir(dir) {
+			continue
+		}
+		err := filepath.WalkDir(base, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			name := entry.Name()
+			if p != base && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") && entry.IsDir() ||
+				name == "node_modules") {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(name))
+			if entry.IsDir() || (ext != ".md" && ext != ".markdown") {
+				return nil
+			}
+			stem := strings.TrimSuffix(name, filepath.Ext(name))
+			rel, _ := filepath.Rel(root, p)
+			file := markdownFile{rel: filepath.ToSlash(rel), slug: stem}
+
+			switch layout {
+			case "jekyll":
+				file.draft = dir == "_drafts"
+				if m := jekyllPostName.FindStringSubmatch(stem); m != nil {
+					file.slug = m[4]
+					file.date, _ = time.Parse("2006-01-02", m[1]+"-"+m[2]+"-"+m[3])
+					file.oldPath = "/" + m[1] + "/" + m[2] + "/" + m[3] + "/" + m[4] + ".html"
+				}
+			case "hugo":
+				if stem == "_index" {
+					return nil
+				}
+				contentRel, _ := filepath.Rel(base, p)
+				section := path.Dir(filepath.ToSlash(contentRel))
+				if stem == "index" {
+					// A page bundle: the folder names the post
+					file.slug = path.Base(section)
+					section = path.Dir(section)
+					if file.slug == "." {
+						return nil
+					}
+				}
+				if section == "." {
+					file.oldPath = "/" + file.slug
+				} else {
+					file.oldPath = "/" + section + "/" + file.slug
+				}
+			default:
+				if strings.EqualFold(stem, "readme") {
+					return nil
+				}
+			}
+			files = append(files, file)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read import folder: %w", err)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].rel < files[j].rel })
+	return files, nil
+}
+
+// parseMarkdownPost reads one post and the media files it refers to
+func parseMarkdownPost(root string, file markdownFile) (*ImportPost, []string, error) {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(file.rel)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", file.rel, err)
+	}
+	meta, body, err := parseFrontMatter(string(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", file.rel, err)
+	}
+
+	post := &ImportPost{
+		Key:     file.rel,
+		Title:   frontMatterString(meta, "title"),
+		Slug:    frontMatterString(meta, "slug"),
+		Content: strings.TrimSpace(body),
+		Format:  ContentFormatMarkdown,
+		Status:  PostStatusPublished,
+		Date:    file.date,
+		Locale:  frontMatterString(meta, "locale", "lang", "language"),
+	}
+	if post.Slug == "" {
+		post.Slug = file.slug
+	}
+	if post.Title == "" {
+		post.Title = markdownHeading(post.Content)
+	}
+	if post.Title == "" {
+		post.Title = strings.ReplaceAll(post.Slug, "-", " ")
+	}
+	if date, ok := frontMatterTime(meta, "date", "publishDate", "published_at"); ok {
+		post.Date = date
+	}
+	if post.Date.IsZero() {
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(file.rel))); err == nil {
+			post.Date = info.ModTime().UTC()
+		}
+	}
+	draft, _ := frontMatterBool(meta, "draft")
+	if published, ok := frontMatterBool(meta, "published"); ok && !published {
+		draft = true
+	}
+	if draft || file.draft {
+		post.Status = PostStatusDraft
+	} else if post.Date.After(time.Now()) {
+		publishAt := post.Date
+		post.PublishAt = &publishAt
+	}
+	if authors := frontMatterStrings(meta, "author", "authors"); len(authors) > 0 {
+		post.AuthorKey = authors[0]
+	}
+	post.Tags = frontMatterStrings(meta, "tags")
+	for _, category := range frontMatterStrings(meta, "categories", "category") {
+		if slug := Slugify(category); slug != "" && !containsString(post.Categories, slug) {
+			post.Categories = append(post.Categories, slug)
+		}
+	}
+
+	if permalink := frontMatterString(meta, "permalink", "url"); permalink != "" {
+		post.OldPaths = append(post.OldPaths, permalink)
+	} else if file.oldPath != "" && !file.draft {
+		post.OldPaths = append(post.OldPaths, file.oldPath)
+	}
+	post.OldPaths = append(post.OldPaths, frontMatterStrings(meta, "aliases", "redirect_from")...)
+
+	post.MediaRefs = make(map[string]string)
+	var refs []string
+	dir := path.Dir(file.rel)
+	for _, pattern := range []*regexp.Regexp{markdownImageRef, markdownLinkRef, markdownHTMLRef} {
+		for _, match := range pattern.FindAllStringSubmatch(post.Content, -1) {
+			ref := match[1]
+			if _, done := post.MediaRefs[ref]; done {
+				continue
+			}
+			if local := resolveMarkdownMedia(root, dir, ref); local != "" {
+				post.MediaRefs[ref] = local
+				refs = append(refs, local)
+			}
+		}
+	}
+	return post, refs, nil
+}
+
+// resolveMarkdownMedia finds the file a post's link points at, relative to
+// the post or, for absolute paths, to the root or its static folder. It
+// returns the file's slash-separated path below root, or "" when the link
+// isn't to a media file in the folder.
+func resolveMarkdownMedia(root, dir, ref string) string {
+	if strings.Contains(ref, "://") || strings.HasPrefix(ref, "//") || strings.HasPrefix(ref, "#") ||
+		strings.HasPrefix(ref, "mailto:") || strings.HasPrefix(ref, "data:") {
+		return ""
+	}
+	clean := ref
+	if i := strings.IndexAny(clean, "?#"); i >= 0 {
+		clean = clean[:i]
+	}
+	if !importMediaSuffix[strings.ToLower(path.Ext(clean))] {
+		return ""
+	}
+	var candidates []string
+	if strings.HasPrefix(clean, "/") {
+		candidates = []string{clean, "/static" + clean}
+	} else {
+		candidates = []string{"/" + dir + "/" + clean}
+	}
+	for _, candidate := range candidates {
+		rel := strings.TrimPrefix(path.Clean(candidate), "/")
+		if rel == "" || rel == "." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel))); err == nil && info.Mode().IsRegular() {
+			return rel
+		}
+	}
+	return ""
+}
+
+// markdownHeading is the text of a post's first level-one heading
+func markdownHeading(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		}
+	}
+	return ""
+}
+
+// parseFrontMatter splits a Markdown file into its front matter and body.
+// YAML (---) and TOML (+++) front matter are read as flat key/value pairs
+// with scalar and list values, which covers what static site generators
+// put there; nested tables are ignored. A leading JSON object is read in
+// full. Files without front matter have empty metadata.
+func parseFrontMatter(text string) (map[string]interface{}, string, error) {
+	text = strings.TrimPrefix(strings.ReplaceAll(text, "\r\n", "\n"), "\ufeff")
+	if strings.HasPrefix(text, "{") {
+		meta := make(map[string]interface{})
+		decoder := json.NewDecoder(strings.NewReader(text))
+		if err := decoder.Decode(&meta); err != nil {
+			return nil, "", fmt.Errorf("invalid JSON front matter: %w", err)
+		}
+		return meta, text[decoder.InputOffset():], nil
+	}
+	for _, delimiter := range []string{"---", "+++"} {
+		if !strings.HasPrefix(text, delimiter+"\n") {
+			continue
+		}
+		rest := text[len(delimiter)+1:]
+		end := strings.Index(rest, "\n"+delimiter)
+		var block, body string
+		switch {
+		case strings.HasPrefix(rest, delimiter):
+			block, body = "", rest[len(delimiter):]
+		case end >= 0:
+			block, body = rest[:end], rest[end+len(delimiter)+1:]
+		default:
+			return nil, "", fmt.Errorf("front matter is not closed with %s", delimiter)
+		}
+		if i := strings.IndexByte(body, '\n'); i >= 0 && strings.TrimSpace(body[:i]) == "" {
+			body = body[i+1:]
+		}
+		if delimiter == "---" {
+			return parseYAMLFrontMatter(block), body, nil
+		}
+		return parseTOMLFrontMatter(block), body, nil
+	}
+	return map[string]interface{}{}, text, nil
+}
+
+func parseYAMLFrontMatter(block string) map[string]interface{} {
+	meta := make(map[string]interface{})
+	var listKey string
+	for _, line := range strings.Split(block, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if listKey != "" {
+				list, _ := meta[listKey].([]interface{})
+				meta[listKey] = append(list, parseFrontMatterScalar(strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))))
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// A nested mapping, which the importer doesn't read
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			listKey = ""
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		value = strings.TrimSpace(value)
+		listKey = ""
+		switch {
+		case value == "":
+			listKey = key
+			meta[key] = []interface{}{}
+		case strings.HasPrefix(value, "["):
+			meta[key] = parseFrontMatterList(value)
+		default:
+			meta[key] = parseFrontMatterScalar(value)
+		}
+	}
+	return meta
+}
+
+func parseTOMLFrontMatter(block string) map[string]interface{} {
+	meta := make(map[string]interface{})
+	inTable := false
+	for _, line := range strings.Split(block, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[") {
+			// Keys after a [table] header belong to the table
+			inTable = true
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, "=")
+		if !ok || inTable {
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, "[") {
+			meta[key] = parseFrontMatterList(value)
+		} else {
+			meta[key] = parseFrontMatterScalar(value)
+		}
+	}
+	return meta
+}
+
+// parseFrontMatterList reads an inline list such as ["a", "b"] or [a, b]
+func parseFrontMatterList(value string) []interface{} {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]"))
+	list := []interface{}{}
+	if value == "" {
+		return list
+	}
+	var item strings.Builder
+	var quote rune
+	flush := func() {
+		if text := strings.TrimSpace(item.String()); text != "" {
+			list = append(list, parseFrontMatterScalar(text))
+		}
+		item.Reset()
+	}
+	for _, c := range value {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			item.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			item.WriteRune(c)
+		case c == ',':
+			flush()
+		default:
+			item.WriteRune(c)
+		}
+	}
+	flush()
+	return list
+}
+
+// parseFrontMatterScalar reads a quoted or bare string, a boolean or a
+// number
+func parseFrontMatterScalar(value string) interface{} {
+	if len(value) >= 2 && (value[0] == '"' && value[len(value)-1] == '"') {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+		return value[1 : len(value)-1]
+	}
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	switch strings.ToLower(value) {
+	case "true", "yes":
+		return true
+	case "false", "no":
+		return false
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n
+	}
+	return value
+}
+
+func frontMatterString(meta map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := meta[key].(type) {
+		case string:
+			if value = strings.TrimSpace(value); value != "" {
+				return value
+			}
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// frontMatterStrings reads a list, or a single value as a one-item list;
+// comma- or space-separated strings, as Jekyll allows for tags, are split
+func frontMatterStrings(meta map[string]interface{}, keys ...string) []string {
+	var values []string
+	add := func(value string) {
+		if value = strings.TrimSpace(value); value != "" && !containsString(values, value) {
+			values = append(values, value)
+		}
+	}
+	for _, key := range keys {
+		switch value := meta[key].(type) {
+		case []interface{}:
+			for _, item := range value {
+				switch item := item.(type) {
+				case string:
+					add(item)
+				case float64:
+					add(strconv.FormatFloat(item, 'f', -1, 64))
+				case map[string]interface{}:
+					if name, ok := item["name"].(string); ok {
+						add(name)
+					}
+				}
+			}
+		case string:
+			if key == "tags" || key == "categories" {
+				separator := ","
+				if !strings.Contains(value, ",") {
+					separator = " "
+				}
+				for _, item := range strings.Split(value, separator) {
+					add(item)
+				}
+			} else {
+				add(value)
+			}
+		case map[string]interface{}:
+			if name, ok := value["name"].(string); ok {
+				add(name)
+			}
+		}
+	}
+	return values
+}
+
+func frontMatterBool(meta map[string]interface{}, key string) (bool, bool) {
+	value, ok := meta[key].(bool)
+	return value, ok
+}
+
+// frontMatterTime reads a date in the formats static site generators
+// write, in UTC when it carries no zone
+func frontMatterTime(meta map[string]interface{}, keys ...string) (time.Time, bool) {
+	layouts := []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05 -0700",
+		"2006-01-02 15:04:05 -07:00",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04 -0700",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+	for _, key := range keys {
+		value := frontMatterString(meta, key)
+		if value == "" {
+			continue
+		}
+		for _, layout := range layouts {
+			if date, err := time.Parse(layout, value); err == nil {
+				return date.UTC(), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// wxrDocument is the part of a WordPress eXtended RSS export the importer
+// reads. Elements are matched by local name, so exports of any WXR version
+// parse the same.
+type wxrDocument struct {
+	Channel struct {
+		Authors    []wxrAuthor   `xml:"author"`
+		Categories []wxrCategory `xml:"category"`
+		Items      []wxrItem     `xml:"item"`
+	} `xml:"channel"`
+}
+
+type wxrAuthor struct {
+	Login       string `xml:"author_login"`
+	Email       string `xml:"author_email"`
+	DisplayName string `xml:"author_display_name"`
+}
+
+type wxrCategory struct {
+	Nicename    string `xml:"category_nicename"`
+	Parent      string `xml:"category_parent"`
+	Name        string `xml:"cat_name"`
+	Description string `xml:"category_description"`
+}
+
+type wxrItem struct {
+	Title         string        `xml:"title"`
+	Link          string        `xml:"link"`
+	GUID          string        `xml:"guid"`
+	Creator       string        `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Content       string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostID        string        `xml:"post_id"`
+	PostDate      string        `xml:"post_date"`
+	PostDateGMT   string        `xml:"post_date_gmt"`
+	PostName      string        `xml:"post_name"`
+	Status        string        `xml:"status"`
+	PostType      string        `xml:"post_type"`
+	AttachmentURL string        `xml:"attachment_url"`
+	Terms         []wxrItemTerm `xml:"category"`
+	Meta          []wxrMeta     `xml:"postmeta"`
+}
+
+type wxrItemTerm struct {
+	Domain   string `xml:"domain,attr"`
+	Nicename string `xml:"nicename,attr"`
+	Name     string `xml:",chardata"`
+}
+
+type wxrMeta struct {
+	Key   string `xml:"meta_key"`
+	Value string `xml:"meta_value"`
+}
+
+func (item *wxrItem) meta(key string) string {
+	for _, meta := range item.Meta {
+		if meta.Key == key {
+			return strings.TrimSpace(meta.Value)
+		}
+	}
+	return ""
+}
+
+var (
+	wxrBlockComment  = regexp.MustCompile(`<!-- /?wp:[^>]*-->\n?`)
+	wxrCaption       = regexp.MustCompile(`(?s)\[caption[^\]]*\](.*?)\[/caption\]`)
+	wxrCaptionImage  = regexp.MustCompile(`(?s)^\s*((?:<a [^>]*>)?\s*<img [^>]*>\s*(?:</a>)?)(.*)$`)
+	wxrSizedVariant  = regexp.MustCompile(`-\d+x\d+(\.[A-Za-z0-9]+)$`)
+	wxrAttributeURL  = regexp.MustCompile(`(?i)(?:src|href)\s*=\s*["']([^"']+)["']`)
+	wxrSrcsetURL     = regexp.MustCompile(`(?i)srcset\s*=\s*["']([^"']+)["']`)
+	wxrBlankLine     = regexp.MustCompile(`\n\s*\n`)
+	wxrParagraphSkip = regexp.MustCompile(`(?i)^\s*<(?:p|div|h[1-6]|ul|ol|li|table|blockquote|pre|figure|hr|img|iframe)[\s>/]`)
+)
+
+// ParseWXR reads a WordPress export. Posts are imported and attachments
+// become media; pages, menus and other item types are listed as skipped.
+// Attachments are read from mediaDir when the export's wp-content/uploads
+// folder was copied there, and are otherwise left to be downloaded.
+func ParseWXR(r io.Reader, mediaDir string) (*ImportSource, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	var doc wxrDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid WXR file: %w", err)
+	}
+	channel := doc.Channel
+	if len(channel.Items) == 0 && len(channel.Authors) == 0 {
+		return nil, fmt.Errorf("invalid WXR file: no channel items found")
+	}
+
+	source := &ImportSource{Format: ImportFormatWXR}
+	for _, author := range channel.Authors {
+		login := strings.TrimSpace(author.Login)
+		if login == "" {
+			continue
+		}
+		source.Authors = append(source.Authors, &ImportAuthor{
+			Key:   login,
+			Name:  html.UnescapeString(strings.TrimSpace(author.DisplayName)),
+			Email: strings.TrimSpace(author.Email),
+		})
+	}
+	for _, category := range channel.Categories {
+		slug := decodeWXRSlug(category.Nicename)
+		if slug == "" || slug == "uncategorized" {
+			continue
+		}
+		source.Categories = append(source.Categories, &ImportCategory{
+			Slug:        slug,
+			Name:        html.UnescapeString(strings.TrimSpace(category.Name)),
+			Parent:      decodeWXRSlug(category.Parent),
+			Description: html.UnescapeString(strings.TrimSpace(category.Description)),
+		})
+	}
+
+	// Attachments first, so posts can refer to them by URL
+	byURL := make(map[string]string)
+	for i := range channel.Items {
+		item := &channel.Items[i]
+		if item.PostType != "attachment" {
+			continue
+		}
+		fileURL := strings.TrimSpace(item.AttachmentURL)
+		if fileURL == "" {
+			fileURL = strings.TrimSpace(item.GUID)
+		}
+		if fileURL == "" {
+			continue
+		}
+		media := &ImportMedia{
+			Key:      "attachment:" + item.PostID,
+			Filename: path.Base(urlPath(fileURL)),
+			Title:    strings.TrimSpace(item.Title),
+			AltText:  item.meta("_wp_attachment_image_alt"),
+			URL:      fileURL,
+		}
+		if mediaDir != "" {
+			if attached := item.meta("_wp_attached_file"); attached != "" {
+				local := filepath.Join(mediaDir, filepath.FromSlash(path.Clean("/"+attached)))
+				if info, err := os.Stat(local); err == nil && info.Mode().IsRegular() {
+					media.Path = local
+				}
+			}
+		}
+		source.Media = append(source.Media, media)
+		byURL[schemelessURL(fileURL)] = media.Key
+	}
+
+	for i := range channel.Items {
+		item := &channel.Items[i]
+		key := "post:" + item.PostID
+		title := strings.TrimSpace(item.Title)
+		switch item.PostType {
+		case "attachment":
+			continue
+		case "post":
+		default:
+			source.Skipped = append(source.Skipped, ImportItemResult{
+				Type: ImportTypePost, Source: key, Title: title, Action: ImportSkip,
+				Notes: []string{fmt.Sprintf("%s items are not imported", item.PostType)},
+			})
+			continue
+		}
+
+		post := &ImportPost{
+			Key:       key,
+			Title:     title,
+			Slug:      decodeWXRSlug(item.PostName),
+			Format:    ContentFormatHTML,
+			AuthorKey: strings.TrimSpace(item.Creator),
+		}
+		post.Date = parseWXRDate(item.PostDateGMT, time.UTC)
+		if post.Date.IsZero() {
+			post.Date = parseWXRDate(item.PostDate, time.Local)
+		}
+		switch item.Status {
+		case "publish":
+			post.Status = PostStatusPublished
+		case "future":
+			post.Status = PostStatusPublished
+			if !post.Date.IsZero() {
+				publishAt := post.Date
+				post.PublishAt = &publishAt
+			}
+		case "draft", "pending", "private":
+			post.Status = PostStatusDraft
+		default:
+			source.Skipped = append(source.Skipped, ImportItemResult{
+				Type: ImportTypePost, Source: key, Title: title, Action: ImportSkip,
+				Notes: []string{fmt.Sprintf("posts with status %q are not imported", item.Status)},
+			})
+			continue
+		}
+
+		for _, term := range item.Terms {
+			slug := decodeWXRSlug(term.Nicename)
+			switch term.Domain {
+			case "category":
+				if slug != "" && slug != "uncategorized" && !containsString(post.Categories, slug) {
+					post.Categories = append(post.Categories, slug)
+				}
+			case "post_tag":
+				if name := html.UnescapeString(strings.TrimSpace(term.Name)); name != "" && !containsString(post.Tags, name) {
+					post.Tags = append(post.Tags, name)
+				}
+			}
+		}
+		for _, category := range post.Categories {
+			if !containsImportCategory(source.Categories, category) {
+				source.Categories = append(source.Categories, &ImportCategory{Slug: category})
+			}
+		}
+
+		post.Content = cleanWXRContent(item.Content)
+		post.MediaRefs = wxrMediaRefs(post.Content, byURL)
+		if link := strings.TrimSpace(item.Link); link != "" && item.Status == "publish" {
+			if oldPath := normalizeImportPath(link); oldPath != "" {
+				post.OldPaths = append(post.OldPaths, oldPath)
+			}
+		}
+		source.Posts = append(source.Posts, post)
+	}
+	return source, nil
+}
+
+// decodeWXRSlug undoes WordPress's percent-encoding of non-ASCII slugs
+func decodeWXRSlug(slug string) string {
+	slug = strings.TrimSpace(slug)
+	if decoded, err := url.PathUnescape(slug); err == nil {
+		slug = decoded
+	}
+	return slug
+}
+
+func parseWXRDate(value string, location *time.Location) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return time.Time{}
+	}
+	date, err := time.ParseInLocation("2006-01-02 15:04:05", value, location)
+	if err != nil {
+		return time.Time{}
+	}
+	return date.UTC()
+}
+
+// urlPath is the path of a URL, or the URL itself when it doesn't parse
+func urlPath(raw string) string {
+	if parsed, err := url.Parse(raw); err == nil && parsed.Path != "" {
+		return parsed.Path
+	}
+	return raw
+}
+
+// schemelessURL drops the scheme, so http and https links to a file match
+func schemelessURL(raw string) string {
+	if i := strings.Index(raw, "://"); i >= 0 {
+		return raw[i+3:]
+	}
+	return strings.TrimPrefix(raw, "//")
+}
+
+// cleanWXRContent turns WordPress post content into plain HTML: block
+// editor comments are dropped, [caption] shortcodes become figures, and
+// classic-editor text, which relies on WordPress adding paragraphs at
+// display time, is split into paragraphs
+func cleanWXRContent(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	blockEditor := wxrBlockComment.MatchString(content)
+	content = wxrBlockComment.ReplaceAllString(content, "")
+	content = wxrCaption.ReplaceAllStringFunc(content, func(shortcode string) string {
+		inner := wxrCaption.FindStringSubmatch(shortcode)[1]
+		parts := wxrCaptionImage.FindStringSubmatch(inner)
+		if parts == nil {
+			return inner
+		}
+		caption := strings.TrimSpace(parts[2])
+		if caption == "" {
+			return "<figure>" + parts[1] + "</figure>"
+		}
+		return "<figure>" + parts[1] + "<figcaption>" + caption + "</figcaption></figure>"
+	})
+	content = strings.TrimSpace(content)
+	if content == "" || blockEditor {
+		return content
+	}
+	return autoParagraph(content)
+}
+
+// autoParagraph wraps blank-line separated text in paragraphs and turns
+// the remaining line breaks into <br>, as WordPress's wpautop does
+func autoParagraph(content string) string {
+	var b strings.Builder
+	for _, block := range wxrBlankLine.Split(content, -1) {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+		if wxrParagraphSkip.MatchString(block) {
+			b.WriteString(block)
+		} else {
+			b.WriteString("<p>" + strings.ReplaceAll(block, "\n", "<br>\n") + "</p>")
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// wxrMediaRefs finds the attachment URLs used in post content, including
+// the resized copies WordPress links as name-300x200.jpg
+func wxrMediaRefs(content string, byURL map[string]string) map[string]string {
+	refs := make(map[string]string)
+	var candidates []string
+	for _, match := range wxrAttributeURL.FindAllStringSubmatch(content, -1) {
+		candidates = append(candidates, match[1])
+	}
+	for _, match := range wxrSrcsetURL.FindAllStringSubmatch(content, -1) {
+		for _, entry := range strings.Split(match[1], ",") {
+			if fields := strings.Fields(entry); len(fields) > 0 {
+				candidates = append(candidates, fields[0])
+			}
+		}
+	}
+	for _, candidate := range candidates {
+		if _, done := refs[candidate]; done || !strings.Contains(candidate, "://") {
+			continue
+		}
+		key := schemelessURL(candidate)
+		if media, ok := byURL[key]; ok {
+			refs[candidate] = media
+		} else if media, ok := byURL[wxrSizedVariant.ReplaceAllString(key, "$1")]; ok {
+			refs[candidate] = media
+		}
+	}
+	return refs
+}
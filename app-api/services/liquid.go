@@ -0,0 +1,919 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LiquidError is a Liquid syntax or render error, located by template name
+// and line
+type LiquidError struct {
+	Template string
+	Line     int
+	Message  string
+}
+
+func (e *LiquidError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Template, e.Line, e.Message)
+}
+
+// LiquidFilter transforms a value in an output or assign; args are the
+// values listed after the filter's colon
+type LiquidFilter func(input interface{}, args ...interface{}) (interface{}, error)
+
+// LiquidLimits bound the work of a single render, including the partials
+// it pulls in, so a theme cannot hang a request or exhaust memory
+type LiquidLimits struct {
+	// MaxSteps counts tags, outputs and loop iterations
+	MaxSteps int
+	// MaxOutput caps the bytes written, and the length of any string a
+	// filter produces
+	MaxOutput int
+	// MaxDepth caps include and render nesting
+	MaxDepth int
+	Timeout  time.Duration
+}
+
+// DefaultLiquidLimits returns limits generous enough for any page of a site
+func DefaultLiquidLimits() LiquidLimits {
+	return LiquidLimits{
+		MaxSteps:  200000,
+		MaxOutput: 4 << 20,
+		MaxDepth:  10,
+		Timeout:   2 * time.Second,
+	}
+}
+
+// LiquidEnv parses and renders Shopify-style Liquid templates. Filters are
+// checked when a template is parsed; Partials resolves the names used by
+// the include and render tags.
+type LiquidEnv struct {
+	Filters map[string]LiquidFilter
+	Limits  LiquidLimits
+	// MoneyFormat is used by the money filter, with {{amount}},
+	// {{amount_no_decimals}}, {{amount_with_comma_separator}} or
+	// {{amount_no_decimals_with_comma_separator}} standing for the amount
+	MoneyFormat string
+	Partials    func(name string) (*LiquidTemplate, error)
+}
+
+// NewLiquidEnv creates an environment with the standard filters and
+// default limits
+func NewLiquidEnv() *LiquidEnv {
+	env := &LiquidEnv{
+		Limits:      DefaultLiquidLimits(),
+		MoneyFormat: "${{amount}}",
+	}
+	env.Filters = liquidFilters(env)
+	return env
+}
+
+// LiquidTemplate is a parsed Liquid template
+type LiquidTemplate struct {
+	env       *LiquidEnv
+	name      string
+	nodes     []liquidNode
+	layout    string
+	hasLayout bool
+}
+
+// Name returns the name the template was parsed with
+func (t *LiquidTemplate) Name() string {
+	return t.name
+}
+
+// Layout returns the layout chosen by a {% layout 'name' %} tag; ok is
+// false without one, and {% layout none %} gives an empty name
+func (t *LiquidTemplate) Layout() (name string, ok bool) {
+	return t.layout, t.hasLayout
+}
+
+// Parse parses Liquid source; name identifies the template in errors
+func (env *LiquidEnv) Parse(name, source string) (*LiquidTemplate, error) {
+	tokens, err := lexLiquid(name, source)
+	if err != nil {
+		return nil, err
+	}
+	p := &liquidParser{env: env, tmpl: &LiquidTemplate{env: env, name: name}, tokens: tokens}
+	nodes, end, err := p.parseBlock("", 0)
+	if err != nil {
+		return nil, err
+	}
+	if end != nil {
+		return nil, p.errorf(end.line, "unexpected %s", end.name)
+	}
+	p.tmpl.nodes = nodes
+	return p.tmpl, nil
+}
+
+// Lexing
+
+type liquidTokenKind int
+
+const (
+	liquidTextToken liquidTokenKind = iota
+	liquidOutputToken
+	liquidTagToken
+)
+
+type liquidToken struct {
+	kind liquidTokenKind
+	text string
+	line int
+}
+
+var (
+	liquidRawEnd     = regexp.MustCompile(`\{%-?\s*endraw\s*-?%\}`)
+	liquidCommentEnd = regexp.MustCompile(`\{%-?\s*endcomment\s*-?%\}`)
+)
+
+// lexLiquid splits source into text, {{ output }} and {% tag %} tokens,
+// applying {{- -}} whitespace control and skipping raw and comment bodies
+func lexLiquid(name, source string) ([]liquidToken, error) {
+	var tokens []liquidToken
+	line, pos := 1, 0
+	trimNext := false
+
+	addText := func(text string) {
+		if trimNext {
+			text = strings.TrimLeft(text, " \t\r\n")
+			trimNext = false
+		}
+		if text != "" {
+			tokens = append(tokens, liquidToken{kind: liquidTextToken, text: text, line: line})
+		}
+	}
+	trimPrevious := func() {
+		if n := len(tokens); n > 0 && tokens[n-1].kind == liquidTextToken {
+			tokens[n-1].text = strings.TrimRight(tokens[n-1].text, " \t\r\n")
+			if tokens[n-1].text == "" {
+				tokens = tokens[:n-1]
+			}
+		}
+	}
+
+	for pos < len(source) {
+		start := nextLiquidDelimiter(source, pos)
+		if start < 0 {
+			addText(source[pos:])
+			break
+		}
+		addText(source[pos:start])
+		line += strings.Count(source[pos:start], "\n")
+
+		kind, closer := liquidOutputToken, "}}"
+		if source[start+1] == '%' {
+			kind, closer = liquidTagToken, "%}"
+		}
+		end := strings.Index(source[start+2:], closer)
+		if end < 0 {
+			return nil, &LiquidError{Template: name, Line: line, Message: fmt.Sprintf("%s is never closed with %s", source[start:start+2], closer)}
+		}
+		end += start + 2
+
+		tokenLine := line
+		markup := source[start+2 : end]
+		if strings.HasPrefix(markup, "-") {
+			markup = markup[1:]
+			trimPrevious()
+		}
+		if strings.HasSuffix(markup, "-") {
+			markup = markup[:len(markup)-1]
+			trimNext = true
+		}
+		markup = strings.TrimSpace(markup)
+		line += strings.Count(source[start:end+2], "\n")
+		pos = end + 2
+
+		if kind == liquidTagToken {
+			tagName, _ := splitLiquidTag(markup)
+			switch tagName {
+			case "raw", "comment":
+				closing := liquidRawEnd
+				if tagName == "comment" {
+					closing = liquidCommentEnd
+				}
+				loc := closing.FindStringIndex(source[pos:])
+				if loc == nil {
+					return nil, &LiquidError{Template: name, Line: tokenLine, Message: fmt.Sprintf("%s tag is never closed", tagName)}
+				}
+				body := source[pos : pos+loc[0]]
+				closer := source[pos+loc[0] : pos+loc[1]]
+				if strings.HasPrefix(closer, "{%-") {
+					body = strings.TrimRight(body, " \t\r\n")
+				}
+				if tagName == "raw" {
+					addText(body)
+				}
+				trimNext = strings.HasSuffix(closer, "-%}")
+				line += strings.Count(source[pos:pos+loc[1]], "\n")
+				pos += loc[1]
+				continue
+			case "#":
+				continue
+			}
+			if strings.HasPrefix(tagName, "#") {
+				continue
+			}
+		}
+		tokens = append(tokens, liquidToken{kind: kind, text: markup, line: tokenLine})
+	}
+	return tokens, nil
+}
+
+func nextLiquidDelimiter(source string, from int) int {
+	for i := from; i < len(source)-1; i++ {
+		if source[i] == '{' && (source[i+1] == '{' || source[i+1] == '%') {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitLiquidTag splits tag markup into its name and the rest
+func splitLiquidTag(markup string) (string, string) {
+	if i := strings.IndexAny(markup, " \t\r\n"); i >= 0 {
+		return markup[:i], strings.TrimSpace(markup[i+1:])
+	}
+	return markup, ""
+}
+
+// Parsing
+
+type liquidParser struct {
+	env    *LiquidEnv
+	tmpl   *LiquidTemplate
+	tokens []liquidToken
+	pos    int
+}
+
+// liquidBlockEnd is the tag that closed a block
+type liquidBlockEnd struct {
+	name   string
+	markup string
+	line   int
+}
+
+func (p *liquidParser) errorf(line int, format string, args ...interface{}) error {
+	return &LiquidError{Template: p.tmpl.name, Line: line, Message: fmt.Sprintf(format, args...)}
+}
+
+// parseBlock parses nodes until one of the ends tags, which it returns.
+// opener names the tag being closed for the error when the source runs
+// out; at the top level opener is empty and end is nil at the end.
+func (p *liquidParser) parseBlock(opener string, openLine int, ends ...string) ([]liquidNode, *liquidBlockEnd, error) {
+	var nodes []liquidNode
+	for p.pos < len(p.tokens) {
+		token := p.tokens[p.pos]
+		p.pos++
+
+		switch token.kind {
+		case liquidTextToken:
+			nodes = append(nodes, &liquidTextNode{text: token.text})
+			continue
+		case liquidOutputToken:
+			if token.text == "" {
+				continue
+			}
+			expr, err := p.parseOutput(token.text, token.line)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, &liquidOutputNode{expr: expr, line: token.line})
+			continue
+		}
+
+		name, markup := splitLiquidTag(token.text)
+		if containsString(ends, name) {
+			return nodes, &liquidBlockEnd{name: name, markup: markup, line: token.line}, nil
+		}
+
+		var node liquidNode
+		var err error
+		switch name {
+		case "if":
+			node, err = p.parseIf(markup, token.line, false)
+		case "unless":
+			node, err = p.parseIf(markup, token.line, true)
+		case "case":
+			node, err = p.parseCase(markup, token.line)
+		case "for":
+			node, err = p.parseFor(markup, token.line)
+		case "assign":
+			node, err = p.parseAssign(markup, token.line)
+		case "capture":
+			node, err = p.parseCapture(markup, token.line)
+		case "include", "render":
+			node, err = p.parseInclude(name, markup, token.line)
+		case "echo":
+			var expr *liquidFiltered
+			expr, err = p.parseOutput(markup, token.line)
+			node = &liquidOutputNode{expr: expr, line: token.line}
+		case "break", "continue":
+			node = &liquidLoopControlNode{brk: name == "break"}
+		case "layout":
+			err = p.parseLayout(markup, token.line)
+		case "":
+			err = p.errorf(token.line, "empty tag")
+		default:
+			if strings.HasPrefix(name, "end") || name == "else" || name == "elsif" || name == "when" {
+				if opener != "" {
+					err = p.errorf(token.line, "unexpected %s in %s block opened on line %d", name, opener, openLine)
+				} else {
+					err = p.errorf(token.line, "unexpected %s", name)
+				}
+			} else {
+				err = p.errorf(token.line, "unknown tag %q", name)
+			}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if opener != "" {
+		return nil, nil, p.errorf(openLine, "%s tag is never closed, expected %s", opener, strings.Join(ends, " or "))
+	}
+	return nodes, nil, nil
+}
+
+func (p *liquidParser) exprParser(markup string, line int) (*liquidExprParser, error) {
+	tokens, err := tokenizeLiquidExpr(markup)
+	if err != nil {
+		return nil, p.errorf(line, "%v", err)
+	}
+	return &liquidExprParser{p: p, tokens: tokens, line: line}, nil
+}
+
+func (p *liquidParser) parseOutput(markup string, line int) (*liquidFiltered, error) {
+	ep, err := p.exprParser(markup, line)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := ep.parseFiltered()
+	if err != nil {
+		return nil, err
+	}
+	return expr, ep.expectEnd()
+}
+
+func (p *liquidParser) parseIf(markup string, line int, negate bool) (liquidNode, error) {
+	opener, closer := "if", "endif"
+	if negate {
+		opener, closer = "unless", "endunless"
+	}
+	node := &liquidIfNode{}
+
+	condMarkup, condLine, first := markup, line, true
+	for {
+		cond, err := p.parseCondition(condMarkup, condLine)
+		if err != nil {
+			return nil, err
+		}
+		body, end, err := p.parseBlock(opener, line, "elsif", "else", closer)
+		if err != nil {
+			return nil, err
+		}
+		node.branches = append(node.branches, liquidBranch{cond: cond, negate: negate && first, body: body, line: condLine})
+		first = false
+
+		switch end.name {
+		case "elsif":
+			condMarkup, condLine = end.markup, end.line
+			continue
+		case "else":
+			body, _, err := p.parseBlock(opener, line, closer)
+			if err != nil {
+				return nil, err
+			}
+			node.branches = append(node.branches, liquidBranch{body: body})
+		}
+		return node, nil
+	}
+}
+
+func (p *liquidParser) parseCondition(markup string, line int) (liquidCondition, error) {
+	if markup == "" {
+		return nil, p.errorf(line, "missing condition")
+	}
+	ep, err := p.exprParser(markup, line)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := ep.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	return cond, ep.expectEnd()
+}
+
+func (p *liquidParser) parseCase(markup string, line int) (liquidNode, error) {
+	ep, err := p.exprParser(markup, line)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := ep.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if err := ep.expectEnd(); err != nil {
+		return nil, err
+	}
+
+	node := &liquidCaseNode{subject: subject}
+	// Anything between case and the first when is ignored
+	_, end, err := p.parseBlock("case", line, "when", "else", "endcase")
+	if err != nil {
+		return nil, err
+	}
+	for end.name == "when" {
+		ep, err := p.exprParser(end.markup, end.line)
+		if err != nil {
+			return nil, err
+		}
+		var values []liquidExpr
+		for {
+			value, err := ep.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if !ep.accept(",") && !ep.acceptWord("or") {
+				break
+			}
+		}
+		if err := ep.expectEnd(); err != nil {
+			return nil, err
+		}
+
+		var body []liquidNode
+		body, end, err = p.parseBlock("case", line, "when", "else", "endcase")
+		if err != nil {
+			return nil, err
+		}
+		node.whens = append(node.whens, liquidWhen{values: values, body: body})
+	}
+	if end.name == "else" {
+		node.elseBody, _, err = p.parseBlock("case", line, "endcase")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (p *liquidParser) parseFor(markup string, line int) (liquidNode, error) {
+	ep, err := p.exprParser(markup, line)
+	if err != nil {
+		return nil, err
+	}
+	node := &liquidForNode{line: line}
+	if node.variable, err = ep.expectIdent("loop variable"); err != nil {
+		return nil, err
+	}
+	if !ep.acceptWord("in") {
+		return nil, p.errorf(line, "expected \"in\" after for %s", node.variable)
+	}
+	if node.collection, err = ep.parsePrimary(); err != nil {
+		return nil, err
+	}
+	for !ep.atEnd() {
+		ep.accept(",")
+		switch {
+		case ep.acceptWord("reversed"):
+			node.reversed = true
+		case ep.acceptWord("limit"):
+			if node.limit, err = ep.parseOption("limit"); err != nil {
+				return nil, err
+			}
+		case ep.acceptWord("offset"):
+			if node.offset, err = ep.parseOption("offset"); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, ep.unexpected()
+		}
+	}
+
+	body, end, err := p.parseBlock("for", line, "else", "endfor")
+	if err != nil {
+		return nil, err
+	}
+	node.body = body
+	if end.name == "else" {
+		if node.elseBody, _, err = p.parseBlock("for", line, "endfor"); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (p *liquidParser) parseAssign(markup string, line int) (liquidNode, error) {
+	ep, err := p.exprParser(markup, line)
+	if err != nil {
+		return nil, err
+	}
+	name, err := ep.expectIdent("variable name")
+	if err != nil {
+		return nil, err
+	}
+	if !ep.accept("=") {
+		return nil, p.errorf(line, "expected = after assign %s", name)
+	}
+	expr, err := ep.parseFiltered()
+	if err != nil {
+		return nil, err
+	}
+	if err := ep.expectEnd(); err != nil {
+		return nil, err
+	}
+	return &liquidAssignNode{name: name, expr: expr}, nil
+}
+
+func (p *liquidParser) parseCapture(markup string, line int) (liquidNode, error) {
+	name := strings.Trim(markup, `"'`)
+	if !liquidIdentPattern.MatchString(name) {
+		return nil, p.errorf(line, "capture needs a variable name")
+	}
+	body, _, err := p.parseBlock("capture", line, "endcapture")
+	if err != nil {
+		return nil, err
+	}
+	return &liquidCaptureNode{name: name, body: body}, nil
+}
+
+// parseInclude parses include and render:
+//
+//	{% render 'name' %}
+//	{% render 'name', key: value, other: value %}
+//	{% render 'name' with value as alias %}
+//	{% render 'name' for collection as alias %}
+func (p *liquidParser) parseInclude(tag, markup string, line int) (liquidNode, error) {
+	ep, err := p.exprParser(markup, line)
+	if err != nil {
+		return nil, err
+	}
+	node := &liquidIncludeNode{isolated: tag == "render", line: line}
+	if node.name, err = ep.parsePrimary(); err != nil {
+		return nil, err
+	}
+	if _, ok := node.name.(*liquidLiteral); !ok && node.isolated {
+		return nil, p.errorf(line, "render needs a quoted partial name")
+	}
+
+	switch {
+	case ep.acceptWord("with"):
+		if node.with, err = ep.parsePrimary(); err != nil {
+			return nil, err
+		}
+	case ep.acceptWord("for"):
+		if node.with, err = ep.parsePrimary(); err != nil {
+			return nil, err
+		}
+		node.loop = true
+	}
+	if node.with != nil && ep.acceptWord("as") {
+		if node.alias, err = ep.expectIdent("alias"); err != nil {
+			return nil, err
+		}
+	}
+
+	for ep.accept(",") || (!ep.atEnd() && ep.peek().kind == liquidIdentToken) {
+		key, err := ep.expectIdent("argument name")
+		if err != nil {
+			return nil, err
+		}
+		if !ep.accept(":") {
+			return nil, p.errorf(line, "expected : after %s", key)
+		}
+		value, err := ep.parseFiltered()
+		if err != nil {
+			return nil, err
+		}
+		node.args = append(node.args, liquidArgument{name: key, value: value})
+	}
+	return node, ep.expectEnd()
+}
+
+func (p *liquidParser) parseLayout(markup string, line int) error {
+	if p.tmpl.hasLayout {
+		return p.errorf(line, "layout is already set")
+	}
+	p.tmpl.hasLayout = true
+	if markup == "none" {
+		return nil
+	}
+	ep, err := p.exprParser(markup, line)
+	if err != nil {
+		return err
+	}
+	expr, err := ep.parsePrimary()
+	if err != nil {
+		return err
+	}
+	if literal, ok := expr.(*liquidLiteral); ok {
+		p.tmpl.layout, _ = literal.value.(string)
+	}
+	if p.tmpl.layout == "" {
+		return p.errorf(line, "layout needs a quoted name or none")
+	}
+	return ep.expectEnd()
+}
+
+// Expressions
+
+type liquidExprTokenKind int
+
+const (
+	liquidIdentToken liquidExprTokenKind = iota
+	liquidStringToken
+	liquidNumberToken
+	liquidPunctToken
+)
+
+type liquidExprToken struct {
+	kind liquidExprTokenKind
+	text string
+}
+
+var liquidIdentPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*\??$`)
+
+// tokenizeLiquidExpr splits the markup of an output or tag into
+// identifiers, strings, numbers and punctuation
+func tokenizeLiquidExpr(markup string) ([]liquidExprToken, error) {
+	var tokens []liquidExprToken
+	for i := 0; i < len(markup); {
+		c := markup[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(markup[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string %s", markup[i:])
+			}
+			tokens = append(tokens, liquidExprToken{kind: liquidStringToken, text: markup[i+1 : i+1+end]})
+			i += end + 2
+		case isDigit(c) || (c == '-' && i+1 < len(markup) && isDigit(markup[i+1])):
+			j := i + 1
+			for j < len(markup) && isDigit(markup[j]) {
+				j++
+			}
+			if j+1 < len(markup) && markup[j] == '.' && isDigit(markup[j+1]) {
+				j++
+				for j < len(markup) && isDigit(markup[j]) {
+					j++
+				}
+			}
+			tokens = append(tokens, liquidExprToken{kind: liquidNumberToken, text: markup[i:j]})
+			i = j
+		case c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z'):
+			j := i + 1
+			for j < len(markup) && (markup[j] == '_' || markup[j] == '-' || isDigit(markup[j]) || (markup[j]|0x20 >= 'a' && markup[j]|0x20 <= 'z')) {
+				j++
+			}
+			if j < len(markup) && markup[j] == '?' {
+				j++
+			}
+			tokens = append(tokens, liquidExprToken{kind: liquidIdentToken, text: markup[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"..", "==", "!=", "<>", "<=", ">=", ".", "[", "]", "(", ")", "|", ":", ",", "=", "<", ">"} {
+				if strings.HasPrefix(markup[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, liquidExprToken{kind: liquidPunctToken, text: op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type liquidExprParser struct {
+	p      *liquidParser
+	tokens []liquidExprToken
+	pos    int
+	line   int
+}
+
+func (ep *liquidExprParser) atEnd() bool {
+	return ep.pos >= len(ep.tokens)
+}
+
+func (ep *liquidExprParser) peek() liquidExprToken {
+	return ep.tokens[ep.pos]
+}
+
+func (ep *liquidExprParser) accept(punct string) bool {
+	if !ep.atEnd() && ep.peek().kind == liquidPunctToken && ep.peek().text == punct {
+		ep.pos++
+		return true
+	}
+	return false
+}
+
+func (ep *liquidExprParser) acceptWord(word string) bool {
+	if !ep.atEnd() && ep.peek().kind == liquidIdentToken && ep.peek().text == word {
+		ep.pos++
+		return true
+	}
+	return false
+}
+
+func (ep *liquidExprParser) expectIdent(what string) (string, error) {
+	if ep.atEnd() || ep.peek().kind != liquidIdentToken {
+		if ep.atEnd() {
+			return "", ep.p.errorf(ep.line, "missing %s", what)
+		}
+		return "", ep.p.errorf(ep.line, "expected %s, found %q", what, ep.peek().text)
+	}
+	ep.pos++
+	return ep.tokens[ep.pos-1].text, nil
+}
+
+func (ep *liquidExprParser) expectEnd() error {
+	if !ep.atEnd() {
+		return ep.unexpected()
+	}
+	return nil
+}
+
+func (ep *liquidExprParser) unexpected() error {
+	if ep.atEnd() {
+		return ep.p.errorf(ep.line, "unexpected end of expression")
+	}
+	return ep.p.errorf(ep.line, "unexpected %q", ep.peek().text)
+}
+
+// parseOption parses the ": value" of a for loop's limit or offset
+func (ep *liquidExprParser) parseOption(name string) (liquidExpr, error) {
+	if !ep.accept(":") {
+		return nil, ep.p.errorf(ep.line, "expected : after %s", name)
+	}
+	return ep.parsePrimary()
+}
+
+// parsePrimary parses a literal, a variable path or a (start..end) range
+func (ep *liquidExprParser) parsePrimary() (liquidExpr, error) {
+	if ep.atEnd() {
+		return nil, ep.unexpected()
+	}
+	token := ep.peek()
+	ep.pos++
+
+	switch token.kind {
+	case liquidStringToken:
+		return &liquidLiteral{value: token.text}, nil
+	case liquidNumberToken:
+		if strings.Contains(token.text, ".") {
+			f, err := strconv.ParseFloat(token.text, 64)
+			if err != nil {
+				return nil, ep.p.errorf(ep.line, "invalid number %s", token.text)
+			}
+			return &liquidLiteral{value: f}, nil
+		}
+		n, err := strconv.ParseInt(token.text, 10, 64)
+		if err != nil {
+			return nil, ep.p.errorf(ep.line, "invalid number %s", token.text)
+		}
+		return &liquidLiteral{value: n}, nil
+	case liquidIdentToken:
+		switch token.text {
+		case "true":
+			return &liquidLiteral{value: true}, nil
+		case "false":
+			return &liquidLiteral{value: false}, nil
+		case "nil", "null":
+			return &liquidLiteral{}, nil
+		case "empty":
+			return &liquidLiteral{value: liquidEmpty{}}, nil
+		case "blank":
+			return &liquidLiteral{value: liquidBlank{}}, nil
+		}
+		variable := &liquidVariable{name: token.text}
+		for {
+			if ep.accept(".") {
+				key, err := ep.expectIdent("property name")
+				if err != nil {
+					return nil, err
+				}
+				variable.path = append(variable.path, &liquidLiteral{value: key})
+			} else if ep.accept("[") {
+				key, err := ep.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				if !ep.accept("]") {
+					return nil, ep.p.errorf(ep.line, "expected ] after index")
+				}
+				variable.path = append(variable.path, key)
+			} else {
+				return variable, nil
+			}
+		}
+	}
+
+	if token.text == "(" {
+		start, err := ep.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if !ep.accept("..") {
+			return nil, ep.p.errorf(ep.line, "expected .. in range")
+		}
+		end, err := ep.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if !ep.accept(")") {
+			return nil, ep.p.errorf(ep.line, "expected ) to close range")
+		}
+		return &liquidRangeExpr{start: start, end: end}, nil
+	}
+	ep.pos--
+	return nil, ep.unexpected()
+}
+
+// parseFiltered parses a value followed by | filter: arg, arg ...
+func (ep *liquidExprParser) parseFiltered() (*liquidFiltered, error) {
+	base, err := ep.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	expr := &liquidFiltered{base: base, line: ep.line}
+	for ep.accept("|") {
+		name, err := ep.expectIdent("filter name")
+		if err != nil {
+			return nil, err
+		}
+		filter, ok := ep.p.env.Filters[name]
+		if !ok {
+			return nil, ep.p.errorf(ep.line, "unknown filter %q", name)
+		}
+		call := liquidFilterCall{name: name, filter: filter}
+		if ep.accept(":") {
+			for {
+				arg, err := ep.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+				if !ep.accept(",") {
+					break
+				}
+			}
+		}
+		expr.filters = append(expr.filters, call)
+	}
+	return expr, nil
+}
+
+var liquidComparisonOps = []string{"==", "!=", "<>", "<", ">", "<=", ">="}
+
+// parseCondition parses comparisons joined by and/or. As in Liquid, there
+// is no precedence: a or b and c reads as a or (b and c).
+func (ep *liquidExprParser) parseCondition() (liquidCondition, error) {
+	left, err := ep.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	cmp := &liquidComparison{left: left}
+	if !ep.atEnd() {
+		token := ep.peek()
+		if (token.kind == liquidPunctToken && containsString(liquidComparisonOps, token.text)) ||
+			(token.kind == liquidIdentToken && token.text == "contains") {
+			ep.pos++
+			cmp.op = token.text
+			if cmp.right, err = ep.parsePrimary(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, op := range []string{"and", "or"} {
+		if ep.acceptWord(op) {
+			rest, err := ep.parseCondition()
+			if err != nil {
+				return nil, err
+			}
+			return &liquidLogic{op: op, left: cmp, right: rest}, nil
+		}
+	}
+	return cmp, nil
+}
@@ -0,0 +1,592 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// liquidFilters returns the standard filters; money reads env's format
+func liquidFilters(env *LiquidEnv) map[string]LiquidFilter {
+	return map[string]LiquidFilter{
+		"date":     liquidDateFilter,
+		"escape":   liquidStringFilter(html.EscapeString),
+		"h":        liquidStringFilter(html.EscapeString),
+		"truncate": liquidTruncateFilter,
+		"money":    env.moneyFilter,
+		"default":  liquidDefaultFilter,
+
+		"escape_once":    liquidStringFilter(func(s string) string { return html.EscapeString(html.UnescapeString(s)) }),
+		"upcase":         liquidStringFilter(strings.ToUpper),
+		"downcase":       liquidStringFilter(strings.ToLower),
+		"capitalize":     liquidStringFilter(liquidCapitalize),
+		"strip":          liquidStringFilter(strings.TrimSpace),
+		"lstrip":         liquidStringFilter(func(s string) string { return strings.TrimLeftFunc(s, unicode.IsSpace) }),
+		"rstrip":         liquidStringFilter(func(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) }),
+		"strip_html":     liquidStringFilter(liquidStripHTML),
+		"strip_newlines": liquidStringFilter(func(s string) string { return strings.NewReplacer("\r\n", "", "\n", "").Replace(s) }),
+		"newline_to_br":  liquidStringFilter(func(s string) string { return strings.ReplaceAll(s, "\n", "<br />\n") }),
+		"url_encode":     liquidStringFilter(url.QueryEscape),
+		"truncatewords":  liquidTruncateWordsFilter,
+		"append":         liquidConcatFilter(false),
+		"prepend":        liquidConcatFilter(true),
+		"replace":        liquidReplaceFilter(-1, false),
+		"replace_first":  liquidReplaceFilter(1, false),
+		"remove":         liquidReplaceFilter(-1, true),
+		"remove_first":   liquidReplaceFilter(1, true),
+		"split":          liquidSplitFilter,
+		"join":           liquidJoinFilter,
+		"first":          liquidPositionFilter("first"),
+		"last":           liquidPositionFilter("last"),
+		"size":           liquidSizeFilter,
+		"reverse":        liquidReverseFilter,
+		"sort":           liquidSortFilter,
+		"map":            liquidMapFilter,
+		"where":          liquidWhereFilter,
+		"plus":           liquidMathFilter("plus"),
+		"minus":          liquidMathFilter("minus"),
+		"times":          liquidMathFilter("times"),
+		"divided_by":     liquidMathFilter("divided_by"),
+		"modulo":         liquidMathFilter("modulo"),
+		"round":          liquidRoundFilter,
+	}
+}
+
+func liquidArg(args []interface{}, i int) (interface{}, bool) {
+	if i < len(args) {
+		return args[i], true
+	}
+	return nil, false
+}
+
+func liquidStringArg(args []interface{}, i int, fallback string) string {
+	if arg, ok := liquidArg(args, i); ok {
+		return liquidString(arg)
+	}
+	return fallback
+}
+
+func liquidRequiredArg(args []interface{}, i int, what string) (interface{}, error) {
+	arg, ok := liquidArg(args, i)
+	if !ok {
+		return nil, fmt.Errorf("missing %s argument", what)
+	}
+	return arg, nil
+}
+
+func liquidIntArg(args []interface{}, i int, what string, fallback int64) (int64, error) {
+	arg, ok := liquidArg(args, i)
+	if !ok {
+		return fallback, nil
+	}
+	n, ok := liquidNumber(arg)
+	if !ok {
+		return 0, fmt.Errorf("%s must be a number, got %s", what, liquidTypeName(arg))
+	}
+	return n.int(), nil
+}
+
+func liquidStringFilter(transform func(string) string) LiquidFilter {
+	return func(input interface{}, args ...interface{}) (interface{}, error) {
+		return transform(liquidString(input)), nil
+	}
+}
+
+// liquidDateFilter formats a date with strftime directives; input may be a
+// time, a Unix timestamp, an ISO 8601 string, "now" or "today"
+func liquidDateFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	format, err := liquidRequiredArg(args, 0, "format")
+	if err != nil {
+		return nil, err
+	}
+	t, ok := liquidTime(input)
+	if !ok || liquidString(format) == "" {
+		return input, nil
+	}
+	return strftime(t, liquidString(format)), nil
+}
+
+var liquidTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+func liquidTime(value interface{}) (time.Time, bool) {
+	value = liquidDeref(value)
+	if t, ok := value.(time.Time); ok {
+		return t, !t.IsZero()
+	}
+	if isLiquidNumeric(value) {
+		n, _ := liquidNumber(value)
+		return time.Unix(n.int(), 0).UTC(), true
+	}
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	switch s = strings.TrimSpace(s); s {
+	case "now", "today":
+		return time.Now(), true
+	}
+	for _, layout := range liquidTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// strftime formats t with the common strftime directives; a - after the %
+// drops padding, as in %-d
+func strftime(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		pad := true
+		if format[i] == '-' && i+1 < len(format) {
+			pad = false
+			i++
+		}
+		number := func(n, width int, fill string) {
+			s := strconv.Itoa(n)
+			if pad && len(s) < width {
+				s = strings.Repeat(fill, width-len(s)) + s
+			}
+			b.WriteString(s)
+		}
+		hour12 := t.Hour() % 12
+		if hour12 == 0 {
+			hour12 = 12
+		}
+
+		switch format[i] {
+		case 'Y':
+			b.WriteString(strconv.Itoa(t.Year()))
+		case 'y':
+			number(t.Year()%100, 2, "0")
+		case 'C':
+			number(t.Year()/100, 2, "0")
+		case 'm':
+			number(int(t.Month()), 2, "0")
+		case 'd':
+			number(t.Day(), 2, "0")
+		case 'e':
+			number(t.Day(), 2, " ")
+		case 'j':
+			number(t.YearDay(), 3, "0")
+		case 'H':
+			number(t.Hour(), 2, "0")
+		case 'k':
+			number(t.Hour(), 2, " ")
+		case 'I':
+			number(hour12, 2, "0")
+		case 'l':
+			number(hour12, 2, " ")
+		case 'M':
+			number(t.Minute(), 2, "0")
+		case 'S':
+			number(t.Second(), 2, "0")
+		case 'L':
+			number(t.Nanosecond()/int(time.Millisecond), 3, "0")
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'P':
+			b.WriteString(t.Format("pm"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'u':
+			number((int(t.Weekday())+6)%7+1, 1, "0")
+		case 'w':
+			number(int(t.Weekday()), 1, "0")
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 's':
+			b.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case 'F':
+			b.WriteString(t.Format("2006-01-02"))
+		case 'D', 'x':
+			b.WriteString(t.Format("01/02/06"))
+		case 'T', 'X':
+			b.WriteString(t.Format("15:04:05"))
+		case 'R':
+			b.WriteString(t.Format("15:04"))
+		case 'r':
+			b.WriteString(t.Format("03:04:05 PM"))
+		case 'c':
+			b.WriteString(t.Format("Mon Jan _2 15:04:05 2006"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			if !pad {
+				b.WriteByte('-')
+			}
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
+
+// liquidTruncateFilter shortens text to length characters, the ellipsis
+// ("..." unless given) included
+func liquidTruncateFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	length, err := liquidIntArg(args, 0, "length", 50)
+	if err != nil {
+		return nil, err
+	}
+	text := liquidString(input)
+	ellipsis := liquidStringArg(args, 1, "...")
+	if int64(utf8.RuneCountInString(text)) <= length {
+		return text, nil
+	}
+	keep := int(length) - utf8.RuneCountInString(ellipsis)
+	if keep < 0 {
+		keep = 0
+	}
+	return string([]rune(text)[:keep]) + ellipsis, nil
+}
+
+func liquidTruncateWordsFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	count, err := liquidIntArg(args, 0, "word count", 15)
+	if err != nil {
+		return nil, err
+	}
+	if count < 1 {
+		count = 1
+	}
+	words := strings.Fields(liquidString(input))
+	if int64(len(words)) <= count {
+		return strings.Join(words, " "), nil
+	}
+	return strings.Join(words[:count], " ") + liquidStringArg(args, 1, "..."), nil
+}
+
+// moneyFilter formats an amount in cents with the environment's
+// MoneyFormat
+func (env *LiquidEnv) moneyFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	if liquidDeref(input) == nil {
+		return "", nil
+	}
+	n, ok := liquidNumber(input)
+	if !ok {
+		return nil, fmt.Errorf("expected an amount in cents, got %s", liquidTypeName(input))
+	}
+	cents := int64(math.Round(n.float()))
+
+	format := env.MoneyFormat
+	if format == "" {
+		format = "${{amount}}"
+	}
+	return strings.NewReplacer(
+		"{{amount}}", formatCents(cents, 2, ",", "."),
+		"{{ amount }}", formatCents(cents, 2, ",", "."),
+		"{{amount_no_decimals}}", formatCents(cents, 0, ",", "."),
+		"{{ amount_no_decimals }}", formatCents(cents, 0, ",", "."),
+		"{{amount_with_comma_separator}}", formatCents(cents, 2, ".", ","),
+		"{{ amount_with_comma_separator }}", formatCents(cents, 2, ".", ","),
+		"{{amount_no_decimals_with_comma_separator}}", formatCents(cents, 0, ".", ","),
+		"{{ amount_no_decimals_with_comma_separator }}", formatCents(cents, 0, ".", ","),
+	).Replace(format), nil
+}
+
+// formatCents writes cents as units with thousands separators, rounding
+// to whole units when decimals is 0
+func formatCents(cents int64, decimals int, thousands, point string) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	units, fraction := cents/100, cents%100
+	if decimals == 0 && fraction >= 50 {
+		units++
+	}
+
+	digits := strconv.FormatInt(units, 10)
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(thousands)
+		}
+		b.WriteRune(digit)
+	}
+	if decimals > 0 {
+		fmt.Fprintf(&b, "%s%02d", point, fraction)
+	}
+	return b.String()
+}
+
+// liquidDefaultFilter replaces nil, false and empty values with its argument
+func liquidDefaultFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	fallback, err := liquidRequiredArg(args, 0, "default value")
+	if err != nil {
+		return nil, err
+	}
+	if !liquidTruthy(input) || liquidIsEmpty(input) {
+		return fallback, nil
+	}
+	if t, ok := liquidDeref(input).(time.Time); ok && t.IsZero() {
+		return fallback, nil
+	}
+	return input, nil
+}
+
+func liquidCapitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + strings.ToLower(s[size:])
+}
+
+var liquidScriptPattern = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>|<!--.*?-->`)
+
+func liquidStripHTML(s string) string {
+	return anyTag.ReplaceAllString(liquidScriptPattern.ReplaceAllString(s, ""), "")
+}
+
+func liquidConcatFilter(prepend bool) LiquidFilter {
+	return func(input interface{}, args ...interface{}) (interface{}, error) {
+		arg, err := liquidRequiredArg(args, 0, "text")
+		if err != nil {
+			return nil, err
+		}
+		if prepend {
+			return liquidString(arg) + liquidString(input), nil
+		}
+		return liquidString(input) + liquidString(arg), nil
+	}
+}
+
+func liquidReplaceFilter(count int, remove bool) LiquidFilter {
+	return func(input interface{}, args ...interface{}) (interface{}, error) {
+		search, err := liquidRequiredArg(args, 0, "search")
+		if err != nil {
+			return nil, err
+		}
+		replacement := ""
+		if !remove {
+			replacement = liquidStringArg(args, 1, "")
+		}
+		return strings.Replace(liquidString(input), liquidString(search), replacement, count), nil
+	}
+}
+
+func liquidSplitFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	separator, err := liquidRequiredArg(args, 0, "separator")
+	if err != nil {
+		return nil, err
+	}
+	text := liquidString(input)
+	if text == "" {
+		return []interface{}{}, nil
+	}
+	parts := strings.Split(text, liquidString(separator))
+	items := make([]interface{}, len(parts))
+	for i, part := range parts {
+		items[i] = part
+	}
+	return items, nil
+}
+
+func liquidJoinFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	items, ok := liquidList(input)
+	if !ok {
+		return liquidString(input), nil
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = liquidString(item)
+	}
+	return strings.Join(parts, liquidStringArg(args, 0, " ")), nil
+}
+
+func liquidPositionFilter(position string) LiquidFilter {
+	return func(input interface{}, args ...interface{}) (interface{}, error) {
+		return liquidIndex(input, position), nil
+	}
+}
+
+func liquidSizeFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	if size := liquidIndex(input, "size"); size != nil {
+		return size, nil
+	}
+	return 0, nil
+}
+
+func liquidReverseFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	items, ok := liquidList(input)
+	if !ok {
+		return input, nil
+	}
+	reversed := make([]interface{}, len(items))
+	for i, item := range items {
+		reversed[len(items)-1-i] = item
+	}
+	return reversed, nil
+}
+
+// liquidSortFilter sorts a list, or a list of objects by a property
+func liquidSortFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	items, ok := liquidList(input)
+	if !ok {
+		return input, nil
+	}
+	sorted := append([]interface{}{}, items...)
+	property, byProperty := liquidArg(args, 0)
+	key := func(item interface{}) interface{} {
+		if byProperty {
+			return liquidIndex(item, liquidString(property))
+		}
+		return item
+	}
+
+	var err error
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := key(sorted[i]), key(sorted[j])
+		switch {
+		case liquidDeref(a) == nil:
+			return false
+		case liquidDeref(b) == nil:
+			return true
+		}
+		order, ok := liquidCompare(a, b)
+		if !ok && err == nil {
+			err = fmt.Errorf("cannot compare %s with %s", liquidTypeName(a), liquidTypeName(b))
+		}
+		return order < 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sorted, nil
+}
+
+func liquidMapFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	property, err := liquidRequiredArg(args, 0, "property")
+	if err != nil {
+		return nil, err
+	}
+	items, ok := liquidList(input)
+	if !ok {
+		items = []interface{}{input}
+	}
+	mapped := make([]interface{}, len(items))
+	for i, item := range items {
+		mapped[i] = liquidIndex(item, liquidString(property))
+	}
+	return mapped, nil
+}
+
+// liquidWhereFilter keeps the objects whose property equals the value, or
+// is truthy when no value is given
+func liquidWhereFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	property, err := liquidRequiredArg(args, 0, "property")
+	if err != nil {
+		return nil, err
+	}
+	items, _ := liquidList(input)
+	value, byValue := liquidArg(args, 1)
+	matched := []interface{}{}
+	for _, item := range items {
+		field := liquidIndex(item, liquidString(property))
+		if (byValue && liquidEqual(field, value)) || (!byValue && liquidTruthy(field)) {
+			matched = append(matched, item)
+		}
+	}
+	return matched, nil
+}
+
+// liquidMathFilter does integer arithmetic when both operands are integers
+// and floating point otherwise
+func liquidMathFilter(op string) LiquidFilter {
+	return func(input interface{}, args ...interface{}) (interface{}, error) {
+		arg, err := liquidRequiredArg(args, 0, "operand")
+		if err != nil {
+			return nil, err
+		}
+		x, ok := liquidNumber(input)
+		if liquidDeref(input) == nil {
+			x, ok = liquidNum{}, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("expected a number, got %s", liquidTypeName(input))
+		}
+		y, ok := liquidNumber(arg)
+		if !ok {
+			return nil, fmt.Errorf("operand must be a number, got %s", liquidTypeName(arg))
+		}
+		if (op == "divided_by" || op == "modulo") && y.float() == 0 {
+			return nil, fmt.Errorf("divided by 0")
+		}
+
+		if !x.isFloat && !y.isFloat {
+			switch op {
+			case "plus":
+				return x.i + y.i, nil
+			case "minus":
+				return x.i - y.i, nil
+			case "times":
+				return x.i * y.i, nil
+			case "divided_by":
+				return int64(math.Floor(float64(x.i) / float64(y.i))), nil
+			default:
+				return x.i - y.i*int64(math.Floor(float64(x.i)/float64(y.i))), nil
+			}
+		}
+		a, b := x.float(), y.float()
+		switch op {
+		case "plus":
+			return a + b, nil
+		case "minus":
+			return a - b, nil
+		case "times":
+			return a * b, nil
+		case "divided_by":
+			return a / b, nil
+		default:
+			return a - b*math.Floor(a/b), nil
+		}
+	}
+}
+
+func liquidRoundFilter(input interface{}, args ...interface{}) (interface{}, error) {
+	n, ok := liquidNumber(input)
+	if !ok {
+		return nil, fmt.Errorf("expected a number, got %s", liquidTypeName(input))
+	}
+	places, err := liquidIntArg(args, 0, "places", 0)
+	if err != nil {
+		return nil, err
+	}
+	if places <= 0 {
+		return int64(math.Round(n.float())), nil
+	}
+	scale := math.Pow(10, float64(places))
+	return math.Round(n.float()*scale) / scale, nil
+}
@@ -0,0 +1,1030 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Render executes the template with vars as its top-level variables
+func (t *LiquidTemplate) Render(vars map[string]interface{}) ([]byte, error) {
+	limits := t.env.Limits
+	r := &liquidRenderer{
+		env:    t.env,
+		name:   t.name,
+		vars:   vars,
+		scopes: []map[string]interface{}{{}},
+		budget: &liquidBudget{limits: limits},
+	}
+	if limits.Timeout > 0 {
+		r.budget.deadline = time.Now().Add(limits.Timeout)
+	}
+
+	var out bytes.Buffer
+	if err := r.renderNodes(t.nodes, &out); err != nil && !errors.Is(err, errLiquidBreak) && !errors.Is(err, errLiquidContinue) {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+var (
+	errLiquidBreak    = errors.New("break outside a for loop")
+	errLiquidContinue = errors.New("continue outside a for loop")
+)
+
+// liquidBudget is the work left for a render, shared with the partials it
+// includes
+type liquidBudget struct {
+	limits   LiquidLimits
+	deadline time.Time
+	steps    int
+	output   int
+}
+
+type liquidRenderer struct {
+	env    *LiquidEnv
+	name   string
+	vars   map[string]interface{}
+	scopes []map[string]interface{}
+	depth  int
+	budget *liquidBudget
+}
+
+func (r *liquidRenderer) errorf(line int, format string, args ...interface{}) error {
+	return &LiquidError{Template: r.name, Line: line, Message: fmt.Sprintf(format, args...)}
+}
+
+// locate gives an error from evaluating an expression its template line
+func (r *liquidRenderer) locate(line int, err error) error {
+	var located *LiquidError
+	if errors.As(err, &located) {
+		return err
+	}
+	return r.errorf(line, "%v", err)
+}
+
+// step charges one unit of work against the render's limits
+func (r *liquidRenderer) step(line int) error {
+	b := r.budget
+	b.steps++
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		return r.errorf(line, "render stopped after %d steps", b.limits.MaxSteps)
+	}
+	if !b.deadline.IsZero() && b.steps%256 == 0 && time.Now().After(b.deadline) {
+		return r.errorf(line, "render stopped after %s", b.limits.Timeout)
+	}
+	return nil
+}
+
+func (r *liquidRenderer) write(out *bytes.Buffer, text string, line int) error {
+	b := r.budget
+	b.output += len(text)
+	if b.limits.MaxOutput > 0 && b.output > b.limits.MaxOutput {
+		return r.errorf(line, "output is larger than %d bytes", b.limits.MaxOutput)
+	}
+	out.WriteString(text)
+	return nil
+}
+
+func (r *liquidRenderer) lookup(name string) interface{} {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if value, ok := r.scopes[i][name]; ok {
+			return value
+		}
+	}
+	return r.vars[name]
+}
+
+// assign sets a template-wide variable, as assign and capture do in Liquid
+func (r *liquidRenderer) assign(name string, value interface{}) {
+	r.scopes[0][name] = value
+}
+
+func (r *liquidRenderer) push(scope map[string]interface{}) {
+	r.scopes = append(r.scopes, scope)
+}
+
+func (r *liquidRenderer) pop() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *liquidRenderer) renderNodes(nodes []liquidNode, out *bytes.Buffer) error {
+	for _, node := range nodes {
+		if err := node.render(r, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Nodes
+
+type liquidNode interface {
+	render(r *liquidRenderer, out *bytes.Buffer) error
+}
+
+type liquidTextNode struct {
+	text string
+}
+
+func (n *liquidTextNode) render(r *liquidRenderer, out *bytes.Buffer) error {
+	return r.write(out, n.text, 0)
+}
+
+type liquidOutputNode struct {
+	expr *liquidFiltered
+	line int
+}
+
+func (n *liquidOutputNode) render(r *liquidRenderer, out *bytes.Buffer) error {
+	if err := r.step(n.line); err != nil {
+		return err
+	}
+	value, err := n.expr.eval(r)
+	if err != nil {
+		return err
+	}
+	return r.write(out, liquidString(value), n.line)
+}
+
+type liquidBranch struct {
+	cond   liquidCondition
+	negate bool
+	body   []liquidNode
+	line   int
+}
+
+type liquidIfNode struct {
+	branches []liquidBranch
+}
+
+func (n *liquidIfNode) render(r *liquidRenderer, out *bytes.Buffer) error {
+	for _, branch := range n.branches {
+		if branch.cond == nil {
+			return r.renderNodes(branch.body, out)
+		}
+		ok, err := branch.cond.test(r)
+		if err != nil {
+			return r.locate(branch.line, err)
+		}
+		if ok != branch.negate {
+			return r.renderNodes(branch.body, out)
+		}
+	}
+	return nil
+}
+
+type liquidWhen struct {
+	values []liquidExpr
+	body   []liquidNode
+}
+
+type liquidCaseNode struct {
+	subject  liquidExpr
+	whens    []liquidWhen
+	elseBody []liquidNode
+}
+
+// render renders every when block that matches, as Liquid does, and the
+// else block only if none did
+func (n *liquidCaseNode) render(r *liquidRenderer, out *bytes.Buffer) error {
+	subject, err := n.subject.eval(r)
+	if err != nil {
+		return err
+	}
+	matched := false
+	for _, when := range n.whens {
+		for _, expr := range when.values {
+			value, err := expr.eval(r)
+			if err != nil {
+				return err
+			}
+			if liquidEqual(subject, value) {
+				matched = true
+				if err := r.renderNodes(when.body, out); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	if !matched {
+		return r.renderNodes(n.elseBody, out)
+	}
+	return nil
+}
+
+type liquidForNode struct {
+	variable   string
+	collection liquidExpr
+	limit      liquidExpr
+	offset     liquidExpr
+	reversed   bool
+	body       []liquidNode
+	elseBody   []liquidNode
+	line       int
+}
+
+func (n *liquidForNode) render(r *liquidRenderer, out *bytes.Buffer) error {
+	items, err := r.loopItems(n.collection)
+	if err != nil {
+		return r.locate(n.line, err)
+	}
+	offset, err := r.loopOption(n.offset, "offset", 0, n.line)
+	if err != nil {
+		return err
+	}
+	limit, err := r.loopOption(n.limit, "limit", len(items), n.line)
+	if err != nil {
+		return err
+	}
+	if offset > len(items) {
+		offset = len(items)
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	if n.reversed {
+		reversed := make([]interface{}, len(items))
+		for i, item := range items {
+			reversed[len(items)-1-i] = item
+		}
+		items = reversed
+	}
+
+	if len(items) == 0 {
+		return r.renderNodes(n.elseBody, out)
+	}
+	return r.loop(n.variable, items, n.line, func() error {
+		return r.renderNodes(n.body, out)
+	})
+}
+
+// loop runs body once per item with the item and a forloop object in scope
+func (r *liquidRenderer) loop(variable string, items []interface{}, line int, body func() error) error {
+	parent := r.lookup("forloop")
+	scope := map[string]interface{}{}
+	r.push(scope)
+	defer r.pop()
+
+	for i, item := range items {
+		if err := r.step(line); err != nil {
+			return err
+		}
+		scope[variable] = item
+		scope["forloop"] = map[string]interface{}{
+			"index":      i + 1,
+			"index0":     i,
+			"rindex":     len(items) - i,
+			"rindex0":    len(items) - i - 1,
+			"first":      i == 0,
+			"last":       i == len(items)-1,
+			"length":     len(items),
+			"parentloop": parent,
+		}
+		err := body()
+		if errors.Is(err, errLiquidBreak) {
+			break
+		}
+		if err != nil && !errors.Is(err, errLiquidContinue) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *liquidRenderer) loopItems(expr liquidExpr) ([]interface{}, error) {
+	if rng, ok := expr.(*liquidRangeExpr); ok {
+		start, end, err := rng.bounds(r)
+		if err != nil {
+			return nil, err
+		}
+		if max := r.budget.limits.MaxSteps; max > 0 && end-start >= int64(max) {
+			return nil, fmt.Errorf("range (%d..%d) is too large", start, end)
+		}
+		var items []interface{}
+		for i := start; i <= end; i++ {
+			items = append(items, i)
+		}
+		return items, nil
+	}
+
+	value, err := expr.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	if items, ok := liquidList(value); ok {
+		return items, nil
+	}
+	value = liquidDeref(value)
+	switch v := reflect.ValueOf(value); {
+	case value == nil:
+		return nil, nil
+	case v.Kind() == reflect.Map:
+		// Maps iterate as [key, value] pairs in key order
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return liquidString(keys[i].Interface()) < liquidString(keys[j].Interface())
+		})
+		items := make([]interface{}, len(keys))
+		for i, key := range keys {
+			items[i] = []interface{}{key.Interface(), v.MapIndex(key).Interface()}
+		}
+		return items, nil
+	}
+	return []interface{}{value}, nil
+}
+
+func (r *liquidRenderer) loopOption(expr liquidExpr, name string, fallback, line int) (int, error) {
+	if expr == nil {
+		return fallback, nil
+	}
+	value, err := expr.eval(r)
+	if err != nil {
+		return 0, err
+	}
+	if liquidDeref(value) == nil {
+		return fallback, nil
+	}
+	n, ok := liquidNumber(value)
+	if !ok {
+		return 0, r.errorf(line, "for loop %s must be a number", name)
+	}
+	if n.int() < 0 {
+		return 0, nil
+	}
+	return int(n.int()), nil
+}
+
+type liquidLoopControlNode struct {
+	brk bool
+}
+
+func (n *liquidLoopControlNode) render(r *liquidRenderer, out *bytes.Buffer) error {
+	if n.brk {
+		return errLiquidBreak
+	}
+	return errLiquidContinue
+}
+
+type liquidAssignNode struct {
+	name string
+	expr *liquidFiltered
+}
+
+func (n *liquidAssignNode) render(r *liquidRenderer, out *bytes.Buffer) error {
+	if err := r.step(n.expr.line); err != nil {
+		return err
+	}
+	value, err := n.expr.eval(r)
+	if err != nil {
+		return err
+	}
+	r.assign(n.name, value)
+	return nil
+}
+
+type liquidCaptureNode struct {
+	name string
+	body []liquidNode
+}
+
+func (n *liquidCaptureNode) render(r *liquidRenderer, out *bytes.Buffer) error {
+	var captured bytes.Buffer
+	if err := r.renderNodes(n.body, &captured); err != nil {
+		return err
+	}
+	r.assign(n.name, captured.String())
+	return nil
+}
+
+type liquidArgument struct {
+	name  string
+	value *liquidFiltered
+}
+
+type liquidIncludeNode struct {
+	name     liquidExpr
+	with     liquidExpr
+	loop     bool
+	alias    string
+	args     []liquidArgument
+	isolated bool
+	line     int
+}
+
+// render runs a partial. include shares the caller's variables; render
+// sees only what is passed to it.
+func (n *liquidIncludeNode) render(r *liquidRenderer, out *bytes.Buffer) error {
+	if err := r.step(n.line); err != nil {
+		return err
+	}
+	nameValue, err := n.name.eval(r)
+	if err != nil {
+		return err
+	}
+	name := liquidString(nameValue)
+	if r.depth >= r.budget.limits.MaxDepth && r.budget.limits.MaxDepth > 0 {
+		return r.errorf(n.line, "partial %q nests deeper than %d levels", name, r.budget.limits.MaxDepth)
+	}
+	if r.env.Partials == nil {
+		return r.errorf(n.line, "partial %q not found", name)
+	}
+	partial, err := r.env.Partials(name)
+	if err != nil {
+		return r.locate(n.line, err)
+	}
+
+	scope := map[string]interface{}{}
+	for _, arg := range n.args {
+		value, err := arg.value.eval(r)
+		if err != nil {
+			return err
+		}
+		scope[arg.name] = value
+	}
+	variable := n.alias
+	if variable == "" {
+		variable = name[strings.LastIndex(name, "/")+1:]
+	}
+
+	child := r
+	if n.isolated {
+		child = &liquidRenderer{env: r.env, scopes: []map[string]interface{}{{}}, budget: r.budget}
+	} else {
+		// Assigns in an included partial stay visible to the caller
+		child = &liquidRenderer{env: r.env, vars: r.vars, scopes: r.scopes, budget: r.budget}
+	}
+	child.name, child.depth = partial.name, r.depth+1
+	child.push(scope)
+
+	if n.with == nil {
+		return child.renderNodes(partial.nodes, out)
+	}
+	value, err := n.with.eval(r)
+	if err != nil {
+		return err
+	}
+	if !n.loop {
+		scope[variable] = value
+		return child.renderNodes(partial.nodes, out)
+	}
+	items, ok := liquidList(value)
+	if !ok && liquidDeref(value) != nil {
+		items = []interface{}{value}
+	}
+	return child.loop(variable, items, n.line, func() error {
+		return child.renderNodes(partial.nodes, out)
+	})
+}
+
+// Expressions
+
+type liquidExpr interface {
+	eval(r *liquidRenderer) (interface{}, error)
+}
+
+// liquidEmpty and liquidBlank are the special values empty and blank,
+// which compare equal to empty strings and collections (and, for blank,
+// nil, false and whitespace)
+type liquidEmpty struct{}
+type liquidBlank struct{}
+
+type liquidLiteral struct {
+	value interface{}
+}
+
+func (e *liquidLiteral) eval(r *liquidRenderer) (interface{}, error) {
+	return e.value, nil
+}
+
+type liquidVariable struct {
+	name string
+	path []liquidExpr
+}
+
+func (e *liquidVariable) eval(r *liquidRenderer) (interface{}, error) {
+	value := r.lookup(e.name)
+	for _, part := range e.path {
+		key, err := part.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		value = liquidIndex(value, key)
+	}
+	return value, nil
+}
+
+type liquidRangeExpr struct {
+	start, end liquidExpr
+}
+
+func (e *liquidRangeExpr) bounds(r *liquidRenderer) (int64, int64, error) {
+	var bounds [2]int64
+	for i, expr := range []liquidExpr{e.start, e.end} {
+		value, err := expr.eval(r)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, ok := liquidNumber(value)
+		if !ok {
+			return 0, 0, fmt.Errorf("range bounds must be numbers")
+		}
+		bounds[i] = n.int()
+	}
+	return bounds[0], bounds[1], nil
+}
+
+func (e *liquidRangeExpr) eval(r *liquidRenderer) (interface{}, error) {
+	items, err := r.loopItems(e)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+type liquidFilterCall struct {
+	name   string
+	filter LiquidFilter
+	args   []liquidExpr
+}
+
+type liquidFiltered struct {
+	base    liquidExpr
+	filters []liquidFilterCall
+	line    int
+}
+
+func (e *liquidFiltered) eval(r *liquidRenderer) (interface{}, error) {
+	value, err := e.base.eval(r)
+	if err != nil {
+		return nil, r.locate(e.line, err)
+	}
+	for _, call := range e.filters {
+		args := make([]interface{}, len(call.args))
+		for i, arg := range call.args {
+			if args[i], err = arg.eval(r); err != nil {
+				return nil, r.locate(e.line, err)
+			}
+		}
+		if value, err = call.filter(value, args...); err != nil {
+			return nil, r.errorf(e.line, "%s: %v", call.name, err)
+		}
+		if s, ok := value.(string); ok && r.budget.limits.MaxOutput > 0 && len(s) > r.budget.limits.MaxOutput {
+			return nil, r.errorf(e.line, "%s: result is larger than %d bytes", call.name, r.budget.limits.MaxOutput)
+		}
+	}
+	return value, nil
+}
+
+// Conditions
+
+type liquidCondition interface {
+	test(r *liquidRenderer) (bool, error)
+}
+
+type liquidComparison struct {
+	left  liquidExpr
+	op    string
+	right liquidExpr
+}
+
+func (c *liquidComparison) test(r *liquidRenderer) (bool, error) {
+	left, err := c.left.eval(r)
+	if err != nil {
+		return false, err
+	}
+	if c.op == "" {
+		return liquidTruthy(left), nil
+	}
+	right, err := c.right.eval(r)
+	if err != nil {
+		return false, err
+	}
+
+	switch c.op {
+	case "==":
+		return liquidEqual(left, right), nil
+	case "!=", "<>":
+		return !liquidEqual(left, right), nil
+	case "contains":
+		return liquidContains(left, right), nil
+	}
+	order, ok := liquidCompare(left, right)
+	if !ok {
+		if liquidDeref(left) == nil || liquidDeref(right) == nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("cannot compare %s with %s", liquidTypeName(left), liquidTypeName(right))
+	}
+	switch c.op {
+	case "<":
+		return order < 0, nil
+	case ">":
+		return order > 0, nil
+	case "<=":
+		return order <= 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+type liquidLogic struct {
+	op          string
+	left, right liquidCondition
+}
+
+func (c *liquidLogic) test(r *liquidRenderer) (bool, error) {
+	left, err := c.left.test(r)
+	if err != nil {
+		return false, err
+	}
+	if c.op == "and" && !left {
+		return false, nil
+	}
+	if c.op == "or" && left {
+		return true, nil
+	}
+	return c.right.test(r)
+}
+
+// Values
+
+// liquidDeref follows pointers and interfaces, turning nil ones into nil
+func liquidDeref(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+func liquidTruthy(value interface{}) bool {
+	switch v := liquidDeref(value).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	}
+	return true
+}
+
+// liquidString renders a value the way an output tag prints it
+func liquidString(value interface{}) string {
+	value = liquidDeref(value)
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05 -0700")
+	case liquidEmpty, liquidBlank:
+		return ""
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return liquidFormatFloat(v.Float())
+	case reflect.Slice, reflect.Array:
+		var b strings.Builder
+		for i := 0; i < v.Len(); i++ {
+			b.WriteString(liquidString(v.Index(i).Interface()))
+		}
+		return b.String()
+	}
+	return fmt.Sprint(value)
+}
+
+func liquidFormatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.ContainsAny(s, ".eEnN") {
+		s += ".0"
+	}
+	return s
+}
+
+// liquidList returns the items of a slice, array or range
+func liquidList(value interface{}) ([]interface{}, bool) {
+	value = liquidDeref(value)
+	if items, ok := value.([]interface{}); ok {
+		return items, true
+	}
+	v := reflect.ValueOf(value)
+	if value == nil || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+	return items, true
+}
+
+// liquidIndex looks up key in value: map keys, struct fields by their JSON
+// name, list indexes, and size, first and last
+func liquidIndex(value, key interface{}) interface{} {
+	value = liquidDeref(value)
+	if value == nil {
+		return nil
+	}
+	name, isName := key.(string)
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.Map:
+		if isName && v.Type().Key().Kind() == reflect.String {
+			if item := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())); item.IsValid() {
+				return item.Interface()
+			}
+		}
+		if name == "size" {
+			return v.Len()
+		}
+	case reflect.Slice, reflect.Array:
+		if n, ok := liquidNumber(key); ok && !isName {
+			i := int(n.int())
+			if i < 0 {
+				i += v.Len()
+			}
+			if i >= 0 && i < v.Len() {
+				return v.Index(i).Interface()
+			}
+			return nil
+		}
+		switch name {
+		case "size":
+			return v.Len()
+		case "first":
+			if v.Len() > 0 {
+				return v.Index(0).Interface()
+			}
+		case "last":
+			if v.Len() > 0 {
+				return v.Index(v.Len() - 1).Interface()
+			}
+		}
+	case reflect.String:
+		if name == "size" {
+			return utf8.RuneCountInString(v.String())
+		}
+	case reflect.Struct:
+		if index, ok := liquidStructFields(v.Type())[liquidFieldKey(name)]; ok && isName {
+			return v.FieldByIndex(index).Interface()
+		}
+	}
+	return nil
+}
+
+var liquidFieldCache sync.Map
+
+// liquidStructFields maps the exported fields of a struct type by their
+// JSON name and their Go name, both folded by liquidFieldKey
+func liquidStructFields(t reflect.Type) map[string][]int {
+	if cached, ok := liquidFieldCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+	fields := make(map[string][]int)
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			fields[liquidFieldKey(tag)] = field.Index
+		}
+		if _, ok := fields[liquidFieldKey(field.Name)]; !ok {
+			fields[liquidFieldKey(field.Name)] = field.Index
+		}
+	}
+	liquidFieldCache.Store(t, fields)
+	return fields
+}
+
+// liquidFieldKey folds created_at and CreatedAt to the same key
+func liquidFieldKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// liquidNum is a number from a template or its data
+type liquidNum struct {
+	i       int64
+	f       float64
+	isFloat bool
+}
+
+func (n liquidNum) int() int64 {
+	if n.isFloat {
+		return int64(n.f)
+	}
+	return n.i
+}
+
+func (n liquidNum) float() float64 {
+	if n.isFloat {
+		return n.f
+	}
+	return float64(n.i)
+}
+
+// liquidNumber converts numbers and numeric strings
+func liquidNumber(value interface{}) (liquidNum, bool) {
+	value = liquidDeref(value)
+	if value == nil {
+		return liquidNum{}, false
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return liquidNum{i: v.Int()}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return liquidNum{i: int64(v.Uint())}, true
+	case reflect.Float32, reflect.Float64:
+		return liquidNum{f: v.Float(), isFloat: true}, true
+	case reflect.String:
+		s := strings.TrimSpace(v.String())
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return liquidNum{i: i}, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return liquidNum{f: f, isFloat: true}, true
+		}
+	}
+	return liquidNum{}, false
+}
+
+func isLiquidNumeric(value interface{}) bool {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// liquidIsEmpty reports whether a value is an empty string or collection
+func liquidIsEmpty(value interface{}) bool {
+	value = liquidDeref(value)
+	if value == nil {
+		return false
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() == 0
+	}
+	return false
+}
+
+func liquidIsBlank(value interface{}) bool {
+	value = liquidDeref(value)
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+		return strings.TrimSpace(v.String()) == ""
+	}
+	return liquidIsEmpty(value)
+}
+
+func liquidEqual(a, b interface{}) bool {
+	a, b = liquidDeref(a), liquidDeref(b)
+	switch {
+	case a == liquidEmpty{}:
+		return liquidIsEmpty(b)
+	case b == liquidEmpty{}:
+		return liquidIsEmpty(a)
+	case a == liquidBlank{}:
+		return liquidIsBlank(b)
+	case b == liquidBlank{}:
+		return liquidIsBlank(a)
+	case a == nil || b == nil:
+		return a == nil && b == nil
+	case isLiquidNumeric(a) && isLiquidNumeric(b):
+		x, _ := liquidNumber(a)
+		y, _ := liquidNumber(b)
+		if x.isFloat || y.isFloat {
+			return x.float() == y.float()
+		}
+		return x.i == y.i
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() == reflect.String && vb.Kind() == reflect.String {
+		return va.String() == vb.String()
+	}
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// liquidCompare orders two numbers, strings or times; ok is false for any
+// other pair
+func liquidCompare(a, b interface{}) (int, bool) {
+	a, b = liquidDeref(a), liquidDeref(b)
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if isLiquidNumeric(a) && isLiquidNumeric(b) {
+		x, _ := liquidNumber(a)
+		y, _ := liquidNumber(b)
+		switch {
+		case x.float() < y.float():
+			return -1, true
+		case x.float() > y.float():
+			return 1, true
+		}
+		return 0, true
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb), true
+		}
+		return 0, false
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() == reflect.String && vb.Kind() == reflect.String {
+		return strings.Compare(va.String(), vb.String()), true
+	}
+	return 0, false
+}
+
+func liquidContains(container, item interface{}) bool {
+	container = liquidDeref(container)
+	if container == nil {
+		return false
+	}
+	if items, ok := liquidList(container); ok {
+		for _, candidate := range items {
+			if liquidEqual(candidate, item) {
+				return true
+			}
+		}
+		return false
+	}
+	v := reflect.ValueOf(container)
+	switch v.Kind() {
+	case reflect.String:
+		return strings.Contains(v.String(), liquidString(item))
+	case reflect.Map:
+		return liquidIndex(container, liquidString(item)) != nil
+	}
+	return false
+}
+
+func liquidTypeName(value interface{}) string {
+	value = liquidDeref(value)
+	switch {
+	case value == nil:
+		return "nil"
+	case isLiquidNumeric(value):
+		return "number"
+	}
+	if _, ok := value.(time.Time); ok {
+		return "date"
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,398 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Site settings that configure a site's languages. supported_locales is a
+// JSON array or a comma-separated string.
+const (
+	SettingDefaultLocale    = "default_locale"
+	SettingSupportedLocales = "supported_locales"
+)
+
+// FallbackLocale is the default locale of sites that configure neither
+// default_locale nor language
+const FallbackLocale = "en"
+
+// XDefaultHreflang marks the alternate shown to visitors whose language
+// matches none of the variants
+const XDefaultHreflang = "x-default"
+
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// SiteLocales is a site's default locale and the locales its posts may use;
+// Supported always includes Default
+type SiteLocales struct {
+	Default   string   `json:"default"`
+	Supported []string `json:"supported"`
+}
+
+// PostAlternate is one language variant of a post, for hreflang links.
+// Href is the variant's public path: the post path, prefixed with the
+// locale unless it is the site's default.
+type PostAlternate struct {
+	Hreflang string `json:"hreflang"`
+	PostID   string `json:"post_id"`
+	Slug     string `json:"slug"`
+	Href     string `json:"href"`
+}
+
+// PostTranslation summarises one variant in a post's translation group
+type PostTranslation struct {
+	PostID    string    `json:"post_id"`
+	Locale    string    `json:"locale"`
+	Title     string    `json:"title"`
+	Slug      string    `json:"slug"`
+	Status    string    `json:"status"`
+	Published bool      `json:"published"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LocaleChain lists locales in order of preference. Published listings
+// filtered by a chain show each translation group once, in the first locale
+// of the chain it has a published variant in.
+type LocaleChain []string
+
+// NormalizeLocale canonicalises a BCP 47 style tag ("en_us" → "en-US") and
+// reports whether it is well formed
+func NormalizeLocale(locale string) (string, bool) {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	if !localePattern.MatchString(locale) {
+		return "", false
+	}
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i])
+		case 4:
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		default:
+			parts[i] = strings.ToLower(parts[i])
+		}
+	}
+	return strings.Join(parts, "-"), true
+}
+
+// ParseAcceptLanguage returns the locales of an Accept-Language header,
+// most preferred first. Wildcards and malformed tags are skipped.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	var entries []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		locale, ok := NormalizeLocale(fields[0])
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		if q > 0 {
+			entries = append(entries, weighted{locale, q})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].q > entries[j].q })
+
+	locales := make([]string, len(entries))
+	for i, entry := range entries {
+		locales[i] = entry.locale
+	}
+	return locales
+}
+
+// Chain builds the fallback chain for the requested locales: each supported
+// requested locale, then supported locales of the same language ("de-AT"
+// falls back to "de", "de" to "de-CH"), then the site default, then the
+// remaining supported locales so every translation group stays reachable
+func (l *SiteLocales) Chain(requested ...string) LocaleChain {
+	chain := LocaleChain{}
+	add := func(locale string) {
+		if containsString(l.Supported, locale) && !containsString(chain, locale) {
+			chain = append(chain, locale)
+		}
+	}
+	for _, raw := range requested {
+		locale, ok := NormalizeLocale(raw)
+		if !ok {
+			continue
+		}
+		add(locale)
+		language := strings.SplitN(locale, "-", 2)[0]
+		add(language)
+		for _, supported := range l.Supported {
+			if strings.HasPrefix(supported, language+"-") {
+				add(supported)
+			}
+		}
+	}
+	add(l.Default)
+	for _, supported := range l.Supported {
+		add(supported)
+	}
+	return chain
+}
+
+// Check normalises a post's locale, defaulting to the site default, and
+// rejects locales the site does not support
+func (l *SiteLocales) Check(locale string) (string, error) {
+	if strings.TrimSpace(locale) == "" {
+		return l.Default, nil
+	}
+	normalized, ok := NormalizeLocale(locale)
+	if !ok {
+		return "", fmt.Errorf("locale %q is not a valid language tag", locale)
+	}
+	if !containsString(l.Supported, normalized) {
+		return "", fmt.Errorf("locale %s is not supported by this site; supported locales are %s",
+			normalized, strings.Join(l.Supported, ", "))
+	}
+	return normalized, nil
+}
+
+// LocalizedPostPath is the public path of a post variant: the default
+// locale keeps PostPath and other locales are prefixed, as in /de/posts/x
+func LocalizedPostPath(slug, locale, defaultLocale string) string {
+	if locale == "" || locale == defaultLocale {
+		return PostPath(slug)
+	}
+	return "/" + strings.ToLower(locale) + PostPath(slug)
+}
+
+// SQL returns " AND ..." conditions restricting the published posts aliased
+// as postAlias to one variant per translation group, appending its
+// parameters to args. An empty chain adds no conditions.
+func (c LocaleChain) SQL(postAlias string, args *[]interface{}) string {
+	if len(c) == 0 {
+		return ""
+	}
+	*args = append(*args, pq.Array([]string(c)))
+	chain := "$" + strconv.Itoa(len(*args)) + "::TEXT[]"
+	return ` AND ` + postAlias + `.locale = ANY(` + chain + `) AND NOT EXISTS (
+			SELECT 1 FROM posts variant
+			WHERE variant.translation_group_id = ` + postAlias + `.translation_group_id
+			AND variant.published = true AND variant.deleted_at IS NULL
+			AND variant.locale = ANY(` + chain + `)
+			AND array_position(` + chain + `, variant.locale) < array_position(` + chain + `, ` + postAlias + `.locale))`
+}
+
+// SiteLocales returns a site's locale configuration. Without a
+// default_locale setting the language setting is used, then FallbackLocale.
+func (s *SiteContentService) SiteLocales(siteRef string) (*SiteLocales, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return loadSiteLocales(s.db, siteID)
+}
+
+func loadSiteLocales(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, siteID string) (*SiteLocales, error) {
+	rows, err := q.Query(`
+		SELECT setting_key, COALESCE(setting_value, '') FROM site_settings
+		WHERE site_id = $1 AND setting_key IN ($2, $3, 'language')`, siteID, SettingDefaultLocale, SettingSupportedLocales)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load locale settings: %w", err)
+	}
+	defer rows.Close()
+
+	values := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan locale setting: %w", err)
+		}
+		values[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load locale settings: %w", err)
+	}
+
+	locales := &SiteLocales{Default: FallbackLocale}
+	for _, key := range []string{SettingDefaultLocale, "language"} {
+		if locale, ok := NormalizeLocale(values[key]); ok {
+			locales.Default = locale
+			break
+		}
+	}
+	supported, _ := parseLocaleList(values[SettingSupportedLocales])
+	locales.Supported = []string{locales.Default}
+	for _, locale := range supported {
+		if !containsString(locales.Supported, locale) {
+			locales.Supported = append(locales.Supported, locale)
+		}
+	}
+	return locales, nil
+}
+
+// parseLocaleList reads supported_locales as a JSON array or a
+// comma-separated list
+func parseLocaleList(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var items []string
+	if strings.HasPrefix(raw, "[") {
+		if err := json.Unmarshal([]byte(raw), &items); err != nil {
+			return nil, fmt.Errorf("must be a JSON array of language tags")
+		}
+	} else {
+		items = strings.Split(raw, ",")
+	}
+
+	locales := make([]string, 0, len(items))
+	for _, item := range items {
+		locale, ok := NormalizeLocale(item)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a valid language tag", strings.TrimSpace(item))
+		}
+		if !containsString(locales, locale) {
+			locales = append(locales, locale)
+		}
+	}
+	return locales, nil
+}
+
+// checkLocaleSetting validates the stored text of the locale settings
+func checkLocaleSetting(key, stored string) error {
+	switch key {
+	case SettingDefaultLocale:
+		if _, ok := NormalizeLocale(stored); !ok {
+			return fmt.Errorf("invalid value for setting %q: %q is not a valid language tag", key, stored)
+		}
+	case SettingSupportedLocales:
+		if _, err := parseLocaleList(stored); err != nil {
+			return fmt.Errorf("invalid value for setting %q: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// ResolvePostLocale checks a new or edited post's locale against its site's
+// configuration, defaulting an empty locale to the site default
+func ResolvePostLocale(db *sql.DB, siteID, locale string) (string, error) {
+	locales, err := loadSiteLocales(db, siteID)
+	if err != nil {
+		return "", err
+	}
+	return locales.Check(locale)
+}
+
+// CheckTranslationGroup verifies that a translation group belongs to the
+// site and has no live variant in locale yet
+func CheckTranslationGroup(db *sql.DB, siteID, groupID, locale string) error {
+	var variants int
+	var taken bool
+	err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(BOOL_OR(locale = $3), false) FROM posts
+		WHERE site_id = $1 AND translation_group_id::TEXT = $2 AND deleted_at IS NULL`,
+		siteID, groupID, locale).Scan(&variants, &taken)
+	if err != nil {
+		return fmt.Errorf("failed to check translation group: %w", err)
+	}
+	if variants == 0 {
+		return fmt.Errorf("translation group not found")
+	}
+	if taken {
+		return fmt.Errorf("a %s translation already exists in this translation group", locale)
+	}
+	return nil
+}
+
+// PostAlternates returns the published variants of each translation group,
+// keyed by group id, with an x-default entry for the site-default variant
+func (s *SiteContentService) PostAlternates(siteID string, groupIDs []string) (map[string][]PostAlternate, error) {
+	result := make(map[string][]PostAlternate, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return result, nil
+	}
+	locales, err := loadSiteLocales(s.db, siteID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT translation_group_id::TEXT, id::TEXT, locale, slug FROM posts
+		WHERE site_id = $1 AND translation_group_id::TEXT = ANY($2::TEXT[])
+		AND published = true AND deleted_at IS NULL
+		ORDER BY translation_group_id, locale`, siteID, pq.Array(groupIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load post alternates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupID string
+		var alternate PostAlternate
+		if err := rows.Scan(&groupID, &alternate.PostID, &alternate.Hreflang, &alternate.Slug); err != nil {
+			return nil, fmt.Errorf("failed to scan post alternate: %w", err)
+		}
+		alternate.Href = LocalizedPostPath(alternate.Slug, alternate.Hreflang, locales.Default)
+		result[groupID] = append(result[groupID], alternate)
+		if alternate.Hreflang == locales.Default {
+			xDefault := alternate
+			xDefault.Hreflang = XDefaultHreflang
+			result[groupID] = append(result[groupID], xDefault)
+		}
+	}
+	return result, rows.Err()
+}
+
+// ListTranslations returns every live variant in a post's translation
+// group, whatever its status
+func (s *SiteContentService) ListTranslations(siteRef, postID string) ([]PostTranslation, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT v.id::TEXT, v.locale, v.title, v.slug, COALESCE(v.status, ''), v.published, v.updated_at
+		FROM posts p
+		JOIN posts v ON v.translation_group_id = p.translation_group_id AND v.site_id = p.site_id
+		WHERE p.site_id = $1 AND p.id::TEXT = $2 AND p.deleted_at IS NULL AND v.deleted_at IS NULL
+		ORDER BY v.locale`, siteID, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list translations: %w", err)
+	}
+	defer rows.Close()
+
+	translations := []PostTranslation{}
+	for rows.Next() {
+		var translation PostTranslation
+		if err := rows.Scan(&translation.PostID, &translation.Locale, &translation.Title, &translation.Slug,
+			&translation.Status, &translation.Published, &translation.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan translation: %w", err)
+		}
+		translations = append(translations, translation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list translations: %w", err)
+	}
+	if len(translations) == 0 {
+		return nil, fmt.Errorf("post not found")
+	}
+	return translations, nil
+}
@@ -0,0 +1,1038 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultMaxUploadBytes = 25 << 20
+	defaultUploadTTL      = 24 * time.Hour
+	maxMediaPerPage       = 100
+)
+
+// allowedMediaTypes maps each accepted sniffed MIME type to the extension
+// stored objects get. SVG is deliberately absent: it can carry script.
+var allowedMediaTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+}
+
+// MediaService manages a site's media library: uploads, generated image
+// variants and the blob storage behind them
+type MediaService struct {
+	db             *sql.DB
+	logger         Logger
+	store          BlobStore
+	webp           WebPEncoder
+	maxUploadBytes int64
+	uploadTTL      time.Duration
+}
+
+// MediaAsset is an uploaded file and its generated renditions
+type MediaAsset struct {
+	ID        string          `json:"id"`
+	SiteID    string          `json:"site_id"`
+	Filename  string          `json:"filename"`
+	MimeType  string          `json:"mime_type"`
+	SizeBytes int64           `json:"size_bytes"`
+	Checksum  string          `json:"checksum_sha256"`
+	URL       string          `json:"url"`
+	Width     int             `json:"width,omitempty"`
+	Height    int             `json:"height,omitempty"`
+	AltText   string          `json:"alt_text"`
+	Title     string          `json:"title,omitempty"`
+	Variants  []*MediaVariant `json:"variants"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+
+	storageKey string
+}
+
+// MediaVariant is a resized or re-encoded rendition of an image asset
+type MediaVariant struct {
+	Name      string `json:"name"`
+	MimeType  string `json:"mime_type"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	SizeBytes int64  `json:"size_bytes"`
+	URL       string `json:"url"`
+
+	storageKey string
+}
+
+// MediaAssetInput carries the descriptive metadata supplied with an upload
+type MediaAssetInput struct {
+	Filename string `json:"filename"`
+	AltText  string `json:"alt_text"`
+	Title    string `json:"title"`
+}
+
+// MediaAssetUpdate changes an asset's metadata; nil fields are left as is
+type MediaAssetUpdate struct {
+	AltText *string `json:"alt_text"`
+	Title   *string `json:"title"`
+}
+
+// MediaUpload is an in-progress resumable upload
+type MediaUpload struct {
+	ID            string    `json:"id"`
+	SiteID        string    `json:"site_id"`
+	Filename      string    `json:"filename"`
+	MimeType      string    `json:"mime_type,omitempty"`
+	TotalBytes    int64     `json:"total_bytes"`
+	ReceivedBytes int64     `json:"received_bytes"`
+	AltText       string    `json:"alt_text,omitempty"`
+	Title         string    `json:"title,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// MediaUploadInput opens a resumable upload of Size bytes
+type MediaUploadInput struct {
+	MediaAssetInput
+	Size int64 `json:"size"`
+}
+
+// MediaQuery filters a media listing. Type is a MIME prefix such as
+// "image/" or an exact type; Search matches filename, title and alt text.
+type MediaQuery struct {
+	Type    string
+	Search  string
+	Page    int
+	PerPage int
+}
+
+// MediaPage is one page of a media listing
+type MediaPage struct {
+	Items      []*MediaAsset `json:"items"`
+	Page       int           `json:"page"`
+	PerPage    int           `json:"per_page"`
+	Total      int           `json:"total"`
+	TotalPages int           `json:"total_pages"`
+}
+
+// MediaUsage reports a customer's storage consumption across all its sites
+type MediaUsage struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+}
+
+// NewMediaService creates a media service storing blobs in store
+func NewMediaService(db *sql.DB, logger Logger, store BlobStore) *MediaService {
+	return &MediaService{
+		db:             db,
+		logger:         logger,
+		store:          store,
+		maxUploadBytes: defaultMaxUploadBytes,
+		uploadTTL:      defaultUploadTTL,
+	}
+}
+
+// SetMaxUploadBytes overrides the per-file size limit
+func (s *MediaService) SetMaxUploadBytes(limit int64) {
+	if limit > 0 {
+		s.maxUploadBytes = limit
+	}
+}
+
+// MaxUploadBytes returns the per-file size limit
+func (s *MediaService) MaxUploadBytes() int64 {
+	return s.maxUploadBytes
+}
+
+// SetWebPEncoder enables WebP variants; without an encoder only resized
+// copies in the original format are generated
+func (s *MediaService) SetWebPEncoder(encoder WebPEncoder) {
+	s.webp = encoder
+}
+
+// Store returns the blob store backing the library
+func (s *MediaService) Store() BlobStore {
+	return s.store
+}
+
+// sniffMediaType detects the MIME type from the leading bytes of r and
+// returns a reader that still yields the whole stream
+func sniffMediaType(r io.Reader) (string, io.Reader, error) {
+	buffered := bufio.NewReaderSize(r, 512)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	if len(head) == 0 {
+		return "", nil, fmt.Errorf("file is empty")
+	}
+	mimeType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	if _, ok := allowedMediaTypes[mimeType]; !ok {
+		return "", nil, fmt.Errorf("unsupported media type %s", mimeType)
+	}
+	return mimeType, buffered, nil
+}
+
+func (s *MediaService) checkSize(size int64) error {
+	if size <= 0 {
+		return fmt.Errorf("file size is required")
+	}
+	if size > s.maxUploadBytes {
+		return fmt.Errorf("file exceeds the maximum upload size of %d bytes", s.maxUploadBytes)
+	}
+	return nil
+}
+
+// Usage returns storage used by the site's customer against its quota
+func (s *MediaService) Usage(siteRef string) (*MediaUsage, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.usage(siteID)
+}
+
+// usage sums originals, variants and reserved resumable uploads over every
+// site belonging to the same customer; quotas come from
+// customers.max_storage_gb
+func (s *MediaService) usage(siteID string) (*MediaUsage, error) {
+	var quotaGB, used int64
+	err := s.db.QueryRow(`
+		WITH customer_sites AS (
+			SELECT id FROM sites WHERE customer_id = (SELECT customer_id FROM sites WHERE id = $1)
+		)
+		SELECT c.max_storage_gb,
+			(COALESCE((SELECT SUM(a.size_bytes) FROM media_assets a
+				WHERE a.site_id IN (SELECT id FROM customer_sites)), 0)
+			+ COALESCE((SELECT SUM(v.size_bytes) FROM media_variants v
+				JOIN media_assets a ON a.id = v.asset_id
+				WHERE a.site_id IN (SELECT id FROM customer_sites)), 0)
+			+ COALESCE((SELECT SUM(u.total_bytes) FROM media_uploads u
+				WHERE u.site_id IN (SELECT id FROM customer_sites) AND u.expires_at > NOW()), 0))::BIGINT
+		FROM sites s JOIN customers c ON c.id = s.customer_id
+		WHERE s.id = $1`, siteID).Scan(&quotaGB, &used)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("site not found")
+		}
+		return nil, fmt.Errorf("failed to calculate storage usage: %w", err)
+	}
+	return &MediaUsage{UsedBytes: used, QuotaBytes: quotaGB << 30}, nil
+}
+
+func (s *MediaService) checkQuota(siteID string, size int64) error {
+	usage, err := s.usage(siteID)
+	if err != nil {
+		return err
+	}
+	if usage.UsedBytes+size > usage.QuotaBytes {
+		return fmt.Errorf("storage quota exceeded: %d of %d bytes used", usage.UsedBytes, usage.QuotaBytes)
+	}
+	return nil
+}
+
+// Upload stores a file of size bytes read from r as a new asset. The MIME
+// type is sniffed from the content; the client's claim is ignored.
+func (s *MediaService) Upload(ctx context.Context, siteRef, userID string, input MediaAssetInput, r io.Reader, size int64) (*MediaAsset, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSize(size); err != nil {
+		return nil, err
+	}
+	mimeType, body, err := sniffMediaType(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(siteID, size); err != nil {
+		return nil, err
+	}
+	return s.createAsset(ctx, siteID, userID, input, mimeType, body, size)
+}
+
+// createAsset streams body into the store while hashing it, records the
+// asset and generates image variants
+func (s *MediaService) createAsset(ctx context.Context, siteID, userID string, input MediaAssetInput, mimeType string, body io.Reader, size int64) (*MediaAsset, error) {
+	asset := &MediaAsset{
+		ID:       uuid.NewString(),
+		SiteID:   siteID,
+		Filename: displayFilename(input.Filename, mimeType),
+		MimeType: mimeType,
+		AltText:  strings.TrimSpace(input.AltText),
+		Title:    strings.TrimSpace(input.Title),
+	}
+	asset.storageKey = path.Join("sites", siteID, "media", asset.ID, storageFilename(asset.Filename, mimeType))
+
+	hash := sha256.New()
+	counter := &countingReader{r: io.TeeReader(io.LimitReader(body, size+1), hash)}
+	if err := s.store.Put(ctx, asset.storageKey, counter, size, mimeType); err != nil {
+		if counter.n != size {
+			return nil, fmt.Errorf("upload size mismatch: received %d of %d bytes", counter.n, size)
+		}
+		return nil, fmt.Errorf("failed to store media: %w", err)
+	}
+	asset.SizeBytes = size
+	asset.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	keys := []string{asset.storageKey}
+	cleanup := func() {
+		for _, key := range keys {
+			s.store.Delete(context.Background(), key)
+		}
+	}
+
+	var variants []encodedImage
+	if strings.HasPrefix(mimeType, "image/") {
+		data, err := s.readBlob(ctx, asset.storageKey)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		width, height, ok := imageDimensions(data, mimeType)
+		if !ok {
+			cleanup()
+			return nil, fmt.Errorf("invalid image: unreadable dimensions")
+		}
+		asset.Width, asset.Height = width, height
+
+		if isResizableImage(mimeType) {
+			var webpErr error
+			variants, webpErr, err = generateImageVariants(ctx, bytes.NewReader(data), mimeType, s.webp)
+			if err != nil {
+				cleanup()
+				return nil, err
+			}
+			if webpErr != nil {
+				s.logger.Error("media_service", "webp_variant", "WebP encoding failed; continuing without WebP variants", map[string]interface{}{
+					"asset_id": asset.ID,
+					"error":    webpErr.Error(),
+				})
+			}
+		}
+	}
+
+	for _, encoded := range variants {
+		variant := &MediaVariant{
+			Name:       encoded.Name,
+			MimeType:   encoded.MimeType,
+			Width:      encoded.Width,
+			Height:     encoded.Height,
+			SizeBytes:  int64(len(encoded.Data)),
+			storageKey: path.Join("sites", siteID, "media", asset.ID, encoded.Name+encoded.Ext),
+		}
+		if err := s.store.Put(ctx, variant.storageKey, bytes.NewReader(encoded.Data), variant.SizeBytes, variant.MimeType); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to store %s variant: %w", encoded.Name, err)
+		}
+		keys = append(keys, variant.storageKey)
+		asset.Variants = append(asset.Variants, variant)
+	}
+
+	if err := s.insertAsset(asset, userID); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	s.logger.Info("media_service", "create_asset", "Media asset created", map[string]interface{}{
+		"asset_id":  asset.ID,
+		"site_id":   siteID,
+		"mime_type": mimeType,
+		"size":      size,
+		"variants":  len(asset.Variants),
+	})
+
+	return s.GetAsset(siteID, asset.ID)
+}
+
+func (s *MediaService) insertAsset(asset *MediaAsset, userID string) error {
+	var uploadedBy interface{}
+	if id, err := strconv.ParseInt(userID, 10, 64); err == nil {
+		uploadedBy = id
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO media_assets (id, site_id, filename, mime_type, size_bytes, checksum_sha256, storage_key,
+			width, height, alt_text, title, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		asset.ID, asset.SiteID, asset.Filename, asset.MimeType, asset.SizeBytes, asset.Checksum, asset.storageKey,
+		nullableInt(asset.Width), nullableInt(asset.Height), nullableString(asset.AltText), nullableString(asset.Title), uploadedBy)
+	if err != nil {
+		return fmt.Errorf("failed to create media asset: %w", err)
+	}
+	for _, variant := range asset.Variants {
+		_, err = tx.Exec(`
+			INSERT INTO media_variants (asset_id, name, mime_type, width, height, size_bytes, storage_key)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			asset.ID, variant.Name, variant.MimeType, variant.Width, variant.Height, variant.SizeBytes, variant.storageKey)
+		if err != nil {
+			return fmt.Errorf("failed to create media variant: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit media asset: %w", err)
+	}
+	return nil
+}
+
+// readBlob loads a stored object, bounded by the upload size limit
+func (s *MediaService) readBlob(ctx context.Context, key string) ([]byte, error) {
+	reader, err := s.store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, s.maxUploadBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	return data, nil
+}
+
+const mediaAssetSelect = `
+	SELECT id, site_id, filename, mime_type, size_bytes, checksum_sha256, storage_key,
+		COALESCE(width, 0), COALESCE(height, 0), COALESCE(alt_text, ''), COALESCE(title, ''),
+		created_at, updated_at
+	FROM media_assets`
+
+func (s *MediaService) scanAsset(row rowScanner) (*MediaAsset, error) {
+	asset := &MediaAsset{Variants: []*MediaVariant{}}
+	err := row.Scan(&asset.ID, &asset.SiteID, &asset.Filename, &asset.MimeType, &asset.SizeBytes, &asset.Checksum,
+		&asset.storageKey, &asset.Width, &asset.Height, &asset.AltText, &asset.Title, &asset.CreatedAt, &asset.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("media asset not found")
+		}
+		return nil, fmt.Errorf("failed to scan media asset: %w", err)
+	}
+	asset.URL = s.store.URL(asset.storageKey)
+	return asset, nil
+}
+
+// attachVariants loads the variants of assets in one query
+func (s *MediaService) attachVariants(assets []*MediaAsset) error {
+	if len(assets) == 0 {
+		return nil
+	}
+	byID := make(map[string]*MediaAsset, len(assets))
+	ids := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		byID[asset.ID] = asset
+		ids = append(ids, asset.ID)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT asset_id, name, mime_type, width, height, size_bytes, storage_key
+		FROM media_variants
+		WHERE asset_id::TEXT = ANY($1)
+		ORDER BY asset_id, width, name`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to load media variants: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var assetID string
+		variant := &MediaVariant{}
+		if err := rows.Scan(&assetID, &variant.Name, &variant.MimeType, &variant.Width, &variant.Height,
+			&variant.SizeBytes, &variant.storageKey); err != nil {
+			return fmt.Errorf("failed to scan media variant: %w", err)
+		}
+		variant.URL = s.store.URL(variant.storageKey)
+		if asset := byID[assetID]; asset != nil {
+			asset.Variants = append(asset.Variants, variant)
+		}
+	}
+	return rows.Err()
+}
+
+// GetAsset returns one asset with its variants
+func (s *MediaService) GetAsset(siteRef, assetID string) (*MediaAsset, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	asset, err := s.scanAsset(s.db.QueryRow(mediaAssetSelect+` WHERE site_id = $1 AND id::TEXT = $2`, siteID, assetID))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachVariants([]*MediaAsset{asset}); err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// ListAssets returns a page of a site's media, newest first
+func (s *MediaService) ListAssets(siteRef string, query MediaQuery) (*MediaPage, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PerPage < 1 {
+		query.PerPage = 20
+	}
+	if query.PerPage > maxMediaPerPage {
+		query.PerPage = maxMediaPerPage
+	}
+
+	args := []interface{}{siteID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+	conditions := []string{"site_id = $1"}
+	if query.Type != "" {
+		if strings.HasSuffix(query.Type, "/") {
+			conditions = append(conditions, "mime_type LIKE "+arg(likePrefix(query.Type)+"%"))
+		} else {
+			conditions = append(conditions, "mime_type = "+arg(query.Type))
+		}
+	}
+	for _, term := range strings.Fields(strings.ToLower(query.Search)) {
+		pattern := arg("%" + likePrefix(term) + "%")
+		conditions = append(conditions, "(LOWER(filename) LIKE "+pattern+
+			" OR LOWER(COALESCE(title, '')) LIKE "+pattern+" OR LOWER(COALESCE(alt_text, '')) LIKE "+pattern+")")
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM media_assets WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count media: %w", err)
+	}
+
+	limit := arg(query.PerPage)
+	offset := arg((query.Page - 1) * query.PerPage)
+	rows, err := s.db.Query(mediaAssetSelect+`
+		WHERE `+where+`
+		ORDER BY created_at DESC, id
+		LIMIT `+limit+` OFFSET `+offset, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media: %w", err)
+	}
+	defer rows.Close()
+
+	page := &MediaPage{
+		Items:      []*MediaAsset{},
+		Page:       query.Page,
+		PerPage:    query.PerPage,
+		Total:      total,
+		TotalPages: (total + query.PerPage - 1) / query.PerPage,
+	}
+	for rows.Next() {
+		asset, err := s.scanAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, asset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list media: %w", err)
+	}
+
+	if err := s.attachVariants(page.Items); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// MediaFile is one stored file of a site's media library, an original or
+// one of its variants. Version changes whenever the file's content does.
+type MediaFile struct {
+	Key     string
+	URL     string
+	Version string
+}
+
+// SiteFiles lists every stored file of a site's media library
+func (s *MediaService) SiteFiles(siteRef string) ([]MediaFile, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	// Variants are regenerated only along with their original, so the
+	// original's checksum versions them too
+	rows, err := s.db.Query(`
+		SELECT storage_key, checksum_sha256 FROM media_assets WHERE site_id = $1
+		UNION ALL
+		SELECT v.storage_key, a.checksum_sha256 || ':' || v.name || ':' || v.size_bytes
+		FROM media_variants v
+		JOIN media_assets a ON a.id = v.asset_id
+		WHERE a.site_id = $1
+		ORDER BY 1`, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []MediaFile
+	for rows.Next() {
+		var file MediaFile
+		if err := rows.Scan(&file.Key, &file.Version); err != nil {
+			return nil, fmt.Errorf("failed to scan media file: %w", err)
+		}
+		file.URL = s.store.URL(file.Key)
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// UpdateAsset changes an asset's alt text or title
+func (s *MediaService) UpdateAsset(siteRef, assetID string, update MediaAssetUpdate) (*MediaAsset, error) {
+	asset, err := s.GetAsset(siteRef, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if update.AltText != nil {
+		asset.AltText = strings.TrimSpace(*update.AltText)
+	}
+	if update.Title != nil {
+		asset.Title = strings.TrimSpace(*update.Title)
+	}
+	if len(asset.Title) > 255 {
+		return nil, fmt.Errorf("title must be at most 255 characters")
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE media_assets SET alt_text = $3, title = $4, updated_at = NOW()
+		WHERE site_id = $1 AND id = $2`,
+		asset.SiteID, asset.ID, nullableString(asset.AltText), nullableString(asset.Title))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update media asset: %w", err)
+	}
+
+	return s.GetAsset(asset.SiteID, asset.ID)
+}
+
+// DeleteAsset removes an asset, its variants and their stored objects
+func (s *MediaService) DeleteAsset(ctx context.Context, siteRef, assetID string) error {
+	asset, err := s.GetAsset(siteRef, assetID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(`DELETE FROM media_assets WHERE site_id = $1 AND id = $2`, asset.SiteID, asset.ID); err != nil {
+		return fmt.Errorf("failed to delete media asset: %w", err)
+	}
+
+	// Rows are gone, so a failed object delete only leaks storage; log it
+	keys := []string{asset.storageKey}
+	for _, variant := range asset.Variants {
+		keys = append(keys, variant.storageKey)
+	}
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil {
+			s.logger.Error("media_service", "delete_asset", "Failed to delete stored object", map[string]interface{}{
+				"asset_id": asset.ID,
+				"key":      key,
+				"error":    err.Error(),
+			})
+		}
+	}
+
+	s.logger.Info("media_service", "delete_asset", "Media asset deleted", map[string]interface{}{
+		"asset_id": asset.ID,
+		"site_id":  asset.SiteID,
+	})
+	return nil
+}
+
+// CreateUpload opens a resumable upload. The declared size is reserved
+// against the quota until the upload completes, is aborted or expires.
+func (s *MediaService) CreateUpload(siteRef, userID string, input MediaUploadInput) (*MediaUpload, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSize(input.Size); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(input.Filename) == "" {
+		return nil, fmt.Errorf("filename is required")
+	}
+	if err := s.checkQuota(siteID, input.Size); err != nil {
+		return nil, err
+	}
+
+	var createdBy interface{}
+	if id, err := strconv.ParseInt(userID, 10, 64); err == nil {
+		createdBy = id
+	}
+
+	var uploadID string
+	err = s.db.QueryRow(`
+		INSERT INTO media_uploads (site_id, filename, total_bytes, alt_text, title, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		siteID, displayFilename(input.Filename, ""), input.Size, nullableString(strings.TrimSpace(input.AltText)),
+		nullableString(strings.TrimSpace(input.Title)), createdBy, time.Now().Add(s.uploadTTL)).Scan(&uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return s.getUpload(siteID, uploadID)
+}
+
+// GetUpload returns an upload's progress so a client can resume it
+func (s *MediaService) GetUpload(siteRef, uploadID string) (*MediaUpload, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.getUpload(siteID, uploadID)
+}
+
+func (s *MediaService) getUpload(siteID, uploadID string) (*MediaUpload, error) {
+	upload := &MediaUpload{}
+	err := s.db.QueryRow(`
+		SELECT id, site_id, filename, COALESCE(mime_type, ''), total_bytes, received_bytes,
+			COALESCE(alt_text, ''), COALESCE(title, ''), created_at, expires_at
+		FROM media_uploads
+		WHERE site_id = $1 AND id::TEXT = $2 AND expires_at > NOW()`, siteID, uploadID).Scan(
+		&upload.ID, &upload.SiteID, &upload.Filename, &upload.MimeType, &upload.TotalBytes, &upload.ReceivedBytes,
+		&upload.AltText, &upload.Title, &upload.CreatedAt, &upload.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("upload not found")
+		}
+		return nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+	return upload, nil
+}
+
+// AppendUpload stores the chunk of length bytes that starts at offset.
+// Chunks must arrive in order: an offset other than the bytes received so
+// far is rejected so the client can re-sync with GetUpload.
+func (s *MediaService) AppendUpload(ctx context.Context, siteRef, uploadID string, offset int64, r io.Reader, length int64) (*MediaUpload, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	upload, err := s.getUpload(siteID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.ReceivedBytes {
+		return nil, fmt.Errorf("upload offset mismatch: expected %d", upload.ReceivedBytes)
+	}
+	if length <= 0 {
+		return nil, fmt.Errorf("chunk length is required")
+	}
+	if offset+length > upload.TotalBytes {
+		return nil, fmt.Errorf("chunk exceeds the declared upload size of %d bytes", upload.TotalBytes)
+	}
+
+	body := r
+	var mimeType interface{}
+	if offset == 0 {
+		sniffed, buffered, err := sniffMediaType(r)
+		if err != nil {
+			return nil, err
+		}
+		mimeType, body = sniffed, buffered
+	}
+
+	key := path.Join("uploads", upload.ID, fmt.Sprintf("%020d", offset))
+	counter := &countingReader{r: io.LimitReader(body, length+1)}
+	if err := s.store.Put(ctx, key, counter, length, "application/octet-stream"); err != nil {
+		if counter.n != length {
+			return nil, fmt.Errorf("upload size mismatch: received %d of %d bytes", counter.n, length)
+		}
+		return nil, fmt.Errorf("failed to store upload chunk: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.store.Delete(context.Background(), key)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The received_bytes guard loses the race cleanly if two clients send
+	// the same chunk concurrently
+	result, err := tx.Exec(`
+		UPDATE media_uploads
+		SET received_bytes = received_bytes + $3, mime_type = COALESCE($4, mime_type), updated_at = NOW()
+		WHERE id = $1 AND received_bytes = $2`, upload.ID, offset, length, mimeType)
+	if err != nil {
+		s.store.Delete(context.Background(), key)
+		return nil, fmt.Errorf("failed to record upload chunk: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		tx.Rollback()
+		s.store.Delete(context.Background(), key)
+		return nil, fmt.Errorf("upload offset mismatch: chunk at %d was already received", offset)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO media_upload_parts (upload_id, byte_offset, size_bytes, storage_key)
+		VALUES ($1, $2, $3, $4)`, upload.ID, offset, length, key)
+	if err != nil {
+		s.store.Delete(context.Background(), key)
+		return nil, fmt.Errorf("failed to record upload chunk: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		s.store.Delete(context.Background(), key)
+		return nil, fmt.Errorf("failed to commit upload chunk: %w", err)
+	}
+
+	return s.getUpload(siteID, upload.ID)
+}
+
+// CompleteUpload assembles a fully received upload into a media asset
+func (s *MediaService) CompleteUpload(ctx context.Context, siteRef, uploadID, userID string) (*MediaAsset, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	upload, err := s.getUpload(siteID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.ReceivedBytes != upload.TotalBytes {
+		return nil, fmt.Errorf("upload is not complete: received %d of %d bytes", upload.ReceivedBytes, upload.TotalBytes)
+	}
+
+	keys, err := s.uploadPartKeys(upload.ID)
+	if err != nil {
+		return nil, err
+	}
+	parts := &blobSequenceReader{ctx: ctx, store: s.store, keys: keys}
+	defer parts.Close()
+
+	asset, err := s.createAsset(ctx, siteID, userID, MediaAssetInput{
+		Filename: upload.Filename,
+		AltText:  upload.AltText,
+		Title:    upload.Title,
+	}, upload.MimeType, parts, upload.TotalBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	s.discardUpload(upload.ID, keys)
+	return asset, nil
+}
+
+// AbortUpload discards an upload and any chunks received
+func (s *MediaService) AbortUpload(siteRef, uploadID string) error {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return err
+	}
+	upload, err := s.getUpload(siteID, uploadID)
+	if err != nil {
+		return err
+	}
+	keys, err := s.uploadPartKeys(upload.ID)
+	if err != nil {
+		return err
+	}
+	s.discardUpload(upload.ID, keys)
+	return nil
+}
+
+func (s *MediaService) uploadPartKeys(uploadID string) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT storage_key FROM media_upload_parts
+		WHERE upload_id = $1
+		ORDER BY byte_offset`, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upload chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan upload chunk: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *MediaService) discardUpload(uploadID string, keys []string) {
+	if _, err := s.db.Exec(`DELETE FROM media_uploads WHERE id = $1`, uploadID); err != nil {
+		s.logger.Error("media_service", "discard_upload", "Failed to delete upload", map[string]interface{}{
+			"upload_id": uploadID,
+			"error":     err.Error(),
+		})
+	}
+	for _, key := range keys {
+		s.store.Delete(context.Background(), key)
+	}
+}
+
+// PurgeExpiredUploads discards resumable uploads past their expiry
+func (s *MediaService) PurgeExpiredUploads() (int, error) {
+	rows, err := s.db.Query(`SELECT id FROM media_uploads WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list expired uploads: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan expired upload: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		keys, err := s.uploadPartKeys(id)
+		if err != nil {
+			return 0, err
+		}
+		s.discardUpload(id, keys)
+	}
+
+	if len(ids) > 0 {
+		s.logger.Info("media_service", "purge_uploads", "Expired uploads purged", map[string]interface{}{
+			"purged": len(ids),
+		})
+	}
+	return len(ids), nil
+}
+
+// RunUploadPurge purges expired uploads every interval until the context is cancelled
+func (s *MediaService) RunUploadPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.PurgeExpiredUploads()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// displayFilename keeps the base name a client supplied, falling back to
+// a generic name with the sniffed extension
+func displayFilename(filename, mimeType string) string {
+	name := strings.TrimSpace(path.Base(strings.ReplaceAll(filename, `\`, "/")))
+	if name == "" || name == "." || name == "/" {
+		name = "upload" + allowedMediaTypes[mimeType]
+	}
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+	return name
+}
+
+// storageFilename derives a URL-safe object name whose extension always
+// matches the sniffed type, so stores serving by extension never mislabel it
+func storageFilename(filename, mimeType string) string {
+	stem := Slugify(strings.TrimSuffix(filename, path.Ext(filename)))
+	if stem == "" {
+		stem = "file"
+	}
+	if len(stem) > 100 {
+		stem = strings.Trim(stem[:100], "-")
+	}
+	return stem + allowedMediaTypes[mimeType]
+}
+
+// MediaTypeForKey returns the MIME type of a stored object from the
+// extension storageFilename gave it
+func MediaTypeForKey(key string) string {
+	ext := strings.ToLower(path.Ext(key))
+	for mimeType, known := range allowedMediaTypes {
+		if known == ext {
+			return mimeType
+		}
+	}
+	return "application/octet-stream"
+}
+
+func nullableInt(value int) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
+
+// countingReader records how many bytes have been read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// blobSequenceReader reads stored objects back to back, opening each only
+// when the previous one is exhausted
+type blobSequenceReader struct {
+	ctx     context.Context
+	store   BlobStore
+	keys    []string
+	current io.ReadCloser
+}
+
+func (b *blobSequenceReader) Read(p []byte) (int, error) {
+	for {
+		if b.current == nil {
+			if len(b.keys) == 0 {
+				return 0, io.EOF
+			}
+			reader, err := b.store.Get(b.ctx, b.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			b.current, b.keys = reader, b.keys[1:]
+		}
+		n, err := b.current.Read(p)
+		if err == io.EOF {
+			b.current.Close()
+			b.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (b *blobSequenceReader) Close() error {
+	if b.current != nil {
+		return b.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Notification is one event in the notification outbox. Events without a
+// recipient concern the whole site.
+type Notification struct {
+	ID           string                 `json:"id"`
+	SiteID       string                 `json:"site_id,omitempty"`
+	Type         string                 `json:"type"`
+	RecipientID  string                 `json:"recipient_id,omitempty"`
+	ActorID      string                 `json:"actor_id,omitempty"`
+	ResourceType string                 `json:"resource_type,omitempty"`
+	ResourceID   string                 `json:"resource_id,omitempty"`
+	Payload      map[string]interface{} `json:"payload"`
+	ReadAt       *time.Time             `json:"read_at,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// NotificationService reads and acknowledges a user's notifications
+type NotificationService struct {
+	db     *sql.DB
+	logger Logger
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(db *sql.DB, logger Logger) *NotificationService {
+	return &NotificationService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// RaiseNotification appends an event to the outbox, inside the caller's
+// transaction when one is passed
+func RaiseNotification(db execer, notification Notification) error {
+	payload := notification.Payload
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO notification_events (site_id, event_type, recipient_id, actor_id, resource_type, resource_id, payload)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		nullableString(notification.SiteID), notification.Type, userIDParam(notification.RecipientID),
+		userIDParam(notification.ActorID), nullableString(notification.ResourceType),
+		nullableString(notification.ResourceID), string(encoded))
+	if err != nil {
+		return fmt.Errorf("failed to raise notification: %w", err)
+	}
+	return nil
+}
+
+// ListForUser returns a user's notifications newest first
+func (s *NotificationService) ListForUser(userID string, unreadOnly bool, limit int) ([]*Notification, error) {
+	if _, err := strconv.ParseInt(userID, 10, 64); err != nil {
+		return nil, fmt.Errorf("user id is required")
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, COALESCE(site_id::TEXT, ''), event_type, COALESCE(recipient_id::TEXT, ''),
+		COALESCE(actor_id::TEXT, ''), COALESCE(resource_type, ''), COALESCE(resource_id, ''),
+		payload, read_at, created_at
+		FROM notification_events
+		WHERE recipient_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT $3`, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+	for rows.Next() {
+		notification := &Notification{}
+		var payload []byte
+		var readAt sql.NullTime
+		err := rows.Scan(&notification.ID, &notification.SiteID, &notification.Type, &notification.RecipientID,
+			&notification.ActorID, &notification.ResourceType, &notification.ResourceID, &payload,
+			&readAt, &notification.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		if err := json.Unmarshal(payload, &notification.Payload); err != nil {
+			return nil, fmt.Errorf("failed to decode notification: %w", err)
+		}
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkRead acknowledges one of the user's notifications
+func (s *NotificationService) MarkRead(userID, notificationID string) error {
+	if _, err := strconv.ParseInt(userID, 10, 64); err != nil {
+		return fmt.Errorf("user id is required")
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE notification_events SET read_at = COALESCE(read_at, NOW())
+		WHERE id::TEXT = $1 AND recipient_id = $2`, notificationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("notification not found")
+	}
+	return nil
+}
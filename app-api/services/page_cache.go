@@ -0,0 +1,118 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedPage is a rendered response held by PageCache
+type CachedPage struct {
+	Body         []byte
+	ContentType  string
+	ContentLang  string
+	ETag         string
+	LastModified time.Time
+	expires      time.Time
+}
+
+// PageCache is an in-memory cache of rendered pages with a fixed lifetime.
+// Keys start with the site ID so a site's pages can be purged together.
+type PageCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*CachedPage
+}
+
+// NewPageCache creates a cache holding up to maxEntries pages for ttl each;
+// a ttl of zero or less disables caching
+func NewPageCache(ttl time.Duration, maxEntries int) *PageCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &PageCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*CachedPage),
+	}
+}
+
+// PageCacheKey builds a cache key for a site's page from the parts that
+// vary its output
+func PageCacheKey(siteID string, parts ...string) string {
+	return siteID + "|" + strings.Join(parts, "|")
+}
+
+// Get returns an unexpired page
+func (c *PageCache) Get(key string) (*CachedPage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	page, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(page.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return page, true
+}
+
+// Set stores a page, filling in its ETag, and evicts expired entries (then
+// the entry closest to expiry) when the cache is full
+func (c *PageCache) Set(key string, page *CachedPage) {
+	sum := sha256.Sum256(page.Body)
+	page.ETag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	page.expires = now.Add(c.ttl)
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		var oldestKey string
+		var oldest time.Time
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+				continue
+			}
+			if oldestKey == "" || entry.expires.Before(oldest) {
+				oldestKey, oldest = k, entry.expires
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			delete(c.entries, oldestKey)
+		}
+	}
+	c.entries[key] = page
+}
+
+// PurgeSite drops every cached page of a site and returns how many there were;
+// an empty site ID empties the cache. Services that change what public pages
+// show call it after committing, and may hold a nil cache.
+func (c *PageCache) PurgeSite(siteID string) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if siteID == "" {
+		purged := len(c.entries)
+		c.entries = make(map[string]*CachedPage)
+		return purged
+	}
+	purged := 0
+	for key := range c.entries {
+		if strings.HasPrefix(key, siteID+"|") {
+			delete(c.entries, key)
+			purged++
+		}
+	}
+	return purged
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Preview token scopes
+const (
+	PreviewScopePost = "post"
+	PreviewScopeSite = "site"
+)
+
+const (
+	defaultPreviewTTL = 72 * time.Hour
+	maxPreviewTTL     = 30 * 24 * time.Hour
+)
+
+// PreviewService issues and checks preview tokens: signed links that let
+// someone without an account read one unpublished post, or every post of a
+// site, until the link expires or is revoked. Only the token's id is
+// stored; the signature is recomputed from the server secret on each use.
+type PreviewService struct {
+	db     *sql.DB
+	logger Logger
+	secret []byte
+}
+
+// PreviewToken is the stored state of a preview link. Token, the link
+// credential itself, is only filled in when the token is created.
+type PreviewToken struct {
+	ID         string     `json:"id"`
+	SiteID     string     `json:"site_id"`
+	PostID     string     `json:"post_id,omitempty"`
+	Scope      string     `json:"scope"`
+	Label      string     `json:"label,omitempty"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	Token      string     `json:"token,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	UseCount   int        `json:"use_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// PreviewTokenInput describes a token to create. Without a post id the
+// token covers the whole site. ExpiresIn is a duration such as "48h".
+type PreviewTokenInput struct {
+	PostID    string `json:"post_id"`
+	Label     string `json:"label"`
+	ExpiresIn string `json:"expires_in"`
+}
+
+// NewPreviewService creates a new preview service that signs tokens with
+// secret. An empty secret gets a random one, so tokens stop working when
+// the process restarts.
+func NewPreviewService(db *sql.DB, logger Logger, secret []byte) *PreviewService {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	return &PreviewService{
+		db:     db,
+		logger: logger,
+		secret: secret,
+	}
+}
+
+// CreateToken issues a preview token for a site or one of its posts
+func (s *PreviewService) CreateToken(siteRef, userID string, input PreviewTokenInput) (*PreviewToken, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := defaultPreviewTTL
+	if input.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(input.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("expires_in must be a positive duration such as 48h")
+		}
+		if ttl > maxPreviewTTL {
+			return nil, fmt.Errorf("expires_in may be at most %s", maxPreviewTTL)
+		}
+	}
+
+	scope := PreviewScopeSite
+	if input.PostID != "" {
+		scope = PreviewScopePost
+		var exists bool
+		err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM posts WHERE id::TEXT = $1 AND site_id = $2 AND deleted_at IS NULL)`,
+			input.PostID, siteID).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check post: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("post not found")
+		}
+	}
+
+	token := &PreviewToken{
+		SiteID:    siteID,
+		PostID:    input.PostID,
+		Scope:     scope,
+		Label:     strings.TrimSpace(input.Label),
+		CreatedBy: userID,
+		ExpiresAt: time.Now().Add(ttl).Truncate(time.Second),
+	}
+	err = s.db.QueryRow(`
+		INSERT INTO preview_tokens (site_id, post_id, scope, label, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`, siteID, nullableString(token.PostID), scope, nullableString(token.Label),
+		userIDParam(userID), token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create preview token: %w", err)
+	}
+	token.Token = s.sign(token.ID, token.ExpiresAt)
+
+	s.audit(AuditEntry{
+		UserID:       userID,
+		Action:       "preview_token.created",
+		ResourceType: "preview_token",
+		ResourceID:   token.ID,
+		Details: map[string]interface{}{
+			"site_id":    siteID,
+			"post_id":    token.PostID,
+			"scope":      scope,
+			"expires_at": token.ExpiresAt,
+		},
+	})
+
+	s.logger.Info("preview_service", "create_token", "Preview token created", map[string]interface{}{
+		"site_id":  siteID,
+		"token_id": token.ID,
+		"scope":    scope,
+	})
+
+	return token, nil
+}
+
+// ListTokens returns a site's preview tokens newest first, optionally only
+// those for one post. Token strings are not included.
+func (s *PreviewService) ListTokens(siteRef, postID string) ([]*PreviewToken, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(previewTokenSelect+`
+		WHERE site_id = $1 AND ($2 = '' OR post_id::TEXT = $2)
+		ORDER BY created_at DESC`, siteID, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list preview tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []*PreviewToken{}
+	for rows.Next() {
+		token, err := scanPreviewToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeToken makes a token unusable immediately
+func (s *PreviewService) RevokeToken(siteRef, tokenID, userID string) error {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE preview_tokens SET revoked_at = NOW()
+		WHERE site_id = $1 AND id::TEXT = $2 AND revoked_at IS NULL`, siteID, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke preview token: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("preview token not found")
+	}
+
+	s.audit(AuditEntry{
+		UserID:       userID,
+		Action:       "preview_token.revoked",
+		ResourceType: "preview_token",
+		ResourceID:   tokenID,
+		Details:      map[string]interface{}{"site_id": siteID},
+	})
+
+	s.logger.Info("preview_service", "revoke_token", "Preview token revoked", map[string]interface{}{
+		"site_id":  siteID,
+		"token_id": tokenID,
+	})
+	return nil
+}
+
+// Authorize checks that raw is a valid, unexpired, unrevoked token covering
+// the post. Errors start with "preview token" so callers can answer 401 or
+// 403 without leaking which check failed beyond that.
+func (s *PreviewService) Authorize(raw, siteRef, postID string) (*PreviewToken, error) {
+	tokenID, expires, ok := s.verify(raw)
+	if !ok {
+		return nil, fmt.Errorf("preview token is invalid")
+	}
+	if time.Now().After(expires) {
+		return nil, fmt.Errorf("preview token has expired")
+	}
+
+	token, err := scanPreviewToken(s.db.QueryRow(previewTokenSelect+` WHERE id::TEXT = $1`, tokenID))
+	if err != nil {
+		if err.Error() == "preview token not found" {
+			return nil, fmt.Errorf("preview token is invalid")
+		}
+		return nil, err
+	}
+	if !token.ExpiresAt.Equal(expires) {
+		return nil, fmt.Errorf("preview token is invalid")
+	}
+	if token.RevokedAt != nil {
+		return nil, fmt.Errorf("preview token has been revoked")
+	}
+
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil || siteID != token.SiteID || (token.Scope == PreviewScopePost && token.PostID != postID) {
+		return nil, fmt.Errorf("preview token does not cover this post")
+	}
+	return token, nil
+}
+
+// RecordUse counts a successful preview and writes it to audit_logs
+func (s *PreviewService) RecordUse(token *PreviewToken, postID, ipAddress, userAgent string) {
+	if _, err := s.db.Exec(`UPDATE preview_tokens SET use_count = use_count + 1, last_used_at = NOW() WHERE id = $1`,
+		token.ID); err != nil {
+		s.logger.Error("preview_service", "record_use", "Failed to count preview token use", map[string]interface{}{
+			"token_id": token.ID,
+			"error":    err.Error(),
+		})
+	}
+
+	s.audit(AuditEntry{
+		Action:       "preview_token.used",
+		ResourceType: "post",
+		ResourceID:   postID,
+		Details: map[string]interface{}{
+			"token_id": token.ID,
+			"site_id":  token.SiteID,
+			"scope":    token.Scope,
+		},
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+}
+
+// audit records an audit entry; a failure is logged rather than failing
+// the request it describes
+func (s *PreviewService) audit(entry AuditEntry) {
+	if err := RecordAudit(s.db, entry); err != nil {
+		s.logger.Error("preview_service", "audit", "Failed to write audit log", map[string]interface{}{
+			"action": entry.Action,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// sign builds "<token id>.<expiry unix seconds>.<signature>"
+func (s *PreviewService) sign(tokenID string, expires time.Time) string {
+	payload := tokenID + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+// verify checks a token's signature and returns the id and expiry it carries
+func (s *PreviewService) verify(raw string) (string, time.Time, bool) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return "", time.Time{}, false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, s.mac(parts[0]+"."+parts[1])) {
+		return "", time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return parts[0], time.Unix(unix, 0), true
+}
+
+func (s *PreviewService) mac(payload string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("preview:" + payload))
+	return mac.Sum(nil)
+}
+
+const previewTokenSelect = `
+	SELECT id, site_id, COALESCE(post_id::TEXT, ''), scope, COALESCE(label, ''), COALESCE(created_by::TEXT, ''),
+	expires_at, revoked_at, last_used_at, use_count, created_at
+	FROM preview_tokens`
+
+func scanPreviewToken(row rowScanner) (*PreviewToken, error) {
+	token := &PreviewToken{}
+	var revokedAt, lastUsedAt sql.NullTime
+	err := row.Scan(&token.ID, &token.SiteID, &token.PostID, &token.Scope, &token.Label, &token.CreatedBy,
+		&token.ExpiresAt, &revokedAt, &lastUsedAt, &token.UseCount, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("preview token not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve preview token: %w", err)
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return token, nil
+}
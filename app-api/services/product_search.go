@@ -0,0 +1,488 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Field weights used when scoring product matches
+var productFieldWeights = map[string]float64{
+	"sku":         8,
+	"name":        5,
+	"category":    3,
+	"benefits":    2,
+	"ingredients": 2,
+	"description": 1,
+}
+
+// Price bands used for the price facet, keyed by label
+var productPriceBands = []struct {
+	Label string
+	Min   float64
+	Max   float64
+}{
+	{"under_25", 0, 25},
+	{"25_to_50", 25, 50},
+	{"50_to_100", 50, 100},
+	{"100_and_over", 100, math.MaxFloat64},
+}
+
+var searchStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "the": true, "to": true, "with": true, "your": true,
+}
+
+// ProductSearchQuery describes a catalog search request
+type ProductSearchQuery struct {
+	Query    string
+	Category string
+	InStock  *bool
+	MinPrice *float64
+	MaxPrice *float64
+	Sort     string // relevance, price_asc, price_desc, name
+	Page     int
+	PerPage  int
+}
+
+// ProductSearchHit is a single ranked search result
+type ProductSearchHit struct {
+	ThorneProduct
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// ProductSearchFacets holds counts for the refinement filters
+type ProductSearchFacets struct {
+	Category   map[string]int `json:"category"`
+	InStock    map[string]int `json:"in_stock"`
+	PriceBands map[string]int `json:"price_band"`
+}
+
+// ProductSearchResult is a page of search hits with facet counts
+type ProductSearchResult struct {
+	Query      string              `json:"query"`
+	Hits       []ProductSearchHit  `json:"hits"`
+	Facets     ProductSearchFacets `json:"facets"`
+	Total      int                 `json:"total"`
+	Page       int                 `json:"page"`
+	PerPage    int                 `json:"per_page"`
+	TotalPages int                 `json:"total_pages"`
+}
+
+// productDocument is the indexed form of a product
+type productDocument struct {
+	product      ThorneProduct
+	categoryName string
+	fields       map[string]string
+	terms        map[string]map[string]int // field -> stemmed term -> frequency
+}
+
+// ProductIndex is an in-memory inverted index over the product catalog
+type ProductIndex struct {
+	docs     []productDocument
+	postings map[string]map[int]bool // stemmed term -> document positions
+	builtAt  time.Time
+}
+
+// NewProductIndex indexes name, description, benefits, ingredients, SKU and category
+func NewProductIndex(products []ThorneProduct, categories []ThorneCategory) *ProductIndex {
+	categoryNames := make(map[string]string)
+	for _, category := range categories {
+		categoryNames[category.ID] = category.Name
+	}
+
+	index := &ProductIndex{
+		postings: make(map[string]map[int]bool),
+		builtAt:  time.Now(),
+	}
+
+	for i, product := range products {
+		doc := productDocument{
+			product:      product,
+			categoryName: categoryNames[product.Category],
+			fields: map[string]string{
+				"sku":         product.SKU,
+				"name":        product.Name,
+				"category":    strings.TrimSpace(product.Category + " " + categoryNames[product.Category]),
+				"benefits":    strings.Join(product.Benefits, ". "),
+				"ingredients": strings.Join(product.Ingredients, ", "),
+				"description": product.Description,
+			},
+			terms: make(map[string]map[string]int),
+		}
+
+		for field, text := range doc.fields {
+			counts := make(map[string]int)
+			for _, token := range tokenize(text) {
+				term := stem(token)
+				counts[term]++
+				if index.postings[term] == nil {
+					index.postings[term] = make(map[int]bool)
+				}
+				index.postings[term][i] = true
+			}
+			doc.terms[field] = counts
+		}
+
+		index.docs = append(index.docs, doc)
+	}
+
+	return index
+}
+
+// Search ranks, filters, facets and paginates the catalog
+func (idx *ProductIndex) Search(q ProductSearchQuery) *ProductSearchResult {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 || q.PerPage > 100 {
+		q.PerPage = 20
+	}
+
+	// Expand each query token to the index terms it matches, allowing for typos
+	var queryTerms [][]weightedTerm
+	tokens := tokenize(q.Query)
+	if len(tokens) == 0 && strings.TrimSpace(q.Query) != "" {
+		// A query of only stop words or punctuation has nothing to match;
+		// only an empty query browses the whole catalog
+		queryTerms = append(queryTerms, nil)
+	}
+	for _, token := range tokens {
+		if expanded := idx.expandTerm(token); len(expanded) > 0 {
+			queryTerms = append(queryTerms, expanded)
+		} else {
+			// A token that matches nothing means nothing can match every token
+			queryTerms = append(queryTerms, nil)
+		}
+	}
+
+	var matches []ProductSearchHit
+	for i, doc := range idx.docs {
+		score, matched, ok := idx.scoreDocument(i, doc, queryTerms)
+		if !ok {
+			continue
+		}
+		hit := ProductSearchHit{ThorneProduct: doc.product, Score: math.Round(score*1000) / 1000}
+		if len(matched) > 0 {
+			hit.Highlights = highlightFields(doc.fields, matched)
+		}
+		matches = append(matches, hit)
+	}
+
+	result := &ProductSearchResult{
+		Query:   q.Query,
+		Page:    q.Page,
+		PerPage: q.PerPage,
+		Hits:    []ProductSearchHit{},
+		Facets: ProductSearchFacets{
+			Category:   make(map[string]int),
+			InStock:    make(map[string]int),
+			PriceBands: make(map[string]int),
+		},
+	}
+
+	// Facets count the text matches before refinements, so every option stays visible
+	for _, hit := range matches {
+		result.Facets.Category[hit.Category]++
+		result.Facets.InStock[fmt.Sprintf("%t", hit.InStock)]++
+		result.Facets.PriceBands[priceBand(hit.RetailPrice)]++
+	}
+
+	var filtered []ProductSearchHit
+	for _, hit := range matches {
+		if q.Category != "" && !strings.EqualFold(hit.Category, q.Category) {
+			continue
+		}
+		if q.InStock != nil && hit.InStock != *q.InStock {
+			continue
+		}
+		if q.MinPrice != nil && hit.RetailPrice < *q.MinPrice {
+			continue
+		}
+		if q.MaxPrice != nil && hit.RetailPrice > *q.MaxPrice {
+			continue
+		}
+		filtered = append(filtered, hit)
+	}
+
+	sortHits(filtered, q.Sort, strings.TrimSpace(q.Query) != "")
+
+	result.Total = len(filtered)
+	result.TotalPages = (result.Total + q.PerPage - 1) / q.PerPage
+	start := (q.Page - 1) * q.PerPage
+	if start < len(filtered) {
+		end := start + q.PerPage
+		if end > len(filtered) {
+			end = len(filtered)
+		}
+		result.Hits = filtered[start:end]
+	}
+
+	return result
+}
+
+// weightedTerm is an index term matched by a query token, discounted for typos
+type weightedTerm struct {
+	term   string
+	weight float64
+}
+
+// expandTerm finds the index terms a query token should match
+func (idx *ProductIndex) expandTerm(token string) []weightedTerm {
+	term := stem(token)
+	if _, ok := idx.postings[term]; ok {
+		return []weightedTerm{{term: term, weight: 1}}
+	}
+
+	var expanded []weightedTerm
+	for candidate := range idx.postings {
+		// Prefix matches support search-as-you-type
+		if len(term) >= 3 && strings.HasPrefix(candidate, term) {
+			expanded = append(expanded, weightedTerm{term: candidate, weight: 0.8})
+			continue
+		}
+		if distance := levenshtein(term, candidate); distance <= typoTolerance(term) {
+			expanded = append(expanded, weightedTerm{term: candidate, weight: 1 - 0.25*float64(distance)})
+		}
+	}
+	return expanded
+}
+
+// scoreDocument returns a TF-IDF score when the document matches every query token
+func (idx *ProductIndex) scoreDocument(pos int, doc productDocument, queryTerms [][]weightedTerm) (float64, map[string]bool, bool) {
+	matched := make(map[string]bool)
+	if len(queryTerms) == 0 {
+		return 0, matched, true
+	}
+
+	total := 0.0
+	for _, alternatives := range queryTerms {
+		best := 0.0
+		for _, alt := range alternatives {
+			if !idx.postings[alt.term][pos] {
+				continue
+			}
+			idf := math.Log(1 + float64(len(idx.docs))/float64(len(idx.postings[alt.term])))
+			score := 0.0
+			for field, weight := range productFieldWeights {
+				if tf := doc.terms[field][alt.term]; tf > 0 {
+					score += weight * (1 + math.Log(float64(tf)))
+				}
+			}
+			score *= idf * alt.weight
+			if score > 0 {
+				matched[alt.term] = true
+			}
+			if score > best {
+				best = score
+			}
+		}
+		if best == 0 {
+			return 0, nil, false
+		}
+		total += best
+	}
+
+	return total, matched, true
+}
+
+// sortHits orders hits by the requested sort, defaulting to relevance
+func sortHits(hits []ProductSearchHit, sortBy string, hasQuery bool) {
+	byName := func(i, j int) bool { return strings.ToLower(hits[i].Name) < strings.ToLower(hits[j].Name) }
+
+	switch sortBy {
+	case "price_asc":
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].RetailPrice < hits[j].RetailPrice })
+	case "price_desc":
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].RetailPrice > hits[j].RetailPrice })
+	case "name":
+		sort.SliceStable(hits, byName)
+	default:
+		if !hasQuery {
+			sort.SliceStable(hits, byName)
+			return
+		}
+		sort.SliceStable(hits, func(i, j int) bool {
+			if hits[i].Score != hits[j].Score {
+				return hits[i].Score > hits[j].Score
+			}
+			return byName(i, j)
+		})
+	}
+}
+
+// highlightFields wraps matched words in <mark> for each field that matched
+func highlightFields(fields map[string]string, matched map[string]bool) map[string][]string {
+	highlights := make(map[string][]string)
+	for field, text := range fields {
+		if snippet, ok := highlightText(text, matched); ok {
+			highlights[field] = []string{snippet}
+		}
+	}
+	return highlights
+}
+
+// highlightText escapes text and marks the words whose stems were matched
+func highlightText(text string, matched map[string]bool) (string, bool) {
+	var b strings.Builder
+	found := false
+	word := []rune{}
+
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		w := string(word)
+		if matched[stem(strings.ToLower(w))] {
+			b.WriteString("<mark>" + html.EscapeString(w) + "</mark>")
+			found = true
+		} else {
+			b.WriteString(html.EscapeString(w))
+		}
+		word = word[:0]
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteString(html.EscapeString(string(r)))
+	}
+	flush()
+
+	return b.String(), found
+}
+
+// tokenize lowercases text and splits it into words, dropping stop words
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := words[:0]
+	for _, word := range words {
+		if !searchStopWords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// stem strips common English suffixes so "supports" and "supporting" match "support"
+func stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+	suffixes := []struct{ suffix, replacement string }{
+		{"ational", "ate"}, {"ization", "ize"}, {"fulness", "ful"},
+		{"iveness", "ive"}, {"ements", ""}, {"ement", ""}, {"ments", ""}, {"ment", ""},
+		{"ities", "ity"}, {"ation", "ate"}, {"ness", ""}, {"ing", ""}, {"ies", "y"},
+		{"ied", "y"}, {"edly", ""}, {"ly", ""}, {"ed", ""}, {"es", ""}, {"s", ""},
+	}
+	for _, s := range suffixes {
+		if strings.HasSuffix(word, s.suffix) && len(word)-len(s.suffix) >= 3 {
+			if s.suffix == "s" && strings.HasSuffix(word, "ss") {
+				return word
+			}
+			return word[:len(word)-len(s.suffix)] + s.replacement
+		}
+	}
+	return word
+}
+
+// typoTolerance is the edit distance allowed for a term of this length
+func typoTolerance(term string) int {
+	switch {
+	case len(term) >= 8:
+		return 2
+	case len(term) >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// priceBand returns the facet label for a retail price
+func priceBand(price float64) string {
+	for _, band := range productPriceBands {
+		if price >= band.Min && price < band.Max {
+			return band.Label
+		}
+	}
+	return productPriceBands[len(productPriceBands)-1].Label
+}
+
+// productIndex returns the cached index, rebuilding it when the catalog files change
+func (s *ThorneService) productIndex() (*ProductIndex, error) {
+	modTime := time.Time{}
+	for _, name := range []string{"thorne-products.json", "thorne-categories.json"} {
+		info, err := os.Stat(filepath.Join(s.configPath, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat config file %s: %v", name, err)
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	if s.index != nil && !modTime.After(s.indexModTime) {
+		return s.index, nil
+	}
+
+	products, err := s.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	s.index = NewProductIndex(products, categories)
+	s.indexModTime = modTime
+	return s.index, nil
+}
+
+// SearchCatalog runs a ranked, faceted search over the product catalog
+func (s *ThorneService) SearchCatalog(q ProductSearchQuery) (*ProductSearchResult, error) {
+	index, err := s.productIndex()
+	if err != nil {
+		return nil, err
+	}
+	return index.Search(q), nil
+}
@@ -0,0 +1,32 @@
+package services
+
+import "testing"
+
+func TestProductIndexSearchQueries(t *testing.T) {
+	index := NewProductIndex([]ThorneProduct{
+		{ID: "p1", Name: "Magnesium Bisglycinate", Description: "Supports restful sleep", Category: "minerals", InStock: true, RetailPrice: 40},
+		{ID: "p2", Name: "Vitamin D-5000", Description: "Supports bone health", Category: "vitamins", InStock: true, RetailPrice: 20},
+	}, []ThorneCategory{{ID: "minerals", Name: "Minerals"}, {ID: "vitamins", Name: "Vitamins"}})
+
+	tests := []struct {
+		name  string
+		query string
+		total int
+	}{
+		{"empty query browses the catalog", "", 2},
+		{"blank query browses the catalog", "   ", 2},
+		{"matching term", "magnesium", 1},
+		{"stop words only", "the and for", 0},
+		{"punctuation only", "?!", 0},
+		{"stop words around a term", "for the bones", 1},
+		{"unknown term", "zinc", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := index.Search(ProductSearchQuery{Query: tt.query})
+			if result.Total != tt.total || len(result.Hits) != tt.total {
+				t.Errorf("Search(%q) total = %d with %d hits, want %d", tt.query, result.Total, len(result.Hits), tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// schedulerBatchSize caps how many posts one instance claims per pass
+const schedulerBatchSize = 100
+
+// PublishScheduler publishes and unpublishes posts whose publish_at or
+// unpublish_at has passed. On sites with the editorial workflow enabled
+// only approved posts are published. Rows are claimed with FOR UPDATE SKIP LOCKED and
+// the schedule column is cleared as the transition is applied, so several
+// API instances can run the scheduler at once without double-firing.
+type PublishScheduler struct {
+	db        *sql.DB
+	logger    Logger
+	interval  time.Duration
+	pageCache *PageCache
+}
+
+// ScheduleRunResult reports what one scheduler pass changed
+type ScheduleRunResult struct {
+	Published   []string `json:"published"`
+	Unpublished []string `json:"unpublished"`
+}
+
+// NewPublishScheduler creates a scheduler that polls every interval
+func NewPublishScheduler(db *sql.DB, logger Logger, interval time.Duration) *PublishScheduler {
+	return &PublishScheduler{
+		db:       db,
+		logger:   logger,
+		interval: interval,
+	}
+}
+
+// SetPageCache sets the public page cache to purge when a pass publishes or
+// unpublishes posts
+func (s *PublishScheduler) SetPageCache(cache *PageCache) {
+	s.pageCache = cache
+}
+
+// Run polls until the context is cancelled
+func (s *PublishScheduler) Run(ctx context.Context) {
+	s.logger.Info("publish_scheduler", "start", "Publish scheduler started", map[string]interface{}{
+		"interval_seconds": s.interval.Seconds(),
+	})
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunOnce(ctx); err != nil {
+			s.logger.Error("publish_scheduler", "run", "Scheduler pass failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce applies every transition that is due. Publishing runs first so a
+// post whose whole window has already passed ends up unpublished.
+func (s *PublishScheduler) RunOnce(ctx context.Context) (*ScheduleRunResult, error) {
+	now := time.Now()
+	result := &ScheduleRunResult{Published: []string{}, Unpublished: []string{}}
+
+	for {
+		ids, err := s.transition(ctx, `
+			SELECT id, site_id FROM posts
+			WHERE publish_at IS NOT NULL AND publish_at <= $1 AND deleted_at IS NULL
+			AND (status = 'approved' OR NOT EXISTS (
+				SELECT 1 FROM site_workflows w WHERE w.site_id = posts.site_id AND w.enabled))
+			ORDER BY publish_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED`, `
+			UPDATE posts
+			SET status = 'published', published = true,
+			published_at = COALESCE(published_at, publish_at), publish_at = NULL, updated_at = $2
+			WHERE id = ANY($1) AND publish_at IS NOT NULL`, now)
+		if err != nil {
+			return result, fmt.Errorf("failed to publish scheduled posts: %w", err)
+		}
+		result.Published = append(result.Published, ids...)
+		if len(ids) < schedulerBatchSize {
+			break
+		}
+	}
+
+	for {
+		ids, err := s.transition(ctx, `
+			SELECT id, site_id FROM posts
+			WHERE unpublish_at IS NOT NULL AND unpublish_at <= $1 AND deleted_at IS NULL
+			ORDER BY unpublish_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED`, `
+			UPDATE posts
+			SET status = 'archived', published = false, unpublish_at = NULL, updated_at = $2
+			WHERE id = ANY($1) AND unpublish_at IS NOT NULL`, now)
+		if err != nil {
+			return result, fmt.Errorf("failed to unpublish scheduled posts: %w", err)
+		}
+		result.Unpublished = append(result.Unpublished, ids...)
+		if len(ids) < schedulerBatchSize {
+			break
+		}
+	}
+
+	if len(result.Published) > 0 || len(result.Unpublished) > 0 {
+		s.logger.Info("publish_scheduler", "run", "Scheduled transitions applied", map[string]interface{}{
+			"published":   result.Published,
+			"unpublished": result.Unpublished,
+		})
+	}
+
+	return result, nil
+}
+
+// transition claims due rows and applies the update in one transaction, then
+// purges the cached pages of every site it touched. The claim query selects
+// each post's id and site_id.
+func (s *PublishScheduler) transition(ctx context.Context, claimQuery, updateQuery string, now time.Time) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, claimQuery, now, schedulerBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	siteIDs := make(map[string]bool)
+	for rows.Next() {
+		var id int64
+		var siteID sql.NullString
+		if err := rows.Scan(&id, &siteID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		siteIDs[siteID.String] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, updateQuery, pq.Array(ids), now); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	for siteID := range siteIDs {
+		s.pageCache.PurgeSite(siteID)
+	}
+
+	claimed := make([]string, len(ids))
+	for i, id := range ids {
+		claimed[i] = fmt.Sprintf("%d", id)
+	}
+	return claimed, nil
+}
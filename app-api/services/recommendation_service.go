@@ -0,0 +1,578 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Recommendation statuses
+const (
+	RecommendationDraft    = "draft"
+	RecommendationApproved = "approved"
+	RecommendationSent     = "sent"
+)
+
+// ErrIntakeNotFound is returned for patients who haven't filled in an intake
+var ErrIntakeNotFound = errors.New("intake not found")
+
+// PatientIntake is the structured health questionnaire kept for each patient
+type PatientIntake struct {
+	PatientID           string    `json:"patient_id"`
+	Goals               []string  `json:"goals"`
+	Allergies           []string  `json:"allergies"`
+	CurrentMedications  []string  `json:"current_medications"`
+	DietaryRestrictions []string  `json:"dietary_restrictions"`
+	Notes               string    `json:"notes,omitempty"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// ProductSuggestion is one ranked product in a recommendation
+type ProductSuggestion struct {
+	ProductID   string   `json:"product_id"`
+	ProductName string   `json:"product_name"`
+	Category    string   `json:"category"`
+	RetailPrice float64  `json:"retail_price"`
+	InStock     bool     `json:"in_stock"`
+	Score       float64  `json:"score"`
+	Reasons     []string `json:"reasons"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// ExcludedProduct records why a product was left out of a recommendation
+type ExcludedProduct struct {
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Reason      string `json:"reason"`
+}
+
+// Recommendation is a set of suggestions awaiting practitioner approval
+type Recommendation struct {
+	ID                 string              `json:"id"`
+	PatientID          string              `json:"patient_id"`
+	Status             string              `json:"status"`
+	Suggestions        []ProductSuggestion `json:"suggestions"`
+	Excluded           []ExcludedProduct   `json:"excluded,omitempty"`
+	ApprovedProductIDs []string            `json:"approved_product_ids,omitempty"`
+	PractitionerNote   string              `json:"practitioner_note,omitempty"`
+	CreatedAt          time.Time           `json:"created_at"`
+	ApprovedAt         *time.Time          `json:"approved_at,omitempty"`
+	SentAt             *time.Time          `json:"sent_at,omitempty"`
+}
+
+// goalKeywords maps intake goals to the benefit vocabulary used in the catalog
+var goalKeywords = map[string][]string{
+	"immune":    {"immune", "antioxidant", "seasonal", "defense"},
+	"energy":    {"energy", "fatigue", "stamina", "metabolic", "metabolism", "vitality"},
+	"recovery":  {"recovery", "muscle", "joint", "inflammation", "inflammatory"},
+	"sports":    {"performance", "endurance", "strength", "exercise", "stamina"},
+	"cognitive": {"cognitive", "memory", "focus", "mental", "clarity", "concentration", "neuroprotection"},
+	"sleep":     {"sleep", "relaxation", "stress"},
+	"stress":    {"stress", "relaxation", "calm"},
+	"digestive": {"digestive", "gut", "microbiome", "absorption", "enzyme"},
+	"heart":     {"heart", "cardiovascular"},
+	"bone":      {"bone", "calcium", "joint"},
+}
+
+// goalAliases normalizes free-text goal words to goalKeywords keys
+var goalAliases = map[string]string{
+	"immunity": "immune", "cold": "immune", "flu": "immune",
+	"tired": "energy", "fatigue": "energy", "vitality": "energy",
+	"muscle": "recovery", "joint": "recovery", "inflammation": "recovery",
+	"athletic": "sports", "sport": "sports", "performance": "sports", "fitness": "sports",
+	"memory": "cognitive", "focus": "cognitive", "brain": "cognitive", "cognition": "cognitive",
+	"insomnia": "sleep", "anxiety": "stress",
+	"gut": "digestive", "digestion": "digestive", "bloating": "digestive",
+	"cardiovascular": "heart", "cholesterol": "heart",
+	"bones": "bone", "osteoporosis": "bone",
+}
+
+// genericGoalWords are too broad to match benefits on their own
+var genericGoalWords = map[string]bool{
+	"health": true, "healthy": true, "support": true, "better": true, "improve": true,
+	"improved": true, "general": true, "overall": true, "wellness": true, "optimization": true,
+	"optimize": true, "more": true, "less": true, "want": true, "need": true, "help": true,
+}
+
+// allergenIngredients lists ingredient words that indicate a common allergen.
+// Keys and words are singular; see singularTerm.
+var allergenIngredients = map[string][]string{
+	"fish":      {"fish", "epa", "dha", "anchovy", "sardine", "cod", "salmon", "krill"},
+	"shellfish": {"shellfish", "krill", "shrimp", "crab", "lobster", "glucosamine"},
+	"dairy":     {"milk", "whey", "casein", "lactose", "dairy"},
+	"milk":      {"milk", "whey", "casein", "lactose", "dairy"},
+	"soy":       {"soy", "soybean", "soya"},
+	"gluten":    {"gluten", "wheat", "barley", "rye"},
+	"wheat":     {"wheat", "gluten"},
+	"egg":       {"egg", "albumin"},
+	"peanut":    {"peanut"},
+	"tree nut":  {"almond", "cashew", "walnut", "pecan", "hazelnut"},
+	"nut":       {"nut", "peanut", "almond", "cashew", "walnut", "pecan", "hazelnut"},
+	"sunflower": {"sunflower"},
+	"lemon":     {"lemon", "citrus"},
+	"citrus":    {"lemon", "citrus", "orange"},
+}
+
+// dietaryExclusions lists singular ingredient words incompatible with a
+// dietary restriction
+var dietaryExclusions = map[string][]string{
+	"vegan":       {"fish", "epa", "dha", "gelatin", "whey", "casein", "milk", "lactose", "beeswax", "krill"},
+	"vegetarian":  {"fish", "epa", "dha", "gelatin", "krill"},
+	"pescatarian": {"gelatin"},
+	"dairy-free":  {"milk", "whey", "casein", "lactose"},
+	"gluten-free": {"gluten", "wheat", "barley", "rye"},
+}
+
+// medicationInteractions flags ingredients to review with a medication
+var medicationInteractions = map[string][]string{
+	"warfarin":       {"vitamin k", "k2", "epa", "dha", "omega", "coq10"},
+	"levothyroxine":  {"calcium", "magnesium", "iron"},
+	"antibiotic":     {"magnesium", "calcium", "zinc", "probiotic", "lactobacillus"},
+	"blood thinner":  {"vitamin k", "k2", "epa", "dha", "omega"},
+	"ssri":           {"5-htp", "st. john"},
+	"bisphosphonate": {"calcium", "magnesium"},
+}
+
+// GetPatientIntake returns the stored intake for a patient
+func (s *ThorneService) GetPatientIntake(patientID string) (*PatientIntake, error) {
+	intakes, err := s.loadIntakes()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, intake := range intakes {
+		if intake.PatientID == patientID {
+			return &intake, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w for patient: %s", ErrIntakeNotFound, patientID)
+}
+
+// SavePatientIntake validates and stores a patient's intake, replacing any previous one
+func (s *ThorneService) SavePatientIntake(intake PatientIntake) (*PatientIntake, error) {
+	if _, err := s.GetPatientByID(intake.PatientID); err != nil {
+		return nil, err
+	}
+
+	intake.Goals = cleanList(intake.Goals)
+	intake.Allergies = cleanList(intake.Allergies)
+	intake.CurrentMedications = cleanList(intake.CurrentMedications)
+	intake.DietaryRestrictions = cleanList(intake.DietaryRestrictions)
+	if len(intake.Goals) == 0 {
+		return nil, fmt.Errorf("at least one health goal is required")
+	}
+	intake.UpdatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intakes, err := s.loadIntakes()
+	if err != nil {
+		return nil, err
+	}
+
+	replaced := false
+	for i := range intakes {
+		if intakes[i].PatientID == intake.PatientID {
+			intakes[i] = intake
+			replaced = true
+		}
+	}
+	if !replaced {
+		intakes = append(intakes, intake)
+	}
+
+	if err := s.saveJSON("thorne-intakes.json", map[string]interface{}{"intakes": intakes}); err != nil {
+		return nil, err
+	}
+	return &intake, nil
+}
+
+// RecommendProducts ranks catalog products for a patient's intake. Patients
+// without an intake fall back to the free-text HealthGoals from registration;
+// any other failure to load the intake is returned, since recommending
+// without the patient's allergies could suggest products they must avoid.
+func (s *ThorneService) RecommendProducts(patientID string) (*Recommendation, error) {
+	patient, err := s.GetPatientByID(patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	intake, err := s.GetPatientIntake(patientID)
+	if errors.Is(err, ErrIntakeNotFound) {
+		intake = &PatientIntake{PatientID: patientID, Goals: []string{patient.HealthGoals}}
+	} else if err != nil {
+		return nil, err
+	}
+
+	products, err := s.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	recommendation := scoreRecommendations(intake, products, categories)
+	recommendation.PatientID = patientID
+	return recommendation, nil
+}
+
+// CreateRecommendation generates and stores a draft recommendation for practitioner review
+func (s *ThorneService) CreateRecommendation(patientID string) (*Recommendation, error) {
+	recommendation, err := s.RecommendProducts(patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recommendations, err := s.loadRecommendations()
+	if err != nil {
+		return nil, err
+	}
+
+	recommendation.ID = fmt.Sprintf("rec-%03d", len(recommendations)+1)
+	recommendation.Status = RecommendationDraft
+	recommendation.CreatedAt = time.Now()
+
+	recommendations = append(recommendations, *recommendation)
+	if err := s.saveRecommendations(recommendations); err != nil {
+		return nil, err
+	}
+	return recommendation, nil
+}
+
+// GetRecommendationsByPatient returns stored recommendations for a patient, newest first
+func (s *ThorneService) GetRecommendationsByPatient(patientID string) ([]Recommendation, error) {
+	recommendations, err := s.loadRecommendations()
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []Recommendation
+	for _, recommendation := range recommendations {
+		if recommendation.PatientID == patientID {
+			filtered = append(filtered, recommendation)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].CreatedAt.After(filtered[j].CreatedAt)
+	})
+
+	return filtered, nil
+}
+
+// ApproveRecommendation records the products the practitioner chose to recommend
+func (s *ThorneService) ApproveRecommendation(id string, productIDs []string, note string) (*Recommendation, error) {
+	return s.updateRecommendation(id, func(rec *Recommendation) error {
+		if rec.Status == RecommendationSent {
+			return fmt.Errorf("recommendation %s has already been sent", id)
+		}
+
+		suggested := make(map[string]bool)
+		for _, suggestion := range rec.Suggestions {
+			suggested[suggestion.ProductID] = true
+		}
+		if len(productIDs) == 0 {
+			return fmt.Errorf("at least one product must be approved")
+		}
+		for _, productID := range productIDs {
+			if !suggested[productID] {
+				return fmt.Errorf("product %s is not part of recommendation %s", productID, id)
+			}
+		}
+
+		now := time.Now()
+		rec.Status = RecommendationApproved
+		rec.ApprovedProductIDs = productIDs
+		rec.PractitionerNote = note
+		rec.ApprovedAt = &now
+		return nil
+	})
+}
+
+// SendRecommendation marks an approved recommendation as sent to the patient
+func (s *ThorneService) SendRecommendation(id string) (*Recommendation, error) {
+	return s.updateRecommendation(id, func(rec *Recommendation) error {
+		if rec.Status != RecommendationApproved {
+			return fmt.Errorf("recommendation %s must be approved before it is sent", id)
+		}
+		now := time.Now()
+		rec.Status = RecommendationSent
+		rec.SentAt = &now
+		return nil
+	})
+}
+
+// updateRecommendation applies change to a stored recommendation and saves it
+func (s *ThorneService) updateRecommendation(id string, change func(*Recommendation) error) (*Recommendation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recommendations, err := s.loadRecommendations()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range recommendations {
+		if recommendations[i].ID != id {
+			continue
+		}
+		if err := change(&recommendations[i]); err != nil {
+			return nil, err
+		}
+		if err := s.saveRecommendations(recommendations); err != nil {
+			return nil, err
+		}
+		return &recommendations[i], nil
+	}
+
+	return nil, fmt.Errorf("recommendation not found: %s", id)
+}
+
+// scoreRecommendations maps intake answers onto category and product benefits
+func scoreRecommendations(intake *PatientIntake, products []ThorneProduct, categories []ThorneCategory) *Recommendation {
+	goalTerms := make(map[string]string) // stemmed keyword -> goal it came from
+	for _, goal := range intake.Goals {
+		for _, key := range goalKeys(goal) {
+			for _, keyword := range goalKeywords[key] {
+				goalTerms[stem(keyword)] = key
+			}
+		}
+		// Words in the goal itself count too, so unmapped goals still match text
+		for _, token := range tokenize(goal) {
+			if genericGoalWords[token] {
+				continue
+			}
+			if _, exists := goalTerms[stem(token)]; !exists {
+				goalTerms[stem(token)] = goal
+			}
+		}
+	}
+
+	categoryByID := make(map[string]ThorneCategory)
+	for _, category := range categories {
+		categoryByID[category.ID] = category
+	}
+
+	recommendation := &Recommendation{Suggestions: []ProductSuggestion{}}
+	for _, product := range products {
+		if reason := exclusionReason(product, intake); reason != "" {
+			recommendation.Excluded = append(recommendation.Excluded, ExcludedProduct{
+				ProductID:   product.ID,
+				ProductName: product.Name,
+				Reason:      reason,
+			})
+			continue
+		}
+
+		score := 0.0
+		reasons := make(map[string]bool)
+		category := categoryByID[product.Category]
+
+		for _, benefit := range product.Benefits {
+			if goal := matchGoal(benefit, goalTerms); goal != "" {
+				score += 2
+				reasons[fmt.Sprintf("%s (%s)", benefit, goal)] = true
+			}
+		}
+		for _, benefit := range category.Benefits {
+			if goal := matchGoal(benefit, goalTerms); goal != "" {
+				score += 1
+				reasons[fmt.Sprintf("%s category: %s (%s)", category.Name, benefit, goal)] = true
+			}
+		}
+		if goal := matchGoal(product.Category+" "+category.Name, goalTerms); goal != "" {
+			score += 1.5
+		}
+		if score == 0 {
+			continue
+		}
+		if !product.InStock {
+			score *= 0.5
+		}
+
+		suggestion := ProductSuggestion{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Category:    product.Category,
+			RetailPrice: product.RetailPrice,
+			InStock:     product.InStock,
+			Score:       score,
+			Warnings:    interactionWarnings(product, intake.CurrentMedications),
+		}
+		for reason := range reasons {
+			suggestion.Reasons = append(suggestion.Reasons, reason)
+		}
+		sort.Strings(suggestion.Reasons)
+		recommendation.Suggestions = append(recommendation.Suggestions, suggestion)
+	}
+
+	sort.SliceStable(recommendation.Suggestions, func(i, j int) bool {
+		a, b := recommendation.Suggestions[i], recommendation.Suggestions[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.ProductName < b.ProductName
+	})
+
+	return recommendation
+}
+
+// goalKeys returns the goalKeywords keys a free-text goal refers to
+func goalKeys(goal string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, token := range tokenize(goal) {
+		key := token
+		if alias, ok := goalAliases[token]; ok {
+			key = alias
+		}
+		if _, ok := goalKeywords[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+	return keys
+}
+
+// matchGoal returns the goal whose keywords appear in text, if any
+func matchGoal(text string, goalTerms map[string]string) string {
+	for _, token := range tokenize(text) {
+		if goal, ok := goalTerms[stem(token)]; ok {
+			return goal
+		}
+	}
+	return ""
+}
+
+// singularTerm reduces a lowercase word to its singular form, so "peanuts",
+// "eggs" and "anchovies" match "peanut", "egg" and "anchovy". Unlike stem it
+// leaves singular words alone, which keeps both sides of a comparison equal.
+func singularTerm(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 4 && (strings.HasSuffix(word, "ches") || strings.HasSuffix(word, "shes") ||
+		strings.HasSuffix(word, "sses") || strings.HasSuffix(word, "xes") || strings.HasSuffix(word, "oes")):
+		return word[:len(word)-2]
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// singularPhrase lowercases text and makes each of its words singular
+func singularPhrase(text string) string {
+	words := strings.Fields(strings.ToLower(text))
+	for i, word := range words {
+		words[i] = singularTerm(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// exclusionReason explains why a product conflicts with allergies or diet, or returns ""
+func exclusionReason(product ThorneProduct, intake *PatientIntake) string {
+	ingredientText := strings.ToLower(product.Name + " " + strings.Join(product.Ingredients, " "))
+	ingredientTokens := make(map[string]bool)
+	for _, token := range tokenize(ingredientText) {
+		ingredientTokens[singularTerm(token)] = true
+	}
+
+	for _, allergy := range intake.Allergies {
+		key := singularPhrase(allergy)
+		words := append([]string{}, allergenIngredients[key]...)
+		for _, token := range tokenize(key) {
+			words = append(words, singularTerm(token))
+		}
+		for _, word := range words {
+			if ingredientTokens[word] {
+				return fmt.Sprintf("contains %s (allergy: %s)", word, allergy)
+			}
+		}
+	}
+
+	for _, restriction := range intake.DietaryRestrictions {
+		key := strings.ToLower(strings.TrimSpace(restriction))
+		if _, ok := dietaryExclusions[key]; !ok {
+			key = singularPhrase(key)
+		}
+		for _, word := range dietaryExclusions[key] {
+			if ingredientTokens[word] {
+				return fmt.Sprintf("contains %s (dietary restriction: %s)", word, restriction)
+			}
+		}
+	}
+
+	return ""
+}
+
+// interactionWarnings flags ingredients to review against current medications
+func interactionWarnings(product ThorneProduct, medications []string) []string {
+	ingredientText := strings.ToLower(strings.Join(product.Ingredients, " "))
+
+	var warnings []string
+	for _, medication := range medications {
+		lower := strings.ToLower(medication)
+		for drug, ingredients := range medicationInteractions {
+			if !strings.Contains(lower, drug) {
+				continue
+			}
+			for _, ingredient := range ingredients {
+				if strings.Contains(ingredientText, ingredient) {
+					warnings = append(warnings, fmt.Sprintf("review %s with %s", ingredient, medication))
+				}
+			}
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// cleanList trims entries and drops blanks and duplicates
+func cleanList(items []string) []string {
+	cleaned := []string{}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[strings.ToLower(item)] {
+			continue
+		}
+		seen[strings.ToLower(item)] = true
+		cleaned = append(cleaned, item)
+	}
+	return cleaned
+}
+
+// loadIntakes reads the intake file, treating a missing file as empty
+func (s *ThorneService) loadIntakes() ([]PatientIntake, error) {
+	var data struct {
+		Intakes []PatientIntake `json:"intakes"`
+	}
+	if err := s.loadOptionalJSON("thorne-intakes.json", &data); err != nil {
+		return nil, err
+	}
+	return data.Intakes, nil
+}
+
+// loadRecommendations reads the recommendations file, treating a missing file as empty
+func (s *ThorneService) loadRecommendations() ([]Recommendation, error) {
+	var data struct {
+		Recommendations []Recommendation `json:"recommendations"`
+	}
+	if err := s.loadOptionalJSON("thorne-recommendations.json", &data); err != nil {
+		return nil, err
+	}
+	return data.Recommendations, nil
+}
+
+func (s *ThorneService) saveRecommendations(recommendations []Recommendation) error {
+	return s.saveJSON("thorne-recommendations.json", map[string]interface{}{"recommendations": recommendations})
+}
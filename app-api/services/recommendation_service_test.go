@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExclusionReasonMatchesPlurals(t *testing.T) {
+	tests := []struct {
+		name        string
+		ingredients []string
+		allergies   []string
+		diet        []string
+		excluded    bool
+	}{
+		{"plural ingredient", []string{"Peanuts", "Honey"}, []string{"peanut"}, nil, true},
+		{"plural allergy", []string{"Peanut flour"}, []string{"Peanuts"}, nil, true},
+		{"plural egg", []string{"Whole Eggs"}, []string{"eggs"}, nil, true},
+		{"tree nuts", []string{"Almonds"}, []string{"Tree Nuts"}, nil, true},
+		{"nuts", []string{"Walnuts"}, []string{"nuts"}, nil, true},
+		{"nuts covers peanuts", []string{"Roasted peanuts"}, []string{"nuts"}, nil, true},
+		{"es plural", []string{"Sardines"}, []string{"fish"}, nil, true},
+		{"ies plural", []string{"Anchovies"}, []string{"fish"}, nil, true},
+		{"dietary plural", []string{"Gelatins", "Rice"}, nil, []string{"Vegetarian"}, true},
+		{"plural restriction", []string{"Gelatin"}, nil, []string{"vegetarians"}, true},
+		{"safe product", []string{"Magnesium citrate", "Rice flour"}, []string{"peanuts", "eggs"}, []string{"vegan"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := ThorneProduct{Name: "Test Product", Ingredients: tt.ingredients}
+			intake := &PatientIntake{Allergies: tt.allergies, DietaryRestrictions: tt.diet}
+			reason := exclusionReason(product, intake)
+			if (reason != "") != tt.excluded {
+				t.Errorf("exclusionReason() = %q, want excluded=%v", reason, tt.excluded)
+			}
+		})
+	}
+}
+
+func TestRecommendProductsIntakeErrors(t *testing.T) {
+	dir := t.TempDir()
+	service := NewThorneService(dir)
+	writeThorneFixture(t, dir, "thorne-patients.json", map[string]interface{}{
+		"patients": []Patient{{ID: "patient-1", Name: "Pat", Email: "pat@example.com", HealthGoals: "sleep"}},
+	})
+	writeThorneFixture(t, dir, "thorne-products.json", map[string]interface{}{
+		"products": []ThorneProduct{{ID: "p1", Name: "Peanut Protein", Ingredients: []string{"Peanuts"}}},
+	})
+	writeThorneFixture(t, dir, "thorne-categories.json", map[string]interface{}{"categories": []ThorneCategory{}})
+
+	// Without an intake the patient's registration goals are used
+	if _, err := service.RecommendProducts("patient-1"); err != nil {
+		t.Fatalf("RecommendProducts() without an intake error = %v", err)
+	}
+
+	// An intake file that can't be read must not be treated as no intake,
+	// or the patient's allergies would be ignored
+	if err := os.WriteFile(filepath.Join(dir, "thorne-intakes.json"), []byte(`{"intakes": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if recommendation, err := service.RecommendProducts("patient-1"); err == nil {
+		t.Fatalf("RecommendProducts() with a corrupt intakes file = %+v, want an error", recommendation)
+	}
+	if _, err := service.GetPatientIntake("patient-1"); err == nil || errors.Is(err, ErrIntakeNotFound) {
+		t.Errorf("GetPatientIntake() with a corrupt intakes file error = %v, want a load error", err)
+	}
+}
@@ -0,0 +1,476 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Redirect match types: exact rules match one path, prefix rules match a
+// path and everything below it and carry the remainder over to the target
+const (
+	RedirectExact  = "exact"
+	RedirectPrefix = "prefix"
+)
+
+// maxRedirectHops bounds how many rules one lookup may chain through
+const maxRedirectHops = 10
+
+// Redirect is a per-site path redirect rule. ChainsTo is set when the
+// target is itself redirected by another rule.
+type Redirect struct {
+	ID         string    `json:"id"`
+	SiteID     string    `json:"site_id"`
+	Source     string    `json:"source"`
+	Target     string    `json:"target"`
+	MatchType  string    `json:"match_type"`
+	StatusCode int       `json:"status_code"`
+	ChainsTo   string    `json:"chains_to,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// RedirectInput is the writable part of a redirect
+type RedirectInput struct {
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	MatchType  string `json:"match_type"`
+	StatusCode int    `json:"status_code"`
+}
+
+// RedirectMatch is the outcome of resolving a path. Chains of rules are
+// collapsed, so Target is always the final destination and Hops counts the
+// rules that were followed.
+type RedirectMatch struct {
+	Path       string `json:"path"`
+	Target     string `json:"target"`
+	StatusCode int    `json:"status_code"`
+	Hops       int    `json:"hops"`
+}
+
+// SlugHistoryEntry is a slug a post used to have
+type SlugHistoryEntry struct {
+	PostID    string    `json:"post_id"`
+	OldSlug   string    `json:"old_slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecordSlugChange remembers oldSlug as a former slug of the post inside the
+// caller's transaction. A slug that is taken again stops redirecting, and an
+// old slug last held by another post now points here.
+func RecordSlugChange(tx *sql.Tx, siteID, postID, oldSlug, newSlug string) error {
+	if oldSlug == "" || oldSlug == newSlug {
+		return nil
+	}
+
+	if _, err := tx.Exec(`DELETE FROM post_slug_history WHERE site_id = $1 AND old_slug = $2`, siteID, newSlug); err != nil {
+		return fmt.Errorf("failed to update slug history: %w", err)
+	}
+	_, err := tx.Exec(`
+		INSERT INTO post_slug_history (site_id, post_id, old_slug)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (site_id, old_slug) DO UPDATE SET post_id = EXCLUDED.post_id, created_at = NOW()`,
+		siteID, postID, oldSlug)
+	if err != nil {
+		return fmt.Errorf("failed to record slug history: %w", err)
+	}
+	return nil
+}
+
+// ResolvePostSlug returns the current slug of the post that used to be
+// published under slug
+func (s *SiteContentService) ResolvePostSlug(siteRef, slug string) (string, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return "", err
+	}
+
+	var current string
+	err = s.db.QueryRow(`
+		SELECT p.slug
+		FROM post_slug_history h
+		JOIN posts p ON p.id = h.post_id
+		WHERE h.site_id = $1 AND h.old_slug = $2 AND p.deleted_at IS NULL AND p.slug <> $2`,
+		siteID, slug).Scan(&current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("slug not found")
+		}
+		return "", fmt.Errorf("failed to resolve slug: %w", err)
+	}
+	return current, nil
+}
+
+// ListSlugHistory returns a post's former slugs, newest first
+func (s *SiteContentService) ListSlugHistory(siteRef, postID string) ([]SlugHistoryEntry, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT post_id::TEXT, old_slug, created_at
+		FROM post_slug_history
+		WHERE site_id = $1 AND post_id::TEXT = $2
+		ORDER BY created_at DESC`, siteID, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list slug history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []SlugHistoryEntry{}
+	for rows.Next() {
+		var entry SlugHistoryEntry
+		if err := rows.Scan(&entry.PostID, &entry.OldSlug, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan slug history: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// ListRedirects returns a site's redirect rules ordered by source, flagging
+// rules whose target is redirected again
+func (s *SiteContentService) ListRedirects(siteRef string) ([]*Redirect, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	redirects, err := s.loadRedirects(siteID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, redirect := range redirects {
+		if next, _, ok := matchRedirect(redirects, redirect.Target); ok && next.ID != redirect.ID {
+			redirect.ChainsTo = next.ID
+		}
+	}
+	return redirects, nil
+}
+
+// GetRedirect returns one redirect rule
+func (s *SiteContentService) GetRedirect(siteRef, redirectID string) (*Redirect, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return scanRedirect(s.db.QueryRow(`
+		SELECT id, site_id, source_path, target_path, match_type, status_code, created_at, updated_at
+		FROM site_redirects
+		WHERE site_id = $1 AND id::TEXT = $2`, siteID, redirectID))
+}
+
+// CreateRedirect validates and stores a redirect rule, refusing rules that
+// would send a visitor round in a loop
+func (s *SiteContentService) CreateRedirect(siteRef string, input RedirectInput) (*Redirect, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeRedirectInput(&input); err != nil {
+		return nil, err
+	}
+	if err := s.checkRedirectLoop(siteID, "", input); err != nil {
+		return nil, err
+	}
+
+	var redirectID string
+	err = s.db.QueryRow(`
+		INSERT INTO site_redirects (site_id, source_path, target_path, match_type, status_code)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`, siteID, input.Source, input.Target, input.MatchType, input.StatusCode).Scan(&redirectID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("a %s redirect from %s already exists", input.MatchType, input.Source)
+		}
+		return nil, fmt.Errorf("failed to create redirect: %w", err)
+	}
+
+	s.logger.Info("site_content_service", "create_redirect", "Redirect created", map[string]interface{}{
+		"site_id":     siteID,
+		"redirect_id": redirectID,
+		"source":      input.Source,
+		"target":      input.Target,
+	})
+
+	return s.GetRedirect(siteID, redirectID)
+}
+
+// UpdateRedirect replaces a redirect rule
+func (s *SiteContentService) UpdateRedirect(siteRef, redirectID string, input RedirectInput) (*Redirect, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.GetRedirect(siteID, redirectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeRedirectInput(&input); err != nil {
+		return nil, err
+	}
+	if err := s.checkRedirectLoop(siteID, current.ID, input); err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE site_redirects SET source_path = $1, target_path = $2, match_type = $3, status_code = $4, updated_at = $5
+		WHERE id = $6`, input.Source, input.Target, input.MatchType, input.StatusCode, time.Now(), current.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("a %s redirect from %s already exists", input.MatchType, input.Source)
+		}
+		return nil, fmt.Errorf("failed to update redirect: %w", err)
+	}
+
+	s.logger.Info("site_content_service", "update_redirect", "Redirect updated", map[string]interface{}{
+		"site_id":     siteID,
+		"redirect_id": current.ID,
+	})
+
+	return s.GetRedirect(siteID, current.ID)
+}
+
+// DeleteRedirect removes a redirect rule
+func (s *SiteContentService) DeleteRedirect(siteRef, redirectID string) error {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`DELETE FROM site_redirects WHERE site_id = $1 AND id::TEXT = $2`, siteID, redirectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete redirect: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("redirect not found")
+	}
+
+	s.logger.Info("site_content_service", "delete_redirect", "Redirect deleted", map[string]interface{}{
+		"site_id":     siteID,
+		"redirect_id": redirectID,
+	})
+	return nil
+}
+
+// ResolveRedirect finds where a request for path should go. Rules are
+// followed through chains to the final target, and a post URL whose slug
+// has since changed redirects to the post's current URL.
+func (s *SiteContentService) ResolveRedirect(siteRef, path string) (*RedirectMatch, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	redirects, err := s.loadRedirects(siteID)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := followRedirects(redirects, path)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil {
+		match = &RedirectMatch{Path: path, Target: path, StatusCode: 301}
+	}
+
+	if slug := strings.TrimPrefix(match.Target, PostPath("")); slug != match.Target && slug != "" {
+		if current, err := s.ResolvePostSlug(siteID, slug); err == nil {
+			match.Target = PostPath(current)
+			match.Hops++
+		}
+	}
+
+	if match.Hops == 0 {
+		return nil, fmt.Errorf("redirect not found")
+	}
+	return match, nil
+}
+
+func (s *SiteContentService) loadRedirects(siteID string) ([]*Redirect, error) {
+	rows, err := s.db.Query(`
+		SELECT id, site_id, source_path, target_path, match_type, status_code, created_at, updated_at
+		FROM site_redirects
+		WHERE site_id = $1
+		ORDER BY source_path, match_type`, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list redirects: %w", err)
+	}
+	defer rows.Close()
+
+	redirects := []*Redirect{}
+	for rows.Next() {
+		redirect, err := scanRedirect(rows)
+		if err != nil {
+			return nil, err
+		}
+		redirects = append(redirects, redirect)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list redirects: %w", err)
+	}
+	return redirects, nil
+}
+
+func scanRedirect(row rowScanner) (*Redirect, error) {
+	redirect := &Redirect{}
+	err := row.Scan(&redirect.ID, &redirect.SiteID, &redirect.Source, &redirect.Target, &redirect.MatchType,
+		&redirect.StatusCode, &redirect.CreatedAt, &redirect.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("redirect not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve redirect: %w", err)
+	}
+	return redirect, nil
+}
+
+// checkRedirectLoop resolves every rule's source with the candidate in
+// place of replacing, failing if a walk comes back to a path it has already
+// visited
+func (s *SiteContentService) checkRedirectLoop(siteID, replacing string, input RedirectInput) error {
+	existing, err := s.loadRedirects(siteID)
+	if err != nil {
+		return err
+	}
+
+	candidate := &Redirect{ID: replacing, Source: input.Source, Target: input.Target,
+		MatchType: input.MatchType, StatusCode: input.StatusCode}
+	redirects := []*Redirect{candidate}
+	for _, redirect := range existing {
+		if redirect.ID != replacing {
+			redirects = append(redirects, redirect)
+		}
+	}
+
+	for _, redirect := range redirects {
+		if _, err := followRedirects(redirects, redirect.Source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// followRedirects applies rules to path until none matches, the target
+// leaves the site, or a loop or overlong chain is found. It returns nil when
+// no rule matches path at all.
+func followRedirects(redirects []*Redirect, path string) (*RedirectMatch, error) {
+	match := &RedirectMatch{Path: path, Target: path}
+	visited := []string{path}
+	for {
+		redirect, target, ok := matchRedirect(redirects, match.Target)
+		if !ok {
+			break
+		}
+		if match.Hops == 0 {
+			match.StatusCode = redirect.StatusCode
+		}
+		match.Target = target
+		match.Hops++
+
+		if containsString(visited, target) {
+			return nil, fmt.Errorf("redirect loop: %s", strings.Join(append(visited, target), " -> "))
+		}
+		if match.Hops > maxRedirectHops {
+			return nil, fmt.Errorf("redirect chain from %s is longer than %d hops", path, maxRedirectHops)
+		}
+		if !strings.HasPrefix(target, "/") {
+			break
+		}
+		visited = append(visited, target)
+	}
+
+	if match.Hops == 0 {
+		return nil, nil
+	}
+	return match, nil
+}
+
+// matchRedirect picks the rule for path: an exact rule wins, otherwise the
+// longest prefix rule whose source is path or one of its parent segments
+func matchRedirect(redirects []*Redirect, path string) (*Redirect, string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, "", false
+	}
+	var best *Redirect
+	for _, redirect := range redirects {
+		switch redirect.MatchType {
+		case RedirectExact:
+			if redirect.Source == path {
+				return redirect, redirect.Target, true
+			}
+		case RedirectPrefix:
+			if pathHasPrefix(path, redirect.Source) && (best == nil || len(redirect.Source) > len(best.Source)) {
+				best = redirect
+			}
+		}
+	}
+	if best == nil {
+		return nil, "", false
+	}
+
+	rest := strings.TrimPrefix(path, best.Source)
+	if rest == "" {
+		return best, best.Target, true
+	}
+	return best, strings.TrimSuffix(best.Target, "/") + "/" + strings.TrimPrefix(rest, "/"), true
+}
+
+// pathHasPrefix reports whether path is prefix or lies below it, matching
+// whole segments only
+func pathHasPrefix(path, prefix string) bool {
+	if prefix == "/" || path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+func normalizeRedirectInput(input *RedirectInput) error {
+	input.Source = strings.TrimSpace(input.Source)
+	input.Target = strings.TrimSpace(input.Target)
+	if input.MatchType == "" {
+		input.MatchType = RedirectExact
+	}
+	if input.StatusCode == 0 {
+		input.StatusCode = 301
+	}
+
+	if input.MatchType != RedirectExact && input.MatchType != RedirectPrefix {
+		return fmt.Errorf("match_type must be %s or %s", RedirectExact, RedirectPrefix)
+	}
+	switch input.StatusCode {
+	case 301, 302, 307, 308:
+	default:
+		return fmt.Errorf("status_code must be 301, 302, 307 or 308")
+	}
+
+	if !strings.HasPrefix(input.Source, "/") || strings.ContainsAny(input.Source, "?#") {
+		return fmt.Errorf("source must be a path starting with / and without a query string")
+	}
+	if len(input.Source) > 1 {
+		input.Source = strings.TrimSuffix(input.Source, "/")
+	}
+
+	if input.Target == "" {
+		return fmt.Errorf("target is required")
+	}
+	if !strings.HasPrefix(input.Target, "/") {
+		parsed, err := url.Parse(input.Target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("target must be a path starting with / or an http(s) URL")
+		}
+	} else if strings.HasPrefix(input.Target, "//") {
+		return fmt.Errorf("target must be a path starting with / or an http(s) URL")
+	}
+
+	if input.MatchType == RedirectPrefix && strings.HasPrefix(input.Target, "/") && pathHasPrefix(input.Target, input.Source) {
+		return fmt.Errorf("a prefix redirect cannot target a path under its own source")
+	}
+	if input.Source == input.Target {
+		return fmt.Errorf("redirect loop: %s -> %s", input.Source, input.Target)
+	}
+	return nil
+}
@@ -0,0 +1,664 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Diff modes
+const (
+	DiffModeLine = "line"
+	DiffModeWord = "word"
+)
+
+// Diff operations
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// maxDiffEdits bounds the Myers search; beyond it the diff degrades to a
+// whole-text replacement rather than using quadratic memory.
+const maxDiffEdits = 2000
+
+// RevisionService manages the immutable edit history of posts
+type RevisionService struct {
+	db        *sql.DB
+	logger    Logger
+	pageCache *PageCache
+}
+
+// PostRevision is a full snapshot of a post as it stood after one edit
+type PostRevision struct {
+	ID             string    `json:"id"`
+	PostID         string    `json:"post_id"`
+	SiteID         string    `json:"site_id"`
+	RevisionNumber int       `json:"revision_number"`
+	Title          string    `json:"title"`
+	Content        string    `json:"content,omitempty"`
+	Blocks         BlockList `json:"blocks,omitempty"`
+	ContentFormat  string    `json:"content_format"`
+	Slug           string    `json:"slug"`
+	Status         string    `json:"status"`
+	AuthorID       string    `json:"author_id,omitempty"`
+	Author         string    `json:"author"`
+	RestoredFrom   string    `json:"restored_from,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// RevisionSnapshot is the post state to record as a new revision
+type RevisionSnapshot struct {
+	PostID       string
+	SiteID       string
+	Title        string
+	Content      string
+	Slug         string
+	Status       string
+	AuthorID     string
+	RestoredFrom string
+}
+
+// PostAutosave is the editor's latest unsaved state of a post. It lives
+// outside posts and post_revisions and is cleared when the post is saved.
+type PostAutosave struct {
+	PostID    string    `json:"post_id"`
+	SiteID    string    `json:"site_id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Slug      string    `json:"slug"`
+	AuthorID  string    `json:"author_id,omitempty"`
+	Author    string    `json:"author"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AutosaveInput is the unsaved editor state to keep for a post
+type AutosaveInput struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Slug    string `json:"slug"`
+}
+
+// DiffOp is one run of equal, inserted or deleted text
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// RevisionDiff compares two revisions of the same post
+type RevisionDiff struct {
+	From         *PostRevision `json:"from"`
+	To           *PostRevision `json:"to"`
+	Mode         string        `json:"mode"`
+	TitleChanged bool          `json:"title_changed"`
+	SlugChanged  bool          `json:"slug_changed"`
+	Title        []DiffOp      `json:"title,omitempty"`
+	Content      []DiffOp      `json:"content"`
+	Insertions   int           `json:"insertions"`
+	Deletions    int           `json:"deletions"`
+}
+
+// NewRevisionService creates a new revision service
+func NewRevisionService(db *sql.DB, logger Logger) *RevisionService {
+	return &RevisionService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetPageCache sets the public page cache to purge when a revision is restored
+func (s *RevisionService) SetPageCache(cache *PageCache) {
+	s.pageCache = cache
+}
+
+// RecordRevision appends a revision inside the caller's transaction. The
+// caller is expected to hold the post row lock (e.g. by having updated it)
+// so revision numbers are assigned without gaps or races. The post's blocks
+// and content format are copied from the row as it stands in the transaction.
+func RecordRevision(tx *sql.Tx, snapshot RevisionSnapshot) (*PostRevision, error) {
+	revision := &PostRevision{
+		PostID:       snapshot.PostID,
+		SiteID:       snapshot.SiteID,
+		Title:        snapshot.Title,
+		Content:      snapshot.Content,
+		Slug:         snapshot.Slug,
+		Status:       snapshot.Status,
+		AuthorID:     snapshot.AuthorID,
+		RestoredFrom: snapshot.RestoredFrom,
+	}
+
+	err := tx.QueryRow(`SELECT COALESCE(MAX(revision_number), 0) + 1 FROM post_revisions WHERE post_id = $1`,
+		snapshot.PostID).Scan(&revision.RevisionNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to number revision: %w", err)
+	}
+
+	query := `
+		INSERT INTO post_revisions (post_id, site_id, revision_number, title, content, blocks, content_format,
+		slug, status, author_id, restored_from)
+		SELECT $1, $2, $3, $4, $5, p.blocks, p.content_format, $6, $7, $8, $9
+		FROM posts p WHERE p.id = $1
+		RETURNING id, created_at`
+	err = tx.QueryRow(query, snapshot.PostID, snapshot.SiteID, revision.RevisionNumber, snapshot.Title,
+		snapshot.Content, snapshot.Slug, snapshot.Status, nullableString(snapshot.AuthorID),
+		nullableString(snapshot.RestoredFrom)).Scan(&revision.ID, &revision.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record revision: %w", err)
+	}
+
+	// The saved post supersedes whatever the editor had autosaved
+	if _, err := tx.Exec(`DELETE FROM post_autosaves WHERE post_id = $1`, snapshot.PostID); err != nil {
+		return nil, fmt.Errorf("failed to clear autosave: %w", err)
+	}
+
+	return revision, nil
+}
+
+// RecordBaselineRevision snapshots a post's current row as revision 1 when it
+// has no history yet, so posts created before revisions existed keep the text
+// they had before their first tracked edit. Call it before updating the post.
+func RecordBaselineRevision(tx *sql.Tx, postID string) error {
+	query := `
+		INSERT INTO post_revisions (post_id, site_id, revision_number, title, content, blocks, content_format,
+		slug, status, author_id, created_at)
+		SELECT p.id, p.site_id, 1, p.title, p.content, p.blocks, p.content_format, p.slug, p.status, p.user_id, p.updated_at
+		FROM posts p
+		WHERE p.id = $1 AND NOT EXISTS (SELECT 1 FROM post_revisions r WHERE r.post_id = p.id)`
+	if _, err := tx.Exec(query, postID); err != nil {
+		return fmt.Errorf("failed to record baseline revision: %w", err)
+	}
+	return nil
+}
+
+// ListRevisions returns a post's revisions newest first, without content
+func (s *RevisionService) ListRevisions(siteID, postID string) ([]PostRevision, error) {
+	s.logger.Debug("revision_service", "list_revisions", "Listing revisions", map[string]interface{}{
+		"site_id": siteID,
+		"post_id": postID,
+	})
+
+	if _, err := strconv.ParseInt(postID, 10, 64); err != nil {
+		return nil, fmt.Errorf("post not found")
+	}
+	if err := s.ensurePost(siteID, postID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT r.id, r.post_id, r.site_id, r.revision_number, r.title, r.slug, COALESCE(r.status, ''),
+		COALESCE(r.author_id::TEXT, ''), COALESCE(u.username, 'Anonymous'),
+		COALESCE(r.restored_from::TEXT, ''), r.created_at
+		FROM post_revisions r
+		LEFT JOIN users u ON r.author_id = u.id
+		WHERE r.post_id = $1 AND r.site_id = $2
+		ORDER BY r.revision_number DESC`
+	rows, err := s.db.Query(query, postID, siteID)
+	if err != nil {
+		s.logger.Error("revision_service", "list_revisions", "Database error", map[string]interface{}{
+			"post_id": postID,
+			"error":   err.Error(),
+		})
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		var revision PostRevision
+		if err := rows.Scan(&revision.ID, &revision.PostID, &revision.SiteID, &revision.RevisionNumber,
+			&revision.Title, &revision.Slug, &revision.Status, &revision.AuthorID, &revision.Author,
+			&revision.RestoredFrom, &revision.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision fetches one revision by UUID or by revision number
+func (s *RevisionService) GetRevision(siteID, postID, revisionRef string) (*PostRevision, error) {
+	if _, err := strconv.ParseInt(postID, 10, 64); err != nil {
+		return nil, fmt.Errorf("post not found")
+	}
+
+	where := "r.id = $3"
+	if _, err := strconv.Atoi(revisionRef); err == nil {
+		where = "r.revision_number = $3"
+	}
+
+	query := `
+		SELECT r.id, r.post_id, r.site_id, r.revision_number, r.title, r.content, r.blocks,
+		COALESCE(r.content_format, 'html'), r.slug, COALESCE(r.status, ''),
+		COALESCE(r.author_id::TEXT, ''), COALESCE(u.username, 'Anonymous'),
+		COALESCE(r.restored_from::TEXT, ''), r.created_at
+		FROM post_revisions r
+		LEFT JOIN users u ON r.author_id = u.id
+		WHERE r.post_id = $1 AND r.site_id = $2 AND ` + where
+
+	var revision PostRevision
+	err := s.db.QueryRow(query, postID, siteID, revisionRef).Scan(&revision.ID, &revision.PostID,
+		&revision.SiteID, &revision.RevisionNumber, &revision.Title, &revision.Content, &revision.Blocks, &revision.ContentFormat, &revision.Slug,
+		&revision.Status, &revision.AuthorID, &revision.Author, &revision.RestoredFrom, &revision.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows || strings.Contains(err.Error(), "invalid input syntax") ||
+			strings.Contains(err.Error(), "could not parse") {
+			return nil, fmt.Errorf("revision not found")
+		}
+		s.logger.Error("revision_service", "get_revision", "Database error", map[string]interface{}{
+			"post_id":     postID,
+			"revision_id": revisionRef,
+			"error":       err.Error(),
+		})
+		return nil, fmt.Errorf("failed to retrieve revision: %w", err)
+	}
+
+	return &revision, nil
+}
+
+// SaveAutosave keeps the editor's unsaved state of a post, replacing any
+// earlier autosave. The post itself and its revisions are left untouched.
+func (s *RevisionService) SaveAutosave(siteID, postID, userID string, input AutosaveInput) (*PostAutosave, error) {
+	if _, err := strconv.ParseInt(postID, 10, 64); err != nil {
+		return nil, fmt.Errorf("post not found")
+	}
+	input.Title = strings.TrimSpace(input.Title)
+	input.Slug = strings.TrimSpace(input.Slug)
+	if input.Title == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+	if len(input.Title) > 255 || len(input.Slug) > 255 {
+		return nil, fmt.Errorf("title and slug must be at most 255 characters")
+	}
+	if err := s.ensurePost(siteID, postID); err != nil {
+		return nil, err
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO post_autosaves (post_id, site_id, title, content, slug, author_id, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (post_id) DO UPDATE
+		SET title = EXCLUDED.title, content = EXCLUDED.content, slug = EXCLUDED.slug,
+		author_id = EXCLUDED.author_id, updated_at = EXCLUDED.updated_at`,
+		postID, siteID, input.Title, input.Content, input.Slug, nullableString(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save autosave: %w", err)
+	}
+
+	s.logger.Debug("revision_service", "save_autosave", "Autosave stored", map[string]interface{}{
+		"site_id": siteID,
+		"post_id": postID,
+		"user_id": userID,
+	})
+	return s.GetAutosave(siteID, postID)
+}
+
+// GetAutosave returns a post's unsaved editor state
+func (s *RevisionService) GetAutosave(siteID, postID string) (*PostAutosave, error) {
+	if _, err := strconv.ParseInt(postID, 10, 64); err != nil {
+		return nil, fmt.Errorf("post not found")
+	}
+
+	var autosave PostAutosave
+	err := s.db.QueryRow(`
+		SELECT a.post_id, a.site_id, a.title, a.content, a.slug, COALESCE(a.author_id::TEXT, ''),
+		COALESCE(u.username, 'Anonymous'), a.updated_at
+		FROM post_autosaves a
+		LEFT JOIN users u ON a.author_id = u.id
+		WHERE a.post_id = $1 AND a.site_id = $2`, postID, siteID).Scan(&autosave.PostID, &autosave.SiteID,
+		&autosave.Title, &autosave.Content, &autosave.Slug, &autosave.AuthorID, &autosave.Author, &autosave.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("autosave not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve autosave: %w", err)
+	}
+	return &autosave, nil
+}
+
+// DiscardAutosave drops a post's unsaved editor state
+func (s *RevisionService) DiscardAutosave(siteID, postID string) error {
+	if _, err := strconv.ParseInt(postID, 10, 64); err != nil {
+		return fmt.Errorf("post not found")
+	}
+	result, err := s.db.Exec(`DELETE FROM post_autosaves WHERE post_id = $1 AND site_id = $2`, postID, siteID)
+	if err != nil {
+		return fmt.Errorf("failed to discard autosave: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("autosave not found")
+	}
+	return nil
+}
+
+// DiffRevisions compares two revisions line by line or word by word
+func (s *RevisionService) DiffRevisions(siteID, postID, fromRef, toRef, mode string) (*RevisionDiff, error) {
+	if mode == "" {
+		mode = DiffModeLine
+	}
+	if mode != DiffModeLine && mode != DiffModeWord {
+		return nil, fmt.Errorf("mode must be one of: line, word")
+	}
+
+	from, err := s.GetRevision(siteID, postID, fromRef)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.GetRevision(siteID, postID, toRef)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &RevisionDiff{
+		Mode:         mode,
+		TitleChanged: from.Title != to.Title,
+		SlugChanged:  from.Slug != to.Slug,
+		Content:      DiffText(from.Content, to.Content, mode),
+	}
+	if diff.TitleChanged {
+		diff.Title = DiffText(from.Title, to.Title, DiffModeWord)
+	}
+	diff.Insertions, diff.Deletions = diffStats(diff.Content, mode)
+
+	// The full texts are already represented by the diff
+	from.Content, to.Content = "", ""
+	diff.From, diff.To = from, to
+
+	return diff, nil
+}
+
+// RestoreRevision copies an old revision's title, content, blocks, format
+// and slug back onto the post and records the result as a new revision; history is
+// never rewritten.
+func (s *RevisionService) RestoreRevision(siteID, postID, revisionRef, userID string) (*PostRevision, error) {
+	source, err := s.GetRevision(siteID, postID, revisionRef)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status, slug string
+	err = tx.QueryRow(`SELECT COALESCE(status, ''), slug FROM posts WHERE id = $1 AND site_id = $2 AND deleted_at IS NULL FOR UPDATE`,
+		postID, siteID).Scan(&status, &slug)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("post not found")
+		}
+		return nil, fmt.Errorf("failed to lock post: %w", err)
+	}
+
+	status, err = WithdrawApprovalOnEdit(tx, postID, userID, status, source.Title, source.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := RecordBaselineRevision(tx, postID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE posts SET title = $1, content = $2, blocks = $3, content_format = $4, slug = $5, status = $6, updated_at = $7
+		WHERE id = $8`, source.Title, source.Content, source.Blocks, source.ContentFormat, source.Slug, status, time.Now(), postID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("slug %q is already used by another post", source.Slug)
+		}
+		return nil, fmt.Errorf("failed to restore post: %w", err)
+	}
+	if err := RecordSlugChange(tx, siteID, postID, slug, source.Slug); err != nil {
+		return nil, err
+	}
+
+	revision, err := RecordRevision(tx, RevisionSnapshot{
+		PostID:       postID,
+		SiteID:       siteID,
+		Title:        source.Title,
+		Content:      source.Content,
+		Slug:         source.Slug,
+		Status:       status,
+		AuthorID:     userID,
+		RestoredFrom: source.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := IndexPost(tx, postID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("revision_service", "restore_revision", "Revision restored", map[string]interface{}{
+		"post_id":         postID,
+		"site_id":         siteID,
+		"restored_from":   source.RevisionNumber,
+		"revision_number": revision.RevisionNumber,
+		"user_id":         userID,
+	})
+
+	return revision, nil
+}
+
+func (s *RevisionService) ensurePost(siteID, postID string) error {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND site_id = $2)`,
+		postID, siteID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check post: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("post not found")
+	}
+	return nil
+}
+
+// DiffText computes a minimal edit script between two texts
+func DiffText(from, to, mode string) []DiffOp {
+	var a, b []string
+	if mode == DiffModeWord {
+		a, b = splitWords(from), splitWords(to)
+	} else {
+		a, b = splitLines(from), splitLines(to)
+	}
+	return diffTokens(a, b)
+}
+
+// diffTokens runs Myers' O(ND) algorithm over the tokens left after trimming
+// the common prefix and suffix, then merges adjacent runs of the same kind.
+func diffTokens(a, b []string) []DiffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []DiffOp
+	for _, token := range a[:prefix] {
+		ops = appendOp(ops, DiffEqual, token)
+	}
+
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if middle, ok := myers(middleA, middleB); ok {
+		for _, op := range middle {
+			ops = appendOp(ops, op.Op, op.Text)
+		}
+	} else {
+		for _, token := range middleA {
+			ops = appendOp(ops, DiffDelete, token)
+		}
+		for _, token := range middleB {
+			ops = appendOp(ops, DiffInsert, token)
+		}
+	}
+
+	for _, token := range a[len(a)-suffix:] {
+		ops = appendOp(ops, DiffEqual, token)
+	}
+	if ops == nil {
+		ops = []DiffOp{}
+	}
+	return ops
+}
+
+// myers returns one DiffOp per token, or false when the edit distance
+// exceeds maxDiffEdits. Each step of the trace keeps only the diagonals it
+// can reach, so memory is O(D²) rather than O(D·(N+M)).
+func myers(a, b []string) ([]DiffOp, bool) {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil, true
+	}
+
+	limit := n + m
+	if limit > maxDiffEdits {
+		limit = maxDiffEdits
+	}
+
+	// v[k] is stored at v[k+offset]; trace[d] holds diagonals -d-1..d+1
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func backtrack(trace [][]int, a, b []string) []DiffOp {
+	x, y := len(a), len(b)
+	var reversed []DiffOp
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := x - y
+
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffOp{Op: DiffEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, DiffOp{Op: DiffInsert, Text: b[y-1]})
+			} else {
+				reversed = append(reversed, DiffOp{Op: DiffDelete, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	ops := make([]DiffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+func appendOp(ops []DiffOp, op, text string) []DiffOp {
+	if n := len(ops); n > 0 && ops[n-1].Op == op {
+		ops[n-1].Text += text
+		return ops
+	}
+	return append(ops, DiffOp{Op: op, Text: text})
+}
+
+// diffStats counts changed lines, or changed words in word mode
+func diffStats(ops []DiffOp, mode string) (insertions, deletions int) {
+	count := func(text string) int {
+		if mode == DiffModeWord {
+			return len(strings.Fields(text))
+		}
+		return len(splitLines(text))
+	}
+	for _, op := range ops {
+		switch op.Op {
+		case DiffInsert:
+			insertions += count(op.Text)
+		case DiffDelete:
+			deletions += count(op.Text)
+		}
+	}
+	return insertions, deletions
+}
+
+// splitLines keeps line endings so joined tokens reproduce the text exactly
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// splitWords splits into words, whitespace runs and single punctuation marks
+func splitWords(text string) []string {
+	var tokens []string
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		end := start + 1
+		switch {
+		case unicode.IsSpace(runes[start]):
+			for end < len(runes) && unicode.IsSpace(runes[end]) {
+				end++
+			}
+		case unicode.IsLetter(runes[start]) || unicode.IsDigit(runes[start]):
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end])) {
+				end++
+			}
+		}
+		tokens = append(tokens, string(runes[start:end]))
+		start = end
+	}
+	return tokens
+}
+
+func nullableString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
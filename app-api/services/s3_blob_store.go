@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3-compatible store. Endpoint is the service URL
+// (https://s3.us-east-1.amazonaws.com, or http://localhost:9000 for MinIO);
+// PathStyle puts the bucket in the path instead of the host name, which
+// MinIO and most local stubs require.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PublicURL string
+	PathStyle bool
+}
+
+// S3BlobStore talks to S3-compatible object storage with SigV4-signed
+// requests over plain HTTP, so it works against AWS, MinIO or a test stub
+type S3BlobStore struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3BlobStore creates an S3-compatible store
+func NewS3BlobStore(config S3Config) (*S3BlobStore, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("s3 endpoint and bucket are required")
+	}
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+	return &S3BlobStore{
+		config: config,
+		client: &http.Client{Timeout: 5 * time.Minute},
+		now:    time.Now,
+	}, nil
+}
+
+func (s *S3BlobStore) objectURL(key string) *url.URL {
+	endpoint, _ := url.Parse(s.config.Endpoint)
+	if s.config.PathStyle {
+		endpoint.Path = "/" + s.config.Bucket + "/" + key
+		endpoint.RawPath = "/" + s.config.Bucket + "/" + escapeS3Key(key)
+	} else {
+		endpoint.Host = s.config.Bucket + "." + endpoint.Host
+		endpoint.Path = "/" + key
+		endpoint.RawPath = "/" + escapeS3Key(key)
+	}
+	return endpoint
+}
+
+func escapeS3Key(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = awsURIEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Put implements BlobStore. The payload is streamed unsigned so large
+// uploads are never buffered in memory.
+func (s *S3BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), r)
+	if err != nil {
+		return fmt.Errorf("failed to build s3 request: %w", err)
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload blob: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to upload blob: %s", s3ErrorMessage(resp))
+	}
+	return nil
+}
+
+// Get implements BlobStore
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build s3 request: %w", err)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch blob: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	case resp.StatusCode/100 != 2:
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch blob: %s", s3ErrorMessage(resp))
+	}
+	return resp.Body, nil
+}
+
+// Delete implements BlobStore
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build s3 request: %w", err)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete blob: %s", s3ErrorMessage(resp))
+	}
+	return nil
+}
+
+// URL implements BlobStore; PublicURL (a CDN or public bucket URL) is
+// preferred over the API endpoint
+func (s *S3BlobStore) URL(key string) string {
+	if s.config.PublicURL != "" {
+		return strings.TrimRight(s.config.PublicURL, "/") + "/" + escapeS3Key(key)
+	}
+	return s.objectURL(key).String()
+}
+
+func (s *S3BlobStore) do(req *http.Request) (*http.Response, error) {
+	s.sign(req)
+	return s.client.Do(req)
+}
+
+func s3ErrorMessage(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Sprintf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// sign adds AWS Signature Version 4 headers to req
+func (s *S3BlobStore) sign(req *http.Request) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+
+	names := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	scope := day + "/" + s.config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), day)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.config.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func canonicalQuery(values url.Values) string {
+	var pairs []string
+	for key, list := range values {
+		for _, value := range list {
+			pairs = append(pairs, awsURIEscape(key)+"="+awsURIEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEscape percent-encodes everything except RFC 3986 unreserved
+// characters, as SigV4 requires
+func awsURIEscape(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			out.WriteByte(c)
+		} else {
+			fmt.Fprintf(&out, "%%%02X", c)
+		}
+	}
+	return out.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// s3Stub is an in-memory, path-style S3 endpoint that rejects requests
+// whose SigV4 signature doesn't verify, like MinIO does
+type s3Stub struct {
+	t         *testing.T
+	bucket    string
+	accessKey string
+	secretKey string
+	region    string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newS3Stub(t *testing.T) (*s3Stub, *httptest.Server) {
+	stub := &s3Stub{
+		t:         t,
+		bucket:    "media",
+		accessKey: "AKIDEXAMPLE",
+		secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		region:    "eu-west-1",
+		objects:   map[string][]byte{},
+		types:     map[string]string{},
+	}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return stub, server
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.verify(r); err != nil {
+		s.t.Logf("rejected %s %s: %v", r.Method, r.URL.Path, err)
+		http.Error(w, "SignatureDoesNotMatch: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	prefix := "/" + s.bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(body)) != r.ContentLength {
+			http.Error(w, "IncompleteBody", http.StatusBadRequest)
+			return
+		}
+		s.objects[key] = body
+		s.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		body, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", s.types[key])
+		w.Write(body)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verify recomputes the request's SigV4 signature from what arrived on the
+// wire
+func (s *s3Stub) verify(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return errors.New("missing AWS4-HMAC-SHA256 authorization")
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		fields[name] = value
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) != len("20060102T150405Z") {
+		return errors.New("missing X-Amz-Date")
+	}
+	scope := amzDate[:8] + "/" + s.region + "/s3/aws4_request"
+	if fields["Credential"] != s.accessKey+"/"+scope {
+		return errors.New("credential scope mismatch: " + fields["Credential"])
+	}
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signed) {
+		return errors.New("signed headers are not sorted")
+	}
+	for _, required := range []string{"host", "x-amz-content-sha256", "x-amz-date"} {
+		if !containsString(signed, required) {
+			return errors.New(required + " is not signed")
+		}
+	}
+	var headers strings.Builder
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonical := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		headers.String(),
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{amzDate[:8], s.region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if !hmac.Equal([]byte(hex.EncodeToString(key)), []byte(fields["Signature"])) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func newStubStore(t *testing.T, stub *s3Stub, server *httptest.Server, secretKey string) *S3BlobStore {
+	store, err := NewS3BlobStore(S3Config{
+		Endpoint:  server.URL + "/",
+		Region:    stub.region,
+		Bucket:    stub.bucket,
+		AccessKey: stub.accessKey,
+		SecretKey: secretKey,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3BlobStore() error = %v", err)
+	}
+	store.now = func() time.Time { return time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC) }
+	return store
+}
+
+func TestS3BlobStoreRoundTrip(t *testing.T) {
+	stub, server := newS3Stub(t)
+	store := newStubStore(t, stub, server, stub.secretKey)
+	ctx := context.Background()
+
+	for _, key := range []string{"uploads/2026/03/photo.jpg", "uploads/2026/03/a b+c (1)~ü.png"} {
+		t.Run(key, func(t *testing.T) {
+			payload := []byte("blob for " + key)
+			if err := store.Put(ctx, key, bytes.NewReader(payload), int64(len(payload)), "image/png"); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			if got := stub.types[key]; got != "image/png" {
+				t.Errorf("stored content type = %q, want image/png", got)
+			}
+
+			body, err := store.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			got, _ := io.ReadAll(body)
+			body.Close()
+			if !bytes.Equal(got, payload) {
+				t.Errorf("Get() = %q, want %q", got, payload)
+			}
+
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, err := store.Get(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+				t.Errorf("Get() after Delete() error = %v, want ErrBlobNotFound", err)
+			}
+			if err := store.Delete(ctx, key); err != nil {
+				t.Errorf("Delete() of a missing key error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestS3BlobStoreRejectedSignature(t *testing.T) {
+	stub, server := newS3Stub(t)
+	store := newStubStore(t, stub, server, "not-the-secret")
+
+	err := store.Put(context.Background(), "a.txt", strings.NewReader("x"), 1, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Fatalf("Put() with a wrong secret error = %v, want status 403", err)
+	}
+	if len(stub.objects) != 0 {
+		t.Errorf("stub stored %d objects for a rejected request", len(stub.objects))
+	}
+}
+
+func TestS3BlobStoreURLs(t *testing.T) {
+	tests := []struct {
+		name   string
+		config S3Config
+		key    string
+		want   string
+	}{
+		{"path style", S3Config{Endpoint: "http://localhost:9000", Bucket: "media", PathStyle: true},
+			"a/b c.jpg", "http://localhost:9000/media/a/b%20c.jpg"},
+		{"virtual host style", S3Config{Endpoint: "https://s3.eu-west-1.amazonaws.com/", Bucket: "media"},
+			"a/b+c.jpg", "https://media.s3.eu-west-1.amazonaws.com/a/b%2Bc.jpg"},
+		{"public url", S3Config{Endpoint: "https://s3.amazonaws.com", Bucket: "media", PublicURL: "https://cdn.example.com/"},
+			"a/ü.jpg", "https://cdn.example.com/a/%C3%BC.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewS3BlobStore(tt.config)
+			if err != nil {
+				t.Fatalf("NewS3BlobStore() error = %v", err)
+			}
+			if got := store.URL(tt.key); got != tt.want {
+				t.Errorf("URL(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// snippetWords is how many words of the body a result snippet shows
+	snippetWords = 30
+)
+
+// searchConfigs maps a locale's language to its PostgreSQL text search
+// configuration; other languages use the simple configuration, which only
+// lowercases
+var searchConfigs = map[string]string{
+	"da": "danish",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fi": "finnish",
+	"fr": "french",
+	"hu": "hungarian",
+	"it": "italian",
+	"nb": "norwegian",
+	"nl": "dutch",
+	"no": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+}
+
+// SearchService runs full-text search over a site's posts. Each live post
+// has a row in post_search_documents holding its title, tag names and the
+// plain text of its rendered content as a weighted tsvector; IndexPost
+// keeps that row in step with the post.
+type SearchService struct {
+	db     *sql.DB
+	logger Logger
+}
+
+// PostSearchQuery narrows a search. Author matches a user id or username;
+// From and To bound the publication date, or the creation date of posts
+// never published. PublishedOnly is set for anonymous callers.
+type PostSearchQuery struct {
+	Query         string
+	Status        string
+	Author        string
+	Locale        string
+	From          *time.Time
+	To            *time.Time
+	PublishedOnly bool
+	Limit         int
+	Offset        int
+}
+
+// PostSearchResult is one matching post. TitleHighlight and Snippet are
+// HTML with the matched words wrapped in <mark>.
+type PostSearchResult struct {
+	PostID         string     `json:"post_id"`
+	Title          string     `json:"title"`
+	Slug           string     `json:"slug"`
+	Locale         string     `json:"locale"`
+	Status         string     `json:"status"`
+	Published      bool       `json:"published"`
+	AuthorID       string     `json:"author_id"`
+	Author         string     `json:"author"`
+	Tags           []string   `json:"tags"`
+	PublishedAt    *time.Time `json:"published_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Rank           float64    `json:"rank"`
+	TitleHighlight string     `json:"title_highlight"`
+	Snippet        string     `json:"snippet"`
+}
+
+// PostSearchResults is one page of results, best match first
+type PostSearchResults struct {
+	Query   string             `json:"query"`
+	Results []PostSearchResult `json:"results"`
+	Total   int                `json:"total"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+}
+
+// NewSearchService creates a new search service
+func NewSearchService(db *sql.DB, logger Logger) *SearchService {
+	return &SearchService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+type execQueryRower interface {
+	execer
+	queryRower
+}
+
+// termIndexer is satisfied by both *sql.DB and *sql.Tx
+type termIndexer interface {
+	execQueryRower
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// IndexPost refreshes a post's search document inside the caller's
+// transaction when one is passed. Deleted or missing posts lose their
+// document.
+func IndexPost(db execQueryRower, postID string) error {
+	var title, content, format, locale string
+	err := db.QueryRow(`
+		SELECT title, content, COALESCE(content_format, 'html'), locale FROM posts
+		WHERE id::TEXT = $1 AND deleted_at IS NULL`, postID).Scan(&title, &content, &format, &locale)
+	if err == sql.ErrNoRows {
+		if _, err := db.Exec(`DELETE FROM post_search_documents WHERE post_id::TEXT = $1`, postID); err != nil {
+			return fmt.Errorf("failed to remove search document: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load post for indexing: %w", err)
+	}
+
+	body := plainText(content)
+	if rendered, err := RenderContent(content, format); err == nil {
+		body = plainText(rendered.HTML)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO post_search_documents (post_id, site_id, search_config, title, body, tags, document, updated_at)
+		SELECT p.id, p.site_id, $2, p.title, $3, COALESCE(tags.names, ''),
+			setweight(to_tsvector($2::regconfig, p.title), 'A') ||
+			setweight(to_tsvector($2::regconfig, COALESCE(tags.names, '')), 'B') ||
+			setweight(to_tsvector($2::regconfig, $3), 'C'),
+			NOW()
+		FROM posts p
+		LEFT JOIN LATERAL (
+			SELECT string_agg(t.name, ', ' ORDER BY t.name) AS names
+			FROM post_terms pt JOIN taxonomy_terms t ON t.id = pt.term_id
+			WHERE pt.post_id = p.id AND t.taxonomy = 'tag'
+		) tags ON true
+		WHERE p.id::TEXT = $1
+		ON CONFLICT (post_id) DO UPDATE SET site_id = EXCLUDED.site_id, search_config = EXCLUDED.search_config,
+		title = EXCLUDED.title, body = EXCLUDED.body, tags = EXCLUDED.tags, document = EXCLUDED.document,
+		updated_at = EXCLUDED.updated_at`, postID, searchConfig(locale), body)
+	if err != nil {
+		return fmt.Errorf("failed to index post: %w", err)
+	}
+	return nil
+}
+
+// IndexPostsWithTerm refreshes the documents of every post assigned a
+// term, after the term's name or assignments changed
+func IndexPostsWithTerm(db termIndexer, termID string) error {
+	ids, err := postsWithTerm(db, termID)
+	if err != nil {
+		return err
+	}
+	return indexPosts(db, ids)
+}
+
+func postsWithTerm(db termIndexer, termID string) ([]string, error) {
+	rows, err := db.Query(`SELECT post_id::TEXT FROM post_terms WHERE term_id = $1`, termID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list posts for term: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan post id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func indexPosts(db execQueryRower, ids []string) error {
+	for _, id := range ids {
+		if err := IndexPost(db, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reindex rebuilds the search documents of all of a site's live posts and
+// returns how many were indexed
+func (s *SearchService) Reindex(siteRef string) (int, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := s.db.Query(`SELECT id::TEXT FROM posts WHERE site_id = $1 AND deleted_at IS NULL ORDER BY id`, siteID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list posts: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan post id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list posts: %w", err)
+	}
+
+	if _, err := s.db.Exec(`
+		DELETE FROM post_search_documents d
+		WHERE d.site_id = $1 AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = d.post_id AND p.deleted_at IS NULL)`,
+		siteID); err != nil {
+		return 0, fmt.Errorf("failed to prune search documents: %w", err)
+	}
+	if err := indexPosts(s.db, ids); err != nil {
+		return 0, err
+	}
+
+	s.logger.Info("search_service", "reindex", "Site search index rebuilt", map[string]interface{}{
+		"site_id": siteID,
+		"posts":   len(ids),
+	})
+	return len(ids), nil
+}
+
+// Search ranks a site's posts against a free-text query. Title matches
+// weigh most, then tags, then body text.
+func (s *SearchService) Search(siteRef string, query PostSearchQuery) (*PostSearchResults, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	query.Query = strings.TrimSpace(query.Query)
+	if query.Query == "" {
+		return nil, fmt.Errorf("q is required")
+	}
+	if query.Status != "" && !IsPostStatus(query.Status) {
+		return nil, fmt.Errorf("status must be one of: draft, in_review, approved, published, archived, deleted")
+	}
+	if query.From != nil && query.To != nil && query.To.Before(*query.From) {
+		return nil, fmt.Errorf("to must not be before from")
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultSearchLimit
+	}
+	if query.Limit > maxSearchLimit {
+		query.Limit = maxSearchLimit
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	args := []interface{}{siteID, query.Query}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+	var conditions strings.Builder
+	if query.PublishedOnly {
+		conditions.WriteString(" AND p.published = true")
+	}
+	if query.Status != "" {
+		conditions.WriteString(" AND p.status = " + arg(query.Status))
+	}
+	if query.Author != "" {
+		ref := arg(query.Author)
+		conditions.WriteString(" AND (p.user_id::TEXT = " + ref + " OR u.username = " + ref + ")")
+	}
+	if query.Locale != "" {
+		conditions.WriteString(" AND p.locale = " + arg(query.Locale))
+	}
+	if query.From != nil {
+		conditions.WriteString(" AND COALESCE(p.published_at, p.created_at) >= " + arg(*query.From))
+	}
+	if query.To != nil {
+		conditions.WriteString(" AND COALESCE(p.published_at, p.created_at) <= " + arg(*query.To))
+	}
+	limit, offset := arg(query.Limit), arg(query.Offset)
+
+	rows, err := s.db.Query(`
+		SELECT p.id::TEXT, p.title, p.slug, p.locale, COALESCE(p.status, ''), p.published, p.user_id::TEXT,
+			COALESCE(u.username, 'Anonymous'), p.published_at, p.created_at, d.body, d.tags,
+			ts_rank(d.document, plainto_tsquery(d.search_config::regconfig, $2)) AS rank,
+			COUNT(*) OVER () AS total
+		FROM post_search_documents d
+		JOIN posts p ON p.id = d.post_id
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE d.site_id = $1 AND p.deleted_at IS NULL
+		AND d.document @@ plainto_tsquery(d.search_config::regconfig, $2)`+conditions.String()+`
+		ORDER BY rank DESC, COALESCE(p.published_at, p.created_at) DESC
+		LIMIT `+limit+` OFFSET `+offset, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+	defer rows.Close()
+
+	terms := searchTerms(query.Query)
+	results := &PostSearchResults{
+		Query:   query.Query,
+		Results: []PostSearchResult{},
+		Limit:   query.Limit,
+		Offset:  query.Offset,
+	}
+	for rows.Next() {
+		var result PostSearchResult
+		var body, tags string
+		var publishedAt sql.NullTime
+		err := rows.Scan(&result.PostID, &result.Title, &result.Slug, &result.Locale, &result.Status, &result.Published,
+			&result.AuthorID, &result.Author, &publishedAt, &result.CreatedAt, &body, &tags, &result.Rank, &results.Total)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		if publishedAt.Valid {
+			result.PublishedAt = &publishedAt.Time
+		}
+		result.Tags = []string{}
+		if tags != "" {
+			result.Tags = strings.Split(tags, ", ")
+		}
+		result.TitleHighlight = highlightTerms(strings.Fields(result.Title), terms)
+		result.Snippet = searchSnippet(body, terms)
+		results.Results = append(results.Results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+
+	s.logger.Debug("search_service", "search", "Posts searched", map[string]interface{}{
+		"site_id": siteID,
+		"query":   query.Query,
+		"total":   results.Total,
+	})
+	return results, nil
+}
+
+// searchConfig picks the text search configuration for a locale
+func searchConfig(locale string) string {
+	if config, ok := searchConfigs[strings.ToLower(strings.SplitN(locale, "-", 2)[0])]; ok {
+		return config
+	}
+	return "simple"
+}
+
+// searchTerms splits a query into lowercase words
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// termMatches approximates stemming for highlighting: a word matches a
+// term when they share most of the term as a prefix, so "healthy" and
+// "health" match each other but "heal" matches neither
+func termMatches(word, term string) bool {
+	word = strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}))
+	w, t := []rune(word), []rune(term)
+	need := len(t) - 2
+	if need < 4 {
+		need = 4
+	}
+	if need > len(t) {
+		need = len(t)
+	}
+	if len(w) < need {
+		return false
+	}
+	for i := 0; i < need; i++ {
+		if w[i] != t[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(word string, terms []string) bool {
+	for _, term := range terms {
+		if termMatches(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightTerms escapes words and joins them, wrapping matches in <mark>
+func highlightTerms(words []string, terms []string) string {
+	out := make([]string, len(words))
+	for i, word := range words {
+		out[i] = html.EscapeString(word)
+		if matchesAny(word, terms) {
+			out[i] = "<mark>" + out[i] + "</mark>"
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// searchSnippet returns about snippetWords words of text around the first
+// match, highlighted; text without a match yields its opening words
+func searchSnippet(text string, terms []string) string {
+	words := strings.Fields(text)
+	first := 0
+	for i, word := range words {
+		if matchesAny(word, terms) {
+			first = i
+			break
+		}
+	}
+
+	start := first - snippetWords/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetWords
+	if end > len(words) {
+		end = len(words)
+	}
+
+	snippet := highlightTerms(words[start:end], terms)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(words) {
+		snippet += "…"
+	}
+	return snippet
+}
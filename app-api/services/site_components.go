@@ -0,0 +1,525 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BlockCatalog supplies products and compliance copy to product grid and
+// disclaimer blocks; ThorneService satisfies it
+type BlockCatalog interface {
+	GetProducts() ([]ThorneProduct, error)
+	GetSettings() (*ThorneSettings, error)
+}
+
+// SiteComponent is a named, reusable block variant for one site
+type SiteComponent struct {
+	ID        string                 `json:"id"`
+	SiteID    string                 `json:"site_id"`
+	Type      string                 `json:"type"`
+	Name      string                 `json:"name"`
+	Props     map[string]interface{} `json:"props"`
+	Data      interface{}            `json:"data,omitempty"`
+	HTML      string                 `json:"html,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// SiteComponentInput is the writable part of a component
+type SiteComponentInput struct {
+	Type  string                 `json:"type"`
+	Name  string                 `json:"name"`
+	Props map[string]interface{} `json:"props"`
+}
+
+// SetBlockCatalog wires the product catalog used by product grid and
+// disclaimer blocks. Without one, product grids render empty and
+// disclaimers fall back to the default medical disclaimer.
+func (s *SiteContentService) SetBlockCatalog(catalog BlockCatalog) {
+	s.catalog = catalog
+}
+
+// ListComponents returns a site's components, optionally of one type, with
+// rendered HTML
+func (s *SiteContentService) ListComponents(siteRef, blockType string) ([]*SiteComponent, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if blockType != "" && !isBlockType(blockType) {
+		return nil, fmt.Errorf("unknown block type %q; use one of: %s", blockType, strings.Join(BlockTypes(), ", "))
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, site_id, block_type, name, props, created_at, updated_at
+		FROM site_components
+		WHERE site_id = $1 AND ($2 = '' OR block_type = $2)
+		ORDER BY block_type, name`, siteID, blockType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list components: %w", err)
+	}
+	defer rows.Close()
+
+	components := []*SiteComponent{}
+	for rows.Next() {
+		component, err := scanComponent(rows)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, component)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list components: %w", err)
+	}
+
+	for _, component := range components {
+		s.renderComponent(component)
+	}
+	return components, nil
+}
+
+// GetComponent returns one component by id or name, rendered
+func (s *SiteContentService) GetComponent(siteRef, componentRef string) (*SiteComponent, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	component, err := s.findComponent(siteID, "", componentRef)
+	if err != nil {
+		return nil, err
+	}
+	s.renderComponent(component)
+	return component, nil
+}
+
+// findComponent looks a component up by id, or by name within blockType
+func (s *SiteContentService) findComponent(siteID, blockType, componentRef string) (*SiteComponent, error) {
+	return scanComponent(s.db.QueryRow(`
+		SELECT id, site_id, block_type, name, props, created_at, updated_at
+		FROM site_components
+		WHERE site_id = $1 AND (id::TEXT = $2 OR (name = $2 AND ($3 = '' OR block_type = $3)))
+		ORDER BY (id::TEXT = $2) DESC
+		LIMIT 1`, siteID, componentRef, blockType))
+}
+
+func scanComponent(row rowScanner) (*SiteComponent, error) {
+	component := &SiteComponent{}
+	var props []byte
+	err := row.Scan(&component.ID, &component.SiteID, &component.Type, &component.Name, &props,
+		&component.CreatedAt, &component.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("component not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve component: %w", err)
+	}
+	if err := json.Unmarshal(props, &component.Props); err != nil {
+		return nil, fmt.Errorf("failed to decode component props: %w", err)
+	}
+	return component, nil
+}
+
+// renderComponent fills a component's HTML; render failures are logged and
+// leave the HTML empty so one broken component does not fail a listing
+func (s *SiteContentService) renderComponent(component *SiteComponent) {
+	block := Block{Type: component.Type, Props: component.Props}
+	if err := s.renderResolvedBlock(&block); err != nil {
+		s.logger.Error("site_content_service", "render_component", "Failed to render component", map[string]interface{}{
+			"component_id": component.ID,
+			"error":        err.Error(),
+		})
+		return
+	}
+	component.Data, component.HTML = block.Data, block.HTML
+}
+
+// CreateComponent validates and stores a reusable block variant
+func (s *SiteContentService) CreateComponent(siteRef string, input SiteComponentInput) (*SiteComponent, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	props, err := s.normalizeComponentInput(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, _ := json.Marshal(props)
+	var componentID string
+	err = s.db.QueryRow(`
+		INSERT INTO site_components (site_id, block_type, name, props)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`, siteID, input.Type, input.Name, encoded).Scan(&componentID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("a %s component named %q already exists", input.Type, input.Name)
+		}
+		return nil, fmt.Errorf("failed to create component: %w", err)
+	}
+
+	s.logger.Info("site_content_service", "create_component", "Component created", map[string]interface{}{
+		"site_id":      siteID,
+		"component_id": componentID,
+		"type":         input.Type,
+	})
+
+	return s.GetComponent(siteID, componentID)
+}
+
+// UpdateComponent replaces a component's name and props. The type is fixed
+// because posts and pages reference the component as that type.
+func (s *SiteContentService) UpdateComponent(siteRef, componentID string, input SiteComponentInput) (*SiteComponent, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.findComponent(siteID, "", componentID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Type == "" {
+		input.Type = current.Type
+	}
+	if input.Type != current.Type {
+		return nil, fmt.Errorf("component type cannot be changed")
+	}
+	props, err := s.normalizeComponentInput(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, _ := json.Marshal(props)
+	_, err = s.db.Exec(`
+		UPDATE site_components SET name = $1, props = $2, updated_at = $3
+		WHERE id = $4`, input.Name, encoded, time.Now(), current.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("a %s component named %q already exists", input.Type, input.Name)
+		}
+		return nil, fmt.Errorf("failed to update component: %w", err)
+	}
+
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("site_content_service", "update_component", "Component updated", map[string]interface{}{
+		"site_id":      siteID,
+		"component_id": current.ID,
+	})
+
+	return s.GetComponent(siteID, current.ID)
+}
+
+// DeleteComponent removes a component no post or page references
+func (s *SiteContentService) DeleteComponent(siteRef, componentID string) error {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return err
+	}
+	component, err := s.findComponent(siteID, "", componentID)
+	if err != nil {
+		return err
+	}
+
+	reference, _ := json.Marshal([]map[string]string{{"component": component.ID}})
+	var pages, posts int
+	err = s.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM site_pages WHERE site_id = $1 AND blocks @> $2::JSONB),
+			(SELECT COUNT(*) FROM posts WHERE site_id = $1 AND blocks @> $2::JSONB)`,
+		siteID, string(reference)).Scan(&pages, &posts)
+	if err != nil {
+		return fmt.Errorf("failed to check component usage: %w", err)
+	}
+	if pages+posts > 0 {
+		return fmt.Errorf("component is referenced by %d pages and %d posts", pages, posts)
+	}
+
+	if _, err := s.db.Exec(`DELETE FROM site_components WHERE id = $1`, component.ID); err != nil {
+		return fmt.Errorf("failed to delete component: %w", err)
+	}
+
+	s.logger.Info("site_content_service", "delete_component", "Component deleted", map[string]interface{}{
+		"site_id":      siteID,
+		"component_id": component.ID,
+	})
+	return nil
+}
+
+func (s *SiteContentService) normalizeComponentInput(input *SiteComponentInput) (map[string]interface{}, error) {
+	if !isBlockType(input.Type) {
+		return nil, fmt.Errorf("unknown block type %q; use one of: %s", input.Type, strings.Join(BlockTypes(), ", "))
+	}
+	if input.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if !pageSlugPattern.MatchString(input.Name) {
+		return nil, fmt.Errorf("name must be lowercase letters, digits and single hyphens")
+	}
+	props, err := normalizeBlockProps(input.Type, input.Props)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBlockProducts(input.Type, props); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+// PrepareBlocks validates blocks for storage: component references are
+// resolved to component ids, only overriding props are kept on referencing
+// blocks, and the rendered HTML of the whole list is returned alongside.
+func (s *SiteContentService) PrepareBlocks(siteRef string, blocks []Block) (BlockList, string, error) {
+	if len(blocks) > maxBlocksPerDocument {
+		return nil, "", fmt.Errorf("at most %d blocks are allowed", maxBlocksPerDocument)
+	}
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, "", err
+	}
+
+	prepared := make(BlockList, 0, len(blocks))
+	for i, block := range blocks {
+		merged, component, err := s.resolveBlock(siteID, block)
+		if err != nil {
+			return nil, "", fmt.Errorf("block %d: %v", i+1, err)
+		}
+
+		stored := Block{Type: merged.Type, Props: merged.Props}
+		if component != nil {
+			stored.Component = component.ID
+			stored.Props = nil
+			for name := range block.Props {
+				value, ok := merged.Props[name]
+				if !ok {
+					continue
+				}
+				if stored.Props == nil {
+					stored.Props = map[string]interface{}{}
+				}
+				stored.Props[name] = value
+			}
+		}
+		prepared = append(prepared, stored)
+	}
+
+	_, html, err := s.RenderBlocks(siteID, prepared)
+	if err != nil {
+		return nil, "", err
+	}
+	return prepared, html, nil
+}
+
+// RenderBlocks resolves component references, renders every block and
+// returns the blocks with merged props, data and HTML plus the combined HTML
+func (s *SiteContentService) RenderBlocks(siteRef string, blocks []Block) (BlockList, string, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rendered := make(BlockList, 0, len(blocks))
+	var out strings.Builder
+	for i, block := range blocks {
+		merged, _, err := s.resolveBlock(siteID, block)
+		if err != nil {
+			return nil, "", fmt.Errorf("block %d: %v", i+1, err)
+		}
+		if err := s.renderResolvedBlock(&merged); err != nil {
+			return nil, "", err
+		}
+		rendered = append(rendered, merged)
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString(merged.HTML)
+	}
+	return rendered, out.String(), nil
+}
+
+// RenderPageBlocks re-renders a page's blocks so edits to shared components
+// show up without re-saving the page. If rendering fails the HTML stored at
+// save time is served instead.
+func (s *SiteContentService) RenderPageBlocks(page *SitePage) {
+	if len(page.Blocks) == 0 {
+		return
+	}
+	blocks, html, err := s.RenderBlocks(page.SiteID, page.Blocks)
+	if err != nil {
+		s.logger.Error("site_content_service", "render_page", "Failed to render page blocks", map[string]interface{}{
+			"page_id": page.ID,
+			"error":   err.Error(),
+		})
+		return
+	}
+	page.Blocks, page.Content = blocks, html
+}
+
+// resolveBlock merges a block's props over its component's and validates
+// the result
+func (s *SiteContentService) resolveBlock(siteID string, block Block) (Block, *SiteComponent, error) {
+	var component *SiteComponent
+	props := map[string]interface{}{}
+
+	if block.Component != "" {
+		var err error
+		component, err = s.findComponent(siteID, block.Type, block.Component)
+		if err != nil {
+			return Block{}, nil, err
+		}
+		if block.Type == "" {
+			block.Type = component.Type
+		}
+		if block.Type != component.Type {
+			return Block{}, nil, fmt.Errorf("component %s is a %s, not a %s", component.Name, component.Type, block.Type)
+		}
+		for name, value := range component.Props {
+			props[name] = value
+		}
+	}
+	for name, value := range block.Props {
+		props[name] = value
+	}
+
+	normalized, err := normalizeBlockProps(block.Type, props)
+	if err != nil {
+		return Block{}, nil, err
+	}
+	if err := s.checkBlockProducts(block.Type, normalized); err != nil {
+		return Block{}, nil, err
+	}
+
+	resolved := Block{Type: block.Type, Props: normalized}
+	if component != nil {
+		resolved.Component = component.ID
+	}
+	return resolved, component, nil
+}
+
+// checkBlockProducts confirms product grid ids and categories exist
+func (s *SiteContentService) checkBlockProducts(blockType string, props map[string]interface{}) error {
+	if blockType != BlockProductGrid || s.catalog == nil {
+		return nil
+	}
+	products, err := s.catalog.GetProducts()
+	if err != nil {
+		return fmt.Errorf("failed to load products: %w", err)
+	}
+
+	known := make(map[string]bool, len(products))
+	categories := map[string]bool{}
+	for _, product := range products {
+		known[product.ID] = true
+		categories[product.Category] = true
+	}
+	for _, id := range blockProductIDs(props) {
+		if !known[id] {
+			return fmt.Errorf("invalid product_grid block: unknown product %q", id)
+		}
+	}
+	if category, ok := props["category"].(string); ok && !categories[category] {
+		return fmt.Errorf("invalid product_grid block: unknown category %q", category)
+	}
+	return nil
+}
+
+func blockProductIDs(props map[string]interface{}) []string {
+	switch ids := props["product_ids"].(type) {
+	case []string:
+		return ids
+	case []interface{}:
+		out := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if text, ok := id.(string); ok {
+				out = append(out, text)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// renderResolvedBlock looks up the catalog data a block needs and renders it
+func (s *SiteContentService) renderResolvedBlock(block *Block) error {
+	var products []ProductCard
+	var disclaimer string
+
+	switch block.Type {
+	case BlockProductGrid:
+		var err error
+		if products, err = s.gridProducts(block.Props); err != nil {
+			return err
+		}
+		block.Data = map[string]interface{}{"products": products}
+	case BlockComplianceDisclaimer:
+		disclaimer, _ = block.Props["text"].(string)
+		if disclaimer == "" && s.catalog != nil {
+			if settings, err := s.catalog.GetSettings(); err == nil {
+				kind, _ := block.Props["kind"].(string)
+				disclaimer = settings.Compliance[kind]
+			}
+		}
+		if disclaimer == "" {
+			disclaimer = defaultMedicalDisclaimer
+		}
+		block.Data = map[string]interface{}{"text": disclaimer}
+	}
+
+	return renderBlock(block, products, disclaimer)
+}
+
+// gridProducts returns the products a grid shows: the listed ids in order,
+// or the category's products, capped at the grid's limit
+func (s *SiteContentService) gridProducts(props map[string]interface{}) ([]ProductCard, error) {
+	cards := []ProductCard{}
+	if s.catalog == nil {
+		return cards, nil
+	}
+	products, err := s.catalog.GetProducts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load products: %w", err)
+	}
+
+	limit := 8
+	switch v := props["limit"].(type) {
+	case int:
+		limit = v
+	case float64:
+		limit = int(v)
+	}
+
+	byID := make(map[string]ThorneProduct, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	var selected []ThorneProduct
+	if ids := blockProductIDs(props); len(ids) > 0 {
+		for _, id := range ids {
+			if product, ok := byID[id]; ok {
+				selected = append(selected, product)
+			}
+		}
+	} else if category, ok := props["category"].(string); ok {
+		for _, product := range products {
+			if product.Category == category {
+				selected = append(selected, product)
+			}
+		}
+	}
+
+	for _, product := range selected {
+		if len(cards) == limit {
+			break
+		}
+		cards = append(cards, ProductCard{
+			ID:       product.ID,
+			Name:     product.Name,
+			ImageURL: product.ImageURL,
+			Price:    product.RetailPrice,
+			InStock:  product.InStock,
+		})
+	}
+	return cards, nil
+}
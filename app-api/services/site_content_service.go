@@ -0,0 +1,543 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Page statuses
+const (
+	PageStatusDraft     = "draft"
+	PageStatusPublished = "published"
+	PageStatusArchived  = "archived"
+)
+
+var pageSlugPattern = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// SiteContentService manages site-level content: pages, navigation menus,
+// typed settings, data collections, block components, taxonomies and redirects. Every method takes a site reference that may be either
+// the site's slug or its UUID.
+type SiteContentService struct {
+	db        *sql.DB
+	logger    Logger
+	catalog   BlockCatalog
+	pageCache *PageCache
+}
+
+// SitePage is a node in a site's page hierarchy
+type SitePage struct {
+	ID        string      `json:"id"`
+	SiteID    string      `json:"site_id"`
+	ParentID  string      `json:"parent_id,omitempty"`
+	Title     string      `json:"title"`
+	Slug      string      `json:"slug"`
+	Path      string      `json:"path"`
+	Content   string      `json:"content,omitempty"`
+	Blocks    BlockList   `json:"blocks,omitempty"`
+	Status    string      `json:"status"`
+	SortOrder int         `json:"sort_order"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Children  []*SitePage `json:"children,omitempty"`
+}
+
+// SitePageInput is the writable part of a page
+type SitePageInput struct {
+	ParentID  string  `json:"parent_id"`
+	Title     string  `json:"title"`
+	Slug      string  `json:"slug"`
+	Content   string  `json:"content"`
+	Blocks    []Block `json:"blocks"`
+	Status    string  `json:"status"`
+	SortOrder int     `json:"sort_order"`
+}
+
+// NewSiteContentService creates a new site content service
+func NewSiteContentService(db *sql.DB, logger Logger) *SiteContentService {
+	return &SiteContentService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetPageCache sets the public page cache to purge when pages or terms change
+func (s *SiteContentService) SetPageCache(cache *PageCache) {
+	s.pageCache = cache
+}
+
+// ResolveSiteID maps a site slug or UUID to the site's UUID
+func (s *SiteContentService) ResolveSiteID(siteRef string) (string, error) {
+	return resolveSiteID(s.db, siteRef)
+}
+
+func resolveSiteID(db *sql.DB, siteRef string) (string, error) {
+	var siteID string
+	err := db.QueryRow(`
+		SELECT id FROM sites
+		WHERE (id::TEXT = $1 OR slug = $1) AND deleted_at IS NULL
+		ORDER BY (id::TEXT = $1) DESC, created_at
+		LIMIT 1`, siteRef).Scan(&siteID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("site not found")
+		}
+		return "", fmt.Errorf("failed to resolve site: %w", err)
+	}
+	return siteID, nil
+}
+
+// ResolveSiteByHost finds the site served on a host name, matched against
+// the domains table and the site's own domain; any port is ignored
+func (s *SiteContentService) ResolveSiteByHost(host string) (string, error) {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	var siteID string
+	err := s.db.QueryRow(`
+		SELECT s.id FROM sites s
+		WHERE s.deleted_at IS NULL AND (LOWER(s.domain) = $1
+		OR EXISTS (SELECT 1 FROM domains d WHERE d.site_id = s.id AND LOWER(d.domain_name) = $1))
+		ORDER BY s.created_at
+		LIMIT 1`, host).Scan(&siteID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("site not found")
+		}
+		return "", fmt.Errorf("failed to resolve site: %w", err)
+	}
+	return siteID, nil
+}
+
+// SetSiteTemplate switches the theme a site's public pages use
+func (s *SiteContentService) SetSiteTemplate(siteRef, template string) error {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`UPDATE sites SET template = $2, updated_at = NOW() WHERE id = $1`, siteID, template); err != nil {
+		return fmt.Errorf("failed to update site template: %w", err)
+	}
+
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("site_content_service", "set_site_template", "Site template changed", map[string]interface{}{
+		"site_id":  siteID,
+		"template": template,
+	})
+	return nil
+}
+
+// ListPages returns the site's pages as a tree ordered by sort_order. Public
+// callers pass publishedOnly, which also hides published pages whose
+// ancestors are not published.
+func (s *SiteContentService) ListPages(siteRef string, publishedOnly bool) ([]*SitePage, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, site_id, COALESCE(parent_id::TEXT, ''), title, slug, path, status, sort_order, created_at, updated_at
+		FROM site_pages
+		WHERE site_id = $1
+		ORDER BY sort_order, title`, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []*SitePage
+	for rows.Next() {
+		page := &SitePage{}
+		if err := rows.Scan(&page.ID, &page.SiteID, &page.ParentID, &page.Title, &page.Slug, &page.Path,
+			&page.Status, &page.SortOrder, &page.CreatedAt, &page.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan page: %w", err)
+		}
+		if publishedOnly && page.Status != PageStatusPublished {
+			continue
+		}
+		pages = append(pages, page)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list pages: %w", err)
+	}
+
+	return buildPageTree(pages), nil
+}
+
+// buildPageTree nests pages under their parents; pages whose parent is
+// missing from the list are dropped so hidden subtrees stay hidden.
+func buildPageTree(pages []*SitePage) []*SitePage {
+	byID := make(map[string]*SitePage, len(pages))
+	for _, page := range pages {
+		byID[page.ID] = page
+	}
+
+	roots := []*SitePage{}
+	for _, page := range pages {
+		if page.ParentID == "" {
+			roots = append(roots, page)
+		} else if parent, ok := byID[page.ParentID]; ok {
+			parent.Children = append(parent.Children, page)
+		}
+	}
+	return roots
+}
+
+// GetPageByPath returns a page by its full path (e.g. "about/team")
+func (s *SiteContentService) GetPageByPath(siteRef, path string, publishedOnly bool) (*SitePage, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	path = strings.Trim(path, "/")
+	page, err := s.scanPage(s.db.QueryRow(pageSelect+` WHERE site_id = $1 AND path = $2`, siteID, path))
+	if err != nil {
+		return nil, err
+	}
+
+	if publishedOnly {
+		// A page is only public when it and every ancestor are published
+		var hidden int
+		err := s.db.QueryRow(`
+			SELECT COUNT(*) FROM site_pages
+			WHERE site_id = $1 AND status != 'published' AND ($2 = path OR $2 LIKE path || '/%')`,
+			siteID, path).Scan(&hidden)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check page visibility: %w", err)
+		}
+		if hidden > 0 {
+			return nil, fmt.Errorf("page not found")
+		}
+	}
+
+	children, err := s.childPages(siteID, page.ID, publishedOnly)
+	if err != nil {
+		return nil, err
+	}
+	page.Children = children
+
+	return page, nil
+}
+
+// GetPage returns a page by ID for the admin API
+func (s *SiteContentService) GetPage(siteRef, pageID string) (*SitePage, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.getPage(s.db, siteID, pageID)
+}
+
+const pageSelect = `
+	SELECT id, site_id, COALESCE(parent_id::TEXT, ''), title, slug, path, content, blocks, status, sort_order, created_at, updated_at
+	FROM site_pages`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *SiteContentService) getPage(q queryRower, siteID, pageID string) (*SitePage, error) {
+	return s.scanPage(q.QueryRow(pageSelect+` WHERE site_id = $1 AND id::TEXT = $2`, siteID, pageID))
+}
+
+func (s *SiteContentService) scanPage(row rowScanner) (*SitePage, error) {
+	page := &SitePage{}
+	err := row.Scan(&page.ID, &page.SiteID, &page.ParentID, &page.Title, &page.Slug, &page.Path,
+		&page.Content, &page.Blocks, &page.Status, &page.SortOrder, &page.CreatedAt, &page.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("page not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve page: %w", err)
+	}
+	return page, nil
+}
+
+func (s *SiteContentService) childPages(siteID, parentID string, publishedOnly bool) ([]*SitePage, error) {
+	rows, err := s.db.Query(`
+		SELECT id, title, slug, path, status, sort_order
+		FROM site_pages
+		WHERE site_id = $1 AND parent_id = $2
+		ORDER BY sort_order, title`, siteID, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list child pages: %w", err)
+	}
+	defer rows.Close()
+
+	var children []*SitePage
+	for rows.Next() {
+		child := &SitePage{SiteID: siteID, ParentID: parentID}
+		if err := rows.Scan(&child.ID, &child.Title, &child.Slug, &child.Path, &child.Status, &child.SortOrder); err != nil {
+			return nil, fmt.Errorf("failed to scan child page: %w", err)
+		}
+		if publishedOnly && child.Status != PageStatusPublished {
+			continue
+		}
+		children = append(children, child)
+	}
+	return children, rows.Err()
+}
+
+// CreatePage adds a page under its parent (or at the root)
+func (s *SiteContentService) CreatePage(siteRef string, input SitePageInput) (*SitePage, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizePageInput(&input); err != nil {
+		return nil, err
+	}
+	blocks, err := s.pageBlocks(siteID, &input)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	path := input.Slug
+	if input.ParentID != "" {
+		parent, err := s.getPage(tx, siteID, input.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("parent page not found")
+		}
+		path = parent.Path + "/" + input.Slug
+	}
+
+	var pageID string
+	err = tx.QueryRow(`
+		INSERT INTO site_pages (site_id, parent_id, title, slug, path, content, blocks, status, sort_order)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`, siteID, nullableString(input.ParentID), input.Title, input.Slug, path,
+		input.Content, blocks, input.Status, input.SortOrder).Scan(&pageID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("a page already exists at /%s", path)
+		}
+		return nil, fmt.Errorf("failed to create page: %w", err)
+	}
+
+	page, err := s.getPage(tx, siteID, pageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit page: %w", err)
+	}
+
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("site_content_service", "create_page", "Page created", map[string]interface{}{
+		"site_id": siteID,
+		"page_id": pageID,
+		"path":    path,
+	})
+
+	return page, nil
+}
+
+// UpdatePage rewrites a page. Moving it or changing its slug rewrites the
+// paths of all its descendants in the same transaction.
+func (s *SiteContentService) UpdatePage(siteRef, pageID string, input SitePageInput) (*SitePage, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizePageInput(&input); err != nil {
+		return nil, err
+	}
+	blocks, err := s.pageBlocks(siteID, &input)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := s.scanPage(tx.QueryRow(pageSelect+` WHERE site_id = $1 AND id::TEXT = $2 FOR UPDATE`, siteID, pageID))
+	if err != nil {
+		return nil, err
+	}
+
+	path := input.Slug
+	if input.ParentID != "" {
+		if input.ParentID == current.ID {
+			return nil, fmt.Errorf("a page cannot be its own parent")
+		}
+		parent, err := s.getPage(tx, siteID, input.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("parent page not found")
+		}
+		if strings.HasPrefix(parent.Path+"/", current.Path+"/") {
+			return nil, fmt.Errorf("a page cannot be moved under its own descendant")
+		}
+		path = parent.Path + "/" + input.Slug
+	}
+
+	_, err = tx.Exec(`
+		UPDATE site_pages
+		SET parent_id = $1, title = $2, slug = $3, path = $4, content = $5, blocks = $6, status = $7,
+		sort_order = $8, updated_at = $9
+		WHERE id = $10`, nullableString(input.ParentID), input.Title, input.Slug, path, input.Content, blocks,
+		input.Status, input.SortOrder, time.Now(), current.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("a page already exists at /%s", path)
+		}
+		return nil, fmt.Errorf("failed to update page: %w", err)
+	}
+
+	if path != current.Path {
+		_, err = tx.Exec(`
+			UPDATE site_pages
+			SET path = $1 || substr(path, $2), updated_at = $3
+			WHERE site_id = $4 AND path LIKE $5`,
+			path, len(current.Path)+1, time.Now(), siteID, likePrefix(current.Path)+"/%")
+		if err != nil {
+			if strings.Contains(err.Error(), "duplicate key") {
+				return nil, fmt.Errorf("moving the page would collide with an existing page path")
+			}
+			return nil, fmt.Errorf("failed to update descendant paths: %w", err)
+		}
+	}
+
+	page, err := s.getPage(tx, siteID, current.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit page: %w", err)
+	}
+
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("site_content_service", "update_page", "Page updated", map[string]interface{}{
+		"site_id":  siteID,
+		"page_id":  current.ID,
+		"path":     path,
+		"old_path": current.Path,
+	})
+
+	return page, nil
+}
+
+// DeletePage removes a page that has no child pages. Menu items pointing at
+// it are removed with it.
+func (s *SiteContentService) DeletePage(siteRef, pageID string) error {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return err
+	}
+
+	var children int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM site_pages WHERE site_id = $1 AND parent_id::TEXT = $2`,
+		siteID, pageID).Scan(&children); err != nil {
+		return fmt.Errorf("failed to check child pages: %w", err)
+	}
+	if children > 0 {
+		return fmt.Errorf("page has %d child pages; move or delete them first", children)
+	}
+
+	result, err := s.db.Exec(`DELETE FROM site_pages WHERE site_id = $1 AND id::TEXT = $2`, siteID, pageID)
+	if err != nil {
+		return fmt.Errorf("failed to delete page: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("page not found")
+	}
+
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("site_content_service", "delete_page", "Page deleted", map[string]interface{}{
+		"site_id": siteID,
+		"page_id": pageID,
+	})
+
+	return nil
+}
+
+// pageBlocks validates a page's blocks; when a page has blocks its content
+// becomes their rendered HTML
+func (s *SiteContentService) pageBlocks(siteID string, input *SitePageInput) (BlockList, error) {
+	if len(input.Blocks) == 0 {
+		return nil, nil
+	}
+	blocks, html, err := s.PrepareBlocks(siteID, input.Blocks)
+	if err != nil {
+		return nil, err
+	}
+	input.Content = html
+	return blocks, nil
+}
+
+func normalizePageInput(input *SitePageInput) error {
+	input.Title = strings.TrimSpace(input.Title)
+	if input.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if input.Slug == "" {
+		input.Slug = Slugify(input.Title)
+	}
+	if !pageSlugPattern.MatchString(input.Slug) {
+		return fmt.Errorf("slug must be lowercase letters, digits and single hyphens")
+	}
+	switch input.Status {
+	case "":
+		input.Status = PageStatusDraft
+	case PageStatusDraft, PageStatusPublished, PageStatusArchived:
+	default:
+		return fmt.Errorf("status must be one of: draft, published, archived")
+	}
+	return nil
+}
+
+// likePrefix escapes LIKE wildcards in a literal prefix
+func likePrefix(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// Slugify converts a string to a URL-friendly slug: lowercase ASCII letters
+// and digits, with every other run of characters collapsed into one hyphen
+func Slugify(s string) string {
+	var result strings.Builder
+	lastHyphen := true
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			result.WriteRune(r)
+			lastHyphen = false
+		case !lastHyphen:
+			result.WriteRune('-')
+			lastHyphen = true
+		}
+	}
+	return strings.TrimSuffix(result.String(), "-")
+}
+
+// PagePath is the public URL path of a page
+func PagePath(path string) string {
+	return "/" + path
+}
+
+// PostPath is the public URL path of a post
+func PostPath(slug string) string {
+	return "/posts/" + slug
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Navigation item types
+const (
+	NavItemPage = "page"
+	NavItemPost = "post"
+	NavItemURL  = "url"
+)
+
+// maxNavigationDepth bounds how deeply menu items may nest
+const maxNavigationDepth = 5
+
+var menuLocationPattern = regexp.MustCompile(`^[a-z0-9]+(?:[-_][a-z0-9]+)*$`)
+
+// NavigationMenu is a named menu shown at a location such as "main" or "footer"
+type NavigationMenu struct {
+	ID        string            `json:"id"`
+	SiteID    string            `json:"site_id"`
+	Name      string            `json:"name"`
+	Location  string            `json:"location"`
+	Items     []*NavigationItem `json:"items"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// NavigationItem is one menu entry. Href is resolved from the target page
+// or post when the menu is read.
+type NavigationItem struct {
+	ID           string            `json:"id,omitempty"`
+	Label        string            `json:"label"`
+	Type         string            `json:"type"`
+	PageID       string            `json:"page_id,omitempty"`
+	PostID       string            `json:"post_id,omitempty"`
+	URL          string            `json:"url,omitempty"`
+	Href         string            `json:"href,omitempty"`
+	OpenInNewTab bool              `json:"open_in_new_tab"`
+	Children     []*NavigationItem `json:"children,omitempty"`
+
+	parentID  string
+	sortOrder int
+	visible   bool
+}
+
+// NavigationMenuInput is the writable part of a menu; Items replaces the
+// whole item tree when non-nil
+type NavigationMenuInput struct {
+	Name     string            `json:"name"`
+	Location string            `json:"location"`
+	Items    []*NavigationItem `json:"items"`
+}
+
+// ListMenus returns a site's menus without their items
+func (s *SiteContentService) ListMenus(siteRef string) ([]*NavigationMenu, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, site_id, name, location, created_at, updated_at
+		FROM navigation_menus
+		WHERE site_id = $1
+		ORDER BY location`, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list menus: %w", err)
+	}
+	defer rows.Close()
+
+	menus := []*NavigationMenu{}
+	for rows.Next() {
+		menu := &NavigationMenu{}
+		if err := rows.Scan(&menu.ID, &menu.SiteID, &menu.Name, &menu.Location, &menu.CreatedAt, &menu.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan menu: %w", err)
+		}
+		menus = append(menus, menu)
+	}
+	return menus, rows.Err()
+}
+
+// GetMenuByLocation returns a menu with its resolved item tree. Public
+// callers pass publishedOnly to drop items pointing at unpublished pages or
+// posts, together with their children.
+func (s *SiteContentService) GetMenuByLocation(siteRef, location string, publishedOnly bool) (*NavigationMenu, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadMenu(siteID, `location = $2`, location, publishedOnly)
+}
+
+// GetMenu returns a menu by ID for the admin API, including hidden items
+func (s *SiteContentService) GetMenu(siteRef, menuID string) (*NavigationMenu, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadMenu(siteID, `id::TEXT = $2`, menuID, false)
+}
+
+func (s *SiteContentService) loadMenu(siteID, where, value string, publishedOnly bool) (*NavigationMenu, error) {
+	menu := &NavigationMenu{}
+	err := s.db.QueryRow(`
+		SELECT id, site_id, name, location, created_at, updated_at
+		FROM navigation_menus
+		WHERE site_id = $1 AND `+where, siteID, value).Scan(&menu.ID, &menu.SiteID, &menu.Name,
+		&menu.Location, &menu.CreatedAt, &menu.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("menu not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve menu: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT i.id, COALESCE(i.parent_id::TEXT, ''), i.label, i.item_type, COALESCE(i.page_id::TEXT, ''),
+		COALESCE(i.post_id::TEXT, ''), COALESCE(i.url, ''), i.sort_order, COALESCE(i.open_in_new_tab, false),
+		COALESCE(pg.path, ''), COALESCE(pg.status, ''), COALESCE(p.slug, ''),
+		COALESCE(p.published AND p.deleted_at IS NULL, false)
+		FROM navigation_items i
+		LEFT JOIN site_pages pg ON i.page_id = pg.id
+		LEFT JOIN posts p ON i.post_id = p.id
+		WHERE i.menu_id = $1
+		ORDER BY i.sort_order, i.label`, menu.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load menu items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*NavigationItem
+	for rows.Next() {
+		item := &NavigationItem{}
+		var pagePath, pageStatus, postSlug string
+		var postPublished bool
+		if err := rows.Scan(&item.ID, &item.parentID, &item.Label, &item.Type, &item.PageID, &item.PostID,
+			&item.URL, &item.sortOrder, &item.OpenInNewTab, &pagePath, &pageStatus, &postSlug, &postPublished); err != nil {
+			return nil, fmt.Errorf("failed to scan menu item: %w", err)
+		}
+
+		switch item.Type {
+		case NavItemPage:
+			item.Href = PagePath(pagePath)
+			item.visible = pageStatus == PageStatusPublished
+		case NavItemPost:
+			item.Href = PostPath(postSlug)
+			item.visible = postPublished
+		default:
+			item.Href = item.URL
+			item.visible = true
+		}
+		if publishedOnly && !item.visible {
+			continue
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load menu items: %w", err)
+	}
+
+	menu.Items = buildNavigationTree(items)
+	return menu, nil
+}
+
+// buildNavigationTree nests items under their parents; orphans (children of
+// hidden items) are dropped
+func buildNavigationTree(items []*NavigationItem) []*NavigationItem {
+	byID := make(map[string]*NavigationItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	roots := []*NavigationItem{}
+	for _, item := range items {
+		if item.parentID == "" {
+			roots = append(roots, item)
+		} else if parent, ok := byID[item.parentID]; ok {
+			parent.Children = append(parent.Children, item)
+		}
+	}
+	return roots
+}
+
+// CreateMenu creates a menu and its items
+func (s *SiteContentService) CreateMenu(siteRef string, input NavigationMenuInput) (*NavigationMenu, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMenuInput(&input); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var menuID string
+	err = tx.QueryRow(`
+		INSERT INTO navigation_menus (site_id, name, location)
+		VALUES ($1, $2, $3)
+		RETURNING id`, siteID, input.Name, input.Location).Scan(&menuID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("a menu already exists at location %q", input.Location)
+		}
+		return nil, fmt.Errorf("failed to create menu: %w", err)
+	}
+
+	if err := s.insertNavigationItems(tx, siteID, menuID, "", input.Items, 1); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit menu: %w", err)
+	}
+
+	s.logger.Info("site_content_service", "create_menu", "Menu created", map[string]interface{}{
+		"site_id":  siteID,
+		"menu_id":  menuID,
+		"location": input.Location,
+	})
+
+	return s.loadMenu(siteID, `id::TEXT = $2`, menuID, false)
+}
+
+// UpdateMenu renames or relocates a menu and, when Items is non-nil,
+// replaces its whole item tree atomically
+func (s *SiteContentService) UpdateMenu(siteRef, menuID string, input NavigationMenuInput) (*NavigationMenu, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMenuInput(&input); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE navigation_menus SET name = $1, location = $2, updated_at = $3
+		WHERE site_id = $4 AND id::TEXT = $5`, input.Name, input.Location, time.Now(), siteID, menuID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("a menu already exists at location %q", input.Location)
+		}
+		return nil, fmt.Errorf("failed to update menu: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return nil, fmt.Errorf("menu not found")
+	}
+
+	if input.Items != nil {
+		// Delete children before parents so the self-reference never dangles
+		if _, err := tx.Exec(`DELETE FROM navigation_items WHERE menu_id = $1 AND parent_id IS NOT NULL`, menuID); err != nil {
+			return nil, fmt.Errorf("failed to clear menu items: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM navigation_items WHERE menu_id = $1`, menuID); err != nil {
+			return nil, fmt.Errorf("failed to clear menu items: %w", err)
+		}
+		if err := s.insertNavigationItems(tx, siteID, menuID, "", input.Items, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit menu: %w", err)
+	}
+
+	s.logger.Info("site_content_service", "update_menu", "Menu updated", map[string]interface{}{
+		"site_id":  siteID,
+		"menu_id":  menuID,
+		"location": input.Location,
+	})
+
+	return s.loadMenu(siteID, `id::TEXT = $2`, menuID, false)
+}
+
+// DeleteMenu removes a menu and all its items
+func (s *SiteContentService) DeleteMenu(siteRef, menuID string) error {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`DELETE FROM navigation_menus WHERE site_id = $1 AND id::TEXT = $2`, siteID, menuID)
+	if err != nil {
+		return fmt.Errorf("failed to delete menu: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("menu not found")
+	}
+	return nil
+}
+
+// insertNavigationItems writes items in order, recursing into children. The
+// position in the slice is the stored sort order.
+func (s *SiteContentService) insertNavigationItems(tx *sql.Tx, siteID, menuID, parentID string, items []*NavigationItem, depth int) error {
+	if len(items) > 0 && depth > maxNavigationDepth {
+		return fmt.Errorf("menu items may nest at most %d levels deep", maxNavigationDepth)
+	}
+
+	for position, item := range items {
+		if err := s.validateNavigationTarget(tx, siteID, item); err != nil {
+			return err
+		}
+
+		var itemID string
+		err := tx.QueryRow(`
+			INSERT INTO navigation_items (menu_id, parent_id, label, item_type, page_id, post_id, url, sort_order, open_in_new_tab)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id`, menuID, nullableString(parentID), item.Label, item.Type, nullableString(item.PageID),
+			nullableString(item.PostID), nullableString(item.URL), position, item.OpenInNewTab).Scan(&itemID)
+		if err != nil {
+			return fmt.Errorf("failed to save menu item %q: %w", item.Label, err)
+		}
+
+		if err := s.insertNavigationItems(tx, siteID, menuID, itemID, item.Children, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNavigationTarget checks an item's label and that its target exists
+// in the same site
+func (s *SiteContentService) validateNavigationTarget(tx *sql.Tx, siteID string, item *NavigationItem) error {
+	item.Label = strings.TrimSpace(item.Label)
+	if item.Label == "" {
+		return fmt.Errorf("every menu item needs a label")
+	}
+
+	var exists bool
+	var err error
+	switch item.Type {
+	case NavItemPage:
+		item.PostID, item.URL = "", ""
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM site_pages WHERE site_id = $1 AND id::TEXT = $2)`,
+			siteID, item.PageID).Scan(&exists)
+	case NavItemPost:
+		item.PageID, item.URL = "", ""
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM posts WHERE site_id = $1 AND id::TEXT = $2 AND deleted_at IS NULL)`,
+			siteID, item.PostID).Scan(&exists)
+	case NavItemURL:
+		item.PageID, item.PostID = "", ""
+		if !isNavigableURL(item.URL) {
+			return fmt.Errorf("menu item %q has an invalid url", item.Label)
+		}
+		return nil
+	default:
+		return fmt.Errorf("menu item %q must have type page, post or url", item.Label)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to check menu item target: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("menu item %q points at a %s that does not exist in this site", item.Label, item.Type)
+	}
+	return nil
+}
+
+// isNavigableURL accepts absolute http(s) and mailto/tel links, and
+// site-relative paths
+func isNavigableURL(raw string) bool {
+	if strings.HasPrefix(raw, "/") {
+		// Browsers read "/\" like "//", as a link to another host
+		return !strings.HasPrefix(raw, "//") && !strings.HasPrefix(raw, "/\\")
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		return parsed.Host != ""
+	case "mailto", "tel":
+		return parsed.Opaque != ""
+	}
+	return false
+}
+
+func validateMenuInput(input *NavigationMenuInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !menuLocationPattern.MatchString(input.Location) {
+		return fmt.Errorf("location must be lowercase letters, digits, hyphens or underscores")
+	}
+	return nil
+}
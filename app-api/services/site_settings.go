@@ -0,0 +1,287 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Setting types accepted in site_settings.setting_type
+const (
+	SettingTypeString  = "string"
+	SettingTypeText    = "text"
+	SettingTypeNumber  = "number"
+	SettingTypeInteger = "integer"
+	SettingTypeBoolean = "boolean"
+	SettingTypeJSON    = "json"
+	SettingTypeURL     = "url"
+	SettingTypeEmail   = "email"
+	SettingTypeColor   = "color"
+)
+
+var (
+	settingKeyPattern   = regexp.MustCompile(`^[a-z][a-z0-9_.]{0,254}$`)
+	settingColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+)
+
+// SiteSetting is one typed key/value for a site. Value holds the decoded
+// value (bool, float64, int64, JSON or string) according to Type.
+type SiteSetting struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	Type      string      `json:"type"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// SiteSettingInput sets a setting; Value may be a JSON string, number,
+// boolean or (for json settings) any JSON value
+type SiteSettingInput struct {
+	Value json.RawMessage `json:"value"`
+	Type  string          `json:"type"`
+}
+
+// ListSettings returns every setting for a site with decoded values
+func (s *SiteContentService) ListSettings(siteRef string) ([]SiteSetting, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return listSiteSettings(s.db, siteID)
+}
+
+func listSiteSettings(db *sql.DB, siteID string) ([]SiteSetting, error) {
+	rows, err := db.Query(`
+		SELECT setting_key, COALESCE(setting_value, ''), COALESCE(setting_type, 'string'), updated_at
+		FROM site_settings
+		WHERE site_id = $1
+		ORDER BY setting_key`, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := []SiteSetting{}
+	for rows.Next() {
+		var setting SiteSetting
+		var raw string
+		if err := rows.Scan(&setting.Key, &raw, &setting.Type, &setting.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan setting: %w", err)
+		}
+		setting.Value = decodeSettingValue(raw, setting.Type)
+		settings = append(settings, setting)
+	}
+	return settings, rows.Err()
+}
+
+// SettingsMap returns a site's settings as key → decoded value
+func (s *SiteContentService) SettingsMap(siteRef string) (map[string]interface{}, error) {
+	settings, err := s.ListSettings(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(settings))
+	for _, setting := range settings {
+		values[setting.Key] = setting.Value
+	}
+	return values, nil
+}
+
+// GetSetting returns one setting
+func (s *SiteContentService) GetSetting(siteRef, key string) (*SiteSetting, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	setting := &SiteSetting{Key: key}
+	var raw string
+	err = s.db.QueryRow(`
+		SELECT COALESCE(setting_value, ''), COALESCE(setting_type, 'string'), updated_at
+		FROM site_settings
+		WHERE site_id = $1 AND setting_key = $2`, siteID, key).Scan(&raw, &setting.Type, &setting.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("setting not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve setting: %w", err)
+	}
+	setting.Value = decodeSettingValue(raw, setting.Type)
+	return setting, nil
+}
+
+// SetSetting validates a value against its type and upserts it. The type
+// defaults to the existing setting's type, then to string.
+func (s *SiteContentService) SetSetting(siteRef, key string, input SiteSettingInput) (*SiteSetting, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if !settingKeyPattern.MatchString(key) {
+		return nil, fmt.Errorf("setting key must start with a letter and use lowercase letters, digits, dots or underscores")
+	}
+
+	if input.Type == "" {
+		err := s.db.QueryRow(`SELECT COALESCE(setting_type, 'string') FROM site_settings WHERE site_id = $1 AND setting_key = $2`,
+			siteID, key).Scan(&input.Type)
+		if err == sql.ErrNoRows {
+			input.Type = SettingTypeString
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to retrieve setting: %w", err)
+		}
+	}
+
+	stored, err := encodeSettingValue(input.Value, input.Type)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s setting %q: %v", input.Type, key, err)
+	}
+	if err := checkLocaleSetting(key, stored); err != nil {
+		return nil, err
+	}
+	if err := checkCommentSetting(key, stored); err != nil {
+		return nil, err
+	}
+
+	setting := &SiteSetting{Key: key, Type: input.Type}
+	err = s.db.QueryRow(`
+		INSERT INTO site_settings (site_id, setting_key, setting_value, setting_type)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (site_id, setting_key)
+		DO UPDATE SET setting_value = excluded.setting_value, setting_type = excluded.setting_type, updated_at = NOW()
+		RETURNING updated_at`, siteID, key, stored, input.Type).Scan(&setting.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save setting: %w", err)
+	}
+	setting.Value = decodeSettingValue(stored, input.Type)
+
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("site_content_service", "set_setting", "Setting saved", map[string]interface{}{
+		"site_id": siteID,
+		"key":     key,
+		"type":    input.Type,
+	})
+
+	return setting, nil
+}
+
+// DeleteSetting removes a setting
+func (s *SiteContentService) DeleteSetting(siteRef, key string) error {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`DELETE FROM site_settings WHERE site_id = $1 AND setting_key = $2`, siteID, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete setting: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("setting not found")
+	}
+	s.pageCache.PurgeSite(siteID)
+	return nil
+}
+
+// encodeSettingValue validates a JSON-encoded value for the setting type
+// and returns the text stored in setting_value
+func encodeSettingValue(value json.RawMessage, settingType string) (string, error) {
+	if len(value) == 0 {
+		return "", fmt.Errorf("value is required")
+	}
+
+	if settingType == SettingTypeJSON {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, value); err != nil {
+			return "", fmt.Errorf("not valid JSON")
+		}
+		return compact.String(), nil
+	}
+
+	// Scalars may be sent as their JSON type or as a string
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		text = strings.TrimSpace(string(value))
+		if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+			return "", fmt.Errorf("objects and arrays need type json")
+		}
+	}
+
+	switch settingType {
+	case SettingTypeString:
+		if strings.ContainsAny(text, "\r\n") {
+			return "", fmt.Errorf("must be a single line (use type text)")
+		}
+		if len(text) > 1024 {
+			return "", fmt.Errorf("must be at most 1024 characters")
+		}
+	case SettingTypeText:
+	case SettingTypeNumber:
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return "", fmt.Errorf("must be a number")
+		}
+		text = strconv.FormatFloat(number, 'f', -1, 64)
+	case SettingTypeInteger:
+		integer, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("must be an integer")
+		}
+		text = strconv.FormatInt(integer, 10)
+	case SettingTypeBoolean:
+		boolean, err := strconv.ParseBool(text)
+		if err != nil {
+			return "", fmt.Errorf("must be true or false")
+		}
+		text = strconv.FormatBool(boolean)
+	case SettingTypeURL:
+		parsed, err := url.Parse(text)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return "", fmt.Errorf("must be an absolute http or https URL")
+		}
+	case SettingTypeEmail:
+		address, err := mail.ParseAddress(text)
+		if err != nil || address.Address != text {
+			return "", fmt.Errorf("must be an email address")
+		}
+	case SettingTypeColor:
+		if !settingColorPattern.MatchString(text) {
+			return "", fmt.Errorf("must be a hex color like #1a2b3c")
+		}
+	default:
+		return "", fmt.Errorf("unknown setting type; use one of: string, text, number, integer, boolean, json, url, email, color")
+	}
+
+	return text, nil
+}
+
+// decodeSettingValue converts stored text to its typed value, falling back
+// to the raw string for rows written before validation existed
+func decodeSettingValue(raw, settingType string) interface{} {
+	switch settingType {
+	case SettingTypeNumber:
+		if number, err := strconv.ParseFloat(raw, 64); err == nil {
+			return number
+		}
+	case SettingTypeInteger:
+		if integer, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return integer
+		}
+	case SettingTypeBoolean:
+		if boolean, err := strconv.ParseBool(raw); err == nil {
+			return boolean
+		}
+	case SettingTypeJSON:
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(raw), &decoded); err == nil {
+			return decoded
+		}
+	}
+	return raw
+}
@@ -0,0 +1,1077 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Static export job statuses
+const (
+	StaticExportRunning   = "running"
+	StaticExportSucceeded = "succeeded"
+	StaticExportFailed    = "failed"
+)
+
+const (
+	// staticExportManifest is left in the output directory so the next
+	// export can skip unchanged pages and remove deleted ones
+	staticExportManifest = ".agoat-export.json"
+	// staticExportFormat is part of every manifest fingerprint; bump it
+	// when the file layout of exports changes
+	staticExportFormat = "1"
+	// maxStaticExportPages bounds the crawl of a site's pages
+	maxStaticExportPages = 100000
+	// maxStaticExportMissing bounds the broken links listed in a report
+	maxStaticExportMissing = 100
+	// staticExportJobsKept is how many finished jobs per site are remembered
+	staticExportJobsKept = 10
+)
+
+// staticFeedFiles are the syndication files an export holds at its root,
+// served live under /api/sites/{siteSlug}/
+var staticFeedFiles = []string{"feed.xml", "atom.xml", "feed.json", "sitemap.xml"}
+
+var (
+	staticSitemapPagePattern = regexp.MustCompile(`^sitemap-[0-9]+\.xml$`)
+	staticLinkAttrPattern    = regexp.MustCompile(`(?i)(\s(href|src|srcset|poster|action)\s*=\s*)("[^"]*"|'[^']*')`)
+	staticSitemapLocPattern  = regexp.MustCompile(`<loc>([^<]*)</loc>`)
+)
+
+// StaticResponse is one rendered URL of a site's public site
+type StaticResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+	// Location is the target of a redirect
+	Location string
+}
+
+// StaticFetcher renders a path of a site's public site, such as "/",
+// "/posts/hello", "/?page=2" or "/feed.xml", as a request to the site would
+type StaticFetcher func(site *SiteInfo, path string) (*StaticResponse, error)
+
+// StaticExportOptions control one export of a site
+type StaticExportOptions struct {
+	// OutputDir receives the site; an earlier export there is updated in
+	// place
+	OutputDir string `json:"-"`
+	// ArchivePath, when set, also packs the export into a .tar.gz
+	ArchivePath string `json:"-"`
+	// SiteHost is used for the site's URLs when it has no domain
+	SiteHost string `json:"-"`
+	// BaseURL is where the export will be served from, such as a CDN
+	// origin (https://cdn.example.com/blog) or a path prefix (/blog); links
+	// are made absolute under it. When empty, links are relative so the
+	// export works from any location.
+	BaseURL string `json:"base_url,omitempty"`
+	// Full renders every page again instead of reusing the unchanged
+	// pages of the previous export
+	Full bool `json:"full"`
+}
+
+// StaticExportReport summarises an export
+type StaticExportReport struct {
+	SiteID      string    `json:"site_id"`
+	Theme       string    `json:"theme"`
+	Incremental bool      `json:"incremental"`
+	Files       int       `json:"files"`
+	Rendered    int       `json:"rendered"`
+	Reused      int       `json:"reused"`
+	Redirects   int       `json:"redirects"`
+	Written     int       `json:"written"`
+	Unchanged   int       `json:"unchanged"`
+	Removed     int       `json:"removed"`
+	MediaCopied int       `json:"media_copied"`
+	Missing     []string  `json:"missing,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// StaticExportJob is an export started from the admin API
+type StaticExportJob struct {
+	ID               string              `json:"id"`
+	SiteID           string              `json:"site_id"`
+	Status           string              `json:"status"`
+	BaseURL          string              `json:"base_url,omitempty"`
+	Full             bool                `json:"full"`
+	Report           *StaticExportReport `json:"report,omitempty"`
+	Error            string              `json:"error,omitempty"`
+	RequestedBy      string              `json:"requested_by,omitempty"`
+	ArchiveAvailable bool                `json:"archive_available"`
+	StartedAt        time.Time           `json:"started_at"`
+	FinishedAt       *time.Time          `json:"finished_at,omitempty"`
+
+	archive string
+}
+
+// staticManifest records what an export wrote. Version identifies the
+// content a page was rendered from, so an incremental export can keep the
+// page without rendering it; Links are the site paths the page links to,
+// so kept pages still lead the crawl to theirs.
+type staticManifest struct {
+	Fingerprint string                        `json:"fingerprint"`
+	Files       map[string]staticManifestFile `json:"files"`
+}
+
+type staticManifestFile struct {
+	Hash    string   `json:"hash"`
+	Version string   `json:"version,omitempty"`
+	Links   []string `json:"links,omitempty"`
+}
+
+// StaticExportService writes a site's public site out as static files: its
+// posts, pages, tag listings, feeds, sitemap, theme assets and media, with
+// links rewritten to work without the server. Pages are rendered by the
+// fetcher, which runs the live site's handlers, so an export looks exactly
+// like the site.
+type StaticExportService struct {
+	db          *sql.DB
+	logger      Logger
+	siteContent *SiteContentService
+	themes      *ThemeEngine
+	media       *MediaService
+	pageCache   *PageCache
+	fetch       StaticFetcher
+	jobDir      string
+
+	mu      sync.Mutex
+	jobs    map[string]*StaticExportJob
+	running map[string]string
+}
+
+// NewStaticExportService creates a static export service; it can't export
+// until SetFetcher is called
+func NewStaticExportService(db *sql.DB, logger Logger, siteContent *SiteContentService, themes *ThemeEngine, media *MediaService, pageCache *PageCache) *StaticExportService {
+	return &StaticExportService{
+		db:          db,
+		logger:      logger,
+		siteContent: siteContent,
+		themes:      themes,
+		media:       media,
+		pageCache:   pageCache,
+		jobDir:      "exports",
+		jobs:        make(map[string]*StaticExportJob),
+		running:     make(map[string]string),
+	}
+}
+
+// SetFetcher sets how pages are rendered
+func (s *StaticExportService) SetFetcher(fetch StaticFetcher) {
+	s.fetch = fetch
+}
+
+// SetJobDir sets where admin API jobs keep each site's export and archives
+func (s *StaticExportService) SetJobDir(dir string) {
+	s.jobDir = dir
+}
+
+// ValidateStaticBaseURL checks an export base URL: an http(s) URL or an
+// absolute path, without query or fragment
+func ValidateStaticBaseURL(baseURL string) error {
+	if baseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || u.RawQuery != "" || u.Fragment != "" ||
+		(u.Scheme == "" && (u.Host != "" || !strings.HasPrefix(u.Path, "/"))) ||
+		(u.Scheme != "" && ((u.Scheme != "http" && u.Scheme != "https") || u.Host == "")) {
+		return fmt.Errorf("base_url must be an http(s) URL or an absolute path")
+	}
+	return nil
+}
+
+// Export exports a site into opts.OutputDir, and packs it into
+// opts.ArchivePath when that is set. Unless opts.Full is set, posts and
+// pages unchanged since the previous export into the same directory are
+// kept rather than rendered again; files are only rewritten when their
+// content changed, and files of the previous export that are no longer
+// part of the site are removed.
+func (s *StaticExportService) Export(siteRef string, opts StaticExportOptions) (*StaticExportReport, error) {
+	if s.fetch == nil {
+		return nil, fmt.Errorf("failed to export site: no page renderer configured")
+	}
+	if opts.OutputDir == "" {
+		return nil, fmt.Errorf("output directory is required")
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+	if err := ValidateStaticBaseURL(opts.BaseURL); err != nil {
+		return nil, err
+	}
+
+	site, err := s.siteContent.GetSiteInfo(siteRef, opts.SiteHost)
+	if err != nil {
+		return nil, err
+	}
+	theme := s.themes.Resolve(site.Template)
+	themeFingerprint, err := s.themes.Fingerprint(theme)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &staticExport{
+		s:          s,
+		site:       site,
+		theme:      theme,
+		opts:       opts,
+		root:       opts.OutputDir,
+		basePath:   "/s/" + site.Slug,
+		feedPrefix: "/api/sites/" + site.Slug + "/",
+		media:      make(map[string]string),
+		files:      make(map[string]staticManifestFile),
+		seen:       make(map[string]bool),
+		report:     &StaticExportReport{SiteID: site.ID, Theme: theme, StartedAt: time.Now()},
+	}
+	export.fingerprint = hashStrings(staticExportFormat, theme, themeFingerprint, opts.BaseURL,
+		site.Name, site.Slug, site.BaseURL, site.Description, site.Language, site.DefaultLocale)
+
+	if err := os.MkdirAll(export.root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	export.loadManifest()
+
+	// Pages come from the live site's handlers, which may hold copies
+	// rendered before the latest edits
+	s.pageCache.PurgeSite(site.ID)
+
+	if err := export.run(); err != nil {
+		return nil, err
+	}
+	if opts.ArchivePath != "" {
+		if err := export.archive(opts.ArchivePath); err != nil {
+			return nil, err
+		}
+	}
+
+	report := export.report
+	report.Files = len(export.files)
+	report.FinishedAt = time.Now()
+	s.logger.Info("static_export", "export", "Exported site", map[string]interface{}{
+		"site_id":     site.ID,
+		"theme":       theme,
+		"incremental": report.Incremental,
+		"files":       report.Files,
+		"rendered":    report.Rendered,
+		"reused":      report.Reused,
+		"written":     report.Written,
+		"removed":     report.Removed,
+		"duration_ms": report.FinishedAt.Sub(report.StartedAt).Milliseconds(),
+	})
+	return report, nil
+}
+
+// StartExport runs an export of a site in the background into the site's
+// directory under the job directory, packing it into a downloadable
+// archive. A site runs one export at a time.
+func (s *StaticExportService) StartExport(siteRef, userID, baseURL string, full bool, siteHost, ipAddress, userAgent string) (*StaticExportJob, error) {
+	siteID, err := s.siteContent.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if err := ValidateStaticBaseURL(baseURL); err != nil {
+		return nil, err
+	}
+
+	job := &StaticExportJob{
+		ID:          uuid.New().String(),
+		SiteID:      siteID,
+		Status:      StaticExportRunning,
+		BaseURL:     baseURL,
+		Full:        full,
+		RequestedBy: userID,
+		StartedAt:   time.Now(),
+	}
+	siteDir := filepath.Join(s.jobDir, siteID)
+	job.archive = filepath.Join(siteDir, "export-"+job.ID+".tar.gz")
+
+	s.mu.Lock()
+	if running, ok := s.running[siteID]; ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("an export is already running for this site (job %s)", running)
+	}
+	s.running[siteID] = job.ID
+	s.jobs[job.ID] = job
+	snapshot := *job
+	s.mu.Unlock()
+
+	s.audit(AuditEntry{
+		UserID:       userID,
+		Action:       "site.export",
+		ResourceType: "site",
+		ResourceID:   siteID,
+		Details: map[string]interface{}{
+			"job_id":   job.ID,
+			"base_url": baseURL,
+			"full":     full,
+		},
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+
+	go func() {
+		report, err := s.Export(siteID, StaticExportOptions{
+			OutputDir:   filepath.Join(siteDir, "site"),
+			ArchivePath: job.archive,
+			SiteHost:    siteHost,
+			BaseURL:     baseURL,
+			Full:        full,
+		})
+		s.finishJob(job, report, err)
+	}()
+	return &snapshot, nil
+}
+
+// finishJob records a job's outcome, removes the archives of the site's
+// earlier jobs and forgets its oldest finished jobs
+func (s *StaticExportService) finishJob(job *StaticExportJob, report *StaticExportReport, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Report = report
+	delete(s.running, job.SiteID)
+	if err != nil {
+		job.Status, job.Error = StaticExportFailed, err.Error()
+		os.Remove(job.archive)
+		s.logger.Error("static_export", "job", "Static export failed", map[string]interface{}{
+			"site_id": job.SiteID,
+			"job_id":  job.ID,
+			"error":   err.Error(),
+		})
+	} else {
+		job.Status, job.ArchiveAvailable = StaticExportSucceeded, true
+	}
+
+	var siteJobs []*StaticExportJob
+	for _, other := range s.jobs {
+		if other.SiteID == job.SiteID && other.Status != StaticExportRunning {
+			siteJobs = append(siteJobs, other)
+		}
+	}
+	sort.Slice(siteJobs, func(i, j int) bool { return siteJobs[i].StartedAt.After(siteJobs[j].StartedAt) })
+	for i, other := range siteJobs {
+		if other != job && other.ArchiveAvailable && job.ArchiveAvailable {
+			os.Remove(other.archive)
+			other.ArchiveAvailable = false
+		}
+		if i >= staticExportJobsKept {
+			delete(s.jobs, other.ID)
+		}
+	}
+}
+
+// ListJobs returns a site's remembered export jobs, newest first
+func (s *StaticExportService) ListJobs(siteRef string) ([]StaticExportJob, error) {
+	siteID, err := s.siteContent.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := []StaticExportJob{}
+	for _, job := range s.jobs {
+		if job.SiteID == siteID {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].StartedAt.After(jobs[j].StartedAt) })
+	return jobs, nil
+}
+
+// GetJob returns one of a site's export jobs
+func (s *StaticExportService) GetJob(siteRef, jobID string) (*StaticExportJob, error) {
+	siteID, err := s.siteContent.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if !ok || job.SiteID != siteID {
+		return nil, fmt.Errorf("export job not found")
+	}
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// JobArchive returns the archive file of a finished export job
+func (s *StaticExportService) JobArchive(siteRef, jobID string) (string, error) {
+	job, err := s.GetJob(siteRef, jobID)
+	if err != nil {
+		return "", err
+	}
+	if !job.ArchiveAvailable {
+		return "", fmt.Errorf("export archive not found")
+	}
+	return job.archive, nil
+}
+
+// audit records an audit entry; a failure is logged rather than failing
+// the request it describes
+func (s *StaticExportService) audit(entry AuditEntry) {
+	if err := RecordAudit(s.db, entry); err != nil {
+		s.logger.Error("static_export", "audit", "Failed to write audit log", map[string]interface{}{
+			"action": entry.Action,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// staticExport is the state of one export run
+type staticExport struct {
+	s           *StaticExportService
+	site        *SiteInfo
+	theme       string
+	opts        StaticExportOptions
+	root        string
+	basePath    string
+	feedPrefix  string
+	fingerprint string
+
+	// media maps media URLs to their files in the export
+	media    map[string]string
+	previous staticManifest
+	files    map[string]staticManifestFile
+	queue    []staticSeed
+	seen     map[string]bool
+	report   *StaticExportReport
+}
+
+// staticSeed is a site path to export; pages with a version may be kept
+// from the previous export when their version is unchanged
+type staticSeed struct {
+	path    string
+	version string
+}
+
+func (x *staticExport) run() error {
+	if err := x.copyMedia(); err != nil {
+		return err
+	}
+	if err := x.copyThemeAssets(); err != nil {
+		return err
+	}
+	if err := x.seed(); err != nil {
+		return err
+	}
+
+	for len(x.queue) > 0 {
+		next := x.queue[0]
+		x.queue = x.queue[1:]
+		if err := x.exportPage(next); err != nil {
+			return err
+		}
+	}
+
+	if err := x.exportNotFound(); err != nil {
+		return err
+	}
+	robots := "User-agent: *\nAllow: /\nSitemap: " + x.absoluteURL("sitemap.xml") + "\n"
+	if err := x.write("robots.txt", []byte(robots), "", nil); err != nil {
+		return err
+	}
+
+	x.removeStale()
+	return x.saveManifest()
+}
+
+// seed queues the site's fixed URLs and every published post, page and
+// tag listing. Pagination and anything else reachable by a link is found
+// while crawling.
+func (x *staticExport) seed() error {
+	x.enqueue("/", "")
+	for _, name := range staticFeedFiles {
+		x.enqueue("/"+name, "")
+	}
+
+	// A post page shows its tags and links its translations, so their
+	// changes are part of its version
+	rows, err := x.s.db.Query(`
+		SELECT p.slug, p.locale,
+			p.updated_at::TEXT || '|' || p.comment_count::TEXT || '|' ||
+			COALESCE((
+				SELECT COUNT(*)::TEXT || ':' || MAX(t.updated_at)::TEXT
+				FROM post_terms pt JOIN taxonomy_terms t ON t.id = pt.term_id
+				WHERE pt.post_id = p.id), '') || '|' ||
+			COALESCE((
+				SELECT COUNT(*)::TEXT || ':' || MAX(o.updated_at)::TEXT
+				FROM posts o
+				WHERE o.translation_group_id = p.translation_group_id
+					AND o.published = true AND o.deleted_at IS NULL), '')
+		FROM posts p
+		WHERE p.site_id = $1 AND p.published = true AND p.deleted_at IS NULL
+		ORDER BY p.id`, x.site.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list posts for export: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var slug, locale, version string
+		if err := rows.Scan(&slug, &locale, &version); err != nil {
+			return fmt.Errorf("failed to scan post for export: %w", err)
+		}
+		x.enqueue(LocalizedPostPath(slug, locale, x.site.DefaultLocale), "post|"+version)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list posts for export: %w", err)
+	}
+
+	if x.s.themes.HasPage(x.theme, ThemePagePage) {
+		// A page lists its published children
+		pageRows, err := x.s.db.Query(`
+			SELECT p.path,
+				p.updated_at::TEXT || '|' || COALESCE((
+					SELECT COUNT(*)::TEXT || ':' || MAX(c.updated_at)::TEXT
+					FROM site_pages c WHERE c.parent_id = p.id AND c.status = 'published'), '')
+			FROM site_pages p
+			WHERE p.site_id = $1 AND p.status = 'published'
+			ORDER BY p.path`, x.site.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list pages for export: %w", err)
+		}
+		defer pageRows.Close()
+		for pageRows.Next() {
+			var pagePath, version string
+			if err := pageRows.Scan(&pagePath, &version); err != nil {
+				return fmt.Errorf("failed to scan page for export: %w", err)
+			}
+			x.enqueue(PagePath(pagePath), "page|"+version)
+		}
+		if err := pageRows.Err(); err != nil {
+			return fmt.Errorf("failed to list pages for export: %w", err)
+		}
+	}
+
+	tagRows, err := x.s.db.Query(`
+		SELECT DISTINCT t.slug
+		FROM taxonomy_terms t
+		JOIN post_terms pt ON pt.term_id = t.id
+		JOIN posts p ON p.id = pt.post_id
+		WHERE t.site_id = $1 AND t.taxonomy = $2 AND p.published = true AND p.deleted_at IS NULL
+		ORDER BY t.slug`, x.site.ID, TaxonomyTag)
+	if err != nil {
+		return fmt.Errorf("failed to list tags for export: %w", err)
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var slug string
+		if err := tagRows.Scan(&slug); err != nil {
+			return fmt.Errorf("failed to scan tag for export: %w", err)
+		}
+		x.enqueue("/tags/"+url.PathEscape(slug), "")
+	}
+	if err := tagRows.Err(); err != nil {
+		return fmt.Errorf("failed to list tags for export: %w", err)
+	}
+	return nil
+}
+
+// enqueue adds a site path to the crawl unless it was seen already
+func (x *staticExport) enqueue(sitePath, version string) {
+	if x.seen[sitePath] || len(x.seen) >= maxStaticExportPages {
+		return
+	}
+	x.seen[sitePath] = true
+	x.queue = append(x.queue, staticSeed{path: sitePath, version: version})
+}
+
+// exportPage renders one site path and writes it, or keeps the previous
+// export's file when the page's version is unchanged
+func (x *staticExport) exportPage(seed staticSeed) error {
+	file, _, ok := x.fileFor(seed.path)
+	if !ok {
+		return nil
+	}
+	if seed.version != "" && x.report.Incremental {
+		if previous, ok := x.previous.Files[file]; ok && previous.Version == seed.version && x.exists(file) {
+			x.files[file] = previous
+			x.report.Reused++
+			for _, link := range previous.Links {
+				x.enqueue(link, "")
+			}
+			return nil
+		}
+	}
+
+	response, err := x.s.fetch(x.site, seed.path)
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %w", seed.path, err)
+	}
+	switch {
+	case response.Status == 200:
+	case response.Status >= 300 && response.Status < 400 && response.Location != "":
+		// Static hosts can't redirect, so a redirect becomes a page that
+		// sends the browser on
+		x.report.Redirects++
+		if _, sitePath, ok := x.fileFor(response.Location); ok && sitePath != "" {
+			x.enqueue(sitePath, "")
+		}
+		target := x.rewriteURL(file, response.Location)
+		stub := "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>Redirecting…</title>" +
+			"<meta http-equiv=\"refresh\" content=\"0; url=" + html.EscapeString(target) + "\">" +
+			"<link rel=\"canonical\" href=\"" + html.EscapeString(target) + "\"></head>" +
+			"<body><a href=\"" + html.EscapeString(target) + "\">Continue</a></body></html>\n"
+		return x.write(file, []byte(stub), "", nil)
+	case response.Status == 404 || response.Status == 410:
+		if len(x.report.Missing) < maxStaticExportMissing {
+			x.report.Missing = append(x.report.Missing, seed.path)
+		}
+		return nil
+	default:
+		return fmt.Errorf("failed to render %s: status %d", seed.path, response.Status)
+	}
+	x.report.Rendered++
+
+	body := response.Body
+	var links []string
+	switch {
+	case strings.HasPrefix(response.ContentType, "text/html"):
+		body, links = x.rewriteHTML(file, body)
+		for _, link := range links {
+			x.enqueue(link, "")
+		}
+	case strings.HasSuffix(file, ".xml") && strings.HasPrefix(file, "sitemap"):
+		// A sitemap index leads to the numbered sitemaps
+		for _, match := range staticSitemapLocPattern.FindAllSubmatch(body, -1) {
+			if _, sitePath, ok := x.fileFor(html.UnescapeString(string(match[1]))); ok && staticSitemapPagePattern.MatchString(strings.TrimPrefix(sitePath, "/")) {
+				x.enqueue(sitePath, "")
+			}
+		}
+	}
+	return x.write(file, x.rebase(body), seed.version, links)
+}
+
+// exportNotFound writes the theme's not_found page as 404.html, the name
+// static hosts serve for unknown paths. Its links are relative to the
+// export's root.
+func (x *staticExport) exportNotFound() error {
+	response, err := x.s.fetch(x.site, "/404.html")
+	if err != nil {
+		return fmt.Errorf("failed to render not found page: %w", err)
+	}
+	if response.Status != 404 || !strings.HasPrefix(response.ContentType, "text/html") {
+		return nil
+	}
+	body, _ := x.rewriteHTML("404.html", response.Body)
+	return x.write("404.html", x.rebase(body), "", nil)
+}
+
+// copyMedia copies the site's media library into media/, streaming only
+// files whose version changed since the previous export
+func (x *staticExport) copyMedia() error {
+	files, err := x.s.media.SiteFiles(x.site.ID)
+	if err != nil {
+		return err
+	}
+	for _, media := range files {
+		file, ok := cleanExportPath("media/" + media.Key)
+		if !ok {
+			continue
+		}
+		x.media[media.URL] = file
+		if previous, ok := x.previous.Files[file]; ok && previous.Version == media.Version && x.exists(file) {
+			x.files[file] = previous
+			x.report.Unchanged++
+			continue
+		}
+
+		reader, err := x.s.media.Store().Get(context.Background(), media.Key)
+		if err != nil {
+			return fmt.Errorf("failed to read media %s: %w", media.Key, err)
+		}
+		hash, err := x.writeStream(file, reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+		x.files[file] = staticManifestFile{Hash: hash, Version: media.Version}
+		x.report.MediaCopied++
+		x.report.Written++
+	}
+	return nil
+}
+
+// copyThemeAssets copies the theme's assets to assets/{theme}/, where the
+// theme's pages link them
+func (x *staticExport) copyThemeAssets() error {
+	assets, err := x.s.themes.AssetFiles(x.theme)
+	if err != nil {
+		return err
+	}
+	for name, source := range assets {
+		file, ok := cleanExportPath("assets/" + x.theme + "/" + name)
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(source)
+		if err != nil {
+			return fmt.Errorf("failed to read theme asset %s: %w", name, err)
+		}
+		version := strconv.FormatInt(info.Size(), 10) + "|" + info.ModTime().UTC().Format(time.RFC3339Nano)
+		if previous, ok := x.previous.Files[file]; ok && previous.Version == version && x.exists(file) {
+			x.files[file] = previous
+			x.report.Unchanged++
+			continue
+		}
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return fmt.Errorf("failed to read theme asset %s: %w", name, err)
+		}
+		if err := x.write(file, data, version, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileFor maps a URL found in the site to the file it becomes in the
+// export and its site path, such as "/posts/hello" to
+// "posts/hello/index.html" and "/?page=2" to "page/2/index.html". URLs
+// outside the site, and API or media URLs the export doesn't hold, aren't
+// mapped.
+func (x *staticExport) fileFor(raw string) (file, sitePath string, ok bool) {
+	if file, ok := x.media[strings.SplitN(raw, "#", 2)[0]]; ok {
+		return file, "", true
+	}
+	if strings.HasPrefix(raw, "//") {
+		return "", "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", false
+	}
+	if u.Scheme != "" || u.Host != "" {
+		if !strings.EqualFold(u.Scheme+"://"+u.Host, x.site.BaseURL) {
+			return "", "", false
+		}
+	} else if !strings.HasPrefix(u.Path, "/") {
+		return "", "", false
+	}
+
+	p := u.Path
+	if p == x.basePath || strings.HasPrefix(p, x.basePath+"/") {
+		p = strings.TrimPrefix(p, x.basePath)
+	} else if strings.HasPrefix(p, x.feedPrefix) {
+		name := strings.TrimPrefix(p, x.feedPrefix)
+		if !containsString(staticFeedFiles, name) && !staticSitemapPagePattern.MatchString(name) {
+			return "", "", false
+		}
+		p = "/" + name
+	}
+	if p == "" {
+		p = "/"
+	}
+	if strings.HasPrefix(p, "/api/") || strings.HasPrefix(p, "/media/") || strings.HasPrefix(p, "/s/") {
+		return "", "", false
+	}
+
+	name := path.Base(p)
+	if strings.HasPrefix(p, "/assets/") || (strings.Contains(name, ".") && !strings.HasSuffix(p, "/")) {
+		file, ok = cleanExportPath(p)
+		return file, p, ok
+	}
+
+	dir := strings.Trim(p, "/")
+	sitePath = "/" + dir
+	if dir == "" {
+		sitePath = "/"
+	}
+	if n, err := strconv.Atoi(u.Query().Get("page")); err == nil && n > 1 {
+		dir = path.Join(dir, "page", strconv.Itoa(n))
+		sitePath += "?page=" + strconv.Itoa(n)
+	}
+	file, ok = cleanExportPath(path.Join(dir, "index.html"))
+	return file, sitePath, ok
+}
+
+// rewriteHTML points a page's links at the export's files and returns the
+// site paths it links to
+func (x *staticExport) rewriteHTML(from string, body []byte) ([]byte, []string) {
+	var links []string
+	linked := make(map[string]bool)
+	rewritten := staticLinkAttrPattern.ReplaceAllFunc(body, func(match []byte) []byte {
+		parts := staticLinkAttrPattern.FindSubmatch(match)
+		quoted := string(parts[3])
+		quote, value := quoted[:1], html.UnescapeString(quoted[1:len(quoted)-1])
+
+		if strings.EqualFold(string(parts[2]), "srcset") {
+			candidates := strings.Split(value, ",")
+			for i, candidate := range candidates {
+				fields := strings.Fields(candidate)
+				if len(fields) > 0 {
+					fields[0] = x.rewriteURL(from, fields[0])
+					candidates[i] = strings.Join(fields, " ")
+				}
+			}
+			value = strings.Join(candidates, ", ")
+		} else {
+			if _, sitePath, ok := x.fileFor(value); ok && sitePath != "" && !linked[sitePath] && !strings.Contains(path.Base(sitePath), ".") {
+				linked[sitePath] = true
+				links = append(links, sitePath)
+			}
+			value = x.rewriteURL(from, value)
+		}
+		return []byte(string(parts[1]) + quote + html.EscapeString(value) + quote)
+	})
+	return rewritten, links
+}
+
+// rewriteURL points one URL found in the export's file from at the file it
+// maps to: relative to from, or under the base URL when one is set. Site
+// URLs that are already absolute are only rewritten under a base URL, so
+// canonical links keep naming the live site.
+func (x *staticExport) rewriteURL(from, raw string) string {
+	file, _, ok := x.fileFor(raw)
+	if !ok {
+		return raw
+	}
+	fragment := ""
+	if i := strings.Index(raw, "#"); i >= 0 {
+		fragment = raw[i:]
+	}
+	absolute := strings.Contains(raw, "://") && x.media[strings.SplitN(raw, "#", 2)[0]] == ""
+	if x.opts.BaseURL != "" {
+		return x.absoluteURL(file) + fragment
+	}
+	if absolute {
+		return raw
+	}
+	rel, err := filepath.Rel(filepath.Dir(filepath.FromSlash(from)), filepath.FromSlash(file))
+	if err != nil {
+		return raw
+	}
+	return filepath.ToSlash(rel) + fragment
+}
+
+// absoluteURL is where a file of the export is served: under the base
+// URL, or on the site's own domain when there is none. Directory indexes
+// are linked by their directory.
+func (x *staticExport) absoluteURL(file string) string {
+	base := x.opts.BaseURL
+	if base == "" {
+		base = x.site.BaseURL
+	}
+	if file == "index.html" {
+		return base + "/"
+	}
+	return base + "/" + strings.TrimSuffix(file, "index.html")
+}
+
+// rebase moves the site's absolute URLs that remain, such as canonical
+// links and feed entries, under the base URL
+func (x *staticExport) rebase(body []byte) []byte {
+	if x.opts.BaseURL == "" || !strings.Contains(x.opts.BaseURL, "://") {
+		return body
+	}
+	text := strings.ReplaceAll(string(body), x.site.BaseURL+x.feedPrefix, x.opts.BaseURL+"/")
+	return []byte(strings.ReplaceAll(text, x.site.BaseURL+"/", x.opts.BaseURL+"/"))
+}
+
+// cleanExportPath turns a site path into a file path under the export's
+// root, refusing paths that would leave it
+func cleanExportPath(p string) (string, bool) {
+	clean := path.Clean("/" + p)
+	if clean == "/" || strings.Contains(p, "..") || strings.ContainsRune(p, 0) {
+		return "", false
+	}
+	return strings.TrimPrefix(clean, "/"), true
+}
+
+// write records a file of the export, writing it only when its content
+// differs from what the previous export left
+func (x *staticExport) write(file string, body []byte, version string, links []string) error {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	x.files[file] = staticManifestFile{Hash: hash, Version: version, Links: links}
+	if previous, ok := x.previous.Files[file]; ok && previous.Hash == hash && x.exists(file) {
+		x.report.Unchanged++
+		return nil
+	}
+	if _, err := x.writeStream(file, bytes.NewReader(body)); err != nil {
+		return err
+	}
+	x.report.Written++
+	return nil
+}
+
+// writeStream writes a file of the export through a temporary file, so an
+// interrupted export never leaves a partial file, and returns its hash
+func (x *staticExport) writeStream(file string, r io.Reader) (string, error) {
+	target := filepath.Join(x.root, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".export-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (x *staticExport) exists(file string) bool {
+	info, err := os.Stat(filepath.Join(x.root, filepath.FromSlash(file)))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// loadManifest reads the previous export's manifest. Pages are only reused
+// when the previous export was made the same way from the same theme and
+// site settings.
+func (x *staticExport) loadManifest() {
+	data, err := os.ReadFile(filepath.Join(x.root, staticExportManifest))
+	if err == nil && json.Unmarshal(data, &x.previous) == nil {
+		x.report.Incremental = !x.opts.Full && x.previous.Fingerprint == x.fingerprint
+	}
+	if x.previous.Files == nil {
+		x.previous.Files = map[string]staticManifestFile{}
+	}
+}
+
+func (x *staticExport) saveManifest() error {
+	data, err := json.Marshal(staticManifest{Fingerprint: x.fingerprint, Files: x.files})
+	if err != nil {
+		return fmt.Errorf("failed to encode export manifest: %w", err)
+	}
+	if _, err := x.writeStream(staticExportManifest, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// removeStale deletes the previous export's files that this one no longer
+// has, along with directories left empty. Only files the manifest lists
+// are touched, so other files in the output directory survive.
+func (x *staticExport) removeStale() {
+	for file := range x.previous.Files {
+		if _, ok := x.files[file]; ok {
+			continue
+		}
+		clean, ok := cleanExportPath(file)
+		if !ok || clean != file {
+			continue
+		}
+		target := filepath.Join(x.root, filepath.FromSlash(file))
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			x.s.logger.Error("static_export", "remove", "Failed to remove stale export file", map[string]interface{}{
+				"file":  file,
+				"error": err.Error(),
+			})
+			continue
+		}
+		x.report.Removed++
+		for dir := filepath.Dir(target); dir != filepath.Clean(x.root) && strings.HasPrefix(dir, filepath.Clean(x.root)); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+}
+
+// archive packs the export's files into a .tar.gz at target
+func (x *staticExport) archive(target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	out, err := os.CreateTemp(filepath.Dir(target), ".archive-*")
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(out.Name())
+
+	files := make([]string, 0, len(x.files))
+	for file := range x.files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		if err := addToArchive(tw, filepath.Join(x.root, filepath.FromSlash(file)), file); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	err = tw.Close()
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), target)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+func addToArchive(tw *tar.Writer, source, name string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	header := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	return nil
+}
+
+// hashStrings hashes values in order, unambiguously
+func hashStrings(values ...string) string {
+	hash := sha256.New()
+	for _, value := range values {
+		fmt.Fprintf(hash, "%d:%s", len(value), value)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
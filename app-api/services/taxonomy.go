@@ -0,0 +1,663 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Taxonomies
+const (
+	TaxonomyTag      = "tag"
+	TaxonomyCategory = "category"
+)
+
+// Term is a tag or category. Categories form a tree through ParentID;
+// tags are always top level.
+type Term struct {
+	ID             string    `json:"id"`
+	SiteID         string    `json:"site_id"`
+	Taxonomy       string    `json:"taxonomy"`
+	ParentID       string    `json:"parent_id,omitempty"`
+	Name           string    `json:"name"`
+	Slug           string    `json:"slug"`
+	Description    string    `json:"description,omitempty"`
+	SortOrder      int       `json:"sort_order"`
+	PostCount      int       `json:"post_count"`
+	PublishedCount int       `json:"published_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Children       []*Term   `json:"children,omitempty"`
+}
+
+// TermInput is the writable part of a term
+type TermInput struct {
+	ParentID    string `json:"parent_id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+// PostTerms are the terms assigned to one post
+type PostTerms struct {
+	Tags       []*Term `json:"tags"`
+	Categories []*Term `json:"categories"`
+}
+
+// PostTermsInput replaces a post's assignments. Tags are matched by slug or
+// name and created when missing; categories must already exist and are
+// referenced by ID or slug. A nil list leaves that taxonomy untouched.
+type PostTermsInput struct {
+	Tags       *[]string `json:"tags"`
+	Categories *[]string `json:"categories"`
+}
+
+// PostTermFilter restricts post listings to posts carrying every listed tag
+// and, for each listed category, that category or one of its descendants
+type PostTermFilter struct {
+	Tags       []string
+	Categories []string
+}
+
+// IsTaxonomy reports whether taxonomy is a known taxonomy
+func IsTaxonomy(taxonomy string) bool {
+	return taxonomy == TaxonomyTag || taxonomy == TaxonomyCategory
+}
+
+// Empty reports whether the filter has no conditions
+func (f PostTermFilter) Empty() bool {
+	return len(f.Tags) == 0 && len(f.Categories) == 0
+}
+
+// SQL returns " AND ..." conditions restricting the posts aliased as
+// postAlias, appending its parameters to args
+func (f PostTermFilter) SQL(postAlias string, args *[]interface{}) string {
+	arg := func(value interface{}) string {
+		*args = append(*args, value)
+		return "$" + strconv.Itoa(len(*args))
+	}
+
+	var out strings.Builder
+	for _, tag := range f.Tags {
+		out.WriteString(` AND EXISTS (
+			SELECT 1 FROM post_terms pt JOIN taxonomy_terms t ON t.id = pt.term_id
+			WHERE pt.post_id = ` + postAlias + `.id AND t.taxonomy = 'tag' AND t.slug = ` + arg(tag) + `)`)
+	}
+	for _, category := range f.Categories {
+		// Post-term links never cross sites, so the tree need not be
+		// restricted to the post's site
+		out.WriteString(` AND EXISTS (
+			SELECT 1 FROM post_terms pt
+			WHERE pt.post_id = ` + postAlias + `.id AND pt.term_id IN (
+				WITH RECURSIVE tree AS (
+					SELECT id FROM taxonomy_terms WHERE taxonomy = 'category' AND slug = ` + arg(category) + `
+					UNION ALL
+					SELECT c.id FROM taxonomy_terms c JOIN tree ON c.parent_id = tree.id
+				)
+				SELECT id FROM tree))`)
+	}
+	return out.String()
+}
+
+const termSelect = `
+	SELECT t.id, t.site_id, t.taxonomy, COALESCE(t.parent_id::TEXT, ''), t.name, t.slug,
+		COALESCE(t.description, ''), t.sort_order, t.created_at, t.updated_at,
+		COUNT(p.id), COUNT(p.id) FILTER (WHERE p.published)
+	FROM taxonomy_terms t
+	LEFT JOIN post_terms pt ON pt.term_id = t.id
+	LEFT JOIN posts p ON p.id = pt.post_id AND p.deleted_at IS NULL`
+
+const termGroupBy = `
+	GROUP BY t.id, t.site_id, t.taxonomy, t.parent_id, t.name, t.slug, t.description, t.sort_order,
+		t.created_at, t.updated_at`
+
+func scanTerm(row rowScanner) (*Term, error) {
+	term := &Term{}
+	err := row.Scan(&term.ID, &term.SiteID, &term.Taxonomy, &term.ParentID, &term.Name, &term.Slug,
+		&term.Description, &term.SortOrder, &term.CreatedAt, &term.UpdatedAt, &term.PostCount, &term.PublishedCount)
+	if err != nil {
+		return nil, err
+	}
+	return term, nil
+}
+
+// ListTerms returns a taxonomy's terms with post counts. Tags come back as
+// a flat list ordered by name; categories as a tree unless flat is set.
+func (s *SiteContentService) ListTerms(siteRef, taxonomy string, flat bool) ([]*Term, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if !IsTaxonomy(taxonomy) {
+		return nil, fmt.Errorf("unknown taxonomy %q", taxonomy)
+	}
+
+	rows, err := s.db.Query(termSelect+`
+		WHERE t.site_id = $1 AND t.taxonomy = $2`+termGroupBy+`
+		ORDER BY t.sort_order, t.name`, siteID, taxonomy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list terms: %w", err)
+	}
+	defer rows.Close()
+
+	terms := []*Term{}
+	for rows.Next() {
+		term, err := scanTerm(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan term: %w", err)
+		}
+		terms = append(terms, term)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list terms: %w", err)
+	}
+
+	if taxonomy == TaxonomyTag || flat {
+		return terms, nil
+	}
+	return buildTermTree(terms), nil
+}
+
+func buildTermTree(terms []*Term) []*Term {
+	byID := make(map[string]*Term, len(terms))
+	for _, term := range terms {
+		byID[term.ID] = term
+	}
+	roots := []*Term{}
+	for _, term := range terms {
+		if parent := byID[term.ParentID]; parent != nil {
+			parent.Children = append(parent.Children, term)
+		} else {
+			roots = append(roots, term)
+		}
+	}
+	return roots
+}
+
+// GetTerm returns a term by ID or slug
+func (s *SiteContentService) GetTerm(siteRef, taxonomy, termRef string) (*Term, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.getTerm(s.db, siteID, taxonomy, termRef)
+}
+
+func (s *SiteContentService) getTerm(q queryRower, siteID, taxonomy, termRef string) (*Term, error) {
+	if !IsTaxonomy(taxonomy) {
+		return nil, fmt.Errorf("unknown taxonomy %q", taxonomy)
+	}
+	term, err := scanTerm(q.QueryRow(termSelect+`
+		WHERE t.site_id = $1 AND t.taxonomy = $2 AND (t.id::TEXT = $3 OR t.slug = $3)`+termGroupBy,
+		siteID, taxonomy, termRef))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%s not found", taxonomy)
+		}
+		return nil, fmt.Errorf("failed to retrieve %s: %w", taxonomy, err)
+	}
+	return term, nil
+}
+
+// validateTerm normalizes input and checks the parent of a category
+func (s *SiteContentService) validateTerm(q queryRower, siteID, taxonomy, termID string, input *TermInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if input.Slug == "" {
+		input.Slug = Slugify(input.Name)
+	}
+	if !pageSlugPattern.MatchString(input.Slug) {
+		return fmt.Errorf("slug must contain only lowercase letters, numbers and hyphens")
+	}
+
+	if input.ParentID == "" {
+		return nil
+	}
+	if taxonomy != TaxonomyCategory {
+		return fmt.Errorf("only categories can have a parent")
+	}
+	parent, err := s.getTerm(q, siteID, TaxonomyCategory, input.ParentID)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return fmt.Errorf("parent category does not exist")
+		}
+		return err
+	}
+	input.ParentID = parent.ID
+
+	if termID != "" {
+		// Walk up from the new parent; reaching the term itself is a cycle
+		for id := parent.ID; id != ""; {
+			if id == termID {
+				return fmt.Errorf("a category cannot be nested under itself or its descendants")
+			}
+			if err := q.QueryRow(`SELECT COALESCE(parent_id::TEXT, '') FROM taxonomy_terms WHERE id = $1`, id).Scan(&id); err != nil {
+				return fmt.Errorf("failed to check category hierarchy: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SiteContentService) slugTaken(q queryRower, siteID, taxonomy, slug, exceptID string) (bool, error) {
+	var exists bool
+	err := q.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM taxonomy_terms
+		WHERE site_id = $1 AND taxonomy = $2 AND slug = $3 AND id::TEXT <> $4)`,
+		siteID, taxonomy, slug, exceptID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check slug: %w", err)
+	}
+	return exists, nil
+}
+
+// CreateTerm adds a tag or category
+func (s *SiteContentService) CreateTerm(siteRef, taxonomy string, input TermInput) (*Term, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if !IsTaxonomy(taxonomy) {
+		return nil, fmt.Errorf("unknown taxonomy %q", taxonomy)
+	}
+	if err := s.validateTerm(s.db, siteID, taxonomy, "", &input); err != nil {
+		return nil, err
+	}
+	if taken, err := s.slugTaken(s.db, siteID, taxonomy, input.Slug, ""); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, fmt.Errorf("a %s with slug %q already exists", taxonomy, input.Slug)
+	}
+
+	var termID string
+	err = s.db.QueryRow(`
+		INSERT INTO taxonomy_terms (site_id, taxonomy, parent_id, name, slug, description, sort_order)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		siteID, taxonomy, nullableString(input.ParentID), input.Name, input.Slug,
+		nullableString(input.Description), input.SortOrder).Scan(&termID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", taxonomy, err)
+	}
+
+	s.logger.Info("site_content_service", "create_term", "Term created", map[string]interface{}{
+		"term_id":  termID,
+		"site_id":  siteID,
+		"taxonomy": taxonomy,
+		"slug":     input.Slug,
+	})
+
+	return s.getTerm(s.db, siteID, taxonomy, termID)
+}
+
+// UpdateTerm renames, re-slugs or moves a term. Assignments reference the
+// term by ID, so existing posts follow the rename.
+func (s *SiteContentService) UpdateTerm(siteRef, taxonomy, termRef string, input TermInput) (*Term, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	term, err := s.getTerm(s.db, siteID, taxonomy, termRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateTerm(s.db, siteID, taxonomy, term.ID, &input); err != nil {
+		return nil, err
+	}
+	if taken, err := s.slugTaken(s.db, siteID, taxonomy, input.Slug, term.ID); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, fmt.Errorf("a %s with slug %q already exists", taxonomy, input.Slug)
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE taxonomy_terms
+		SET parent_id = $2, name = $3, slug = $4, description = $5, sort_order = $6, updated_at = NOW()
+		WHERE id = $1`,
+		term.ID, nullableString(input.ParentID), input.Name, input.Slug,
+		nullableString(input.Description), input.SortOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update %s: %w", taxonomy, err)
+	}
+	if taxonomy == TaxonomyTag && input.Name != term.Name {
+		if err := IndexPostsWithTerm(s.db, term.ID); err != nil {
+			return nil, err
+		}
+	}
+	s.pageCache.PurgeSite(siteID)
+
+	return s.getTerm(s.db, siteID, taxonomy, term.ID)
+}
+
+// DeleteTerm removes a term and its assignments; child categories move up
+// to the deleted category's parent
+func (s *SiteContentService) DeleteTerm(siteRef, taxonomy, termRef string) error {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return err
+	}
+	term, err := s.getTerm(s.db, siteID, taxonomy, termRef)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE taxonomy_terms SET parent_id = $2, updated_at = NOW() WHERE parent_id = $1`,
+		term.ID, nullableString(term.ParentID)); err != nil {
+		return fmt.Errorf("failed to reparent child categories: %w", err)
+	}
+	tagged, err := postsWithTerm(tx, term.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM post_terms WHERE term_id = $1`, term.ID); err != nil {
+		return fmt.Errorf("failed to delete %s assignments: %w", taxonomy, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM taxonomy_terms WHERE id = $1`, term.ID); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", taxonomy, err)
+	}
+	if taxonomy == TaxonomyTag {
+		if err := indexPosts(tx, tagged); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %s deletion: %w", taxonomy, err)
+	}
+
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("site_content_service", "delete_term", "Term deleted", map[string]interface{}{
+		"term_id":  term.ID,
+		"site_id":  siteID,
+		"taxonomy": taxonomy,
+	})
+	return nil
+}
+
+// MergeTerms folds source into target: posts tagged with source are
+// reassigned to target, child categories move under target and source is
+// deleted
+func (s *SiteContentService) MergeTerms(siteRef, taxonomy, sourceRef, targetRef string) (*Term, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.getTerm(s.db, siteID, taxonomy, sourceRef)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.getTerm(s.db, siteID, taxonomy, targetRef)
+	if err != nil {
+		return nil, err
+	}
+	if source.ID == target.ID {
+		return nil, fmt.Errorf("cannot merge a %s into itself", taxonomy)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if taxonomy == TaxonomyCategory {
+		// Merging into a descendant would leave the descendant parented to
+		// a deleted category; lift the target out of the source's subtree
+		for id := target.ParentID; id != ""; {
+			if id == source.ID {
+				if _, err := tx.Exec(`UPDATE taxonomy_terms SET parent_id = $2 WHERE id = $1`,
+					target.ID, nullableString(source.ParentID)); err != nil {
+					return nil, fmt.Errorf("failed to move %s: %w", taxonomy, err)
+				}
+				break
+			}
+			if err := tx.QueryRow(`SELECT COALESCE(parent_id::TEXT, '') FROM taxonomy_terms WHERE id = $1`, id).Scan(&id); err != nil {
+				return nil, fmt.Errorf("failed to check category hierarchy: %w", err)
+			}
+		}
+		if _, err := tx.Exec(`UPDATE taxonomy_terms SET parent_id = $2, updated_at = NOW() WHERE parent_id = $1`,
+			source.ID, target.ID); err != nil {
+			return nil, fmt.Errorf("failed to move child categories: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO post_terms (post_id, term_id)
+		SELECT post_id, $2 FROM post_terms WHERE term_id = $1
+		ON CONFLICT DO NOTHING`, source.ID, target.ID); err != nil {
+		return nil, fmt.Errorf("failed to reassign posts: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM post_terms WHERE term_id = $1`, source.ID); err != nil {
+		return nil, fmt.Errorf("failed to reassign posts: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM taxonomy_terms WHERE id = $1`, source.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete merged %s: %w", taxonomy, err)
+	}
+	if taxonomy == TaxonomyTag {
+		if err := IndexPostsWithTerm(tx, target.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("site_content_service", "merge_terms", "Terms merged", map[string]interface{}{
+		"site_id":  siteID,
+		"taxonomy": taxonomy,
+		"source":   source.Slug,
+		"target":   target.Slug,
+	})
+
+	return s.getTerm(s.db, siteID, taxonomy, target.ID)
+}
+
+// GetPostTerms returns the tags and categories assigned to a post
+func (s *SiteContentService) GetPostTerms(siteRef, postID string) (*PostTerms, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPost(s.db, siteID, postID); err != nil {
+		return nil, err
+	}
+	terms, err := s.TermsForPosts([]string{postID})
+	if err != nil {
+		return nil, err
+	}
+	return terms[postID], nil
+}
+
+func (s *SiteContentService) checkPost(q queryRower, siteID, postID string) error {
+	var exists bool
+	err := q.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM posts WHERE id::TEXT = $1 AND site_id = $2 AND deleted_at IS NULL)`,
+		postID, siteID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to look up post: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("post not found")
+	}
+	return nil
+}
+
+// SetPostTerms replaces a post's tags and/or categories
+func (s *SiteContentService) SetPostTerms(siteRef, postID string, input PostTermsInput) (*PostTerms, error) {
+	siteID, err := s.ResolveSiteID(siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.checkPost(tx, siteID, postID); err != nil {
+		return nil, err
+	}
+
+	if input.Tags != nil {
+		var ids []string
+		for _, name := range *input.Tags {
+			id, err := s.resolveOrCreateTag(tx, siteID, name)
+			if err != nil {
+				return nil, err
+			}
+			if id != "" && !containsString(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		if err := replacePostTerms(tx, postID, TaxonomyTag, ids); err != nil {
+			return nil, err
+		}
+		if err := IndexPost(tx, postID); err != nil {
+			return nil, err
+		}
+	}
+
+	if input.Categories != nil {
+		var ids []string
+		for _, ref := range *input.Categories {
+			var id string
+			err := tx.QueryRow(`
+				SELECT id FROM taxonomy_terms
+				WHERE site_id = $1 AND taxonomy = 'category' AND (id::TEXT = $2 OR slug = $2)`,
+				siteID, strings.TrimSpace(ref)).Scan(&id)
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("unknown category %q", ref)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up category: %w", err)
+			}
+			if !containsString(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		if err := replacePostTerms(tx, postID, TaxonomyCategory, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit post terms: %w", err)
+	}
+	s.pageCache.PurgeSite(siteID)
+
+	terms, err := s.TermsForPosts([]string{postID})
+	if err != nil {
+		return nil, err
+	}
+	return terms[postID], nil
+}
+
+// resolveOrCreateTag finds a tag by slug or case-insensitive name and
+// creates it when there is none
+func (s *SiteContentService) resolveOrCreateTag(tx *sql.Tx, siteID, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil
+	}
+	slug := Slugify(name)
+	if slug == "" {
+		return "", fmt.Errorf("tag %q has no usable characters for a slug", name)
+	}
+
+	var id string
+	err := tx.QueryRow(`
+		SELECT id FROM taxonomy_terms
+		WHERE site_id = $1 AND taxonomy = 'tag' AND (slug = $2 OR LOWER(name) = LOWER($3))
+		ORDER BY (slug = $2) DESC
+		LIMIT 1`, siteID, slug, name).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to look up tag: %w", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO taxonomy_terms (site_id, taxonomy, name, slug)
+		VALUES ($1, 'tag', $2, $3)
+		RETURNING id`, siteID, name, slug).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tag: %w", err)
+	}
+	return id, nil
+}
+
+func replacePostTerms(tx *sql.Tx, postID, taxonomy string, termIDs []string) error {
+	_, err := tx.Exec(`
+		DELETE FROM post_terms
+		WHERE post_id::TEXT = $1 AND term_id IN (SELECT id FROM taxonomy_terms WHERE taxonomy = $2)`,
+		postID, taxonomy)
+	if err != nil {
+		return fmt.Errorf("failed to clear post %ss: %w", taxonomy, err)
+	}
+	for _, termID := range termIDs {
+		if _, err := tx.Exec(`INSERT INTO post_terms (post_id, term_id) VALUES ($1, $2)`, postID, termID); err != nil {
+			return fmt.Errorf("failed to assign post %s: %w", taxonomy, err)
+		}
+	}
+	return nil
+}
+
+// TermsForPosts loads the assignments of several posts in one query; every
+// requested post gets an entry, empty when it has no terms
+func (s *SiteContentService) TermsForPosts(postIDs []string) (map[string]*PostTerms, error) {
+	result := make(map[string]*PostTerms, len(postIDs))
+	for _, id := range postIDs {
+		result[id] = &PostTerms{Tags: []*Term{}, Categories: []*Term{}}
+	}
+	if len(postIDs) == 0 {
+		return result, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT pt.post_id::TEXT, t.id, t.site_id, t.taxonomy, COALESCE(t.parent_id::TEXT, ''), t.name, t.slug,
+			COALESCE(t.description, ''), t.sort_order, t.created_at, t.updated_at
+		FROM post_terms pt
+		JOIN taxonomy_terms t ON t.id = pt.term_id
+		WHERE pt.post_id::TEXT = ANY($1)
+		ORDER BY t.taxonomy, t.sort_order, t.name`, pq.Array(postIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load post terms: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID string
+		term := &Term{}
+		if err := rows.Scan(&postID, &term.ID, &term.SiteID, &term.Taxonomy, &term.ParentID, &term.Name, &term.Slug,
+			&term.Description, &term.SortOrder, &term.CreatedAt, &term.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan post term: %w", err)
+		}
+		terms := result[postID]
+		if terms == nil {
+			continue
+		}
+		if term.Taxonomy == TaxonomyTag {
+			terms.Tags = append(terms.Tags, term)
+		} else {
+			terms.Categories = append(terms.Categories, term)
+		}
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,515 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultTheme is used by sites whose template names no installed theme
+const DefaultTheme = "default"
+
+// Theme pages; every theme provides index, post and tag, and may add a
+// page page for the site's pages and a not_found page for 404s
+const (
+	ThemePageIndex    = "index"
+	ThemePagePost     = "post"
+	ThemePageTag      = "tag"
+	ThemePagePage     = "page"
+	ThemePageNotFound = "not_found"
+)
+
+var (
+	themeNamePattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+	themeLayoutPattern = regexp.MustCompile(`^\s*\{\{/\*\s*layout:\s*([a-z0-9_-]+)\s*\*/\}\}`)
+)
+
+// ThemeInfo describes an installed theme
+type ThemeInfo struct {
+	Name    string   `json:"name"`
+	Pages   []string `json:"pages"`
+	Layouts []string `json:"layouts"`
+}
+
+// ThemeEngine renders site pages from theme directories. A theme is a
+// directory under the engine's root holding page templates (index.html,
+// post.html, tag.html, page.html, not_found.html), layouts/*.html,
+// partials/*.html and static files under assets/. A page runs inside
+// layouts/default.html, or the layout named by a leading
+// {{/* layout: name */}} comment, and the layout includes it with
+// {{template "content" .}}; partials are included as
+// {{template "partials/name" .}}.
+//
+// Pages, layouts and partials may instead be Liquid (.liquid). A Liquid
+// page runs inside layouts/default.liquid, or the layout named by its
+// {% layout 'name' %} tag, which prints the page with
+// {{ content_for_layout }}; partials are included with
+// {% render 'name' %} or {% include 'name' %}.
+//
+// Themes are parsed on first use and kept until Reload.
+type ThemeEngine struct {
+	dir    string
+	logger Logger
+	funcs  template.FuncMap
+
+	mu     sync.RWMutex
+	themes map[string]*theme
+}
+
+type theme struct {
+	info          ThemeInfo
+	pages         map[string]*themePage
+	liquidLayouts map[string]*LiquidTemplate
+}
+
+type themePage struct {
+	tmpl   *template.Template
+	liquid *LiquidTemplate
+	layout string
+}
+
+// LiquidData is page data that can also be given to Liquid templates,
+// which only see maps of variables
+type LiquidData interface {
+	LiquidVars() map[string]interface{}
+}
+
+// NewThemeEngine creates a theme engine rooted at dir
+func NewThemeEngine(dir string, logger Logger) *ThemeEngine {
+	return &ThemeEngine{
+		dir:    dir,
+		logger: logger,
+		funcs:  themeFuncs(),
+		themes: make(map[string]*theme),
+	}
+}
+
+// themeFuncs are the helpers available to every theme template
+func themeFuncs() template.FuncMap {
+	return template.FuncMap{
+		// safeHTML marks already sanitized HTML, such as rendered post
+		// content, as safe to insert unescaped
+		"safeHTML": func(html string) template.HTML {
+			return template.HTML(html)
+		},
+		"formatDate": func(layout string, value interface{}) string {
+			switch t := value.(type) {
+			case time.Time:
+				return t.Format(layout)
+			case *time.Time:
+				if t != nil {
+					return t.Format(layout)
+				}
+			}
+			return ""
+		},
+		"truncate": func(length int, text string) string {
+			if utf8.RuneCountInString(text) <= length {
+				return text
+			}
+			return string([]rune(text)[:length]) + "…"
+		},
+		"plainText": plainText,
+		// default returns value unless it is nil, a nil pointer or a zero
+		// value, as in {{default .CreatedAt .PublishedAt}}
+		"default": func(fallback, value interface{}) interface{} {
+			if value == nil {
+				return fallback
+			}
+			if v := reflect.ValueOf(value); v.IsZero() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+				return fallback
+			}
+			return value
+		},
+		// dict builds a map from key/value pairs, to pass several values to
+		// a partial
+		"dict": func(pairs ...interface{}) (map[string]interface{}, error) {
+			if len(pairs)%2 != 0 {
+				return nil, fmt.Errorf("dict needs key/value pairs")
+			}
+			values := make(map[string]interface{}, len(pairs)/2)
+			for i := 0; i < len(pairs); i += 2 {
+				key, ok := pairs[i].(string)
+				if !ok {
+					return nil, fmt.Errorf("dict keys must be strings")
+				}
+				values[key] = pairs[i+1]
+			}
+			return values, nil
+		},
+	}
+}
+
+// Themes lists the installed themes by name
+func (e *ThemeEngine) Themes() ([]ThemeInfo, error) {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list themes: %w", err)
+	}
+
+	themes := []ThemeInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !themeNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		loaded, err := e.load(entry.Name())
+		if err != nil {
+			e.logger.Error("theme_engine", "list_themes", "Skipping theme that failed to load", map[string]interface{}{
+				"theme": entry.Name(),
+				"error": err.Error(),
+			})
+			continue
+		}
+		themes = append(themes, loaded.info)
+	}
+	return themes, nil
+}
+
+// HasTheme reports whether name is an installed theme
+func (e *ThemeEngine) HasTheme(name string) bool {
+	if !themeNamePattern.MatchString(name) {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(e.dir, name))
+	return err == nil && info.IsDir()
+}
+
+// Resolve returns the theme a site with the given template uses, falling
+// back to the default theme
+func (e *ThemeEngine) Resolve(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if e.HasTheme(name) {
+		return name
+	}
+	return DefaultTheme
+}
+
+// Reload drops parsed templates so the next render reads them from disk
+// again; an empty name reloads every theme
+func (e *ThemeEngine) Reload(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if name == "" {
+		e.themes = make(map[string]*theme)
+	} else {
+		delete(e.themes, name)
+	}
+	e.logger.Info("theme_engine", "reload", "Themes reloaded", map[string]interface{}{
+		"theme": name,
+	})
+}
+
+// HasPage reports whether a theme provides a page
+func (e *ThemeEngine) HasPage(themeName, page string) bool {
+	loaded, err := e.load(themeName)
+	if err != nil {
+		return false
+	}
+	_, ok := loaded.pages[page]
+	return ok
+}
+
+// Render executes a theme page with data
+func (e *ThemeEngine) Render(themeName, page string, data interface{}) ([]byte, error) {
+	loaded, err := e.load(themeName)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := loaded.pages[page]
+	if !ok {
+		return nil, fmt.Errorf("theme %q has no %s page", themeName, page)
+	}
+	if entry.liquid != nil {
+		return renderLiquidPage(loaded, themeName, page, entry, data)
+	}
+
+	name := page
+	if entry.layout != "" {
+		name = "layouts/" + entry.layout
+	}
+	var out bytes.Buffer
+	if err := entry.tmpl.ExecuteTemplate(&out, name, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s page of theme %q: %w", page, themeName, err)
+	}
+	return out.Bytes(), nil
+}
+
+func renderLiquidPage(loaded *theme, themeName, page string, entry *themePage, data interface{}) ([]byte, error) {
+	var vars map[string]interface{}
+	switch d := data.(type) {
+	case map[string]interface{}:
+		vars = d
+	case LiquidData:
+		vars = d.LiquidVars()
+	default:
+		return nil, fmt.Errorf("%s page of theme %q is Liquid and cannot render %T", page, themeName, data)
+	}
+
+	out, err := entry.liquid.Render(vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s page of theme %q: %w", page, themeName, err)
+	}
+	if entry.layout == "" {
+		return out, nil
+	}
+
+	layoutVars := make(map[string]interface{}, len(vars)+1)
+	for key, value := range vars {
+		layoutVars[key] = value
+	}
+	layoutVars["content_for_layout"] = string(out)
+	out, err = loaded.liquidLayouts[entry.layout].Render(layoutVars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s page of theme %q: %w", page, themeName, err)
+	}
+	return out, nil
+}
+
+// AssetPath returns the file for a theme's static asset, refusing paths
+// that leave the theme's assets directory
+func (e *ThemeEngine) AssetPath(themeName, asset string) (string, error) {
+	if !themeNamePattern.MatchString(themeName) {
+		return "", fmt.Errorf("asset not found")
+	}
+	root := filepath.Join(e.dir, themeName, "assets")
+	path := filepath.Join(root, filepath.FromSlash(filepath.Clean("/"+asset)))
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("asset not found")
+	}
+	return path, nil
+}
+
+// AssetFiles lists a theme's static assets, mapping each asset's path under
+// assets/ to its file
+func (e *ThemeEngine) AssetFiles(themeName string) (map[string]string, error) {
+	if !e.HasTheme(themeName) {
+		return nil, fmt.Errorf("theme %q not found", themeName)
+	}
+	root := filepath.Join(e.dir, themeName, "assets")
+	files := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.Type().IsRegular() {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = path
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list assets of theme %q: %w", themeName, err)
+	}
+	return files, nil
+}
+
+// Fingerprint summarises every file of a theme by name, size and
+// modification time; it changes whenever the theme is edited
+func (e *ThemeEngine) Fingerprint(themeName string) (string, error) {
+	if !e.HasTheme(themeName) {
+		return "", fmt.Errorf("theme %q not found", themeName)
+	}
+	root := filepath.Join(e.dir, themeName)
+	hash := sha256.New()
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\n", filepath.ToSlash(path[len(root):]), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read theme %q: %w", themeName, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (e *ThemeEngine) load(name string) (*theme, error) {
+	e.mu.RLock()
+	loaded, ok := e.themes[name]
+	e.mu.RUnlock()
+	if ok {
+		return loaded, nil
+	}
+	if !e.HasTheme(name) {
+		return nil, fmt.Errorf("theme %q not found", name)
+	}
+
+	loaded, err := e.parse(name)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.themes[name] = loaded
+	e.mu.Unlock()
+	return loaded, nil
+}
+
+// parse reads a theme directory; each page gets its own template set
+// holding every layout and partial, so pages can define the same blocks
+func (e *ThemeEngine) parse(name string) (*theme, error) {
+	root := filepath.Join(e.dir, name)
+	shared, err := readThemeFiles(root, ".html", "layouts", "partials")
+	if err != nil {
+		return nil, err
+	}
+	pages, err := readThemeFiles(root, ".html", "")
+	if err != nil {
+		return nil, err
+	}
+	liquidFiles, err := readThemeFiles(root, ".liquid", "", "layouts", "partials")
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := &theme{
+		info:          ThemeInfo{Name: name, Pages: []string{}, Layouts: []string{}},
+		pages:         make(map[string]*themePage),
+		liquidLayouts: make(map[string]*LiquidTemplate),
+	}
+	for templateName := range shared {
+		if layout := strings.TrimPrefix(templateName, "layouts/"); layout != templateName {
+			loaded.info.Layouts = append(loaded.info.Layouts, layout)
+		}
+	}
+
+	for page, source := range pages {
+		tmpl, err := template.New(page).Funcs(e.funcs).Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s page in theme %q: %w", page, name, err)
+		}
+		for templateName, text := range shared {
+			if _, err := tmpl.New(templateName).Parse(text); err != nil {
+				return nil, fmt.Errorf("failed to parse %s in theme %q: %w", templateName, name, err)
+			}
+		}
+
+		entry := &themePage{tmpl: tmpl}
+		if match := themeLayoutPattern.FindStringSubmatch(source); match != nil {
+			entry.layout = match[1]
+		} else if _, ok := shared["layouts/default"]; ok {
+			entry.layout = "default"
+		}
+		if entry.layout != "" {
+			if _, ok := shared["layouts/"+entry.layout]; !ok {
+				return nil, fmt.Errorf("%s page in theme %q uses missing layout %q", page, name, entry.layout)
+			}
+		}
+		loaded.pages[page] = entry
+		loaded.info.Pages = append(loaded.info.Pages, page)
+	}
+	if err := parseLiquidTheme(name, loaded, liquidFiles); err != nil {
+		return nil, err
+	}
+
+	for _, required := range []string{ThemePageIndex, ThemePagePost, ThemePageTag} {
+		if _, ok := loaded.pages[required]; !ok {
+			return nil, fmt.Errorf("theme %q has no %s page", name, required)
+		}
+	}
+	sort.Strings(loaded.info.Pages)
+	sort.Strings(loaded.info.Layouts)
+	return loaded, nil
+}
+
+// parseLiquidTheme adds a theme's .liquid pages and layouts to loaded; a
+// page may be Go template or Liquid but not both
+func parseLiquidTheme(name string, loaded *theme, files map[string]string) error {
+	env := NewLiquidEnv()
+	partials := make(map[string]*LiquidTemplate)
+	env.Partials = func(partial string) (*LiquidTemplate, error) {
+		if tmpl, ok := partials[partial]; ok {
+			return tmpl, nil
+		}
+		return nil, fmt.Errorf("partial %q not found", partial)
+	}
+
+	pages := make(map[string]*LiquidTemplate)
+	for key, source := range files {
+		tmpl, err := env.Parse(name+"/"+key+".liquid", source)
+		if err != nil {
+			return fmt.Errorf("failed to parse theme %q: %w", name, err)
+		}
+		if layout := strings.TrimPrefix(key, "layouts/"); layout != key {
+			loaded.liquidLayouts[layout] = tmpl
+			if !containsString(loaded.info.Layouts, layout) {
+				loaded.info.Layouts = append(loaded.info.Layouts, layout)
+			}
+		} else if partial := strings.TrimPrefix(key, "partials/"); partial != key {
+			partials[partial] = tmpl
+		} else {
+			pages[key] = tmpl
+		}
+	}
+
+	for page, tmpl := range pages {
+		if _, ok := loaded.pages[page]; ok {
+			return fmt.Errorf("theme %q has both %s.html and %s.liquid", name, page, page)
+		}
+		entry := &themePage{liquid: tmpl}
+		if layout, ok := tmpl.Layout(); ok {
+			entry.layout = layout
+		} else if _, ok := loaded.liquidLayouts["default"]; ok {
+			entry.layout = "default"
+		}
+		if _, ok := loaded.liquidLayouts[entry.layout]; entry.layout != "" && !ok {
+			return fmt.Errorf("%s page in theme %q uses missing layout %q", page, name, entry.layout)
+		}
+		loaded.pages[page] = entry
+		loaded.info.Pages = append(loaded.info.Pages, page)
+	}
+	return nil
+}
+
+// readThemeFiles reads the files with extension ext directly inside each
+// subdirectory of root, keyed "subdir/name" without the extension ("name"
+// for root)
+func readThemeFiles(root, ext string, subdirs ...string) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, subdir := range subdirs {
+		entries, err := os.ReadDir(filepath.Join(root, subdir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read theme directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ext {
+				continue
+			}
+			text, err := os.ReadFile(filepath.Join(root, subdir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read theme file: %w", err)
+			}
+			key := strings.TrimSuffix(entry.Name(), ext)
+			if subdir != "" {
+				key = subdir + "/" + key
+			}
+			files[key] = string(text)
+		}
+	}
+	return files, nil
+}
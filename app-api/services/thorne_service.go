@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,6 +24,7 @@ type ThorneProduct struct {
 	InStock        bool     `json:"in_stock"`
 	Benefits       []string `json:"benefits"`
 	Ingredients    []string `json:"ingredients"`
+	Fulfillment    string   `json:"fulfillment,omitempty"`
 }
 
 // ThorneCategory represents a product category
@@ -59,24 +62,33 @@ type Order struct {
 	OrderDate      time.Time  `json:"order_date"`
 	ShippedDate    *time.Time `json:"shipped_date"`
 	TrackingNumber *string    `json:"tracking_number"`
+	Carrier        string     `json:"carrier,omitempty"`
+	FulfillmentRef string     `json:"fulfillment_ref,omitempty"`
+	SiteID         string     `json:"site_id,omitempty"`
+	UnitCost       float64    `json:"unit_cost,omitempty"`
 }
 
 // ThorneSettings represents site settings
 type ThorneSettings struct {
-	SiteName                string            `json:"site_name"`
-	PractitionerName        string            `json:"practitioner_name"`
-	PractitionerCredentials string            `json:"practitioner_credentials"`
-	ContactEmail            string            `json:"contact_email"`
-	Phone                   string            `json:"phone"`
-	Address                 map[string]string `json:"address"`
-	BusinessHours           map[string]string `json:"business_hours"`
-	Compliance              map[string]string `json:"compliance"`
-	Features                map[string]bool   `json:"features"`
+	SiteName                string              `json:"site_name"`
+	PractitionerName        string              `json:"practitioner_name"`
+	PractitionerCredentials string              `json:"practitioner_credentials"`
+	ContactEmail            string              `json:"contact_email"`
+	Phone                   string              `json:"phone"`
+	Address                 map[string]string   `json:"address"`
+	BusinessHours           map[string]string   `json:"business_hours"`
+	Compliance              map[string]string   `json:"compliance"`
+	Features                map[string]bool     `json:"features"`
+	Fulfillment             FulfillmentSettings `json:"fulfillment"`
 }
 
 // ThorneService handles data operations for Thorne-related functionality
 type ThorneService struct {
-	configPath string
+	configPath   string
+	mu           sync.Mutex
+	indexMu      sync.Mutex
+	index        *ProductIndex
+	indexModTime time.Time
 }
 
 // NewThorneService creates a new ThorneService instance
@@ -223,6 +235,31 @@ func (s *ThorneService) GetOrders() ([]Order, error) {
 	return data.Orders, nil
 }
 
+// GetOrderByID returns an order by ID
+func (s *ThorneService) GetOrderByID(id string) (*Order, error) {
+	orders, err := s.GetOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, order := range orders {
+		if order.ID == id {
+			return &order, nil
+		}
+	}
+
+	return nil, fmt.Errorf("order not found: %s", id)
+}
+
+// saveOrders writes the full order list back to the orders file
+func (s *ThorneService) saveOrders(orders []Order) error {
+	data := struct {
+		Orders []Order `json:"orders"`
+	}{Orders: orders}
+
+	return s.saveJSON("thorne-orders.json", data)
+}
+
 // GetOrdersByPatientID returns orders for a specific patient
 func (s *ThorneService) GetOrdersByPatientID(patientID string) ([]Order, error) {
 	orders, err := s.GetOrders()
@@ -254,21 +291,16 @@ func (s *ThorneService) GetSettings() (*ThorneSettings, error) {
 	return &data.Settings, nil
 }
 
-// SearchProducts searches products by name or description
+// SearchProducts returns products matching the query, best match first
 func (s *ThorneService) SearchProducts(query string) ([]ThorneProduct, error) {
-	products, err := s.GetProducts()
+	result, err := s.SearchCatalog(ProductSearchQuery{Query: query, PerPage: 100})
 	if err != nil {
 		return nil, err
 	}
 
-	query = strings.ToLower(query)
 	var results []ThorneProduct
-
-	for _, product := range products {
-		if strings.Contains(strings.ToLower(product.Name), query) ||
-			strings.Contains(strings.ToLower(product.Description), query) {
-			results = append(results, product)
-		}
+	for _, hit := range result.Hits {
+		results = append(results, hit.ThorneProduct)
 	}
 
 	return results, nil
@@ -291,6 +323,14 @@ func (s *ThorneService) loadJSON(filename string, target interface{}) error {
 	return nil
 }
 
+// loadOptionalJSON loads a JSON data file, leaving target empty when the file does not exist yet
+func (s *ThorneService) loadOptionalJSON(filename string, target interface{}) error {
+	if _, err := os.Stat(filepath.Join(s.configPath, filename)); os.IsNotExist(err) {
+		return nil
+	}
+	return s.loadJSON(filename, target)
+}
+
 // saveJSON saves data to a JSON configuration file
 func (s *ThorneService) saveJSON(filename string, data interface{}) error {
 	filePath := filepath.Join(s.configPath, filename)
@@ -339,8 +379,11 @@ func (s *ThorneService) RegisterPatient(name, email, healthGoals string) (*Patie
 	return &patient, nil
 }
 
-// CreateOrder creates a new order (in a real app, this would save to database)
-func (s *ThorneService) CreateOrder(patientID, productID string, quantity int) (*Order, error) {
+// CreateOrder creates a new order, routes it to a fulfillment provider and saves it.
+// siteID records which storefront took the order and may be empty. The order is
+// saved as pending before it is submitted, and the lock is not held during the
+// submission, so a slow provider doesn't block other order updates.
+func (s *ThorneService) CreateOrder(patientID, productID string, quantity int, siteID string) (*Order, error) {
 	// Get product details
 	product, err := s.GetProductByID(productID)
 	if err != nil {
@@ -348,14 +391,17 @@ func (s *ThorneService) CreateOrder(patientID, productID string, quantity int) (
 	}
 
 	// Verify patient exists
-	_, err = s.GetPatientByID(patientID)
+	patient, err := s.GetPatientByID(patientID)
 	if err != nil {
 		return nil, err
 	}
 
+	s.mu.Lock()
+
 	// Generate new order ID
 	orders, err := s.GetOrders()
 	if err != nil {
+		s.mu.Unlock()
 		return nil, err
 	}
 
@@ -369,13 +415,62 @@ func (s *ThorneService) CreateOrder(patientID, productID string, quantity int) (
 		Quantity:       quantity,
 		UnitPrice:      product.RetailPrice,
 		TotalPrice:     product.RetailPrice * float64(quantity),
-		Status:         "pending",
-		Fulfillment:    "direct", // Default to direct fulfillment
+		Status:         OrderStatusPending,
+		Fulfillment:    FulfillmentDirect,
 		OrderDate:      time.Now(),
 		ShippedDate:    nil,
 		TrackingNumber: nil,
+		SiteID:         siteID,
+		UnitCost:       product.WholesalePrice,
 	}
 
-	// In a real app, this would save to database
-	return &order, nil
+	orders = append(orders, order)
+	err = s.saveOrders(orders)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed submission is still recorded so it can be retried or handled by hand
+	fulfillErr := s.fulfillOrder(&order, product, patient)
+
+	saved, err := s.recordSubmission(order)
+	if err != nil {
+		return nil, fmt.Errorf("order %s was sent to %s but its result could not be saved: %w", order.ID, order.Fulfillment, err)
+	}
+
+	if fulfillErr != nil {
+		return saved, fulfillErr
+	}
+	return saved, nil
+}
+
+// recordSubmission stores the outcome of submitting an order. A tracking
+// update that arrived while the submission was in flight is kept rather than
+// being reset to the submission status.
+func (s *ThorneService) recordSubmission(submitted Order) (*Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders, err := s.GetOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		if orders[i].ID != submitted.ID {
+			continue
+		}
+		orders[i].Fulfillment = submitted.Fulfillment
+		orders[i].FulfillmentRef = submitted.FulfillmentRef
+		if orders[i].Status == OrderStatusPending {
+			orders[i].Status = submitted.Status
+		}
+		if err := s.saveOrders(orders); err != nil {
+			return nil, err
+		}
+		return &orders[i], nil
+	}
+
+	return nil, fmt.Errorf("order not found: %s", submitted.ID)
 }
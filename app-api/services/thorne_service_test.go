@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeThorneFixture(t *testing.T, dir, name string, data interface{}) {
+	t.Helper()
+	content, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCreateOrderSubmitsWithoutHoldingLock checks that a drop-ship order is
+// saved before it is submitted and that tracking updates arriving during the
+// submission are neither blocked nor overwritten
+func TestCreateOrderSubmitsWithoutHoldingLock(t *testing.T) {
+	dir := t.TempDir()
+	service := NewThorneService(dir)
+
+	tracked := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order, err := service.GetOrderByID("order-001")
+		if err == nil && order.Status != OrderStatusPending {
+			err = os.ErrInvalid
+		}
+		if err == nil {
+			done := make(chan error, 1)
+			go func() {
+				_, err := service.UpdateOrderTracking("order-001", TrackingUpdate{TrackingNumber: "1Z999", Carrier: "UPS"})
+				done <- err
+			}()
+			select {
+			case err = <-done:
+			case <-time.After(2 * time.Second):
+				err = os.ErrDeadlineExceeded
+			}
+		}
+		tracked <- err
+		json.NewEncoder(w).Encode(map[string]string{"id": "TX-1"})
+	}))
+	defer server.Close()
+	t.Setenv("THORNE_EXPRESS_URL", server.URL)
+	t.Setenv("THORNE_EXPRESS_API_KEY", "")
+
+	writeThorneFixture(t, dir, "thorne-products.json", map[string]interface{}{
+		"products": []ThorneProduct{{ID: "p1", Name: "Product", SKU: "SKU-1", RetailPrice: 10, Fulfillment: FulfillmentThorne}},
+	})
+	writeThorneFixture(t, dir, "thorne-patients.json", map[string]interface{}{
+		"patients": []Patient{{ID: "patient-1", Name: "Pat", Email: "pat@example.com"}},
+	})
+	writeThorneFixture(t, dir, "thorne-orders.json", map[string]interface{}{"orders": []Order{}})
+	writeThorneFixture(t, dir, "thorne-settings.json", map[string]interface{}{
+		"settings": ThorneSettings{Features: map[string]bool{"enable_thorne_express": true}},
+	})
+
+	order, err := service.CreateOrder("patient-1", "p1", 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-tracked; err != nil {
+		t.Fatalf("order was not saved as pending or tracking was blocked during submission: %v", err)
+	}
+
+	if order.FulfillmentRef != "TX-1" || order.Fulfillment != FulfillmentThorne {
+		t.Errorf("submission not recorded: ref %q, provider %q", order.FulfillmentRef, order.Fulfillment)
+	}
+	if order.TrackingNumber == nil || *order.TrackingNumber != "1Z999" {
+		t.Errorf("tracking update made during submission was lost: %v", order.TrackingNumber)
+	}
+	if order.Status == OrderStatusSubmitted {
+		t.Errorf("status was reset to %s after the tracking update", order.Status)
+	}
+}
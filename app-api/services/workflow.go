@@ -0,0 +1,772 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Post statuses. in_review and approved only occur on sites with the
+// editorial workflow enabled.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusInReview  = "in_review"
+	PostStatusApproved  = "approved"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+	PostStatusDeleted   = "deleted"
+)
+
+// Workflow roles besides the values of users.role: the post's own author,
+// and anyone at all
+const (
+	WorkflowRoleAuthor = "author"
+	WorkflowRoleAny    = "*"
+)
+
+// workflowRoleAdmin may review posts without being an assigned reviewer
+const workflowRoleAdmin = "admin"
+
+// errWorkflowStatus is returned when a status is set directly on a post
+// whose site routes status changes through workflow transitions
+const errWorkflowStatus = "status changes on this site go through the editorial workflow"
+
+// WorkflowTransition allows users holding one of Roles to move a post from
+// one status to another
+type WorkflowTransition struct {
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	Roles          []string `json:"roles"`
+	RequireComment bool     `json:"require_comment,omitempty"`
+}
+
+// Workflow is a site's editorial workflow configuration
+type Workflow struct {
+	SiteID      string               `json:"site_id"`
+	Enabled     bool                 `json:"enabled"`
+	Transitions []WorkflowTransition `json:"transitions"`
+	UpdatedAt   *time.Time           `json:"updated_at,omitempty"`
+}
+
+// WorkflowEvent is one status change in a post's history
+type WorkflowEvent struct {
+	ID        string    `json:"id"`
+	SiteID    string    `json:"site_id"`
+	PostID    string    `json:"post_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	ActorID   string    `json:"actor_id,omitempty"`
+	Actor     string    `json:"actor"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TransitionInput requests a status change with an optional comment
+type TransitionInput struct {
+	To      string `json:"to"`
+	Comment string `json:"comment"`
+}
+
+// PostReviewer is a user assigned to review a post
+type PostReviewer struct {
+	PostID     string    `json:"post_id"`
+	UserID     string    `json:"user_id"`
+	Username   string    `json:"username"`
+	AssignedBy string    `json:"assigned_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PostWorkflowState is where a post stands in its site's workflow.
+// Available lists the transitions the requesting user may make.
+type PostWorkflowState struct {
+	PostID    string               `json:"post_id"`
+	Status    string               `json:"status"`
+	Enabled   bool                 `json:"enabled"`
+	Reviewers []PostReviewer       `json:"reviewers"`
+	Available []WorkflowTransition `json:"available"`
+	History   []WorkflowEvent      `json:"history"`
+}
+
+// WorkflowService runs the per-site editorial workflow: which status
+// changes are allowed and by whom, who reviews a post, and the history of
+// approvals and rejections. Publishing on a workflow site always requires
+// the post to have been approved first.
+type WorkflowService struct {
+	db        *sql.DB
+	logger    Logger
+	pageCache *PageCache
+}
+
+// NewWorkflowService creates a new workflow service
+func NewWorkflowService(db *sql.DB, logger Logger) *WorkflowService {
+	return &WorkflowService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetPageCache sets the public page cache to purge when a post changes status
+func (s *WorkflowService) SetPageCache(cache *PageCache) {
+	s.pageCache = cache
+}
+
+// IsPostStatus reports whether status is a known post status
+func IsPostStatus(status string) bool {
+	switch status {
+	case PostStatusDraft, PostStatusInReview, PostStatusApproved, PostStatusPublished,
+		PostStatusArchived, PostStatusDeleted:
+		return true
+	}
+	return false
+}
+
+// DefaultWorkflowTransitions is the draft → in_review → approved →
+// published workflow used until a site configures its own
+func DefaultWorkflowTransitions() []WorkflowTransition {
+	return []WorkflowTransition{
+		{From: PostStatusDraft, To: PostStatusInReview, Roles: []string{WorkflowRoleAuthor, "editor", "admin"}},
+		{From: PostStatusInReview, To: PostStatusApproved, Roles: []string{"reviewer", "compliance", "admin"}},
+		{From: PostStatusInReview, To: PostStatusDraft, Roles: []string{"reviewer", "compliance", "admin"}, RequireComment: true},
+		{From: PostStatusApproved, To: PostStatusPublished, Roles: []string{"editor", "admin"}},
+		{From: PostStatusApproved, To: PostStatusDraft, Roles: []string{WorkflowRoleAuthor, "editor", "reviewer", "compliance", "admin"}},
+		{From: PostStatusPublished, To: PostStatusDraft, Roles: []string{"editor", "admin"}},
+		{From: PostStatusPublished, To: PostStatusArchived, Roles: []string{"editor", "admin"}},
+		{From: PostStatusArchived, To: PostStatusDraft, Roles: []string{"editor", "admin"}},
+	}
+}
+
+// GetWorkflow returns a site's workflow; sites that never configured one
+// get the default transitions, disabled
+func (s *WorkflowService) GetWorkflow(siteRef string) (*Workflow, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return loadWorkflow(s.db, siteID)
+}
+
+// UpdateWorkflow validates and stores a site's workflow. Omitting the
+// transitions keeps the defaults.
+func (s *WorkflowService) UpdateWorkflow(siteRef string, input Workflow) (*Workflow, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(input.Transitions) == 0 {
+		input.Transitions = DefaultWorkflowTransitions()
+	}
+	if err := validateTransitions(input.Transitions); err != nil {
+		return nil, err
+	}
+
+	encoded, _ := json.Marshal(input.Transitions)
+	_, err = s.db.Exec(`
+		INSERT INTO site_workflows (site_id, enabled, transitions, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (site_id) DO UPDATE SET enabled = EXCLUDED.enabled, transitions = EXCLUDED.transitions,
+		updated_at = EXCLUDED.updated_at`, siteID, input.Enabled, string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save workflow: %w", err)
+	}
+
+	s.logger.Info("workflow_service", "update_workflow", "Workflow updated", map[string]interface{}{
+		"site_id":     siteID,
+		"enabled":     input.Enabled,
+		"transitions": len(input.Transitions),
+	})
+
+	return loadWorkflow(s.db, siteID)
+}
+
+func validateTransitions(transitions []WorkflowTransition) error {
+	seen := map[string]bool{}
+	for i := range transitions {
+		transition := &transitions[i]
+		if !IsPostStatus(transition.From) || !IsPostStatus(transition.To) ||
+			transition.From == PostStatusDeleted || transition.To == PostStatusDeleted {
+			return fmt.Errorf("transition %d: from and to must be draft, in_review, approved, published or archived", i+1)
+		}
+		if transition.From == transition.To {
+			return fmt.Errorf("transition %d: from and to must differ", i+1)
+		}
+		if transition.To == PostStatusPublished && transition.From != PostStatusApproved {
+			return fmt.Errorf("transition %d: posts can only be published once approved", i+1)
+		}
+		if len(transition.Roles) == 0 {
+			return fmt.Errorf("transition %d: at least one role is required", i+1)
+		}
+		key := transition.From + ">" + transition.To
+		if seen[key] {
+			return fmt.Errorf("transition %d: %s to %s is listed twice", i+1, transition.From, transition.To)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func loadWorkflow(q queryRower, siteID string) (*Workflow, error) {
+	workflow := &Workflow{SiteID: siteID}
+	var transitions []byte
+	var updatedAt time.Time
+	err := q.QueryRow(`SELECT enabled, transitions, updated_at FROM site_workflows WHERE site_id = $1`,
+		siteID).Scan(&workflow.Enabled, &transitions, &updatedAt)
+	if err == sql.ErrNoRows {
+		workflow.Transitions = DefaultWorkflowTransitions()
+		return workflow, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow: %w", err)
+	}
+	if err := json.Unmarshal(transitions, &workflow.Transitions); err != nil {
+		return nil, fmt.Errorf("failed to decode workflow: %w", err)
+	}
+	workflow.UpdatedAt = &updatedAt
+	return workflow, nil
+}
+
+// CheckNewPostStatus rejects creating a post in any status but draft on a
+// workflow site. It reports whether the site's workflow is enabled.
+func CheckNewPostStatus(q queryRower, siteID, status string) (bool, error) {
+	workflow, err := loadWorkflow(q, siteID)
+	if err != nil {
+		return false, err
+	}
+	if workflow.Enabled && status != "" && status != PostStatusDraft {
+		return true, fmt.Errorf("new posts start as draft; %s", errWorkflowStatus)
+	}
+	return workflow.Enabled, nil
+}
+
+// CheckStatusChange resolves the status a direct post update may set and
+// reports whether the post's site has the workflow enabled. There an empty
+// status keeps the current one and any other change is refused; elsewhere
+// the requested status is returned unchanged.
+func CheckStatusChange(q queryRower, postID, status string) (string, bool, error) {
+	var siteID, current string
+	err := q.QueryRow(`SELECT site_id, COALESCE(status, '') FROM posts WHERE id::TEXT = $1 AND deleted_at IS NULL`,
+		postID).Scan(&siteID, &current)
+	if err == sql.ErrNoRows {
+		return status, false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to check post status: %w", err)
+	}
+
+	workflow, err := loadWorkflow(q, siteID)
+	if err != nil {
+		return "", false, err
+	}
+	if !workflow.Enabled {
+		return status, false, nil
+	}
+	if status == "" {
+		return current, true, nil
+	}
+	if status != current {
+		return "", true, fmt.Errorf("%s", errWorkflowStatus)
+	}
+	return status, true, nil
+}
+
+// WithdrawApprovalOnEdit is called inside the caller's transaction before
+// a post is overwritten. When an approved post keeps its approved status but
+// its title or content change, the approval no longer covers the text, so
+// the event is recorded and draft is returned as the status to write.
+// Otherwise status is returned unchanged.
+func WithdrawApprovalOnEdit(tx *sql.Tx, postID, editorID, status, title, content string) (string, error) {
+	var siteID, current, oldTitle, oldContent string
+	err := tx.QueryRow(`
+		SELECT site_id, COALESCE(status, ''), title, content FROM posts
+		WHERE id::TEXT = $1 AND deleted_at IS NULL`, postID).Scan(&siteID, &current, &oldTitle, &oldContent)
+	if err == sql.ErrNoRows {
+		return status, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check approval: %w", err)
+	}
+	if current != PostStatusApproved || status != PostStatusApproved || (title == oldTitle && content == oldContent) {
+		return status, nil
+	}
+
+	comment := "Approval withdrawn because the post was edited"
+	if _, err := recordWorkflowEvent(tx, siteID, postID, PostStatusApproved, PostStatusDraft, editorID, comment); err != nil {
+		return "", err
+	}
+	err = RecordAudit(tx, AuditEntry{
+		UserID:       editorID,
+		Action:       "post.workflow_transition",
+		ResourceType: "post",
+		ResourceID:   postID,
+		Details: map[string]interface{}{
+			"site_id": siteID,
+			"from":    PostStatusApproved,
+			"to":      PostStatusDraft,
+			"comment": comment,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return PostStatusDraft, nil
+}
+
+// State returns a post's workflow status, reviewers, history and the
+// transitions actorID may make
+func (s *WorkflowService) State(siteRef, postID, actorID string) (*PostWorkflowState, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	var status, authorID string
+	err = s.db.QueryRow(`
+		SELECT COALESCE(status, ''), user_id::TEXT FROM posts
+		WHERE id::TEXT = $1 AND site_id = $2 AND deleted_at IS NULL`, postID, siteID).Scan(&status, &authorID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("post not found")
+		}
+		return nil, fmt.Errorf("failed to load post: %w", err)
+	}
+
+	workflow, err := loadWorkflow(s.db, siteID)
+	if err != nil {
+		return nil, err
+	}
+	reviewers, err := s.listReviewers(siteID, postID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.history(siteID, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &PostWorkflowState{
+		PostID:    postID,
+		Status:    status,
+		Enabled:   workflow.Enabled,
+		Reviewers: reviewers,
+		Available: []WorkflowTransition{},
+		History:   history,
+	}
+	if workflow.Enabled && actorID != "" {
+		role, err := userRole(s.db, actorID)
+		if err != nil {
+			return nil, err
+		}
+		for _, transition := range workflow.Transitions {
+			if transition.From == status && transitionAllows(transition, role, actorID == authorID) {
+				state.Available = append(state.Available, transition)
+			}
+		}
+	}
+	return state, nil
+}
+
+// Transition moves a post along the workflow. The actor's role must be
+// allowed to make the transition, reviews are limited to assigned
+// reviewers when there are any, and authors cannot approve their own posts.
+// The change is written to the audit log and raises notification events.
+func (s *WorkflowService) Transition(siteRef, postID, actorID string, input TransitionInput) (*WorkflowEvent, error) {
+	if actorID == "" {
+		return nil, fmt.Errorf("user id is required")
+	}
+	input.Comment = strings.TrimSpace(input.Comment)
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current, authorID, title string
+	err = tx.QueryRow(`
+		SELECT COALESCE(status, ''), user_id::TEXT, title FROM posts
+		WHERE id::TEXT = $1 AND site_id = $2 AND deleted_at IS NULL
+		FOR UPDATE`, postID, siteID).Scan(&current, &authorID, &title)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("post not found")
+		}
+		return nil, fmt.Errorf("failed to lock post: %w", err)
+	}
+
+	workflow, err := loadWorkflow(tx, siteID)
+	if err != nil {
+		return nil, err
+	}
+	if !workflow.Enabled {
+		return nil, fmt.Errorf("the editorial workflow is not enabled for this site")
+	}
+
+	var transition *WorkflowTransition
+	for i := range workflow.Transitions {
+		if workflow.Transitions[i].From == current && workflow.Transitions[i].To == input.To {
+			transition = &workflow.Transitions[i]
+			break
+		}
+	}
+	if transition == nil {
+		return nil, fmt.Errorf("a %s post cannot move to %s", current, input.To)
+	}
+
+	role, err := userRole(tx, actorID)
+	if err != nil {
+		return nil, err
+	}
+	isAuthor := actorID == authorID
+	if !transitionAllows(*transition, role, isAuthor) {
+		return nil, fmt.Errorf("permission denied: moving a post from %s to %s needs one of the roles %s",
+			current, input.To, strings.Join(transition.Roles, ", "))
+	}
+	if input.To == PostStatusApproved && isAuthor {
+		return nil, fmt.Errorf("permission denied: authors cannot approve their own posts")
+	}
+	if current == PostStatusInReview && role != workflowRoleAdmin {
+		reviewers, err := reviewerIDs(tx, postID)
+		if err != nil {
+			return nil, err
+		}
+		if len(reviewers) > 0 && !containsString(reviewers, actorID) {
+			return nil, fmt.Errorf("permission denied: only assigned reviewers may review this post")
+		}
+	}
+	if transition.RequireComment && input.Comment == "" {
+		return nil, fmt.Errorf("a comment is required to move a post from %s to %s", current, input.To)
+	}
+
+	// Publishing through the workflow happens now, replacing any schedule
+	_, err = tx.Exec(`
+		UPDATE posts SET status = $1, published = ($1 = 'published'), updated_at = $2,
+		published_at = CASE WHEN $1 = 'published' THEN COALESCE(published_at, $2) ELSE published_at END,
+		publish_at = CASE WHEN $1 = 'published' THEN NULL ELSE publish_at END
+		WHERE id::TEXT = $3`, input.To, time.Now(), postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update post status: %w", err)
+	}
+
+	event, err := recordWorkflowEvent(tx, siteID, postID, current, input.To, actorID, input.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	err = RecordAudit(tx, AuditEntry{
+		UserID:       actorID,
+		Action:       "post.workflow_transition",
+		ResourceType: "post",
+		ResourceID:   postID,
+		Details: map[string]interface{}{
+			"site_id": siteID,
+			"from":    current,
+			"to":      input.To,
+			"comment": input.Comment,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.notifyTransition(tx, event, authorID, title); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transition: %w", err)
+	}
+
+	s.pageCache.PurgeSite(siteID)
+
+	s.logger.Info("workflow_service", "transition", "Post status changed", map[string]interface{}{
+		"site_id":  siteID,
+		"post_id":  postID,
+		"from":     current,
+		"to":       input.To,
+		"actor_id": actorID,
+	})
+
+	return event, nil
+}
+
+// notifyTransition raises the notification events for a status change:
+// review requests go to the assigned reviewers (or the whole site when none
+// are assigned) and outcomes go to the post's author
+func (s *WorkflowService) notifyTransition(tx *sql.Tx, event *WorkflowEvent, authorID, title string) error {
+	base := Notification{
+		SiteID:       event.SiteID,
+		ActorID:      event.ActorID,
+		ResourceType: "post",
+		ResourceID:   event.PostID,
+		Payload: map[string]interface{}{
+			"title":   title,
+			"from":    event.From,
+			"to":      event.To,
+			"comment": event.Comment,
+		},
+	}
+
+	var recipients []string
+	switch {
+	case event.To == PostStatusInReview:
+		base.Type = "post.review_requested"
+		reviewers, err := reviewerIDs(tx, event.PostID)
+		if err != nil {
+			return err
+		}
+		recipients = reviewers
+		if len(recipients) == 0 {
+			recipients = []string{""}
+		}
+	case event.To == PostStatusApproved:
+		base.Type = "post.approved"
+		recipients = []string{authorID}
+	case event.From == PostStatusInReview && event.To == PostStatusDraft:
+		base.Type = "post.rejected"
+		recipients = []string{authorID}
+	case event.To == PostStatusPublished:
+		base.Type = "post.published"
+		recipients = []string{authorID}
+	default:
+		base.Type = "post.status_changed"
+		recipients = []string{authorID}
+	}
+
+	for _, recipient := range recipients {
+		if recipient != "" && recipient == event.ActorID {
+			continue
+		}
+		notification := base
+		notification.RecipientID = recipient
+		if err := RaiseNotification(tx, notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History returns a post's workflow events oldest first
+func (s *WorkflowService) History(siteRef, postID string) ([]WorkflowEvent, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.history(siteID, postID)
+}
+
+func (s *WorkflowService) history(siteID, postID string) ([]WorkflowEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT e.id, e.site_id, e.post_id::TEXT, e.from_status, e.to_status, COALESCE(e.actor_id::TEXT, ''),
+		COALESCE(u.username, 'Anonymous'), COALESCE(e.comment, ''), e.created_at
+		FROM post_workflow_events e
+		LEFT JOIN users u ON e.actor_id = u.id
+		WHERE e.site_id = $1 AND e.post_id::TEXT = $2
+		ORDER BY e.created_at`, siteID, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow history: %w", err)
+	}
+	defer rows.Close()
+
+	events := []WorkflowEvent{}
+	for rows.Next() {
+		var event WorkflowEvent
+		err := rows.Scan(&event.ID, &event.SiteID, &event.PostID, &event.From, &event.To, &event.ActorID,
+			&event.Actor, &event.Comment, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan workflow event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// ListReviewers returns the users assigned to review a post
+func (s *WorkflowService) ListReviewers(siteRef, postID string) ([]PostReviewer, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	return s.listReviewers(siteID, postID)
+}
+
+func (s *WorkflowService) listReviewers(siteID, postID string) ([]PostReviewer, error) {
+	rows, err := s.db.Query(`
+		SELECT r.post_id::TEXT, r.user_id::TEXT, u.username, COALESCE(r.assigned_by::TEXT, ''), r.created_at
+		FROM post_reviewers r
+		JOIN posts p ON p.id = r.post_id
+		JOIN users u ON u.id = r.user_id
+		WHERE p.site_id = $1 AND r.post_id::TEXT = $2
+		ORDER BY r.created_at`, siteID, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviewers: %w", err)
+	}
+	defer rows.Close()
+
+	reviewers := []PostReviewer{}
+	for rows.Next() {
+		var reviewer PostReviewer
+		if err := rows.Scan(&reviewer.PostID, &reviewer.UserID, &reviewer.Username, &reviewer.AssignedBy,
+			&reviewer.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reviewer: %w", err)
+		}
+		reviewers = append(reviewers, reviewer)
+	}
+	return reviewers, rows.Err()
+}
+
+// AssignReviewer adds a reviewer to a post and notifies them
+func (s *WorkflowService) AssignReviewer(siteRef, postID, reviewerID, actorID string) ([]PostReviewer, error) {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var title string
+	err = tx.QueryRow(`SELECT title FROM posts WHERE id::TEXT = $1 AND site_id = $2 AND deleted_at IS NULL`,
+		postID, siteID).Scan(&title)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("post not found")
+		}
+		return nil, fmt.Errorf("failed to load post: %w", err)
+	}
+	if _, err := userRole(tx, reviewerID); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO post_reviewers (post_id, user_id, assigned_by)
+		SELECT p.id, $2, $3 FROM posts p WHERE p.id::TEXT = $1
+		ON CONFLICT (post_id, user_id) DO NOTHING`, postID, userIDParam(reviewerID), userIDParam(actorID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign reviewer: %w", err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		err = RecordAudit(tx, AuditEntry{
+			UserID:       actorID,
+			Action:       "post.reviewer_assigned",
+			ResourceType: "post",
+			ResourceID:   postID,
+			Details:      map[string]interface{}{"site_id": siteID, "reviewer_id": reviewerID},
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = RaiseNotification(tx, Notification{
+			SiteID:       siteID,
+			Type:         "post.reviewer_assigned",
+			RecipientID:  reviewerID,
+			ActorID:      actorID,
+			ResourceType: "post",
+			ResourceID:   postID,
+			Payload:      map[string]interface{}{"title": title},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reviewer assignment: %w", err)
+	}
+	return s.listReviewers(siteID, postID)
+}
+
+// UnassignReviewer removes a reviewer from a post
+func (s *WorkflowService) UnassignReviewer(siteRef, postID, reviewerID, actorID string) error {
+	siteID, err := resolveSiteID(s.db, siteRef)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(`
+		DELETE FROM post_reviewers
+		WHERE post_id::TEXT = $1 AND user_id::TEXT = $2
+		AND post_id IN (SELECT id FROM posts WHERE site_id = $3)`, postID, reviewerID, siteID)
+	if err != nil {
+		return fmt.Errorf("failed to unassign reviewer: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("reviewer not found")
+	}
+
+	if err := RecordAudit(s.db, AuditEntry{
+		UserID:       actorID,
+		Action:       "post.reviewer_unassigned",
+		ResourceType: "post",
+		ResourceID:   postID,
+		Details:      map[string]interface{}{"site_id": siteID, "reviewer_id": reviewerID},
+	}); err != nil {
+		s.logger.Error("workflow_service", "unassign_reviewer", "Failed to write audit log", map[string]interface{}{
+			"post_id": postID,
+			"error":   err.Error(),
+		})
+	}
+	return nil
+}
+
+func recordWorkflowEvent(tx *sql.Tx, siteID, postID, from, to, actorID, comment string) (*WorkflowEvent, error) {
+	event := &WorkflowEvent{SiteID: siteID, PostID: postID, From: from, To: to, ActorID: actorID, Comment: comment}
+	err := tx.QueryRow(`
+		INSERT INTO post_workflow_events (site_id, post_id, from_status, to_status, actor_id, comment)
+		SELECT $1, p.id, $3, $4, $5, $6 FROM posts p WHERE p.id::TEXT = $2
+		RETURNING id, created_at`, siteID, postID, from, to, userIDParam(actorID), nullableString(comment)).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record workflow event: %w", err)
+	}
+	return event, nil
+}
+
+// transitionAllows reports whether a user with role (or the post's author)
+// may make the transition
+func transitionAllows(transition WorkflowTransition, role string, isAuthor bool) bool {
+	for _, allowed := range transition.Roles {
+		if allowed == WorkflowRoleAny || (allowed == WorkflowRoleAuthor && isAuthor) || (role != "" && allowed == role) {
+			return true
+		}
+	}
+	return false
+}
+
+func userRole(q queryRower, userID string) (string, error) {
+	var role string
+	err := q.QueryRow(`SELECT COALESCE(role, '') FROM users WHERE id::TEXT = $1 AND deleted_at IS NULL`,
+		userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("user not found")
+		}
+		return "", fmt.Errorf("failed to load user: %w", err)
+	}
+	return role, nil
+}
+
+func reviewerIDs(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, postID string) ([]string, error) {
+	rows, err := q.Query(`SELECT user_id::TEXT FROM post_reviewers WHERE post_id::TEXT = $1`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviewers: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan reviewer: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}